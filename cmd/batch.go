@@ -7,6 +7,9 @@ import (
 	"strings"
 
 	"github.com/adamstac/7zarch-go/internal/batch"
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/query"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
@@ -25,20 +28,34 @@ Operations:
 Selection methods:
   --query=<name>     Use saved query to select archives
   --stdin            Read archive UIDs from stdin (one per line)
+  --tag=<tag>        Select archives carrying the tag (narrows other methods)
+  --filter=<expr>    Select archives matching a filter expression
   [filters...]       Use filter flags to select archives
 
+Filters narrow --query and --stdin selections; on their own they select
+every matching archive.
+
 Examples:
   # Move archives using saved query
   7zarch-go batch move --query=old-files --to=/archive/old/
 
   # Delete archives from stdin
-  7zarch-go list --older-than=1y --output=json | jq -r '.[].uid' | 7zarch-go batch delete --stdin --confirm
+  7zarch-go --format json list --older-than=1y | jq -r '.data.archives[].uid' | 7zarch-go batch delete --stdin --confirm
 
   # Move with filters
   7zarch-go batch move --profile=documents --larger-than=100MB --to=/backup/docs/
 
   # Batch delete with confirmation
-  7zarch-go batch delete --query=temp-files --confirm`,
+  7zarch-go batch delete --query=temp-files --confirm
+
+  # Delete large archives that have already been uploaded
+  7zarch-go batch delete --filter "uploaded and size > 1GB and age > 90d" --confirm
+
+  # Move everything for one client
+  7zarch-go batch move --tag=client:acme --to=/archive/acme/
+
+  # Queue a large move for the background worker
+  7zarch-go batch move --profile=media --to=/mnt/nas/media/ --background`,
 		Args:              cobra.ExactArgs(1),
 		ValidArgsFunction: batchOperationCompletion,
 		RunE:              runBatch,
@@ -47,22 +64,25 @@ Examples:
 	// Selection flags
 	cmd.Flags().String("query", "", "Use saved query to select archives")
 	cmd.Flags().Bool("stdin", false, "Read archive UIDs from stdin")
-	cmd.Flags().Bool("all", false, "Process all archives (REQUIRED if no query/stdin specified)")
+	cmd.Flags().Bool("all", false, "Process all archives (REQUIRED if no query/stdin/filter specified)")
+	cmd.Flags().StringSlice("tag", nil, "Only archives carrying this tag (repeatable; all must match)")
 
 	// Operation-specific flags
 	cmd.Flags().String("to", "", "Destination path for move operation")
 	cmd.Flags().Bool("confirm", false, "Confirm destructive operations")
 	cmd.Flags().Bool("dry-run", false, "Show what would be done without executing")
+	addBackgroundFlag(cmd)
 
 	// Progress and output flags
 	cmd.Flags().Bool("progress", true, "Show progress during batch operations")
 	cmd.Flags().Int("concurrent", 4, "Number of concurrent operations")
 	cmd.Flags().String("output", "table", "Output format: table, json, csv, yaml")
+	deprecateOutputFlag(cmd)
 
 	// Include all list filter flags for direct filtering
 	addListFilterFlags(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runBatch(cmd *cobra.Command, args []string) error {
@@ -86,6 +106,10 @@ func runBatch(cmd *cobra.Command, args []string) error {
 	useStdin, _ := cmd.Flags().GetBool("stdin")
 	useAll, _ := cmd.Flags().GetBool("all")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	f, err := batchFilter(cmd)
+	if err != nil {
+		return err
+	}
 
 	// Validate selection method - exactly one must be specified
 	selectionCount := 0
@@ -99,18 +123,38 @@ func runBatch(cmd *cobra.Command, args []string) error {
 		selectionCount++
 	}
 
+	// Filters on their own select every matching archive
+	if selectionCount == 0 && !f.Empty() {
+		useAll = true
+		selectionCount++
+	}
+
 	if selectionCount == 0 {
-		return fmt.Errorf("must specify exactly one selection method: --query, --stdin, or --all")
+		return fmt.Errorf("must specify exactly one selection method: --query, --stdin, --all, or filters")
 	}
 	if selectionCount > 1 {
 		return fmt.Errorf("cannot combine selection methods: use only one of --query, --stdin, or --all")
 	}
 
+	if getBool(cmd, "background") {
+		if useStdin {
+			return fmt.Errorf("--background cannot read archives from --stdin; use --query or filters")
+		}
+		if operation == "move" && getString(cmd, "to") == "" {
+			return fmt.Errorf("move operation requires --to flag")
+		}
+		if operation == "delete" && !getBool(cmd, "confirm") {
+			return fmt.Errorf("delete operation requires --confirm flag for safety")
+		}
+		return queueJob(cmd, args)
+	}
+
 	// Initialize storage
-	manager, err := storage.NewManager("")
+	_, manager, cleanup, err := cmdutil.InitStorageManager()
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return err
 	}
+	defer cleanup()
 
 	var archives []*storage.Archive
 
@@ -129,8 +173,8 @@ func runBatch(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to read archives from stdin: %w", err)
 		}
 	} else if useAll {
-		// Use all archives (explicitly requested with --all flag)
-		archives, err = manager.Registry().List()
+		// Use all archives matching the filters, if any
+		archives, err = f.Select(manager.Registry())
 		if err != nil {
 			return fmt.Errorf("failed to list archives: %w", err)
 		}
@@ -139,13 +183,23 @@ func runBatch(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("internal error: no valid selection method")
 	}
 
+	if !useAll {
+		archives = f.Apply(archives)
+	}
+
+	p := printerFor(cmd)
+	out := p.Text()
+	result := &batchResult{Operation: operation, Archives: archives, To: getString(cmd, "to"), DryRun: dryRun}
+	if result.Archives == nil {
+		result.Archives = []*storage.Archive{}
+	}
 	if len(archives) == 0 {
-		fmt.Println("No archives selected for batch operation")
-		return nil
+		fmt.Fprintln(out, "No archives selected for batch operation")
+		return p.Result(result)
 	}
 
 	// Show what will be processed
-	fmt.Printf("Selected %d archive(s) for %s operation:\n", len(archives), operation)
+	fmt.Fprintf(out, "Selected %d archive(s) for %s operation:\n", len(archives), operation)
 	for i, archive := range archives {
 		if i < 10 { // Show first 10
 			// Safe UID prefix extraction with bounds checking
@@ -153,17 +207,17 @@ func runBatch(cmd *cobra.Command, args []string) error {
 			if len(archive.UID) > 12 {
 				uidDisplay = archive.UID[:12]
 			}
-			fmt.Printf("  - %s (%s)\n", archive.Name, uidDisplay)
+			fmt.Fprintf(out, "  - %s (%s)\n", archive.Name, uidDisplay)
 		} else if i == 10 {
-			fmt.Printf("  ... and %d more\n", len(archives)-10)
+			fmt.Fprintf(out, "  ... and %d more\n", len(archives)-10)
 			break
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(out)
 
 	if dryRun {
-		fmt.Println("Dry run - no operations performed")
-		return nil
+		fmt.Fprintln(out, "Dry run - no operations performed")
+		return p.Result(result)
 	}
 
 	// Validate operation-specific requirements
@@ -173,18 +227,34 @@ func runBatch(cmd *cobra.Command, args []string) error {
 		if to == "" {
 			return fmt.Errorf("move operation requires --to flag")
 		}
-		return performMove(cmd, archives, to, manager)
+		return performMove(cmd, p, result, manager)
 	case "delete":
 		confirm, _ := cmd.Flags().GetBool("confirm")
 		if !confirm {
 			return fmt.Errorf("delete operation requires --confirm flag for safety")
 		}
-		return performDelete(cmd, archives, manager)
+		return performDelete(cmd, p, result, manager)
 	}
 
 	return nil
 }
 
+// batchResult is the structured result of a batch operation
+type batchResult struct {
+	Operation string             `json:"operation"`
+	Archives  []*storage.Archive `json:"archives"`
+	To        string             `json:"to,omitempty"`
+	DryRun    bool               `json:"dry_run,omitempty"`
+	Errors    []string           `json:"errors,omitempty"`
+}
+
+// batchProgress is streamed as a "progress" event in NDJSON output
+type batchProgress struct {
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Current   string `json:"current"`
+}
+
 func readArchivesFromStdin(registry *storage.Registry) ([]*storage.Archive, error) {
 	var uids []string
 	scanner := bufio.NewScanner(os.Stdin)
@@ -200,9 +270,10 @@ func readArchivesFromStdin(registry *storage.Registry) ([]*storage.Archive, erro
 		return nil, fmt.Errorf("error reading stdin: %w", err)
 	}
 
+	resolver := storage.NewResolver(registry)
 	var archives []*storage.Archive
 	for _, uid := range uids {
-		archive, err := registry.Get(uid)
+		archive, err := resolver.Resolve(uid)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get archive %s: %w", uid, err)
 		}
@@ -212,77 +283,70 @@ func readArchivesFromStdin(registry *storage.Registry) ([]*storage.Archive, erro
 	return archives, nil
 }
 
-func performMove(cmd *cobra.Command, archives []*storage.Archive, destPath string, manager *storage.Manager) error {
-	concurrent, _ := cmd.Flags().GetInt("concurrent")
-	showProgress, _ := cmd.Flags().GetBool("progress")
-
-	processor := batch.NewProcessor(manager)
-	processor.SetConcurrency(concurrent)
+func performMove(cmd *cobra.Command, p *output.Printer, result *batchResult, manager *storage.Manager) error {
+	processor := newBatchProcessor(cmd, manager)
+	err := processor.Move(cmd.Context(), result.Archives, result.To, batchProgressCallback(cmd, p, result))
+	if err != nil {
+		return p.Failure(result, fmt.Errorf("batch move failed: %w", err))
+	}
 
-	var progressCallback batch.ProgressCallback
-	if showProgress {
-		progressCallback = func(update batch.ProgressUpdate) {
-			percent := float64(update.Completed) / float64(update.Total) * 100
-			fmt.Printf("\rProgress: %d/%d (%.1f%%) - %s", 
-				update.Completed, update.Total, percent, update.Current)
-			if update.Completed == update.Total {
-				fmt.Printf(" - Completed in %v\n", update.Elapsed)
-			}
-		}
+	if getBool(cmd, "progress") {
+		fmt.Fprintf(p.Text(), "Successfully moved %d archives to %s\n", len(result.Archives), result.To)
 	}
+	return p.Result(result)
+}
 
-	ctx := cmd.Context()
-	err := processor.Move(ctx, archives, destPath, progressCallback)
+func performDelete(cmd *cobra.Command, p *output.Printer, result *batchResult, manager *storage.Manager) error {
+	processor := newBatchProcessor(cmd, manager)
+	err := processor.Delete(cmd.Context(), result.Archives, batchProgressCallback(cmd, p, result))
 	if err != nil {
-		return fmt.Errorf("batch move failed: %w", err)
+		return p.Failure(result, fmt.Errorf("batch delete failed: %w", err))
 	}
 
-	if showProgress {
-		fmt.Printf("Successfully moved %d archives to %s\n", len(archives), destPath)
+	if getBool(cmd, "progress") && len(result.Archives) > 0 {
+		fmt.Fprintf(p.Text(), "Successfully deleted %d archives\n", len(result.Archives))
 	}
-
-	return nil
+	return p.Result(result)
 }
 
-func performDelete(cmd *cobra.Command, archives []*storage.Archive, manager *storage.Manager) error {
+func newBatchProcessor(cmd *cobra.Command, manager *storage.Manager) *batch.Processor {
 	concurrent, _ := cmd.Flags().GetInt("concurrent")
-	showProgress, _ := cmd.Flags().GetBool("progress")
-
 	processor := batch.NewProcessor(manager)
 	processor.SetConcurrency(concurrent)
+	return processor
+}
 
-	var progressCallback batch.ProgressCallback
-	if showProgress {
-		progressCallback = func(update batch.ProgressUpdate) {
-			percent := float64(update.Completed) / float64(update.Total) * 100
-			fmt.Printf("\rProgress: %d/%d (%.1f%%) - %s", 
-				update.Completed, update.Total, percent, update.Current)
-			if update.Completed == update.Total {
-				elapsed := update.Elapsed
-				if len(update.Errors) > 0 {
-					fmt.Printf(" - Completed with %d errors in %v\n", len(update.Errors), elapsed)
-					fmt.Println("\nErrors:")
-					for _, err := range update.Errors {
-						fmt.Printf("  - %v\n", err)
-					}
-				} else {
-					fmt.Printf(" - Completed in %v\n", elapsed)
+// batchProgressCallback shows progress with --progress, streams it as
+// events in NDJSON and records the errors in result
+func batchProgressCallback(cmd *cobra.Command, p *output.Printer, result *batchResult) batch.ProgressCallback {
+	showProgress := getBool(cmd, "progress")
+	out := p.Text()
+	return func(update batch.ProgressUpdate) {
+		_ = p.Event("progress", batchProgress{Completed: update.Completed, Total: update.Total, Current: update.Current})
+		if update.Completed == update.Total {
+			result.Errors = result.Errors[:0]
+			for _, err := range update.Errors {
+				result.Errors = append(result.Errors, err.Error())
+			}
+		}
+		if !showProgress {
+			return
+		}
+		percent := float64(update.Completed) / float64(update.Total) * 100
+		fmt.Fprintf(out, "\rProgress: %d/%d (%.1f%%) - %s",
+			update.Completed, update.Total, percent, update.Current)
+		if update.Completed == update.Total {
+			if len(update.Errors) > 0 {
+				fmt.Fprintf(out, " - Completed with %d errors in %v\n", len(update.Errors), update.Elapsed)
+				fmt.Fprintln(out, "\nErrors:")
+				for _, err := range update.Errors {
+					fmt.Fprintf(out, "  - %v\n", err)
 				}
+			} else {
+				fmt.Fprintf(out, " - Completed in %v\n", update.Elapsed)
 			}
 		}
 	}
-
-	ctx := cmd.Context()
-	err := processor.Delete(ctx, archives, progressCallback)
-	if err != nil {
-		return fmt.Errorf("batch delete failed: %w", err)
-	}
-
-	if showProgress && len(archives) > 0 {
-		fmt.Printf("Successfully deleted %d archives\n", len(archives))
-	}
-
-	return nil
 }
 
 func batchOperationCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -294,12 +358,34 @@ func batchOperationCompletion(cmd *cobra.Command, args []string, toComplete stri
 
 
 func addListFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("filter", "", "Filter expression, e.g. 'size > 1GB and profile = media and not uploaded'")
 	cmd.Flags().String("profile", "", "Filter by compression profile")
 	cmd.Flags().Bool("managed", false, "Show only managed archives")
-	cmd.Flags().String("status", "", "Filter by status (ok, missing, error)")
+	cmd.Flags().String("status", "", "Filter by status (present, missing, deleted)")
 	cmd.Flags().String("pattern", "", "Filter by name pattern (glob)")
 	cmd.Flags().String("larger-than", "", "Filter by minimum size (e.g., 100MB, 1GB)")
 	cmd.Flags().String("smaller-than", "", "Filter by maximum size")
 	cmd.Flags().String("older-than", "", "Filter by age (e.g., 30d, 1y)")
 	cmd.Flags().String("newer-than", "", "Filter by recency")
-}
\ No newline at end of file
+}
+
+// batchFilter compiles the tag, filter-flag and --filter options into one filter
+func batchFilter(cmd *cobra.Command) (*filter.Filter, error) {
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	flags := filter.Flags{
+		Profile:     getString(cmd, "profile"),
+		Managed:     getBool(cmd, "managed"),
+		Status:      getString(cmd, "status"),
+		Pattern:     getString(cmd, "pattern"),
+		LargerThan:  getString(cmd, "larger-than"),
+		SmallerThan: getString(cmd, "smaller-than"),
+		OlderThan:   getString(cmd, "older-than"),
+		NewerThan:   getString(cmd, "newer-than"),
+		Tags:        tags,
+	}
+	expr, err := flags.Expression()
+	if err != nil {
+		return nil, err
+	}
+	return filter.Parse(filter.And(expr, getString(cmd, "filter")))
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestBatchFilterFlags(t *testing.T) {
+	cmd := BatchCmd()
+	_ = cmd.Flags().Set("smaller-than", "1MB")
+	_ = cmd.Flags().Set("newer-than", "7d")
+	_ = cmd.Flags().Set("filter", "profile = media")
+
+	f, err := batchFilter(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.String(), "age < 7d and size < 1MB and profile = media"; got != want {
+		t.Fatalf("expression = %q, want %q", got, want)
+	}
+
+	archives := []*storage.Archive{
+		{Name: "small", Size: 10, Profile: "media", Created: time.Now()},
+		{Name: "big", Size: 2 << 20, Profile: "media", Created: time.Now()},
+		{Name: "old", Size: 10, Profile: "media", Created: time.Now().AddDate(0, 0, -30)},
+	}
+	matched := f.Apply(archives)
+	if len(matched) != 1 || matched[0].Name != "small" {
+		t.Fatalf("expected only the small recent archive, got %d", len(matched))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -41,7 +42,7 @@ func configShowCmd() *cobra.Command {
 		RunE:  runConfigShow,
 	}
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runConfigInit(cmd *cobra.Command, args []string) error {
@@ -52,8 +53,8 @@ func runConfigInit(cmd *cobra.Command, args []string) error {
 
 	// Check if config already exists
 	if _, err := os.Stat(configPath); err == nil {
-		fmt.Printf("Config file already exists: %s\n", configPath)
-		fmt.Printf("Remove it first if you want to recreate it.\n")
+		fmt.Fprintf(cmd.OutOrStdout(), "Config file already exists: %s\n", configPath)
+		fmt.Fprintf(cmd.OutOrStdout(), "Remove it first if you want to recreate it.\n")
 		return nil
 	}
 
@@ -154,9 +155,9 @@ presets:
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
-	fmt.Printf("✅ Created config file: %s\n", configPath)
-	fmt.Printf("\nEdit this file to customize your 7zarch-go defaults and presets.\n")
-	fmt.Printf("Run '7zarch-go config show' to see your current settings.\n")
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Created config file: %s\n", configPath)
+	fmt.Fprintf(cmd.OutOrStdout(), "\nEdit this file to customize your 7zarch-go defaults and presets.\n")
+	fmt.Fprintf(cmd.OutOrStdout(), "Run '7zarch-go config show' to see your current settings.\n")
 
 	return nil
 }
@@ -168,7 +169,6 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	}
 
 	configPath, _ := config.ConfigPath()
-	fmt.Printf("Configuration loaded from: %s\n\n", configPath)
 
 	// Convert to YAML and display
 	data, err := yaml.Marshal(cfg)
@@ -176,9 +176,23 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	fmt.Printf("Current Configuration:\n")
-	fmt.Printf("=====================\n")
-	fmt.Print(string(data))
+	if p := printerFor(cmd); p.Structured() {
+		// Round-trip through YAML so keys match the config file
+		var settings map[string]any
+		if err := yaml.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		return p.Result(struct {
+			Path   string         `json:"path"`
+			Config map[string]any `json:"config"`
+		}{configPath, settings})
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Configuration loaded from: %s\n\n", configPath)
+	fmt.Fprintf(out, "Current Configuration:\n")
+	fmt.Fprintf(out, "=====================\n")
+	fmt.Fprint(out, string(data))
 
 	return nil
 }
@@ -9,7 +9,11 @@ import (
 	"time"
 
 	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/display"
+	"github.com/adamstac/7zarch-go/internal/hooks"
+	"github.com/adamstac/7zarch-go/internal/output"
 	errs "github.com/adamstac/7zarch-go/internal/errors"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/schollz/progressbar/v3"
@@ -29,6 +33,7 @@ var (
 	profileName      string
 	presetName       string
 	noManaged        bool
+	createTimeout    time.Duration
 )
 
 func CreateCmd() *cobra.Command {
@@ -57,7 +62,10 @@ Archives are stored in managed storage by default for easy tracking.`,
   7zarch-go create -o /backup/archive.7z ~/data
 
   # Dry run to preview without creating
-  7zarch-go create --dry-run ~/test-folder`,
+  7zarch-go create --dry-run ~/test-folder
+
+  # Queue a large archive for the background worker
+  7zarch-go create --background --timeout 0 ~/Videos`,
 		Args:  cobra.ExactArgs(1),
 		RunE:  runCreate,
 	}
@@ -75,17 +83,21 @@ Archives are stored in managed storage by default for easy tracking.`,
 	cmd.Flags().StringVar(&profileName, "profile", "", "Compression profile (media, documents, balanced)")
 	cmd.Flags().StringVar(&presetName, "preset", "", "Use predefined settings preset")
 	cmd.Flags().BoolVar(&noManaged, "no-managed", false, "Don't use managed storage (use current directory)")
+	cmd.Flags().DurationVar(&createTimeout, "timeout", 30*time.Minute, "Give up if compression takes longer than this (0 = no limit)")
+	addBackgroundFlag(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
 	sourcePath := args[0]
+	p := printerFor(cmd)
+	out := p.Text()
 
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		fmt.Printf("⚠️  Config loading failed, using defaults: %v\n", err)
+		fmt.Fprintf(out, "⚠️  Config loading failed, using defaults: %v\n", err)
 		cfg = config.DefaultConfig()
 	}
 
@@ -127,7 +139,7 @@ func runCreate(cmd *cobra.Command, args []string) error {
 			threads = preset.Threads
 		}
 
-		fmt.Printf("📋 Using preset: %s\n", presetName)
+		fmt.Fprintf(out, "📋 Using preset: %s\n", presetName)
 	}
 
 	// Apply config defaults (CLI flags and presets override config)
@@ -164,6 +176,10 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if getBool(cmd, "background") {
+		return queueJob(cmd, args)
+	}
+
 	// Initialize storage manager if using managed storage
 	var storageManager *storage.Manager
 	var useManaged bool
@@ -177,6 +193,9 @@ func runCreate(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to initialize managed storage: %w", err)
 		}
 		defer storageManager.Close()
+		if err := cmdutil.ConfigureManager(storageManager, cfg); err != nil {
+			return err
+		}
 	}
 
 	// If we are not using managed storage, initialize the registry if configured to register external outputs
@@ -201,8 +220,8 @@ func runCreate(cmd *cobra.Command, args []string) error {
 			archiveName = filepath.Join(outputPath, baseName)
 		}
 	} else if useManaged {
-		// Use managed storage
-		archiveName = storageManager.GetManagedPath(baseName)
+		// Use managed storage; by_type layouts are finalised once the profile is known
+		archiveName = storageManager.ManagedPathFor(baseName, profileName, time.Now())
 	} else {
 		// Default to current directory
 		archiveName = baseName
@@ -215,46 +234,87 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		createChecksums = true
 	}
 
+	// Repeated backups of the same source in managed storage become new
+	// versions with a timestamp suffix instead of colliding
+	if _, err := os.Stat(archiveName); err == nil && useManaged && !forceOverwrite {
+		archiveName = filepath.Join(filepath.Dir(archiveName), storage.VersionedName(baseName, time.Now()))
+		fmt.Fprintf(out, "ℹ️  %s already exists; creating a new version\n", baseName)
+	}
+
 	// Check if archive already exists
 	if _, err := os.Stat(archiveName); err == nil && !forceOverwrite {
 		// File exists and force not specified
-		fmt.Printf("❌ Archive already exists: %s\n", archiveName)
-		fmt.Printf("\nOptions:\n")
-		fmt.Printf("  • Use --force to overwrite\n")
-		fmt.Printf("  • Use a different --output path\n")
-		fmt.Printf("  • Delete the existing file first\n")
+		fmt.Fprintf(out, "❌ Archive already exists: %s\n", archiveName)
+		fmt.Fprintf(out, "\nOptions:\n")
+		fmt.Fprintf(out, "  • Use --force to overwrite\n")
+		fmt.Fprintf(out, "  • Use a different --output path\n")
+		fmt.Fprintf(out, "  • Delete the existing file first\n")
 		return fmt.Errorf("archive already exists (use --force to overwrite)")
 	}
 
+	// Check quota and disk space before 7z runs rather than failing midway
+	estimate, err := estimateArchiveSize(absPath, profileName, cfg, storageManager)
+	if err != nil {
+		fmt.Fprintf(out, "⚠️  Warning: could not estimate archive size, skipping space checks: %v\n", err)
+	} else if err := checkCreateCapacity(archiveName, estimate, useManaged, cfg, storageManager); err != nil {
+		return err
+	}
+
+	planned := &storage.Archive{
+		Name:       filepath.Base(archiveName),
+		Path:       archiveName,
+		Profile:    profileName,
+		Managed:    useManaged,
+		SourcePath: absPath,
+	}
 	if dryRun {
-		fmt.Printf("DRY RUN MODE - No files will be created\n\n")
-		fmt.Printf("Would create archive: %s\n", archiveName)
-		fmt.Printf("Source: %s\n", absPath)
-		fmt.Printf("Compression level: %d\n", compressionLevel)
+		fmt.Fprintf(out, "DRY RUN MODE - No files will be created\n\n")
+		fmt.Fprintf(out, "Would create archive: %s\n", archiveName)
+		fmt.Fprintf(out, "Source: %s\n", absPath)
+		fmt.Fprintf(out, "Compression level: %d\n", compressionLevel)
+		if estimate > 0 {
+			fmt.Fprintf(out, "Estimated size: %s\n", display.FormatSize(estimate))
+		}
 		if threads > 0 {
-			fmt.Printf("Threads: %d\n", threads)
+			fmt.Fprintf(out, "Threads: %d\n", threads)
 		} else {
-			fmt.Printf("Threads: auto\n")
+			fmt.Fprintf(out, "Threads: auto\n")
 		}
 		if createLog {
-			fmt.Printf("Would create log: %s.log\n", archiveName)
+			fmt.Fprintf(out, "Would create log: %s.log\n", archiveName)
 		}
 		if createChecksums {
-			fmt.Printf("Would create checksum: %s.sha256\n", archiveName)
+			fmt.Fprintf(out, "Would create checksum: %s.sha256\n", archiveName)
+		}
+		return p.Result(createResult{Archive: planned, EstimatedSize: estimate, DryRun: true})
+	}
+
+	// A failing pre_create hook vetoes the archive
+	runner, err := cmdutil.Hooks(cfg)
+	if err != nil {
+		return err
+	}
+	if err := runner.Run(context.Background(), hooks.PreCreate, planned, map[string]string{"source": absPath}); err != nil {
+		return fmt.Errorf("pre_create hook failed: %w", err)
+	}
+
+	if useManaged {
+		// #nosec G301: restrict permissions on managed storage directories
+		if err := os.MkdirAll(filepath.Dir(archiveName), 0750); err != nil {
+			return fmt.Errorf("failed to prepare managed storage: %w", err)
 		}
-		return nil
 	}
 
 	// Show meaningful start message (after profile is determined)
-	fmt.Printf("Creating archive: %s\n", filepath.Base(archiveName))
-	fmt.Printf("Source: %s\n", absPath)
+	fmt.Fprintf(out, "Creating archive: %s\n", filepath.Base(archiveName))
+	fmt.Fprintf(out, "Source: %s\n", absPath)
 	// Note: Compression level will be shown after profile determination
 	if threads > 0 {
-		fmt.Printf("Threads: %d\n", threads)
+		fmt.Fprintf(out, "Threads: %d\n", threads)
 	} else {
-		fmt.Printf("Threads: auto\n")
+		fmt.Fprintf(out, "Threads: auto\n")
 	}
-	fmt.Printf("\n")
+	fmt.Fprintf(out, "\n")
 
 	// Create a spinner that shows we're working
 	bar := progressbar.NewOptions(-1,
@@ -262,13 +322,14 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		progressbar.OptionSetWidth(40),
 		progressbar.OptionThrottle(200*time.Millisecond),
 		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetWriter(out),
 	)
 
 	// Create archive manager
 	manager := archive.NewManager()
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := withTimeout(context.Background(), createTimeout)
 	defer cancel()
 
 	// Start the spinner
@@ -304,6 +365,7 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		Exclude:          excludes,
 		MediaThreshold:   cfg.Compression.MediaThreshold,
 		DocsThreshold:    cfg.Compression.DocsThreshold,
+		Out:              out,
 	}
 
 	startTime := time.Now()
@@ -319,43 +381,108 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	// If the user explicitly requested only one artifact without --comprehensive, we could support that here.
 	// For now, we centralize to avoid duplication.
 
+	created := &storage.Archive{
+		Name:       filepath.Base(result.Path),
+		Path:       result.Path,
+		Size:       result.Size,
+		Created:    result.Created,
+		Profile:    result.Profile.Name,
+		Checksum:   result.Checksum,
+		Managed:    useManaged,
+		SourcePath: absPath,
+		SourceSize: result.OriginalSize,
+	}
+
 	// Register in registry (managed or external)
 	if storageManager != nil {
-		managed := useManaged
-		if err := storageManager.Add(
-			filepath.Base(result.Path),
-			result.Path,
-			result.Size,
-			result.Profile.Name,
-			result.Checksum,
-			"",
-			managed,
-		); err != nil {
+		fingerprint, err := storage.SourceFingerprint(absPath)
+		if err != nil {
+			fmt.Fprintf(out, "⚠️  Warning: Failed to fingerprint source: %v\n", err)
+		}
+		created.SourceFingerprint = fingerprint
+		if err := storageManager.Register(created); err != nil {
 			// Non-fatal error - archive was created successfully
-			fmt.Printf("⚠️  Warning: Failed to register archive in registry: %v\n", err)
+			fmt.Fprintf(out, "⚠️  Warning: Failed to register archive in registry: %v\n", err)
+		} else if useManaged {
+			// Place the archive according to the managed storage layout
+			if err := storageManager.PlaceManaged(created); err != nil {
+				fmt.Fprintf(out, "⚠️  Warning: Failed to organize archive: %v\n", err)
+			}
+			result.Path = created.Path
 		}
 	}
+	runner.Notify(context.Background(), hooks.PostCreate, created, map[string]string{"source": absPath})
 
 	// Print results
-	fmt.Printf("\n✅ Archive created successfully!\n")
+	fmt.Fprintf(out, "\n✅ Archive created successfully!\n")
 	if useManaged {
-		fmt.Printf("📦 Stored in managed storage: %s\n", filepath.Base(result.Path))
+		fmt.Fprintf(out, "📦 Stored in managed storage: %s\n", filepath.Base(result.Path))
 	} else {
-		fmt.Printf("Archive: %s\n", result.Path)
+		fmt.Fprintf(out, "Archive: %s\n", result.Path)
 	}
-	fmt.Printf("Size: %.2f MB\n", float64(result.Size)/(1024*1024))
-	fmt.Printf("Files: %d\n", result.FileCount)
-	fmt.Printf("Compression: Level %d (%s profile)\n", result.Profile.Level, result.Profile.Name)
-	fmt.Printf("Duration: %s\n", duration.Round(time.Second))
+	fmt.Fprintf(out, "Size: %.2f MB\n", float64(result.Size)/(1024*1024))
+	fmt.Fprintf(out, "Files: %d\n", result.FileCount)
+	fmt.Fprintf(out, "Compression: Level %d (%s profile)\n", result.Profile.Level, result.Profile.Name)
+	fmt.Fprintf(out, "Duration: %s\n", duration.Round(time.Second))
 
 	if result.Size > 0 && result.OriginalSize > 0 {
 		ratio := float64(result.Size) / float64(result.OriginalSize) * 100
-		fmt.Printf("Size reduction: %.1f%%\n", 100-ratio)
+		fmt.Fprintf(out, "Size reduction: %.1f%%\n", 100-ratio)
 	}
 
 	if useManaged {
-		fmt.Printf("\n💡 Tip: Use '7zarch-go list' to see all managed archives\n")
+		fmt.Fprintf(out, "\n💡 Tip: Use '7zarch-go list' to see all managed archives\n")
 	}
 
-	return nil
+	return p.Result(createResult{
+		Archive:      created,
+		Files:        result.FileCount,
+		OriginalSize: result.OriginalSize,
+		Compression:  &result.Profile,
+		DurationMS:   duration.Milliseconds(),
+	})
+}
+
+// createResult is the structured result of create. A dry run describes the
+// planned archive and its estimated size.
+type createResult struct {
+	Archive       *storage.Archive            `json:"archive"`
+	Files         int                         `json:"files,omitempty"`
+	OriginalSize  int64                       `json:"original_size,omitempty"`
+	Compression   *archive.CompressionProfile `json:"compression,omitempty"`
+	DurationMS    int64                       `json:"duration_ms,omitempty"`
+	EstimatedSize int64                       `json:"estimated_size,omitempty"`
+	DryRun        bool                        `json:"dry_run,omitempty"`
+}
+
+// estimateArchiveSize predicts the size of the archive of source from its
+// content and the compression its profile achieved on earlier archives
+func estimateArchiveSize(source, profile string, cfg *config.Config, mgr *storage.Manager) (int64, error) {
+	stats, recommended, err := archive.AnalyzeContentWithThresholds(source, cfg.Compression.MediaThreshold, cfg.Compression.DocsThreshold)
+	if err != nil {
+		return 0, err
+	}
+	if profile == "" {
+		profile = recommended.Name
+	}
+	var ratio float64
+	if mgr != nil {
+		if r, _, err := mgr.Registry().CompressionRatio(profile); err == nil {
+			ratio = r
+		}
+	}
+	return archive.EstimateSize(stats, ratio), nil
+}
+
+// checkCreateCapacity fails when the archive would not fit: managed storage
+// checks its quota and free space, other outputs their disk's free space
+func checkCreateCapacity(output string, estimate int64, managed bool, cfg *config.Config, mgr *storage.Manager) error {
+	if managed {
+		return mgr.CheckCapacity(estimate, "")
+	}
+	quota, err := cmdutil.QuotaFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return storage.CheckFreeSpace(filepath.Dir(output), estimate, quota.MinFree)
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/output"
+)
+
+func TestCreateJSONOutputParses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	mgr := setupManagedStore(t)
+	// A stand-in 7z that writes the archive it is asked for
+	bin := t.TempDir()
+	script := "#!/bin/sh\nprintf archive > \"$2\"\necho 'Everything is Ok'\n"
+	if err := os.WriteFile(filepath.Join(bin, "7z"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "notes.txt"), []byte("some notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything create prints goes through the process's stdout, so read
+	// that rather than a buffer only the printer writes to
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	prev := resultFormat
+	resultFormat = output.JSON
+	cmd := CreateCmd()
+	cmd.SetErr(io.Discard)
+	runErr := cmd.RunE(cmd, []string{src})
+	resultFormat, os.Stdout = prev, stdout
+	w.Close()
+	data, _ := io.ReadAll(r)
+	if runErr != nil {
+		t.Fatalf("create: %v", runErr)
+	}
+
+	var doc struct {
+		output.Document
+		Data createResult `json:"data"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("stdout is not a JSON document: %v\n%s", err, data)
+	}
+	if !doc.OK || doc.Data.Archive == nil || doc.Data.DryRun {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if _, err := mgr.Get(doc.Data.Archive.Name); err != nil {
+		t.Errorf("created archive not registered: %v", err)
+	}
+}
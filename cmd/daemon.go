@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/jobs"
+	"github.com/adamstac/7zarch-go/internal/schedule"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// DaemonCmd returns the `daemon` command that runs configured schedules
+func DaemonCmd() *cobra.Command {
+	var flagNoWorker bool
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run scheduled tasks from the config",
+		Long: `Run in the foreground and queue the tasks in the config's schedules as
+they fall due, along with a worker that runs them (see 'worker').
+
+Each schedule runs create, scrub, prune or upload with its arguments on a
+cron expression. Runs are recorded in the registry ('schedules history'):
+when the daemon starts, a schedule that fell due while it was stopped runs
+once to catch up unless it sets skip_missed, and a run is skipped while the
+job from the schedule's previous run is still queued or running.`,
+		Example: `  # Run schedules and their jobs
+  7zarch-go daemon
+
+  # Only queue jobs; separate workers run them
+  7zarch-go daemon --no-worker`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			entries, err := schedule.Compile(cfg.Schedules)
+			if err != nil {
+				return &errs.ConfigurationError{Setting: "schedules", Message: err.Error()}
+			}
+
+			out := cmd.OutOrStdout()
+			logf := func(format string, a ...interface{}) {
+				fmt.Fprintf(out, "%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+			}
+			sched := schedule.New(mgr.Registry(), entries, time.Now(), schedule.Options{Logf: logf})
+
+			if len(entries) == 0 {
+				fmt.Fprintf(os.Stderr, "⚠️  Warning: no schedules configured; add them under schedules: in the config\n")
+			}
+			fmt.Fprintf(out, "🗓️  Running %d schedules (Ctrl+C to stop)\n", len(entries))
+			for _, e := range entries {
+				next, err := sched.Next(e)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "   %-20s %-7s %-15s next %s\n", e.Name, e.Task, e.Cron, formatNext(next))
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			// Keep running when the terminal goes away
+			signal.Ignore(syscall.SIGHUP, syscall.SIGPIPE)
+
+			g, ctx := errgroup.WithContext(ctx)
+			g.Go(func() error { return sched.Run(ctx) })
+			if !flagNoWorker {
+				w := jobs.NewWorker(mgr, jobs.Options{Concurrency: cfg.Jobs.Concurrency, Logf: logf})
+				g.Go(func() error { return w.Run(ctx) })
+			}
+			return g.Wait()
+		},
+	}
+
+	cmd.Flags().BoolVar(&flagNoWorker, "no-worker", false, "Only queue scheduled jobs; leave running them to 'worker'")
+	return cmd
+}
+
+func formatNext(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04")
+}
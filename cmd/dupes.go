@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/display"
+	"github.com/adamstac/7zarch-go/internal/dupes"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// DupesCmd returns the `dupes` command that reports duplicate archives
+func DupesCmd() *cobra.Command {
+	var (
+		flagNear      bool
+		flagThreshold float64
+		flagTrash     bool
+		flagForce     bool
+		flagDryRun    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dupes",
+		Short: "Find duplicate archives and optionally trash redundant copies",
+		Long: `Find archives holding the same content.
+
+Exact duplicates share a checksum. With --near, archives are also compared by
+their member listings (path, size and CRC of each file), which catches the same
+files compressed with different profiles. Each group shows the copy that would
+be kept (managed first, then oldest) and how much space trashing the others
+would reclaim. Use --trash to move redundant copies to trash through the
+normal soft-delete flow.`,
+		Example: `  # Report byte-identical archives
+  7zarch-go dupes
+
+  # Include near-duplicates (requires 7z)
+  7zarch-go dupes --near --threshold 0.95
+
+  # Trash redundant copies after confirmation
+  7zarch-go dupes --trash`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			archives, err := mgr.List()
+			if err != nil {
+				return err
+			}
+
+			groups := dupes.FindExact(archives)
+			if flagNear {
+				am := archive.NewManager()
+				list := func(a *storage.Archive) ([]archive.Member, error) {
+					return am.ListMembers(context.Background(), a.Path)
+				}
+				near, listErrs := dupes.FindNear(archives, groups, list, flagThreshold)
+				for _, e := range listErrs {
+					fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Skipped %v\n", firstLine(e.Error()))
+				}
+				groups = append(groups, near...)
+			}
+
+			if len(groups) == 0 {
+				fmt.Fprintln(out, "No duplicates found.")
+				return nil
+			}
+
+			printDupeGroups(out, groups)
+
+			if !flagTrash {
+				return nil
+			}
+
+			var redundant []*storage.Archive
+			for _, g := range groups {
+				redundant = append(redundant, g.Redundant...)
+			}
+
+			if flagDryRun {
+				fmt.Fprintf(out, "Would trash %d archives, reclaiming %s\n",
+					len(redundant), display.FormatSize(dupes.TotalReclaimable(groups)))
+				return nil
+			}
+
+			if !flagForce {
+				fmt.Fprintf(out, "Trash %d redundant archives? [y/N]: ", len(redundant))
+				reader := bufio.NewReader(os.Stdin)
+				line, _ := reader.ReadString('\n')
+				line = strings.TrimSpace(strings.ToLower(line))
+				if line != "y" && line != "yes" {
+					fmt.Fprintln(out, "Aborted.")
+					return nil
+				}
+			}
+
+			trashed := 0
+			var freed int64
+			for _, a := range redundant {
+				size := a.Size
+				if err := mgr.Trash(a); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Failed to trash %s: %v\n", a.Name, err)
+					continue
+				}
+				trashed++
+				freed += size
+			}
+			fmt.Fprintf(out, "Trashed %d archives (%s reclaimable after purge).\n", trashed, display.FormatSize(freed))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flagNear, "near", false, "Also detect near-duplicates by comparing member listings (requires 7z)")
+	cmd.Flags().Float64Var(&flagThreshold, "threshold", dupes.DefaultThreshold, "Minimum member overlap (0-1) for near-duplicates")
+	cmd.Flags().BoolVar(&flagTrash, "trash", false, "Move redundant copies to trash")
+	cmd.Flags().BoolVar(&flagForce, "force", false, "Skip confirmation prompts")
+	cmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Show actions without making changes")
+	return cmd
+}
+
+func printDupeGroups(out io.Writer, groups []dupes.Group) {
+	for i, g := range groups {
+		label := "Exact duplicates"
+		if g.Kind == dupes.Near {
+			label = fmt.Sprintf("Near duplicates (%.0f%% overlap)", g.Similarity*100)
+		}
+		fmt.Fprintf(out, "Group %d: %s — reclaimable %s\n", i+1, label, display.FormatSize(g.Reclaimable))
+		printDupeRow(out, "keep ", g.Keep)
+		for _, a := range g.Redundant {
+			printDupeRow(out, "trash", a)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "%d groups, %s reclaimable in total\n", len(groups), display.FormatSize(dupes.TotalReclaimable(groups)))
+}
+
+func printDupeRow(out io.Writer, action string, a *storage.Archive) {
+	uid := a.UID
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	fmt.Fprintf(out, "  %s  %-8s  %-30s  %10s  %s\n", action, uid, a.Name, display.FormatSize(a.Size), a.Path)
+}
+
+// firstLine trims multi-line errors (e.g. 7z output) for warnings
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// setupManagedStore points HOME at a temp dir with a config whose managed
+// storage lives in another temp dir, and returns a manager for seeding data.
+// Optional mutators adjust the config before it is written.
+func setupManagedStore(t *testing.T, mutate ...func(*config.Config)) *storage.Manager {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cfg := config.DefaultConfig()
+	cfg.Storage.ManagedPath = t.TempDir()
+	for _, m := range mutate {
+		m(cfg)
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("yaml marshal: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".7zarch-go-config"), data, 0644); err != nil {
+		t.Fatalf("write cfg: %v", err)
+	}
+
+	mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
+	if err != nil {
+		t.Fatalf("manager error: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	return mgr
+}
+
+// runEWithArgs invokes RunE directly with positional args and captures output
+func runEWithArgs(t *testing.T, cmd *cobra.Command, args ...string) (string, error) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	err := cmd.RunE(cmd, args)
+	return buf.String(), err
+}
+
+func TestDupesTrashesRedundantCopies(t *testing.T) {
+	mgr := setupManagedStore(t)
+
+	for _, name := range []string{"photos.7z", "photos-copy.7z", "unique.7z"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		content, sum := "same bytes", "checksum-same"
+		if name == "unique.7z" {
+			content, sum = "different", "checksum-unique"
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.Add(name, p, int64(len(content)), "Media", sum, "", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := runEWithFlags(t, DupesCmd(), nil)
+	if err != nil {
+		t.Fatalf("dupes: %v", err)
+	}
+	if !strings.Contains(out, "Exact duplicates") || !strings.Contains(out, "1 groups") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+
+	out, err = runEWithFlags(t, DupesCmd(), func(f *pflag.FlagSet) {
+		_ = f.Set("trash", "true")
+		_ = f.Set("force", "true")
+	})
+	if err != nil {
+		t.Fatalf("dupes --trash: %v", err)
+	}
+	if !strings.Contains(out, "Trashed 1 archives") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	kept, _ := mgr.Get("photos.7z")
+	trashed, _ := mgr.Get("photos-copy.7z")
+	if kept.Status != "present" || trashed.Status != "deleted" {
+		t.Fatalf("expected photos.7z kept and copy trashed, got %s/%s", kept.Status, trashed.Status)
+	}
+	if !strings.HasPrefix(trashed.Path, mgr.GetTrashPath()) {
+		t.Fatalf("trashed copy not moved to trash: %s", trashed.Path)
+	}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adamstac/7zarch-go/internal/output"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// resultFormat is the result format chosen with the global --format flag
+var resultFormat = output.Text
+
+// AddFormatFlag adds the global --format flag to root. Commands marked with
+// output.Annotate write their result as a JSON document or NDJSON stream;
+// the rest send their text to stderr and Finish writes a document saying
+// whether they succeeded. Call it after AddVaultFlag.
+func AddFormatFlag(root *cobra.Command) {
+	root.PersistentFlags().Var(&resultFormat, "format", "Result format: text, json or ndjson")
+	next := root.PersistentPreRunE
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if resultFormat.Structured() {
+			cmd.SilenceErrors, cmd.SilenceUsage = true, true
+			if !output.Annotated(cmd) {
+				cmd.SetOut(cmd.ErrOrStderr())
+			}
+		}
+		if next != nil {
+			return next(cmd, args)
+		}
+		return nil
+	}
+}
+
+// Finish reports how the command root ran ended and returns err. In text
+// format that is the error message; otherwise a document for commands that
+// did not write their own.
+func Finish(cmd *cobra.Command, err error) error {
+	if !resultFormat.Structured() {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return err
+	}
+	p := output.New(os.Stdout, os.Stderr, resultFormat, commandName(cmd))
+	if err != nil {
+		_ = p.Error(err)
+	} else if !output.Annotated(cmd) {
+		_ = p.Result(nil)
+	}
+	return err
+}
+
+// archivesResult is the result of commands listing archives. Next is the
+// cursor for the following page, if there is one.
+type archivesResult struct {
+	Archives []*storage.Archive `json:"archives"`
+	Next     string             `json:"next,omitempty"`
+}
+
+// listed returns the result for a page of archives; an empty page is an
+// empty array rather than null
+func listed(archives []*storage.Archive, next string) archivesResult {
+	if archives == nil {
+		archives = []*storage.Archive{}
+	}
+	return archivesResult{Archives: archives, Next: next}
+}
+
+// archiveAction is the result of commands acting on one archive, such as
+// delete and restore
+type archiveAction struct {
+	Action  string           `json:"action"`
+	Archive *storage.Archive `json:"archive"`
+	From    string           `json:"from,omitempty"`
+	To      string           `json:"to,omitempty"`
+	DryRun  bool             `json:"dry_run,omitempty"`
+}
+
+// deprecateOutputFlag marks a command's older --output json|csv|yaml flag
+// as deprecated. It keeps its shapes for existing scripts, but --format is
+// the one machine-readable flag, the same on every command.
+func deprecateOutputFlag(cmd *cobra.Command) {
+	_ = cmd.Flags().MarkDeprecated("output", "use --format json or --format ndjson instead")
+}
+
+// printerFor returns the printer for a command's result
+func printerFor(cmd *cobra.Command) *output.Printer {
+	return output.New(cmd.OutOrStdout(), cmd.ErrOrStderr(), resultFormat, commandName(cmd))
+}
+
+// commandName is the command path without the program name, e.g. "db status"
+func commandName(cmd *cobra.Command) string {
+	if cmd == nil {
+		return ""
+	}
+	if !cmd.HasParent() {
+		return cmd.Name()
+	}
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
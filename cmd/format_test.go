@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/output"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// runStructured runs cmd with the global --format set to format and returns
+// what it wrote to stdout and stderr separately
+func runStructured(t *testing.T, format output.Format, cmd *cobra.Command, args ...string) (string, string, error) {
+	t.Helper()
+	prev := resultFormat
+	resultFormat = format
+	t.Cleanup(func() { resultFormat = prev })
+
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	err := cmd.RunE(cmd, args)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestDeleteWritesJSONDocument(t *testing.T) {
+	mgr := setupManagedStore(t)
+	path := filepath.Join(mgr.GetArchivesPath(), "photos.7z")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Add("photos.7z", path, 4, "", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := runStructured(t, output.JSON, MasDeleteCmd(), "photos.7z")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var doc struct {
+		output.Document
+		Data archiveAction `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &doc); err != nil {
+		t.Fatalf("stdout is not a JSON document: %v\n%s", err, stdout)
+	}
+	if doc.SchemaVersion != output.SchemaVersion || doc.Command != "delete" || !doc.OK {
+		t.Fatalf("unexpected document: %+v", doc.Document)
+	}
+	if doc.Data.Action != "trashed" || doc.Data.Archive.Name != "photos.7z" || doc.Data.From != path {
+		t.Fatalf("unexpected data: %+v", doc.Data)
+	}
+	if !strings.HasPrefix(doc.Data.To, mgr.GetTrashPath()) {
+		t.Fatalf("archive should have moved to the trash, got %q", doc.Data.To)
+	}
+}
+
+func TestProfilesTextFormatUnchanged(t *testing.T) {
+	stdout, _, err := runStructured(t, output.Text, ProfilesCmd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "Available Compression Profiles") || strings.Contains(stdout, "schema_version") {
+		t.Fatalf("unexpected text output:\n%s", stdout)
+	}
+}
+
+func TestTestDirectoryStreamsNDJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for _, name := range []string{"a.7z", "b.7z"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("not an archive"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := TestCmd()
+	_ = cmd.Flags().Set("directory", "true")
+	t.Cleanup(func() { testDirectory = false })
+
+	stdout, _, err := runStructured(t, output.NDJSON, cmd, dir)
+	if !output.IsReported(err) {
+		t.Fatalf("corrupt archives should fail with a reported error, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want 2 archive events and a result, got:\n%s", stdout)
+	}
+	var types []string
+	for _, line := range lines {
+		var v struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		types = append(types, v.Type)
+	}
+	if types[0] != "archive" || types[1] != "archive" || types[2] != "error" {
+		t.Fatalf("unexpected line types %v", types)
+	}
+
+	var last struct {
+		output.Document
+		Data testReport `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.OK || last.Data.Tested != 2 || last.Data.Failed != 2 || len(last.Data.Archives) != 0 {
+		t.Fatalf("unexpected result: %+v", last)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	root := &cobra.Command{Use: "7zarch-go"}
+	db := &cobra.Command{Use: "db"}
+	status := &cobra.Command{Use: "status"}
+	root.AddCommand(db)
+	db.AddCommand(status)
+	if got := commandName(status); got != "db status" {
+		t.Fatalf("commandName = %q, want %q", got, "db status")
+	}
+	if got := commandName(&cobra.Command{Use: "delete <id>"}); got != "delete" {
+		t.Fatalf("commandName = %q, want %q", got, "delete")
+	}
+}
+
+func TestOutputFlagWritesToCommandOutput(t *testing.T) {
+	mgr := setupManagedStore(t)
+	path := filepath.Join(mgr.GetArchivesPath(), "photos.7z")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum, _ := storage.FileChecksum(path)
+	if err := mgr.Add("photos.7z", path, 4, "", sum, "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	list := ListCmd()
+	_ = list.Flags().Set("output", "json")
+	stdout, _, err := runStructured(t, output.Text, list)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var archives []storage.Archive
+	if err := json.Unmarshal([]byte(stdout), &archives); err != nil || len(archives) != 1 {
+		t.Fatalf("list --output json = %v, %q", err, stdout)
+	}
+
+	show := MasShowCmd()
+	_ = show.Flags().Set("output", "yaml")
+	_ = show.Flags().Set("verify", "true")
+	stdout, _, err = runStructured(t, output.Text, show, "photos.7z")
+	if err != nil {
+		t.Fatalf("show: %v", err)
+	}
+	if !strings.Contains(stdout, "name: photos.7z") || !strings.Contains(stdout, `"checksum_verified":true`) {
+		t.Fatalf("unexpected show --output yaml:\n%s", stdout)
+	}
+}
+
+func TestOutputFlagDeprecatedForFormat(t *testing.T) {
+	for _, c := range []*cobra.Command{ListCmd(), MasShowCmd(), searchQueryCmd(), trashListCmd(), queryListCmd(),
+		queryRunCmd(), queryShowCmd(), jobsListCmd(), LogCmd(), BatchCmd()} {
+		if f := c.Flags().Lookup("output"); f == nil || !strings.Contains(f.Deprecated, "--format") {
+			t.Errorf("%s: --output should be deprecated in favour of --format", c.Name())
+		}
+		if !output.Annotated(c) {
+			t.Errorf("%s: has no --format result to replace --output with", c.Name())
+		}
+	}
+
+	// log, which had only --output json, now writes a document
+	mgr := setupManagedStore(t)
+	if err := mgr.Register(&storage.Archive{Name: "a.7z", Path: "/tmp/a.7z", Size: 1, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err := runStructured(t, output.JSON, LogCmd())
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	var doc struct {
+		output.Document
+		Data struct {
+			Events []*storage.Event `json:"events"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &doc); err != nil || len(doc.Data.Events) != 1 || doc.Data.Events[0].Action != "add" {
+		t.Fatalf("log --format json = %v\n%s", err, stdout)
+	}
+}
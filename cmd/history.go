@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/display"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// HistoryCmd returns the `history` command listing every version of a source
+func HistoryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <source-or-id>",
+		Short: "List all archive versions created from a source",
+		Long: `List every archive created from the same source directory, oldest first,
+with size changes between versions. The argument is either a source path or
+any archive ID accepted by 'show' (uid, checksum prefix, numeric id, or name).`,
+		Example: `  7zarch-go history ~/Projects/website
+  7zarch-go history 01K2E3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			source, err := resolveHistorySource(mgr, args[0])
+			if err != nil {
+				return err
+			}
+
+			versions, err := mgr.History(source)
+			if err != nil {
+				return err
+			}
+			printHistory(cmd.OutOrStdout(), source, versions)
+			return nil
+		},
+	}
+}
+
+// resolveHistorySource maps a source path or archive ID to a source path
+func resolveHistorySource(mgr *storage.Manager, input string) (string, error) {
+	if abs, err := filepath.Abs(input); err == nil {
+		if _, statErr := os.Stat(abs); statErr == nil {
+			return abs, nil
+		}
+		// Source may have been removed since it was archived
+		if versions, err := mgr.Registry().ListBySource(abs); err == nil && len(versions) > 0 {
+			return abs, nil
+		}
+	}
+
+	resolver := storage.NewResolver(mgr.Registry())
+	arc, err := resolver.Resolve(input)
+	if err != nil {
+		if amb, ok := err.(*storage.AmbiguousIDError); ok {
+			printAmbiguousOptions(amb)
+		}
+		return "", cmdutil.HandleResolverError(err, input)
+	}
+	if arc.SourcePath == "" {
+		return "", fmt.Errorf("archive %s has no recorded source (created before lineage tracking)", arc.Name)
+	}
+	return arc.SourcePath, nil
+}
+
+func printHistory(out io.Writer, source string, versions []storage.Version) {
+	fmt.Fprintf(out, "Source: %s\n", source)
+	if len(versions) == 0 {
+		fmt.Fprintln(out, "No archives recorded for this source.")
+		return
+	}
+	fmt.Fprintf(out, "%-3s %-8s %-19s %-10s %-11s %-9s %s\n", "#", "UID", "CREATED", "SIZE", "DELTA", "STATUS", "NAME")
+	for i, v := range versions {
+		a := v.Archive
+		delta := "—"
+		if i > 0 {
+			delta = formatSizeDelta(v.SizeDelta)
+			if !v.Changed {
+				delta += " ="
+			}
+		}
+		fmt.Fprintf(out, "%-3d %-8s %-19s %-10s %-11s %-9s %s\n", i+1, safePrefix(a.UID, 8),
+			a.Created.Format("2006-01-02 15:04:05"), display.FormatSize(a.Size), delta, a.Status, a.Name)
+	}
+	fmt.Fprintf(out, "\n%d versions (= marks an unchanged source)\n", len(versions))
+}
+
+func formatSizeDelta(d int64) string {
+	switch {
+	case d > 0:
+		return "+" + display.FormatSize(d)
+	case d < 0:
+		return "-" + display.FormatSize(-d)
+	default:
+		return "±0"
+	}
+}
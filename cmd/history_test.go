@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestHistoryListsVersionsBySourceAndID(t *testing.T) {
+	mgr := setupManagedStore(t)
+	src := t.TempDir()
+
+	base := time.Now().Add(-time.Hour)
+	var last *storage.Archive
+	for i, size := range []int64{1000, 1500} {
+		at := base.Add(time.Duration(i) * time.Minute)
+		name := "site.7z"
+		if i > 0 {
+			name = storage.VersionedName(name, at)
+		}
+		last = &storage.Archive{
+			Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name), Size: size,
+			Created: at, Managed: true, SourcePath: src, SourceFingerprint: "fp",
+		}
+		if err := mgr.Register(last); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, arg := range []string{src, last.UID} {
+		out, err := runEWithArgs(t, HistoryCmd(), arg)
+		if err != nil {
+			t.Fatalf("history %s: %v", arg, err)
+		}
+		if !containsAll(out, []string{"site.7z", "2 versions", "+500 B"}) {
+			t.Fatalf("unexpected output for %s:\n%s", arg, out)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/hooks"
+)
+
+func TestDeleteAndPurgeRunHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p hooks.Payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		events = append(events, p.Event+" "+p.Archive.Name)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	mgr := setupManagedStore(t, func(c *config.Config) {
+		c.Hooks.OnDelete = []config.Hook{{URL: srv.URL}}
+		c.Hooks.OnPurge = []config.Hook{{URL: srv.URL}}
+	})
+	p := filepath.Join(mgr.GetArchivesPath(), "old.7z")
+	if err := os.WriteFile(p, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Add("old.7z", p, 3, "Media", "checksum-old", "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := runEWithArgs(t, MasDeleteCmd(), "old.7z"); err != nil {
+		t.Fatalf("delete = %q, %v", out, err)
+	}
+	purge := trashPurgeCmd()
+	_ = purge.Flags().Set("all", "true")
+	_ = purge.Flags().Set("force", "true")
+	if out, err := runEWithArgs(t, purge); err != nil {
+		t.Fatalf("purge = %q, %v", out, err)
+	}
+
+	if want := []string{"on_delete old.7z", "on_purge old.7z"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks saw %v, want %v", events, want)
+	}
+}
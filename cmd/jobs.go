@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/output"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// JobsCmd returns the `jobs` command managing the background job queue
+func JobsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Manage background jobs",
+		Long: `Manage commands queued with --background. Queued jobs are run by
+'7zarch-go worker'; their output is kept in a log per job.`,
+	}
+	cmd.AddCommand(jobsListCmd())
+	cmd.AddCommand(jobsCancelCmd())
+	cmd.AddCommand(jobsRetryCmd())
+	cmd.AddCommand(jobsLogsCmd())
+	return cmd
+}
+
+func jobsListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List background jobs, newest first",
+		Example: `  7zarch-go jobs list
+  7zarch-go jobs list --status running,queued
+  7zarch-go --format json jobs list`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			var statuses []string
+			if s := getString(cmd, "status"); s != "" {
+				for _, status := range strings.Split(s, ",") {
+					status = strings.TrimSpace(status)
+					switch status {
+					case storage.JobQueued, storage.JobRunning, storage.JobSucceeded, storage.JobFailed, storage.JobCanceled:
+						statuses = append(statuses, status)
+					default:
+						return &errs.ValidationError{Field: "--status", Value: status,
+							Message: "use queued, running, succeeded, failed or canceled"}
+					}
+				}
+			}
+			jobs, err := mgr.Registry().Jobs(getInt(cmd, "limit"), statuses...)
+			if err != nil {
+				return err
+			}
+			if p := printerFor(cmd); p.Structured() {
+				if jobs == nil {
+					jobs = []*storage.Job{}
+				}
+				return p.Result(struct {
+					Jobs []*storage.Job `json:"jobs"`
+				}{jobs})
+			}
+			switch getString(cmd, "output") {
+			case "":
+				printJobs(cmd.OutOrStdout(), jobs)
+				return nil
+			case "json":
+				if jobs == nil {
+					jobs = []*storage.Job{}
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(jobs)
+			default:
+				return fmt.Errorf("unsupported output format: %s", getString(cmd, "output"))
+			}
+		},
+	}
+	cmd.Flags().String("status", "", "Only jobs in these states, comma separated (queued, running, succeeded, failed, canceled)")
+	cmd.Flags().Int("limit", 50, "Maximum number of jobs to show (0 = all)")
+	cmd.Flags().String("output", "", "Output format: json (default: table)")
+	deprecateOutputFlag(cmd)
+	return output.Annotate(cmd)
+}
+
+func printJobs(out io.Writer, jobs []*storage.Job) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(out, "No jobs.")
+		return
+	}
+	fmt.Fprintf(out, "%-6s %-8s %-10s %-5s %-16s %-9s %s\n", "ID", "TYPE", "STATUS", "TRIES", "QUEUED", "DURATION", "COMMAND")
+	for _, j := range jobs {
+		status := j.Status
+		if j.Cancel && j.Status == storage.JobRunning {
+			status = "canceling"
+		}
+		duration := "—"
+		if j.Started != nil {
+			duration = j.Duration().Round(time.Second).String()
+		}
+		fmt.Fprintf(out, "%-6d %-8s %-10s %-5d %-16s %-9s %s\n", j.ID, truncate(j.Type, 8), status, j.Attempts,
+			j.Created.Format("2006-01-02 15:04"), duration, truncate(strings.Join(j.Args, " "), 60))
+		if j.Status == storage.JobFailed && j.Error != "" {
+			fmt.Fprintf(out, "%-6s └ %s\n", "", j.Error)
+		}
+	}
+}
+
+func jobsCancelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel <job-id>",
+		Short: "Cancel a queued job or stop a running one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobID(args[0])
+			if err != nil {
+				return err
+			}
+			_, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			j, err := mgr.Registry().CancelJob(id)
+			if err != nil {
+				return err
+			}
+			if j.Status == storage.JobCanceled {
+				fmt.Fprintf(cmd.OutOrStdout(), "⏹️  Canceled job %d\n", id)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "⏹️  Asked the worker to stop job %d\n", id)
+			}
+			return nil
+		},
+	}
+}
+
+func jobsRetryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <job-id>",
+		Short: "Queue a failed or canceled job to run again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobID(args[0])
+			if err != nil {
+				return err
+			}
+			_, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if _, err := mgr.Registry().RetryJob(id); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "🔁 Queued job %d to run again\n", id)
+			return nil
+		},
+	}
+}
+
+func jobsLogsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs <job-id>",
+		Short: "Show a job's output",
+		Long: `Show the output of every attempt of a job. With --follow, keep printing
+output until the job finishes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseJobID(args[0])
+			if err != nil {
+				return err
+			}
+			_, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			j, err := mgr.Registry().Job(id)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			f, err := os.Open(mgr.JobLogPath(id))
+			if os.IsNotExist(err) {
+				if !getBool(cmd, "follow") || j.Done() {
+					fmt.Fprintf(out, "Job %d has no output yet (%s)\n", id, j.Status)
+					return nil
+				}
+			} else if err != nil {
+				return fmt.Errorf("failed to open job log: %w", err)
+			} else {
+				defer f.Close()
+			}
+			if !getBool(cmd, "follow") {
+				_, err = io.Copy(out, f)
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return followJobLog(ctx, out, mgr, id, f)
+		},
+	}
+	cmd.Flags().BoolP("follow", "f", false, "Keep printing output until the job finishes")
+	return cmd
+}
+
+// followJobLog copies a job's log to out as it grows until the job is done.
+// f may be nil when the log has not been created yet.
+func followJobLog(ctx context.Context, out io.Writer, mgr *storage.Manager, id int64, f *os.File) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if f == nil {
+			if opened, err := os.Open(mgr.JobLogPath(id)); err == nil {
+				f = opened
+				defer f.Close()
+			}
+		}
+		if f != nil {
+			if _, err := io.Copy(out, f); err != nil {
+				return err
+			}
+		}
+		j, err := mgr.Registry().Job(id)
+		if err != nil {
+			return err
+		}
+		if j.Done() {
+			if f != nil {
+				_, err = io.Copy(out, f) // output written after the last copy
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func parseJobID(s string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(s, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, &errs.ValidationError{Field: "job-id", Value: s, Message: "use the numeric ID shown by 'jobs list'"}
+	}
+	return id, nil
+}
+
+// addBackgroundFlag adds --background, which queues the command as a job
+// for the worker instead of running it
+func addBackgroundFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("background", false, "Queue as a background job for '7zarch-go worker' instead of running now")
+}
+
+// queueJob queues cmd with the flags given on the command line as a
+// background job. The working directory is recorded so relative paths
+// resolve as they would have here.
+func queueJob(cmd *cobra.Command, args []string) error {
+	if getBool(cmd, "dry-run") {
+		return &errs.ValidationError{Field: "--background", Value: "true", Message: "cannot be combined with --dry-run"}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to read working directory: %w", err)
+	}
+	_, mgr, cleanup, err := cmdutil.InitJobQueue()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	j, err := mgr.Registry().EnqueueJob(jobArgs(cmd, args), dir)
+	if err != nil {
+		return err
+	}
+	p := printerFor(cmd)
+	out := p.Text()
+	fmt.Fprintf(out, "📥 Queued job %d: %s\n", j.ID, strings.Join(j.Args, " "))
+	fmt.Fprintf(out, "💡 Run '7zarch-go worker' to process the queue; follow it with '7zarch-go jobs logs %d -f'\n", j.ID)
+	return p.Result(struct {
+		Job *storage.Job `json:"job"`
+	}{j})
+}
+
+// jobArgs rebuilds the command line of cmd from its path, the flags set on
+// the command line and its positional arguments, leaving out --background
+// and the result --format, which only concern the command queueing the job
+func jobArgs(cmd *cobra.Command, args []string) []string {
+	argv := strings.Fields(cmd.CommandPath())[1:]
+	if !cmd.HasParent() {
+		argv = []string{cmd.Name()}
+	}
+	add := func(f *pflag.Flag) {
+		if !f.Changed || f.Name == "background" || f.Name == "format" {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range sv.GetSlice() {
+				argv = append(argv, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		argv = append(argv, "--"+f.Name+"="+f.Value.String())
+	}
+	cmd.InheritedFlags().VisitAll(add)
+	cmd.LocalFlags().VisitAll(add)
+	if len(args) > 0 {
+		argv = append(argv, "--")
+		argv = append(argv, args...)
+	}
+	return argv
+}
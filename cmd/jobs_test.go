@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestCreateBackgroundQueuesJob(t *testing.T) {
+	mgr := setupManagedStore(t)
+	src := t.TempDir()
+
+	cmd := CreateCmd()
+	for name, value := range map[string]string{"background": "true", "profile": "media", "timeout": "2h"} {
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := runEWithArgs(t, cmd, src)
+	if err != nil {
+		t.Fatalf("create --background: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Queued job 1") {
+		t.Errorf("expected queued message, got:\n%s", out)
+	}
+
+	j, err := mgr.Registry().Job(1)
+	if err != nil {
+		t.Fatalf("Job: %v", err)
+	}
+	want := []string{"create", "--profile=media", "--timeout=2h0m0s", "--", src}
+	if !reflect.DeepEqual(j.Args, want) || j.Type != "create" || j.Status != storage.JobQueued {
+		t.Errorf("job = %+v, want args %v", j, want)
+	}
+	if wd, _ := os.Getwd(); j.Dir != wd {
+		t.Errorf("job dir = %q, want %q", j.Dir, wd)
+	}
+	if _, err := os.Stat(mgr.GetArchivesPath()); err == nil {
+		if entries, _ := os.ReadDir(mgr.GetArchivesPath()); len(entries) != 0 {
+			t.Errorf("create --background created an archive")
+		}
+	}
+
+	// A missing source is reported now rather than when the job runs
+	cmd = CreateCmd()
+	_ = cmd.Flags().Set("background", "true")
+	if _, err := runEWithArgs(t, cmd, "/does/not/exist"); err == nil {
+		t.Error("expected an error for a missing source")
+	}
+}
+
+func TestJobsListCancelRetry(t *testing.T) {
+	mgr := setupManagedStore(t)
+	reg := mgr.Registry()
+	j, err := reg.EnqueueJob([]string{"test", "--directory", "--", "/backups"}, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, jobsListCmd())
+	if err != nil {
+		t.Fatalf("jobs list: %v", err)
+	}
+	if !strings.Contains(out, "queued") || !strings.Contains(out, "test --directory -- /backups") {
+		t.Errorf("jobs list missing job:\n%s", out)
+	}
+
+	if _, err := runEWithArgs(t, jobsRetryCmd(), "1"); err == nil {
+		t.Error("retried a queued job")
+	}
+	if out, err := runEWithArgs(t, jobsCancelCmd(), "1"); err != nil || !strings.Contains(out, "Canceled job 1") {
+		t.Fatalf("jobs cancel = %q, %v", out, err)
+	}
+	if got, _ := reg.Job(j.ID); got.Status != storage.JobCanceled {
+		t.Fatalf("job status = %s, want canceled", got.Status)
+	}
+	if out, err := runEWithArgs(t, jobsRetryCmd(), "1"); err != nil || !strings.Contains(out, "Queued job 1") {
+		t.Fatalf("jobs retry = %q, %v", out, err)
+	}
+	if got, _ := reg.Job(j.ID); got.Status != storage.JobQueued {
+		t.Fatalf("job status = %s, want queued", got.Status)
+	}
+
+	list := jobsListCmd()
+	_ = list.Flags().Set("status", "failed")
+	if out, err := runEWithArgs(t, list); err != nil || !strings.Contains(out, "No jobs.") {
+		t.Errorf("jobs list --status failed = %q, %v", out, err)
+	}
+	if _, err := runEWithArgs(t, jobsCancelCmd(), "abc"); err == nil {
+		t.Error("expected an error for a non-numeric job id")
+	}
+
+	out, err = runEWithArgs(t, jobsLogsCmd(), "1")
+	if err != nil || !strings.Contains(out, "no output yet") {
+		t.Errorf("jobs logs = %q, %v", out, err)
+	}
+}
@@ -1,19 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
 	"github.com/adamstac/7zarch-go/internal/debug"
 	"github.com/adamstac/7zarch-go/internal/display"
 	"github.com/adamstac/7zarch-go/internal/display/modes"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/query"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
@@ -57,7 +62,17 @@ type listFilters struct {
 	status       string
 	profile      string
 	largerThan   int64
+	tags         []string
+	where        []string
+	expr         string // --filter expression, combined with the flags above
+	groupBy      string
 	debug        bool
+	sortBy       string // created|name|size
+	reverse      bool
+	limit        int
+	offset       int
+	after        string // cursor printed by a previous page
+	allVaults    bool
 }
 
 func ListCmd() *cobra.Command {
@@ -85,10 +100,17 @@ Display mode is auto-detected based on terminal width if not specified.`,
   7zarch-go list --managed          # Only managed archives
   7zarch-go list --older-than 30d   # Archives older than 30 days
   7zarch-go list --larger-than 100M # Archives larger than 100MB
+  7zarch-go list --tag client:acme  # Archives tagged client:acme
+  7zarch-go list --where project_year>=2024
+  7zarch-go list --filter 'size > 1GB and profile = media and not uploaded'
+  7zarch-go list --tree --group-by tag
+
+  # Sort and page through large registries
+  7zarch-go list --sort size --limit 50
+  7zarch-go list --limit 50 --after <cursor>   # cursor printed by the previous page
   
   # Machine-readable output
-  7zarch-go list --output json      # JSON format for scripting
-  7zarch-go list --output csv       # CSV format for spreadsheets`,
+  7zarch-go --format json list      # JSON document for scripting`,
 		RunE:  runList,
 	}
 
@@ -105,7 +127,14 @@ Display mode is auto-detected based on terminal width if not specified.`,
 	cmd.Flags().String("profile", "", "Filter by profile (media|documents|balanced)")
 	cmd.Flags().Int64("larger-than", 0, "Filter by size larger than bytes (e.g., 1048576)")
 	cmd.Flags().Bool("deleted", false, "Show only deleted archives")
+	cmd.Flags().StringSlice("tag", nil, "Only archives carrying this tag (repeatable; all must match)")
+	cmd.Flags().String("filter", "", "Filter expression, e.g. 'size > 1GB and profile = media and not uploaded'")
+	cmd.Flags().StringArray("where", nil, "Filter by custom field, e.g. retention_class=legal or project_year>=2024 (repeatable)")
+	cmd.Flags().Bool("all-vaults", false, "List archives from every configured vault")
+	cmd.Flags().String("group-by", "", "Group tree and dashboard output by: location|tag (default: location)")
+	addPageFlags(cmd)
 	cmd.Flags().String("output", "", "Output format: table|json|csv|yaml (default: table)")
+	deprecateOutputFlag(cmd)
 	
 	// Query integration flags
 	cmd.Flags().String("save-query", "", "Save current filters as a named query")
@@ -121,7 +150,7 @@ Display mode is auto-detected based on terminal width if not specified.`,
 	// Debug flag
 	cmd.Flags().Bool("debug", false, "Show performance and debug information")
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runList(cmd *cobra.Command, args []string) error {
@@ -148,7 +177,22 @@ func runList(cmd *cobra.Command, args []string) error {
 		status:       getString(cmd, "status"),
 		profile:      getString(cmd, "profile"),
 		largerThan:   getInt64(cmd, "larger-than"),
+		groupBy:      getString(cmd, "group-by"),
 		debug:        getBool(cmd, "debug"),
+		allVaults:    getBool(cmd, "all-vaults"),
+	}
+	readPageFlags(cmd, &opts)
+	opts.tags, _ = cmd.Flags().GetStringSlice("tag")
+	opts.where, _ = cmd.Flags().GetStringArray("where")
+	opts.expr = getString(cmd, "filter")
+	if _, err := opts.compile(); err != nil {
+		return err
+	}
+	if _, err := opts.criteria(); err != nil {
+		return err
+	}
+	if opts.groupBy != "" && opts.groupBy != "location" && opts.groupBy != "tag" {
+		return fmt.Errorf("invalid --group-by %q (supported: location, tag)", opts.groupBy)
 	}
 	
 	// If save-query flag is set, save the current filters
@@ -156,7 +200,7 @@ func runList(cmd *cobra.Command, args []string) error {
 		if err := saveCurrentFiltersAsQuery(opts, saveQueryName); err != nil {
 			return fmt.Errorf("failed to save query: %w", err)
 		}
-		fmt.Printf("✅ Query '%s' saved successfully\n", saveQueryName)
+		fmt.Fprintf(printerFor(cmd).Text(), "✅ Query '%s' saved successfully\n", saveQueryName)
 	}
 	
 	// Initialize metrics if debug mode
@@ -165,10 +209,25 @@ func runList(cmd *cobra.Command, args []string) error {
 		metrics = debug.NewMetrics()
 	}
 
+	if p := printerFor(cmd); p.Structured() {
+		if directory != "" {
+			return listDirectoryResult(p, directory, opts.pattern)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		page, err := findListPage(cfg, opts)
+		if err != nil {
+			return err
+		}
+		return p.Result(listed(page.Archives, page.Next))
+	}
+
 	// Check for output format
 	outputFormat := getString(cmd, "output")
 	if outputFormat != "" {
-		return listRegistryArchivesWithOutput(opts, outputFormat)
+		return listRegistryArchivesWithOutput(printerFor(cmd).Text(), opts, outputFormat)
 	}
 
 	// Determine display mode
@@ -209,6 +268,17 @@ func determineDisplayMode(cmd *cobra.Command) display.Mode {
 	return display.ModeAuto
 }
 
+// newDisplayManager returns a display manager with every display mode registered
+func newDisplayManager() *display.Manager {
+	displayManager := display.NewManager()
+	displayManager.Register(display.ModeTable, modes.NewTableDisplay())
+	displayManager.Register(display.ModeCompact, modes.NewCompactDisplay())
+	displayManager.Register(display.ModeCard, modes.NewCardDisplay())
+	displayManager.Register(display.ModeTree, modes.NewTreeDisplay())
+	displayManager.Register(display.ModeDashboard, modes.NewDashboardDisplay())
+	return displayManager
+}
+
 // listRegistryArchivesWithDisplay uses the new display system
 func listRegistryArchivesWithDisplay(opts listFilters, mode display.Mode, metrics *debug.Metrics) error {
 	// Load configuration
@@ -216,29 +286,13 @@ func listRegistryArchivesWithDisplay(opts listFilters, mode display.Mode, metric
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
-	// Initialize storage manager
-	storageManager, err := storage.NewManager(cfg.Storage.ManagedPath)
-	if err != nil {
-		return fmt.Errorf("failed to initialize managed storage: %w", err)
-	}
-	defer storageManager.Close()
-
-	// Get archives based on filters
-	var archives []*storage.Archive
-	if opts.notUploaded {
-		archives, err = storageManager.ListNotUploaded()
-	} else if opts.olderThan != "" {
-		dur, parseErr := parseHumanDuration(opts.olderThan)
-		if parseErr != nil {
-			return fmt.Errorf("invalid duration format: %w", parseErr)
-		}
-		archives, err = storageManager.ListOlderThan(dur)
-	} else {
-		archives, err = storageManager.List()
-	}
+	// Select archives matching the filters (pushed down to SQL where possible)
+	page, err := findListPage(cfg, opts)
 	if err != nil {
-		return fmt.Errorf("failed to list archives: %w", err)
+		return err
 	}
+	archives := page.Archives
+	defer printNextPageHint(page)
 
 	// Record query completion if metrics enabled
 	if metrics != nil {
@@ -251,31 +305,17 @@ func listRegistryArchivesWithDisplay(opts listFilters, mode display.Mode, metric
 		}
 	}
 
-	// Apply filters
-	archives = applyAllFilters(archives, opts)
-
 	// Use enhanced display system for supported modes
 	if mode == display.ModeTable || mode == display.ModeCompact || mode == display.ModeCard || mode == display.ModeTree || mode == display.ModeDashboard {
-		// Initialize display manager
-		displayManager := display.NewManager()
-
-		// Register available display modes
-		tableDisplay := modes.NewTableDisplay()
-		compactDisplay := modes.NewCompactDisplay()
-		cardDisplay := modes.NewCardDisplay()
-		treeDisplay := modes.NewTreeDisplay()
-		dashboardDisplay := modes.NewDashboardDisplay()
-		displayManager.Register(display.ModeTable, tableDisplay)
-		displayManager.Register(display.ModeCompact, compactDisplay)
-		displayManager.Register(display.ModeCard, cardDisplay)
-		displayManager.Register(display.ModeTree, treeDisplay)
-		displayManager.Register(display.ModeDashboard, dashboardDisplay)
+		// Initialize display manager with the available display modes
+		displayManager := newDisplayManager()
 
 		// Configure display options
 		displayOpts := display.Options{
 			Mode:        mode,
 			Details:     opts.details,
 			ShowHeaders: mode != display.ModeCompact, // No headers for compact by default
+			GroupBy:     opts.groupBy,
 		}
 
 		// Render using the display system
@@ -285,7 +325,7 @@ func listRegistryArchivesWithDisplay(opts listFilters, mode display.Mode, metric
 		if metrics != nil {
 			metrics.RecordRenderTime()
 			if opts.debug {
-				fmt.Printf("\n%s\n", metrics.String())
+				fmt.Fprintf(os.Stderr, "\n%s\n", metrics.String())
 			}
 		}
 		
@@ -297,176 +337,158 @@ func listRegistryArchivesWithDisplay(opts listFilters, mode display.Mode, metric
 	
 	// Show debug output for fallback display too
 	if metrics != nil && opts.debug {
-		fmt.Printf("\n%s\n", metrics.String())
+		fmt.Fprintf(os.Stderr, "\n%s\n", metrics.String())
 	}
 	
 	return err
 }
 
-// applyAllFilters applies all configured filters to the archive list
-func applyAllFilters(archives []*storage.Archive, opts listFilters) []*storage.Archive {
-	// Apply pattern filter
-	if opts.pattern != "" {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if matched, _ := filepath.Match(opts.pattern, a.Name); matched {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+// expression returns the filter expression described by the list flags
+func (opts listFilters) expression() (string, error) {
+	flags := filter.Flags{
+		NotUploaded: opts.notUploaded,
+		Pattern:     opts.pattern,
+		OlderThan:   opts.olderThan,
+		Managed:     opts.onlyManaged,
+		External:    opts.onlyExternal,
+		Missing:     opts.onlyMissing,
+		Deleted:     opts.onlyDeleted,
+		Status:      opts.status,
+		Profile:     opts.profile,
+		Tags:        opts.tags,
+		Where:       opts.where,
 	}
-
-	// Apply managed/external filter
-	if opts.onlyManaged || opts.onlyExternal {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if opts.onlyManaged && a.Managed {
-				filtered = append(filtered, a)
-			}
-			if opts.onlyExternal && !a.Managed {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
-	}
-
-	// Apply missing filter
-	if opts.onlyMissing {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if a.Status == "missing" {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+	if opts.largerThan > 0 {
+		flags.LargerThan = strconv.FormatInt(opts.largerThan, 10)
 	}
-
-	// Apply deleted filter
-	if opts.onlyDeleted {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if a.Status == "deleted" {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+	expr, err := flags.Expression()
+	if err != nil {
+		return "", err
 	}
-
-	// Apply status/profile/larger-than filters
-	archives = applyFilters(archives, struct {
-		status, profile string
-		largerThan      int64
-	}{opts.status, opts.profile, opts.largerThan})
-
-	return archives
+	return filter.And(expr, opts.expr), nil
 }
 
-// displayArchivesOriginal is the original display function (fallback)
-func displayArchivesOriginal(archives []*storage.Archive, opts listFilters) error {
-	if len(archives) == 0 {
-		fmt.Printf("No archives found.\n")
-		fmt.Printf("💡 Tip: Create archives with '7zarch-go create <path>' to see them here.\n")
-		return nil
+// compile parses the combined filter expression
+func (opts listFilters) compile() (*filter.Filter, error) {
+	expr, err := opts.expression()
+	if err != nil {
+		return nil, err
 	}
+	return filter.Parse(expr)
+}
 
-	// Group and summarize
-	var managedCount, externalCount, missingCount, deletedCount int
-	var activeManaged, activeExternal, deletedArchives []*storage.Archive
-
-	for _, a := range archives {
-		if a.Status == "deleted" {
-			deletedCount++
-			deletedArchives = append(deletedArchives, a)
-		} else if a.Managed {
-			managedCount++
-			activeManaged = append(activeManaged, a)
-		} else {
-			externalCount++
-			activeExternal = append(activeExternal, a)
-		}
-		if a.Status == "missing" {
-			missingCount++
-		}
-	}
+// addPageFlags registers the ordering and paging flags shared by list and query run
+func addPageFlags(cmd *cobra.Command) {
+	cmd.Flags().String("sort", "created", "Sort by: created|name|size (newest, A-Z and largest first)")
+	cmd.Flags().Bool("reverse", false, "Reverse the sort order")
+	cmd.Flags().Int("limit", 0, "Maximum number of archives to show (0 = no limit)")
+	cmd.Flags().Int("offset", 0, "Skip this many archives")
+	cmd.Flags().String("after", "", "Continue after a cursor printed by a previous --limit page")
+}
 
-	fmt.Printf("📦 Archives (%d found)\n", len(archives))
-	fmt.Printf("Active: %d (Managed: %d, External: %d) | Missing: %d | Deleted: %d\n\n",
-		managedCount+externalCount, managedCount, externalCount, missingCount, deletedCount)
+// readPageFlags copies the ordering and paging flags into opts
+func readPageFlags(cmd *cobra.Command, opts *listFilters) {
+	// An unset --sort leaves the order to the command, e.g. relevance for a
+	// saved query with a search term; Find treats it as created
+	if cmd.Flags().Changed("sort") {
+		opts.sortBy = getString(cmd, "sort")
+	}
+	opts.reverse = getBool(cmd, "reverse")
+	opts.limit = getInt(cmd, "limit")
+	opts.offset = getInt(cmd, "offset")
+	opts.after = getString(cmd, "after")
+}
 
-	// Delegate to existing printer
-	return printGroupedArchives(archives, opts.details)
+// criteria returns the ordering and paging requested by the list flags
+func (opts listFilters) criteria() (storage.Criteria, error) {
+	key, err := storage.ParseSortKey(opts.sortBy)
+	if err != nil {
+		return storage.Criteria{}, err
+	}
+	if opts.limit < 0 || opts.offset < 0 {
+		return storage.Criteria{}, fmt.Errorf("--limit and --offset must not be negative")
+	}
+	return storage.Criteria{
+		Sort:      storage.SortKey(opts.sortBy),
+		Ascending: (key == storage.SortName) != opts.reverse,
+		Limit:     opts.limit,
+		Offset:    opts.offset,
+		After:     opts.after,
+	}, nil
 }
 
-func listRegistryArchives(opts listFilters) error {
-	// Load configuration
-	cfg, err := config.Load()
+// findRegistryArchives selects one page of archives matching the list flags
+func findRegistryArchives(registry *storage.Registry, opts listFilters) (*storage.Page, error) {
+	f, err := opts.compile()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
-	// Initialize storage manager
-	storageManager, err := storage.NewManager(cfg.Storage.ManagedPath)
+	c, err := opts.criteria()
 	if err != nil {
-		return fmt.Errorf("failed to initialize managed storage: %w", err)
+		return nil, err
 	}
-	defer storageManager.Close()
+	page, err := registry.Find(context.Background(), f.Criteria(c))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+	return page, nil
+}
 
-	// Get archives based on filters
-	var archives []*storage.Archive
-	if opts.notUploaded {
-		archives, err = storageManager.ListNotUploaded()
-	} else if opts.olderThan != "" {
-		dur, parseErr := parseHumanDuration(opts.olderThan)
-		if parseErr != nil {
-			return fmt.Errorf("invalid duration format: %w", parseErr)
+// findListPage selects one page of archives from the selected vault, or with
+// --all-vaults from every vault merged into one list
+func findListPage(cfg *config.Config, opts listFilters) (*storage.Page, error) {
+	if !opts.allVaults {
+		mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize managed storage: %w", err)
 		}
-		archives, err = storageManager.ListOlderThan(dur)
-	} else {
-		archives, err = storageManager.List()
+		defer mgr.Close()
+		return findRegistryArchives(mgr.Registry(), opts)
 	}
+
+	f, err := opts.compile()
 	if err != nil {
-		return fmt.Errorf("failed to list archives: %w", err)
+		return nil, err
 	}
-
-	// Apply pattern filter
-	if opts.pattern != "" {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if matched, _ := filepath.Match(opts.pattern, a.Name); matched {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+	c, err := opts.criteria()
+	if err != nil {
+		return nil, err
 	}
-	// Apply managed/external filter
-	if opts.onlyManaged || opts.onlyExternal {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if opts.onlyManaged && a.Managed {
-				filtered = append(filtered, a)
-			}
-			if opts.onlyExternal && !a.Managed {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+	mgrs, cleanup, err := cmdutil.OpenVaults()
+	if err != nil {
+		return nil, err
 	}
-	// Apply missing filter
-	if opts.onlyMissing {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range archives {
-			if a.Status == "missing" {
-				filtered = append(filtered, a)
-			}
-		}
-		archives = filtered
+	defer cleanup()
+	regs := make(map[string]*storage.Registry, len(mgrs))
+	for name, m := range mgrs {
+		regs[name] = m.Registry()
 	}
+	archives, err := storage.FindAll(context.Background(), regs, f.Criteria(c))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+	return &storage.Page{Archives: archives}, nil
+}
 
-	// Simple filter block (status/profile/larger-than)
-	archives = applyFilters(archives, struct {
-		status, profile string
-		largerThan      int64
-	}{opts.status, opts.profile, opts.largerThan})
+// printNextPageHint tells the user how to fetch the following page. It goes
+// to stderr so machine-readable output stays clean.
+func printNextPageHint(page *storage.Page) {
+	if page.Next != "" {
+		fmt.Fprintf(os.Stderr, "\nMore archives available: --after %s\n", page.Next)
+	}
+}
 
+// applyAllFilters applies all configured filters to an in-memory archive list
+func applyAllFilters(archives []*storage.Archive, opts listFilters) ([]*storage.Archive, error) {
+	f, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	return f.Apply(archives), nil
+}
+
+// displayArchivesOriginal is the original display function (fallback)
+func displayArchivesOriginal(archives []*storage.Archive, opts listFilters) error {
 	if len(archives) == 0 {
 		fmt.Printf("No archives found.\n")
 		fmt.Printf("💡 Tip: Create archives with '7zarch-go create <path>' to see them here.\n")
@@ -497,46 +519,10 @@ func listRegistryArchives(opts listFilters) error {
 	fmt.Printf("Active: %d (Managed: %d, External: %d) | Missing: %d | Deleted: %d\n\n",
 		managedCount+externalCount, managedCount, externalCount, missingCount, deletedCount)
 
-	// Delegate to existing printer to keep behavior identical
+	// Delegate to existing printer
 	return printGroupedArchives(archives, opts.details)
 }
 
-// applyFilters applies status/profile/largerThan filters in sequence
-func applyFilters(archives []*storage.Archive, filters struct {
-	status, profile string
-	largerThan      int64
-}) []*storage.Archive {
-	result := archives
-	if filters.status != "" {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range result {
-			if a.Status == filters.status {
-				filtered = append(filtered, a)
-			}
-		}
-		result = filtered
-	}
-	if filters.profile != "" {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range result {
-			if a.Profile == filters.profile {
-				filtered = append(filtered, a)
-			}
-		}
-		result = filtered
-	}
-	if filters.largerThan > 0 {
-		filtered := make([]*storage.Archive, 0)
-		for _, a := range result {
-			if a.Size > filters.largerThan {
-				filtered = append(filtered, a)
-			}
-		}
-		result = filtered
-	}
-	return result
-}
-
 // printGroupedArchives prints groups and summary (same behavior as before)
 func printGroupedArchives(archives []*storage.Archive, details bool) error {
 	// Group and summarize
@@ -592,6 +578,15 @@ func printGroupedArchives(archives []*storage.Archive, details bool) error {
 }
 
 func printArchiveTable(archives []*storage.Archive, details bool) {
+	// Archives listed across vaults lead with their vault
+	vaultCol := func(*storage.Archive) {}
+	for _, a := range archives {
+		if a.Vault != "" {
+			fmt.Printf("%-10s  ", "Vault")
+			vaultCol = func(a *storage.Archive) { fmt.Printf("%-10s  ", a.Vault) }
+			break
+		}
+	}
 	// Headers
 	if details {
 		fmt.Printf("%-12s  %-30s  %8s  %-10s  %-19s  %-7s\n", "ID", "Name", "Size", "Profile", "Created", "Status")
@@ -599,6 +594,7 @@ func printArchiveTable(archives []*storage.Archive, details bool) {
 		fmt.Printf("%-12s  %-30s  %8s  %-7s\n", "ID", "Name", "Size", "Status")
 	}
 	for _, a := range archives {
+		vaultCol(a)
 		id := a.UID
 		if len(id) > 12 {
 			id = id[:12]
@@ -691,21 +687,9 @@ func listManagedArchives(details, notUploaded bool, pattern, olderThan string) e
 func listDirectory(directory string, details bool, pattern string) error {
 	fmt.Printf("📁 Listing .7z files in: %s\n\n", directory)
 
-	// Find .7z files in directory
-	matches, err := filepath.Glob(filepath.Join(directory, "*.7z"))
+	matches, err := directoryArchives(directory, pattern)
 	if err != nil {
-		return fmt.Errorf("failed to scan directory: %w", err)
-	}
-
-	// Apply pattern filter if specified
-	if pattern != "" {
-		filtered := make([]string, 0)
-		for _, match := range matches {
-			if matched, _ := filepath.Match(pattern, filepath.Base(match)); matched {
-				filtered = append(filtered, match)
-			}
-		}
-		matches = filtered
+		return err
 	}
 
 	if len(matches) == 0 {
@@ -733,6 +717,44 @@ func listDirectory(directory string, details bool, pattern string) error {
 	return nil
 }
 
+// directoryArchives returns the .7z files in directory matching pattern
+func directoryArchives(directory, pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(directory, "*.7z"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	// Apply pattern filter if specified
+	if pattern != "" {
+		filtered := make([]string, 0)
+		for _, match := range matches {
+			if matched, _ := filepath.Match(pattern, filepath.Base(match)); matched {
+				filtered = append(filtered, match)
+			}
+		}
+		matches = filtered
+	}
+	return matches, nil
+}
+
+// listDirectoryResult writes the .7z files in directory as unregistered
+// archives
+func listDirectoryResult(p *output.Printer, directory, pattern string) error {
+	matches, err := directoryArchives(directory, pattern)
+	if err != nil {
+		return err
+	}
+	archives := make([]*storage.Archive, 0, len(matches))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		archives = append(archives, &storage.Archive{Name: filepath.Base(path), Path: path, Size: info.Size(), Created: info.ModTime()})
+	}
+	return p.Result(archivesResult{Archives: archives})
+}
+
 func displayArchive(archive *storage.Archive, details bool) {
 	// Show upload status
 	status := "📤 Not uploaded"
@@ -821,48 +843,31 @@ func formatDuration(d time.Duration) string {
 }
 
 // listRegistryArchivesWithOutput handles machine-readable output formats
-func listRegistryArchivesWithOutput(opts listFilters, format string) error {
+func listRegistryArchivesWithOutput(w io.Writer, opts listFilters, format string) error {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Initialize storage manager
-	storageManager, err := storage.NewManager(cfg.Storage.ManagedPath)
+	// Select archives matching the filters (pushed down to SQL where possible)
+	page, err := findListPage(cfg, opts)
 	if err != nil {
-		return fmt.Errorf("failed to initialize managed storage: %w", err)
-	}
-	defer storageManager.Close()
-
-	// Get archives based on filters
-	var archives []*storage.Archive
-	if opts.notUploaded {
-		archives, err = storageManager.ListNotUploaded()
-	} else if opts.olderThan != "" {
-		dur, parseErr := parseHumanDuration(opts.olderThan)
-		if parseErr != nil {
-			return fmt.Errorf("invalid duration format: %w", parseErr)
-		}
-		archives, err = storageManager.ListOlderThan(dur)
-	} else {
-		archives, err = storageManager.List()
+		return err
 	}
-	if err != nil {
-		return fmt.Errorf("failed to list archives: %w", err)
+	archives := page.Archives
+	if format != "table" {
+		defer printNextPageHint(page)
 	}
 
-	// Apply filters
-	archives = applyAllFilters(archives, opts)
-
 	// Output in requested format
 	switch format {
 	case "json":
-		return outputJSON(archives)
+		return outputJSON(w, archives)
 	case "csv":
-		return outputCSV(archives)
+		return outputCSV(w, archives)
 	case "yaml":
-		return outputYAML(archives)
+		return outputYAML(w, archives)
 	case "table":
 		// Fall back to display system
 		return listRegistryArchivesWithDisplay(opts, display.ModeTable, nil)
@@ -871,14 +876,14 @@ func listRegistryArchivesWithOutput(opts listFilters, format string) error {
 	}
 }
 
-func outputJSON(archives []*storage.Archive) error {
-	enc := json.NewEncoder(os.Stdout)
+func outputJSON(w io.Writer, archives []*storage.Archive) error {
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	return enc.Encode(archives)
 }
 
-func outputCSV(archives []*storage.Archive) error {
-	writer := csv.NewWriter(os.Stdout)
+func outputCSV(w io.Writer, archives []*storage.Archive) error {
+	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
 	// Write header
@@ -929,8 +934,8 @@ func outputCSV(archives []*storage.Archive) error {
 	return nil
 }
 
-func outputYAML(archives []*storage.Archive) error {
-	enc := yaml.NewEncoder(os.Stdout)
+func outputYAML(w io.Writer, archives []*storage.Archive) error {
+	enc := yaml.NewEncoder(w)
 	defer enc.Close()
 	return enc.Encode(archives)
 }
@@ -955,14 +960,26 @@ func runListWithSavedQuery(cmd *cobra.Command, queryName, saveQueryName string)
 	queryManager := query.NewQueryManager(storageManager.Registry().DB(), resolver)
 
 	// Run the saved query
-	archives, err := queryManager.Run(queryName)
+	var opts listFilters
+	readPageFlags(cmd, &opts)
+	c, err := opts.criteria()
+	if err != nil {
+		return err
+	}
+	page, err := queryManager.RunPage(context.Background(), queryName, c)
 	if err != nil {
 		return fmt.Errorf("failed to run query '%s': %w", queryName, err)
 	}
+	archives := page.Archives
+	defer printNextPageHint(page)
 
 	// If save-query flag is also set, this doesn't make sense with --query, so warn
 	if saveQueryName != "" {
-		fmt.Printf("⚠️  Warning: --save-query ignored when using --query\n")
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: --save-query ignored when using --query\n")
+	}
+
+	if p := printerFor(cmd); p.Structured() {
+		return p.Result(listed(archives, page.Next))
 	}
 
 	// Check for output format first
@@ -970,11 +987,11 @@ func runListWithSavedQuery(cmd *cobra.Command, queryName, saveQueryName string)
 	if outputFormat != "" {
 		switch outputFormat {
 		case "json":
-			return outputJSON(archives)
+			return outputJSON(printerFor(cmd).Text(), archives)
 		case "csv":
-			return outputCSV(archives)
+			return outputCSV(printerFor(cmd).Text(), archives)
 		case "yaml":
-			return outputYAML(archives)
+			return outputYAML(printerFor(cmd).Text(), archives)
 		default:
 			return fmt.Errorf("unsupported output format: %s", outputFormat)
 		}
@@ -992,45 +1009,17 @@ func runListWithSavedQuery(cmd *cobra.Command, queryName, saveQueryName string)
 	return printGroupedArchives(archives, getBool(cmd, "details"))
 }
 
-// saveCurrentFiltersAsQuery converts listFilters to a map and saves as query
+// saveCurrentFiltersAsQuery saves the listFilters expression as a named query
 func saveCurrentFiltersAsQuery(opts listFilters, queryName string) error {
-	// Convert listFilters to map format expected by query system
-	filters := make(map[string]string)
-
-	if opts.notUploaded {
-		filters["not-uploaded"] = "true"
-	}
-	if opts.pattern != "" {
-		filters["pattern"] = opts.pattern
-	}
-	if opts.olderThan != "" {
-		filters["older-than"] = opts.olderThan
-	}
-	if opts.onlyManaged {
-		filters["managed"] = "true"
-	}
-	if opts.onlyExternal {
-		filters["external"] = "true"
-	}
-	if opts.onlyMissing {
-		filters["missing"] = "true"
-	}
-	if opts.onlyDeleted {
-		filters["deleted"] = "true"
-	}
-	if opts.status != "" {
-		filters["status"] = opts.status
-	}
-	if opts.profile != "" {
-		filters["profile"] = opts.profile
-	}
-	if opts.largerThan > 0 {
-		filters["larger-than"] = fmt.Sprintf("%d", opts.largerThan)
+	// Saved queries store the filter expression
+	expr, err := opts.expression()
+	if err != nil {
+		return err
 	}
-
-	if len(filters) == 0 {
+	if expr == "" {
 		return fmt.Errorf("no filters specified - cannot save empty query")
 	}
+	filters := map[string]string{query.FilterKey: expr}
 
 	// Load configuration and initialize query manager
 	cfg, err := config.Load()
@@ -1,16 +1,19 @@
 package cmd
 
 import (
-	"github.com/adamstac/7zarch-go/internal/storage"
+	"fmt"
 	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
 )
 
 func TestApplyFilters_Status(t *testing.T) {
 	archives := []*storage.Archive{{Status: "present"}, {Status: "missing"}, {Status: "deleted"}}
-	filtered := applyFilters(archives, struct {
-		status, profile string
-		largerThan      int64
-	}{"missing", "", 0})
+	filtered, err := applyAllFilters(archives, listFilters{status: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(filtered) != 1 || filtered[0].Status != "missing" {
 		t.Fatalf("expected 1 missing, got %d", len(filtered))
 	}
@@ -18,10 +21,10 @@ func TestApplyFilters_Status(t *testing.T) {
 
 func TestApplyFilters_Profile(t *testing.T) {
 	archives := []*storage.Archive{{Profile: "A"}, {Profile: "B"}, {Profile: "A"}}
-	filtered := applyFilters(archives, struct {
-		status, profile string
-		largerThan      int64
-	}{"", "A", 0})
+	filtered, err := applyAllFilters(archives, listFilters{profile: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(filtered) != 2 {
 		t.Fatalf("expected 2 with profile A, got %d", len(filtered))
 	}
@@ -29,11 +32,61 @@ func TestApplyFilters_Profile(t *testing.T) {
 
 func TestApplyFilters_LargerThan(t *testing.T) {
 	archives := []*storage.Archive{{Size: 10}, {Size: 20}, {Size: 5}}
-	filtered := applyFilters(archives, struct {
-		status, profile string
-		largerThan      int64
-	}{"", "", 10})
+	filtered, err := applyAllFilters(archives, listFilters{largerThan: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(filtered) != 1 || filtered[0].Size != 20 {
 		t.Fatalf("expected only size 20, got %d entries", len(filtered))
 	}
 }
+
+func TestApplyFilters_Expression(t *testing.T) {
+	archives := []*storage.Archive{
+		{Name: "a", Size: 2 << 30, Profile: "media", Status: "present"},
+		{Name: "b", Size: 2 << 30, Profile: "media", Status: "present", Uploaded: true},
+		{Name: "c", Size: 10, Profile: "media", Status: "present"},
+	}
+	filtered, err := applyAllFilters(archives, listFilters{expr: "size > 1GB and profile = media and not uploaded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0].Name != "a" {
+		t.Fatalf("expected only archive a, got %d entries", len(filtered))
+	}
+	if _, err := applyAllFilters(archives, listFilters{expr: "size >"}); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+}
+
+func TestFindRegistryArchivesPages(t *testing.T) {
+	mgr := setupManagedStore(t)
+	for i, size := range []int64{30, 10, 20} {
+		a := &storage.Archive{UID: fmt.Sprintf("uid-%d", i), Name: fmt.Sprintf("a%d.7z", i), Path: fmt.Sprintf("/x/a%d.7z", i), Size: size, Status: "present", Created: time.Now()}
+		if err := mgr.Registry().Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := listFilters{sortBy: "size", limit: 2}
+	page, err := findRegistryArchives(mgr.Registry(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Archives) != 2 || page.Archives[0].Size != 30 || page.Next == "" {
+		t.Fatalf("unexpected first page: %d archives, next %q", len(page.Archives), page.Next)
+	}
+
+	opts.after = page.Next
+	page, err = findRegistryArchives(mgr.Registry(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Archives) != 1 || page.Archives[0].Size != 10 || page.Next != "" {
+		t.Fatalf("unexpected last page: %d archives, next %q", len(page.Archives), page.Next)
+	}
+
+	if _, err := findRegistryArchives(mgr.Registry(), listFilters{sortBy: "colour"}); err == nil {
+		t.Fatal("expected error for unknown sort key")
+	}
+}
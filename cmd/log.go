@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/output"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// LogCmd returns the `log` command browsing the registry change journal
+func LogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log [archive]",
+		Short: "Show the journal of registry changes",
+		Long: `Show recorded changes to the registry, newest first. Every addition, update,
+move, delete, restore, tag and metadata change is journaled with who made it,
+when, and the archive's state before and after. Use 'undo <event-id>' to
+reverse a change.`,
+		Example: `  7zarch-go log
+  7zarch-go log 01K2E3 --details
+  7zarch-go log --action delete --since 7d`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			p := printerFor(cmd)
+			f := storage.EventFilter{Action: getString(cmd, "action"), Limit: getInt(cmd, "limit")}
+			if len(args) == 1 {
+				arc, err := storage.NewResolver(mgr.Registry()).Resolve(args[0])
+				if err != nil {
+					if amb, ok := err.(*storage.AmbiguousIDError); ok && !p.Structured() {
+						printAmbiguousOptions(amb)
+					}
+					return cmdutil.HandleResolverError(err, args[0])
+				}
+				f.ArchiveID = arc.ID
+			}
+			if since := getString(cmd, "since"); since != "" {
+				age, err := filter.ParseAge(since)
+				if err != nil {
+					return fmt.Errorf("invalid --since: %w", err)
+				}
+				f.Since = time.Now().Add(-age)
+			}
+
+			events, err := mgr.Registry().Events(f)
+			if err != nil {
+				return err
+			}
+			if p.Structured() {
+				if events == nil {
+					events = []*storage.Event{}
+				}
+				return p.Result(struct {
+					Events []*storage.Event `json:"events"`
+				}{events})
+			}
+			switch getString(cmd, "output") {
+			case "":
+				printEvents(cmd.OutOrStdout(), events, getBool(cmd, "details"))
+				return nil
+			case "json":
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(events)
+			default:
+				return fmt.Errorf("unsupported output format: %s", getString(cmd, "output"))
+			}
+		},
+	}
+	cmd.Flags().String("action", "", "Only show one kind of change (add, update, move, delete, restore, remove, tag, untag, meta, undo)")
+	cmd.Flags().String("since", "", "Only show changes newer than an age (e.g. 7d, 2w)")
+	cmd.Flags().Int("limit", 50, "Maximum number of events to show (0 = all)")
+	cmd.Flags().Bool("details", false, "List every changed field")
+	cmd.Flags().String("output", "", "Output format: json (default: table)")
+	deprecateOutputFlag(cmd)
+	return output.Annotate(cmd)
+}
+
+func printEvents(out io.Writer, events []*storage.Event, details bool) {
+	if len(events) == 0 {
+		fmt.Fprintln(out, "No changes recorded.")
+		return
+	}
+	fmt.Fprintf(out, "%-6s %-19s %-16s %-8s %-8s %-24s %s\n", "ID", "TIME", "ACTOR", "ACTION", "UID", "NAME", "CHANGE")
+	for _, ev := range events {
+		changes := ev.Changes()
+		summary := "—"
+		if len(changes) > 0 {
+			summary = changes[0]
+			if len(changes) > 1 && !details {
+				summary += fmt.Sprintf(" (+%d more)", len(changes)-1)
+			}
+		}
+		if ev.Undoes != 0 {
+			summary = fmt.Sprintf("undo #%d", ev.Undoes)
+		}
+		if ev.UndoneBy != 0 {
+			summary += fmt.Sprintf(" [undone by #%d]", ev.UndoneBy)
+		}
+		fmt.Fprintf(out, "%-6d %-19s %-16s %-8s %-8s %-24s %s\n", ev.ID, ev.Time.Format("2006-01-02 15:04:05"),
+			truncate(ev.Actor, 16), ev.Action, safePrefix(ev.ArchiveUID, 8), truncate(ev.ArchiveName, 24), summary)
+		if details {
+			start := 1
+			if ev.Undoes != 0 {
+				start = 0
+			}
+			for _, c := range changes[min(start, len(changes)):] {
+				fmt.Fprintf(out, "%-6s %s\n", "", c)
+			}
+		}
+	}
+	fmt.Fprintf(out, "\n%d events\n", len(events))
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(rs[:n-1])) + "…"
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestLogAndUndoTrash(t *testing.T) {
+	mgr := setupManagedStore(t)
+
+	path := filepath.Join(mgr.GetArchivesPath(), "report.7z")
+	if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	arc := &storage.Archive{Name: "report.7z", Path: path, Size: 1, Created: time.Now(), Managed: true}
+	if err := mgr.Register(arc); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Trash(arc); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, LogCmd())
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if !containsAll(out, []string{"delete", "add", "report.7z", "status: present → deleted", "2 events"}) {
+		t.Fatalf("unexpected log output:\n%s", out)
+	}
+
+	dry := UndoCmd()
+	_ = dry.Flags().Set("dry-run", "true")
+	out, err = runEWithArgs(t, dry)
+	if err != nil {
+		t.Fatalf("undo --dry-run: %v", err)
+	}
+	if !strings.Contains(out, "Would undo") || !strings.Contains(out, "move ") {
+		t.Fatalf("unexpected dry-run output:\n%s", out)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("dry run moved the file")
+	}
+
+	if out, err = runEWithArgs(t, UndoCmd()); err != nil {
+		t.Fatalf("undo: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file not restored from trash: %v", err)
+	}
+	got, err := mgr.Registry().GetByID(arc.ID)
+	if err != nil || got.Status != "present" || got.Path != path {
+		t.Fatalf("archive after undo = %+v, %v", got, err)
+	}
+
+	logCmd := LogCmd()
+	_ = logCmd.Flags().Set("action", "delete")
+	out, err = runEWithArgs(t, logCmd, arc.UID)
+	if err != nil {
+		t.Fatalf("log --action: %v", err)
+	}
+	if !strings.Contains(out, "[undone by #") || !strings.Contains(out, "1 events") {
+		t.Fatalf("unexpected filtered log:\n%s", out)
+	}
+
+	// Only the addition is left, which cannot be undone
+	if _, err := runEWithArgs(t, UndoCmd()); err == nil {
+		t.Fatal("expected nothing left to undo")
+	}
+}
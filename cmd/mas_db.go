@@ -1,35 +1,63 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 func MasDbCmd() *cobra.Command {
-	cmd := &cobra.Command{Use: "db", Short: "Database operations (status, migrate, backup)"}
+	cmd := &cobra.Command{Use: "db", Short: "Database operations (status, migrate, rollback, backup, restore, export, import)"}
 	cmd.AddCommand(masDbStatusCmd())
 	cmd.AddCommand(masDbMigrateCmd())
+	cmd.AddCommand(masDbRollbackCmd())
 	cmd.AddCommand(masDbBackupCmd())
+	cmd.AddCommand(masDbRestoreCmd())
+	cmd.AddCommand(masDbExportCmd())
+	cmd.AddCommand(masDbImportCmd())
+	cmd.AddCommand(masDbReorganizeCmd())
 	return cmd
 }
 
+// dbStatus is the structured result of db status
+type dbStatus struct {
+	Database string        `json:"database"`
+	Version  string        `json:"version,omitempty"` // latest applied migration
+	Size     int64         `json:"size"`
+	Applied  []dbMigration `json:"applied"`
+	Pending  []dbMigration `json:"pending"`
+}
+
+type dbMigration struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Unknown     bool       `json:"unknown,omitempty"`  // applied by a newer release
+	Modified    bool       `json:"modified,omitempty"` // checksum mismatch
+}
+
 func masDbStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	return output.Annotate(&cobra.Command{
 		Use:   "status",
 		Short: "Show database version and applied migrations",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, _ := config.Load()
-			mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
+			runner, dbPath, err := openMigrationRunner()
 			if err != nil {
 				return err
 			}
-			defer mgr.Close()
-
-			runner := mgr.NewMigrationRunner()
+			defer runner.Close()
+			out := cmd.OutOrStdout()
 
 			applied, err := runner.GetAppliedMigrations()
 			if err != nil {
@@ -41,119 +69,421 @@ func masDbStatusCmd() *cobra.Command {
 				return fmt.Errorf("failed to get pending migrations: %w", err)
 			}
 
-			fmt.Printf("Database: %s\n", mgr.Registry().Path())
+			if p := printerFor(cmd); p.Structured() {
+				status := dbStatus{Database: dbPath, Applied: []dbMigration{}, Pending: []dbMigration{}}
+				for _, m := range applied {
+					appliedAt := m.AppliedAt
+					status.Applied = append(status.Applied, dbMigration{ID: m.ID, Name: m.Name, AppliedAt: &appliedAt,
+						Unknown: !m.Known, Modified: m.Modified})
+					status.Version = m.ID
+				}
+				for _, m := range pending {
+					status.Pending = append(status.Pending, dbMigration{ID: m.ID, Name: m.Name, Description: m.Description})
+				}
+				if stat, err := os.Stat(dbPath); err == nil {
+					status.Size = stat.Size()
+				}
+				return p.Result(status)
+			}
+
+			fmt.Fprintf(out, "Database: %s\n", dbPath)
 
 			if len(applied) > 0 {
 				latestMigration := applied[len(applied)-1]
-				fmt.Printf("Schema Version: %s\n", latestMigration.ID)
+				fmt.Fprintf(out, "Schema Version: %s\n", latestMigration.ID)
 			} else {
-				fmt.Printf("Schema Version: (none applied)\n")
+				fmt.Fprintf(out, "Schema Version: (none applied)\n")
 			}
 
-			fmt.Printf("Applied Migrations: %d\n", len(applied))
+			fmt.Fprintf(out, "Applied Migrations: %d\n", len(applied))
 			for _, migration := range applied {
-				fmt.Printf("  ✓ %s: %s (applied %s)\n",
+				mark, note := "✓", ""
+				switch {
+				case !migration.Known:
+					mark, note = "?", " [unknown to this version]"
+				case migration.Modified:
+					mark, note = "!", " [checksum mismatch]"
+				}
+				fmt.Fprintf(out, "  %s %s: %s (applied %s)%s\n",
+					mark,
 					migration.ID,
 					migration.Name,
-					migration.AppliedAt.Format("2006-01-02 15:04:05"))
+					migration.AppliedAt.Format("2006-01-02 15:04:05"),
+					note)
 			}
 
 			if len(pending) > 0 {
-				fmt.Printf("Pending Migrations: %d\n", len(pending))
+				fmt.Fprintf(out, "Pending Migrations: %d\n", len(pending))
 				for _, migration := range pending {
-					fmt.Printf("  - %s: %s\n", migration.ID, migration.Description)
+					fmt.Fprintf(out, "  - %s: %s\n", migration.ID, migration.Description)
 				}
 			} else {
-				fmt.Printf("Pending Migrations: 0\n")
+				fmt.Fprintf(out, "Pending Migrations: 0\n")
 			}
 
 			// Get database size
-			if stat, err := os.Stat(mgr.Registry().Path()); err == nil {
-				fmt.Printf("Database Size: %.1f KB\n", float64(stat.Size())/1024)
+			if stat, err := os.Stat(dbPath); err == nil {
+				fmt.Fprintf(out, "Database Size: %.1f KB\n", float64(stat.Size())/1024)
 			}
 
 			return nil
 		},
-	}
+	})
 }
 
 func masDbMigrateCmd() *cobra.Command {
 	var dryRun bool
 	var backupOnly bool
+	var to string
 	cmd := &cobra.Command{
 		Use:   "migrate",
-		Short: "Apply pending migrations",
+		Short: "Apply pending migrations, or move the schema to a given version",
+		Long: `Apply pending migrations. With --to, migrate up or down to the given
+version (an ID such as 0007_tags, or its number). A backup of the registry is
+taken before any change.
+
+Any other command migrates the registry back up to the latest version, so
+migrating down is only useful before running an older release.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, _ := config.Load()
-			mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
+			runner, dbPath, err := openMigrationRunner()
 			if err != nil {
 				return err
 			}
-			defer mgr.Close()
+			defer runner.Close()
+			out := cmd.OutOrStdout()
 
-			runner := mgr.NewMigrationRunner()
+			if !dryRun && !backupOnly {
+				lock, err := storage.LockRegistry(dbPath, "db migrate")
+				if err != nil {
+					return err
+				}
+				defer lock.Release()
+			}
 
 			if backupOnly {
-				backupPath, err := runner.CreateBackup(mgr.Registry().Path())
+				backupPath, err := runner.CreateBackup(dbPath)
 				if err != nil {
 					return fmt.Errorf("backup failed: %w", err)
 				}
-				fmt.Printf("Backup created: %s\n", backupPath)
+				fmt.Fprintf(out, "Backup created: %s\n", backupPath)
 				return nil
 			}
 
-			pending, err := runner.GetPendingMigrations()
-			if err != nil {
-				return fmt.Errorf("failed to get pending migrations: %w", err)
+			target := to
+			if target == "" {
+				all := storage.Migrations()
+				target = all[len(all)-1].ID
 			}
-
-			if len(pending) == 0 {
-				fmt.Println("No pending migrations")
-				return nil
+			steps, err := runner.Plan(target)
+			if err != nil {
+				return err
 			}
-
-			if dryRun {
-				fmt.Printf("Dry run: would apply %d migration(s)\n", len(pending))
-				for _, migration := range pending {
-					fmt.Printf("  - %s: %s\n", migration.ID, migration.Description)
+			if len(steps) == 0 {
+				if to != "" {
+					fmt.Fprintf(out, "Schema is already at %s\n", to)
+				} else {
+					fmt.Fprintln(out, "No pending migrations")
 				}
 				return nil
 			}
+			return runMigrationSteps(out, runner, dbPath, steps, dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done")
+	cmd.Flags().BoolVar(&backupOnly, "backup-only", false, "Create a backup without migrating")
+	cmd.Flags().StringVar(&to, "to", "", "Migrate up or down to this version")
+	return cmd
+}
 
-			fmt.Printf("Applying %d pending migration(s)...\n", len(pending))
+func masDbRollbackCmd() *cobra.Command {
+	var dryRun bool
+	var steps int
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back the most recently applied migrations",
+		Long: `Roll back the newest applied migration, or the newest --steps migrations.
+A backup of the registry is taken first. Rolling back drops the tables and
+columns the migration added, along with their data.
+
+Any other command migrates the registry back up to the latest version, so
+rollback is only useful before running an older release.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runner, dbPath, err := openMigrationRunner()
+			if err != nil {
+				return err
+			}
+			defer runner.Close()
 
-			if err := runner.ApplyPending(mgr.Registry().Path()); err != nil {
-				return fmt.Errorf("migration failed: %w", err)
+			if !dryRun {
+				lock, err := storage.LockRegistry(dbPath, "db rollback")
+				if err != nil {
+					return err
+				}
+				defer lock.Release()
 			}
 
-			fmt.Println("✓ Migrations completed successfully")
-			return nil
+			plan, err := runner.PlanRollback(steps)
+			if err != nil {
+				return err
+			}
+			return runMigrationSteps(cmd.OutOrStdout(), runner, dbPath, plan, dryRun)
 		},
 	}
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done")
-	cmd.Flags().BoolVar(&backupOnly, "backup-only", false, "Create a backup without migrating")
+	cmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to roll back")
 	return cmd
 }
 
+// openMigrationRunner opens the configured registry without migrating it
+func openMigrationRunner() (*storage.MigrationRunner, string, error) {
+	cfg, _ := config.Load()
+	dbPath, err := storage.RegistryPath(cfg.Storage.ManagedPath)
+	if err != nil {
+		return nil, "", err
+	}
+	runner, err := storage.OpenMigrationRunner(dbPath)
+	if err != nil {
+		return nil, "", err
+	}
+	runner.SetBackupDir(cfg.Storage.BackupDir)
+	return runner, dbPath, nil
+}
+
+func runMigrationSteps(out io.Writer, runner *storage.MigrationRunner, dbPath string, steps []storage.MigrationStep, dryRun bool) error {
+	if dryRun {
+		fmt.Fprintf(out, "Dry run: would run %d migration(s)\n", len(steps))
+	} else {
+		fmt.Fprintf(out, "Running %d migration(s)...\n", len(steps))
+	}
+	for _, s := range steps {
+		verb := "apply"
+		if s.Rollback {
+			verb = "roll back"
+		}
+		fmt.Fprintf(out, "  - %s %s: %s\n", verb, s.ID, s.Description)
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := runner.Run(dbPath, steps); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	fmt.Fprintln(out, "✓ Migrations completed successfully")
+	return nil
+}
+
 func masDbBackupCmd() *cobra.Command {
-	return &cobra.Command{
+	var list bool
+	cmd := &cobra.Command{
 		Use:   "backup",
 		Short: "Create a timestamped backup of the registry database",
+		Long: `Create a consistent backup of the registry, safe while other commands
+are writing, then remove the oldest backups beyond storage.backup_keep.
+Backups are kept in storage.backup_dir (default <managed_path>/backups).`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _ := config.Load()
+			out := cmd.OutOrStdout()
+			dir, err := storage.BackupDirFor(cfg.Storage.ManagedPath, cfg.Storage.BackupDir)
+			if err != nil {
+				return err
+			}
+
+			if list {
+				backups, err := storage.ListBackups(dir)
+				if err != nil {
+					return err
+				}
+				if len(backups) == 0 {
+					fmt.Fprintf(out, "No backups in %s\n", dir)
+					return nil
+				}
+				for _, b := range backups {
+					fmt.Fprintf(out, "%s  %s  %.1f KB\n", b.Created.Format("2006-01-02 15:04:05"), filepath.Base(b.Path), float64(b.Size)/1024)
+				}
+				fmt.Fprintf(out, "%d backups in %s\n", len(backups), dir)
+				return nil
+			}
+
 			mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
 			if err != nil {
 				return err
 			}
 			defer mgr.Close()
+			mgr.SetBackupDir(dir)
 
-			runner := mgr.NewMigrationRunner()
-			backupPath, err := runner.CreateBackup(mgr.Registry().Path())
+			backupPath, err := mgr.Backup()
 			if err != nil {
 				return fmt.Errorf("backup failed: %w", err)
 			}
-			fmt.Printf("Backup created: %s\n", backupPath)
+			fmt.Fprintf(out, "Backup created: %s\n", backupPath)
+			if cfg.Storage.BackupKeep > 0 {
+				removed, err := storage.PruneBackups(dir, cfg.Storage.BackupKeep)
+				if err != nil {
+					return err
+				}
+				if len(removed) > 0 {
+					fmt.Fprintf(out, "Removed %d old backup(s)\n", len(removed))
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&list, "list", false, "List existing backups, newest first")
+	return cmd
+}
+
+func masDbRestoreCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "restore <backup>",
+		Short: "Replace the registry with a backup",
+		Long: `Replace the registry with a backup after checking its integrity. The
+backup is a path, a file name in the backup directory, or "latest". The
+registry being replaced is backed up first, and a backup from an older
+release is migrated the next time the registry is opened.
+
+No other 7zarch-go command should be running during a restore.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _ := config.Load()
+			out := cmd.OutOrStdout()
+			dir, err := storage.BackupDirFor(cfg.Storage.ManagedPath, cfg.Storage.BackupDir)
+			if err != nil {
+				return err
+			}
+			dbPath, err := storage.RegistryPath(cfg.Storage.ManagedPath)
+			if err != nil {
+				return err
+			}
+
+			path, err := resolveBackup(dir, args[0])
+			if err != nil {
+				return err
+			}
+			if err := storage.VerifyBackup(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Backup %s passed the integrity check\n", path)
+
+			if !force {
+				fmt.Fprintf(out, "Replace %s with this backup? [y/N]: ", dbPath)
+				reader := bufio.NewReader(os.Stdin)
+				line, _ := reader.ReadString('\n')
+				line = strings.TrimSpace(strings.ToLower(line))
+				if line != "y" && line != "yes" {
+					fmt.Fprintln(out, "Aborted.")
+					return nil
+				}
+			}
+
+			lock, err := storage.LockRegistry(dbPath, "db restore")
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			saved, err := storage.RestoreBackup(path, dbPath, dir)
+			if err != nil {
+				return fmt.Errorf("restore failed: %w", err)
+			}
+			if saved != "" {
+				fmt.Fprintf(out, "Previous registry saved to %s\n", saved)
+			}
+			fmt.Fprintln(out, "✓ Registry restored")
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Skip confirmation prompt")
+	return cmd
+}
+
+// resolveBackup finds a backup given as a path, a name in dir, or "latest"
+func resolveBackup(dir, arg string) (string, error) {
+	if arg == "latest" {
+		backups, err := storage.ListBackups(dir)
+		if err != nil {
+			return "", err
+		}
+		if len(backups) == 0 {
+			return "", fmt.Errorf("no backups in %s", dir)
+		}
+		return backups[0].Path, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+	if path := filepath.Join(dir, arg); filepath.Base(arg) == arg {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("backup not found: %s", arg)
+}
+
+func masDbReorganizeCmd() *cobra.Command {
+	var dryRun bool
+	var layoutFlag string
+	cmd := &cobra.Command{
+		Use:   "reorganize",
+		Short: "Move managed archives into the configured storage layout",
+		Long: `Move existing managed archives into the layout set by storage.auto_organize
+(flat, by_date, by_type). Archives move with their .log and .sha256 files and
+registry paths are updated in a single transaction; if any move fails, files
+already moved are put back and nothing is changed. Archives whose file is
+missing are listed and left alone.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if layoutFlag != "" {
+				layout, err := storage.ParseLayout(layoutFlag)
+				if err != nil {
+					return err
+				}
+				mgr.SetLayout(layout)
+			}
+
+			if !dryRun {
+				lock, err := mgr.Lock("db reorganize")
+				if err != nil {
+					return err
+				}
+				defer lock.Release()
+			}
+
+			moves, missing, err := mgr.PlanReorganize()
+			if err != nil {
+				return fmt.Errorf("failed to plan reorganize: %w", err)
+			}
+			for _, a := range missing {
+				fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Warning: skipping %s: file missing at %s\n", a.Name, a.Path)
+			}
+			if len(moves) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Managed storage already uses the %s layout\n", mgr.Layout())
+				return nil
+			}
+
+			if dryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Dry run: would move %d archive(s) into %s layout\n", len(moves), mgr.Layout())
+				for _, mv := range moves {
+					fmt.Fprintf(cmd.OutOrStdout(), "  %s -> %s\n", mv.From, mv.To)
+				}
+				return nil
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Moving %d archive(s) into %s layout...\n", len(moves), mgr.Layout())
+			if err := mgr.Reorganize(moves); err != nil {
+				return fmt.Errorf("reorganize failed: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "✓ Reorganize completed successfully")
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done")
+	cmd.Flags().StringVar(&layoutFlag, "layout", "", "Override the configured layout (flat, by_date, by_type)")
+	return cmd
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func TestDbRollbackAndMigrateTo(t *testing.T) {
+	mgr := setupManagedStore(t)
+	mgr.Close()
+	all := storage.Migrations()
+	// Roll back to 0008_archive_meta, whatever has been added since
+	const kept = 8
+	steps := len(all) - kept
+
+	dry := masDbRollbackCmd()
+	_ = dry.Flags().Set("dry-run", "true")
+	out, err := runEWithArgs(t, dry)
+	if err != nil {
+		t.Fatalf("rollback --dry-run: %v", err)
+	}
+	if !strings.Contains(out, "roll back "+all[len(all)-1].ID) {
+		t.Fatalf("unexpected dry-run output:\n%s", out)
+	}
+
+	rollback := masDbRollbackCmd()
+	_ = rollback.Flags().Set("steps", strconv.Itoa(steps))
+	if out, err = runEWithArgs(t, rollback); err != nil {
+		t.Fatalf("rollback: %v\n%s", err, out)
+	}
+
+	out, err = runEWithArgs(t, masDbStatusCmd())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !containsAll(out, []string{"Schema Version: " + all[kept-1].ID, "Pending Migrations: " + strconv.Itoa(steps)}) {
+		t.Fatalf("unexpected status:\n%s", out)
+	}
+
+	migrate := masDbMigrateCmd()
+	_ = migrate.Flags().Set("to", "0010")
+	if out, err = runEWithArgs(t, migrate); err != nil {
+		t.Fatalf("migrate --to: %v\n%s", err, out)
+	}
+	if !containsAll(out, []string{"apply 0009_fulltext", "apply 0010_events"}) || strings.Contains(out, "0011") {
+		t.Fatalf("unexpected migrate output:\n%s", out)
+	}
+
+	var rest []string
+	for _, m := range all[10:] {
+		rest = append(rest, "apply "+m.ID)
+	}
+	if out, err = runEWithArgs(t, masDbMigrateCmd()); err != nil || !containsAll(out, rest) {
+		t.Fatalf("migrate: %v\n%s", err, out)
+	}
+	if out, err = runEWithArgs(t, masDbMigrateCmd()); err != nil || !strings.Contains(out, "No pending migrations") {
+		t.Fatalf("second migrate: %v\n%s", err, out)
+	}
+}
+
+func TestDbBackupAndRestore(t *testing.T) {
+	mgr := setupManagedStore(t, func(c *config.Config) { c.Storage.BackupKeep = 2 })
+	keep := &storage.Archive{Name: "keep.7z", Path: "/tmp/keep.7z", Size: 1, Created: time.Now()}
+	if err := mgr.Register(keep); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if out, err := runEWithArgs(t, masDbBackupCmd()); err != nil || !strings.Contains(out, "Backup created") {
+			t.Fatalf("backup: %v\n%s", err, out)
+		}
+	}
+	list := masDbBackupCmd()
+	_ = list.Flags().Set("list", "true")
+	out, err := runEWithArgs(t, list)
+	if err != nil || !strings.Contains(out, "2 backups in") {
+		t.Fatalf("backup --list: %v\n%s", err, out)
+	}
+
+	later := &storage.Archive{Name: "later.7z", Path: "/tmp/later.7z", Size: 1, Created: time.Now()}
+	if err := mgr.Register(later); err != nil {
+		t.Fatal(err)
+	}
+	mgr.Close()
+
+	restore := masDbRestoreCmd()
+	_ = restore.Flags().Set("force", "true")
+	if out, err = runEWithArgs(t, restore, "latest"); err != nil {
+		t.Fatalf("restore: %v\n%s", err, out)
+	}
+	if !containsAll(out, []string{"passed the integrity check", "Previous registry saved to", "Registry restored"}) {
+		t.Fatalf("unexpected restore output:\n%s", out)
+	}
+
+	reopened, err := storage.NewManager(mgr.GetBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.Get("keep.7z"); err != nil {
+		t.Errorf("restored registry lost keep.7z: %v", err)
+	}
+	if _, err := reopened.Get("later.7z"); err == nil {
+		t.Error("restored registry still has later.7z")
+	}
+
+	if _, err := runEWithArgs(t, masDbRestoreCmd(), "no-such.bak"); err == nil {
+		t.Error("expected error for a missing backup")
+	}
+}
+
+func TestDbCommandsRespectRegistryLock(t *testing.T) {
+	mgr := setupManagedStore(t)
+	defer mgr.Close()
+	lock, err := mgr.Lock("db reorganize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	for name, c := range map[string]*cobra.Command{
+		"reorganize": masDbReorganizeCmd(),
+		"migrate":    masDbMigrateCmd(),
+		"rollback":   masDbRollbackCmd(),
+	} {
+		_, err := runEWithArgs(t, c)
+		var locked *storage.LockedError
+		if !errors.As(err, &locked) || locked.Operation != "db reorganize" {
+			t.Errorf("%s: err = %v, want the registry lock error", name, err)
+		}
+	}
+
+	dry := masDbReorganizeCmd()
+	_ = dry.Flags().Set("dry-run", "true")
+	if _, err := runEWithArgs(t, dry); err != nil {
+		t.Errorf("reorganize --dry-run should not need the lock: %v", err)
+	}
+}
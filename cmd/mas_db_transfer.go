@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func masDbExportCmd() *cobra.Command {
+	var format, output string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the registry as JSON or NDJSON",
+		Long: `Write every archive, with its tags and custom fields, in the same JSON
+shape as the archives of list --format json. Use db import on another
+machine to merge it into that registry.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			w := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", output, err)
+				}
+				defer f.Close()
+				w = f
+			}
+			n, err := mgr.Registry().Export(w, format)
+			if err != nil {
+				return err
+			}
+			if w != cmd.OutOrStdout() {
+				fmt.Fprintf(cmd.OutOrStdout(), "Exported %d archive(s) to %s\n", n, output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", storage.FormatJSON, "Export format (json, ndjson)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to a file instead of standard output")
+	return cmd
+}
+
+func masDbImportCmd() *cobra.Command {
+	var merge string
+	var rewrites []string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Merge a registry export into this registry",
+		Long: `Merge archives from a db export file (JSON or NDJSON; "-" reads standard
+input) into the registry. Archives are matched by UID; --merge decides what
+happens when a UID is already registered and the two differ:
+
+  skip       keep the registered archive (default)
+  overwrite  replace it with the imported one
+  newer      keep whichever was changed last
+
+--rewrite-prefix old=new moves paths to where the files live on this machine
+and may be repeated; the first matching rule applies. Conflicts are reported,
+and the import is applied in a single transaction.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := storage.ParseMergeMode(merge)
+			if err != nil {
+				return err
+			}
+			opts := storage.ImportOptions{Mode: mode, DryRun: dryRun}
+			for _, r := range rewrites {
+				rw, err := storage.ParsePathRewrite(r)
+				if err != nil {
+					return err
+				}
+				opts.Rewrites = append(opts.Rewrites, rw)
+			}
+			archives, err := storage.ReadArchivesFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			if !dryRun {
+				lock, err := mgr.Lock("db import")
+				if err != nil {
+					return err
+				}
+				defer lock.Release()
+			}
+
+			res, err := mgr.Registry().Import(archives, opts)
+			if err != nil {
+				return fmt.Errorf("import failed: %w", err)
+			}
+			printImportResult(cmd, len(archives), res, dryRun)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&merge, "merge", storage.MergeSkip, "How to resolve existing UIDs (skip, overwrite, newer)")
+	cmd.Flags().StringArrayVar(&rewrites, "rewrite-prefix", nil, "Rewrite a path prefix, old=new (repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be imported without changing anything")
+	return cmd
+}
+
+func printImportResult(cmd *cobra.Command, total int, res *storage.ImportResult, dryRun bool) {
+	out := cmd.OutOrStdout()
+	if dryRun {
+		fmt.Fprintf(out, "Dry run: %d record(s) read, nothing changed\n", total)
+	} else {
+		fmt.Fprintf(out, "Imported %d record(s)\n", total)
+	}
+	fmt.Fprintf(out, "  added: %d, updated: %d, unchanged: %d, skipped: %d\n", res.Added, res.Updated, res.Unchanged, res.Skipped)
+	if len(res.Conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nConflicts (%d):\n", len(res.Conflicts))
+	for _, c := range res.Conflicts {
+		fmt.Fprintf(out, "  %s  %s: %s → %s\n", safePrefix(c.UID, 8), c.Name, c.Reason, c.Resolution)
+		for _, ch := range c.Changes {
+			fmt.Fprintf(out, "      %s\n", ch)
+		}
+	}
+}
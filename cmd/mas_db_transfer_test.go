@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestDbExportImport(t *testing.T) {
+	src := setupManagedStore(t)
+	photos := &storage.Archive{Name: "photos.7z", Path: "/old/photos.7z", Size: 1, Created: time.Now(), Tags: []string{"photos"}}
+	if err := src.Register(photos); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "catalog.ndjson")
+	export := masDbExportCmd()
+	_ = export.Flags().Set("format", "ndjson")
+	_ = export.Flags().Set("output", file)
+	if out, err := runEWithArgs(t, export); err != nil || !strings.Contains(out, "Exported 1 archive(s)") {
+		t.Fatalf("export: %v\n%s", err, out)
+	}
+
+	// A second workstation with its own registry
+	dst := setupManagedStore(t)
+	dst.Close()
+	imp := masDbImportCmd()
+	_ = imp.Flags().Set("rewrite-prefix", "/old=/new")
+	out, err := runEWithArgs(t, imp, file)
+	if err != nil || !strings.Contains(out, "added: 1") {
+		t.Fatalf("import: %v\n%s", err, out)
+	}
+
+	reopened, err := storage.NewManager(dst.GetBasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.Registry().GetByUID(photos.UID)
+	if err != nil || got.Path != "/new/photos.7z" || len(got.Tags) != 1 {
+		t.Fatalf("imported archive = %+v, %v", got, err)
+	}
+	got.Size = 2
+	if err := reopened.Registry().Update(got); err != nil {
+		t.Fatal(err)
+	}
+	reopened.Close()
+
+	// The registered copy now differs; the default merge keeps it
+	out, err = runEWithArgs(t, masDbImportCmd(), file)
+	if err != nil {
+		t.Fatalf("second import: %v\n%s", err, out)
+	}
+	if !containsAll(out, []string{"skipped: 1", "Conflicts (1)", "kept registered", "size: 2 → 1"}) {
+		t.Fatalf("unexpected conflict report:\n%s", out)
+	}
+}
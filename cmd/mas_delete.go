@@ -1,14 +1,9 @@
 package cmd
 
 import (
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"time"
-
 	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
 )
@@ -42,69 +37,24 @@ func MasDeleteCmd() *cobra.Command {
 				}
 			}
 
-			now := time.Now()
-			orig := arc.Path
-
+			from := arc.Path
+			result := archiveAction{Action: "trashed", Archive: arc, From: from}
 			if force {
 				// Physically remove file if present
-				_ = os.Remove(arc.Path)
-				arc.Status = "deleted"
-				arc.DeletedAt = &now
-				if arc.OriginalPath == "" {
-					arc.OriginalPath = orig
-				}
-				return mgr.Registry().Update(arc)
-			}
-
-			// Soft delete
-			if arc.Managed {
-				// Move to managed trash directory
-				trashDir := mgr.GetTrashPath()
-				// #nosec G301: restrict permissions on created trash directory
-				if err := os.MkdirAll(trashDir, 0750); err != nil {
-					return fmt.Errorf("failed to create trash: %w", err)
-				}
-				trashPath := filepath.Join(trashDir, filepath.Base(arc.Path))
-				if err := moveOrCopy(arc.Path, trashPath); err != nil {
-					return fmt.Errorf("failed to move to trash: %w", err)
-				}
-				arc.Path = trashPath
+				result.Action = "removed"
+				err = mgr.Remove(arc)
 			} else {
-				// External: default DB-only delete (do not touch file)
+				err = mgr.Trash(arc)
+			}
+			if err != nil {
+				return err
 			}
-			arc.Status = "deleted"
-			arc.DeletedAt = &now
-			if arc.OriginalPath == "" {
-				arc.OriginalPath = orig
+			if arc.Path != from {
+				result.To = arc.Path
 			}
-			return mgr.Registry().Update(arc)
+			return printerFor(cmd).Result(result)
 		},
 	}
 	cmd.Flags().BoolVar(&force, "force", false, "Physically remove file instead of soft delete")
-	return cmd
-}
-
-// moveOrCopy tries to rename; if it fails (e.g., cross-device), it copies then removes
-func moveOrCopy(src, dst string) error {
-	if err := os.Rename(src, dst); err == nil {
-		return nil
-	}
-	// fallback to copy + remove
-	// #nosec G304: src and dst are derived from managed paths within storage; validated upstream
-	srcF, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcF.Close()
-	// Use restrictive permissions for destination file
-	// #nosec G304: destination is inside managed trash or archives path
-	dstF, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer dstF.Close()
-	if _, err := io.Copy(dstF, srcF); err != nil {
-		return err
-	}
-	return os.Remove(src)
+	return output.Annotate(cmd)
 }
@@ -1,13 +1,10 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
 
 	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	errs "github.com/adamstac/7zarch-go/internal/errors"
@@ -15,37 +12,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// copyFile copies src to dst with mode preservation
-func copyFile(src, dst string) error {
-	// #nosec G304: src/dst validated via resolver and managed paths
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-
-	// Use restrictive permissions for new file
-	// #nosec G304: destination path validated/constructed earlier and rooted within managed base when applicable
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	if _, err = io.Copy(out, in); err != nil {
-		return err
-	}
-	if err = out.Sync(); err != nil {
-		return err
-	}
-	if info, err := os.Stat(src); err == nil {
-		_ = os.Chmod(dst, info.Mode())
-	}
-	return nil
-}
-
 func MasMoveCmd() *cobra.Command {
-	var to string
+	var to, toVault string
 	cmd := &cobra.Command{
 		Use:   "move <id>",
 		Short: "Move an archive (default to managed storage if --to omitted)",
@@ -73,6 +41,13 @@ func MasMoveCmd() *cobra.Command {
 				}
 			}
 
+			if toVault != "" {
+				if to != "" {
+					return &errs.ValidationError{Field: "--to-vault", Value: toVault, Message: "cannot be combined with --to"}
+				}
+				return moveToVault(cmd, mgr, arc, toVault)
+			}
+
 			dest := to
 			if dest == "" {
 
@@ -80,7 +55,7 @@ func MasMoveCmd() *cobra.Command {
 				if name == "" {
 					name = filepath.Base(arc.Path)
 				}
-				dest = mgr.GetManagedPath(name)
+				dest = mgr.ManagedPathFor(name, arc.Profile, arc.Created)
 			}
 
 			// If dest is an existing directory, place the file under it by name
@@ -92,40 +67,51 @@ func MasMoveCmd() *cobra.Command {
 				dest = filepath.Join(dest, name)
 			}
 
-			// #nosec G301: restrict permissions on created directory
-			if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
-				return err
-			}
-
-			// Prevent accidental overwrite
-			if info, err := os.Stat(dest); err == nil && !info.IsDir() {
-				return fmt.Errorf("destination file already exists: %s", dest)
-			}
+			// More precise managed-path check
+			rel, _ := filepath.Rel(mgr.GetBasePath(), dest)
+			up := ".." + string(os.PathSeparator)
+			managed := rel != ".." && !strings.HasPrefix(rel, up)
 
-			if err := os.Rename(arc.Path, dest); err != nil {
-				// Handle cross-device rename (EXDEV)
-				var linkErr *os.LinkError
-				if errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV) {
-					if err := copyFile(arc.Path, dest); err != nil {
-						return fmt.Errorf("copy fallback failed: %w", err)
-					}
-					if err := os.Remove(arc.Path); err != nil {
-						return fmt.Errorf("cleanup source failed after copy: %w", err)
-					}
-				} else {
+			// An archive entering managed storage counts against its quota
+			if managed && !arc.Managed {
+				if err := mgr.CheckCapacity(arc.Size, arc.Path); err != nil {
 					return err
 				}
 			}
 
-			arc.Path = dest
-			// More precise managed-path check
-			rel, _ := filepath.Rel(mgr.GetBasePath(), dest)
-			up := ".." + string(os.PathSeparator)
-			arc.Managed = rel != ".." && !strings.HasPrefix(rel, up)
-
-			return mgr.Registry().Update(arc)
+			// The manager creates the directory, refuses to overwrite, and
+			// repairs a move interrupted by a crash
+			return mgr.MoveArchive(arc, dest, func(a *storage.Archive) {
+				a.Managed = managed
+			})
 		},
 	}
 	cmd.Flags().StringVar(&to, "to", "", "Destination path or managed default if omitted")
+	cmd.Flags().StringVar(&toVault, "to-vault", "", "Move the archive into another vault's managed storage")
 	return cmd
 }
+
+// moveToVault transfers an archive, file and registry row, into another vault
+func moveToVault(cmd *cobra.Command, src *storage.Manager, arc *storage.Archive, vault string) error {
+	cfg, dst, cleanup, err := cmdutil.InitVaultManager(vault)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if dst.GetBasePath() == src.GetBasePath() {
+		return &errs.InvalidOperationError{
+			Operation: "move",
+			Resource:  "archive",
+			Reason:    fmt.Sprintf("archive is already in vault %s", cfg.Storage.Vault),
+		}
+	}
+	if err := src.TransferArchive(arc, dst); err != nil {
+		return err
+	}
+	moved, err := dst.Registry().GetByUID(arc.UID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "✓ Moved %s to vault %s: %s\n", arc.Name, cfg.Storage.Vault, moved.Path)
+	return nil
+}
@@ -1,16 +1,16 @@
 package cmd
 
 import (
-	"crypto/sha256"
 	"encoding/csv"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -18,8 +18,8 @@ import (
 
 func MasShowCmd() *cobra.Command {
 	var (
-		verify bool
-		output string
+		verify     bool
+		flagOutput string
 	)
 	cmd := &cobra.Command{
 		Use:   "show <id>",
@@ -33,10 +33,11 @@ func MasShowCmd() *cobra.Command {
 			}
 			defer cleanup()
 
+			p := printerFor(cmd)
 			resolver := storage.NewResolver(mgr.Registry())
 			arc, err := resolver.Resolve(id)
 			if err != nil {
-				if amb, ok := err.(*storage.AmbiguousIDError); ok {
+				if amb, ok := err.(*storage.AmbiguousIDError); ok && !p.Structured() {
 					printAmbiguousOptions(amb)
 				}
 				return cmdutil.HandleResolverError(err, id)
@@ -52,8 +53,20 @@ func MasShowCmd() *cobra.Command {
 			arc.LastSeen = &now
 			_ = mgr.Registry().Update(arc)
 
-			if output != "" {
-				return outputArchive(arc, output, verify)
+			if p.Structured() {
+				result := showResult{Archive: arc}
+				if verify && arc.Status == "present" && arc.Checksum != "" {
+					computed, err := storage.FileChecksum(arc.Path)
+					if err != nil {
+						return err
+					}
+					verified := computed == arc.Checksum
+					result.ChecksumVerified, result.ComputedChecksum = &verified, computed
+				}
+				return p.Result(result)
+			}
+			if flagOutput != "" {
+				return outputArchive(p.Text(), arc, flagOutput, verify)
 			}
 
 			printArchive(arc, verify)
@@ -61,8 +74,17 @@ func MasShowCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().BoolVar(&verify, "verify", false, "Verify checksum against file (slower)")
-	cmd.Flags().StringVar(&output, "output", "", "Output format: json|csv|yaml (default: human-readable)")
-	return cmd
+	cmd.Flags().StringVar(&flagOutput, "output", "", "Output format: json|csv|yaml (default: human-readable)")
+	deprecateOutputFlag(cmd)
+	return output.Annotate(cmd)
+}
+
+// showResult is the structured result of show; the checksum fields are set
+// with --verify
+type showResult struct {
+	Archive          *storage.Archive `json:"archive"`
+	ChecksumVerified *bool            `json:"checksum_verified,omitempty"`
+	ComputedChecksum string           `json:"computed_checksum,omitempty"`
 }
 
 func printArchive(a *storage.Archive, verify bool) {
@@ -84,7 +106,7 @@ func printArchive(a *storage.Archive, verify bool) {
 	if a.Checksum == "" {
 		fmt.Printf("Checksum:   (none)\n")
 	} else if verify && a.Status == "present" {
-		computed, err := computeSHA256(a.Path)
+		computed, err := storage.FileChecksum(a.Path)
 		if err == nil && computed == a.Checksum {
 			fmt.Printf("Checksum:   %s (verified ✓)\n", a.Checksum)
 		} else if err == nil {
@@ -98,23 +120,21 @@ func printArchive(a *storage.Archive, verify bool) {
 	if a.Profile != "" {
 		fmt.Printf("Profile:    %s\n", a.Profile)
 	}
-	if a.Uploaded {
-		fmt.Printf("Uploaded:   %t (%s)\n", a.Uploaded, a.Destination)
+	if a.SourcePath != "" {
+		fmt.Printf("Source:     %s\n", a.SourcePath)
 	}
-}
-
-func computeSHA256(path string) (string, error) {
-	// #nosec G304: path originates from registry-managed archive object
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
+	if len(a.Tags) > 0 {
+		fmt.Printf("Tags:       %s\n", strings.Join(a.Tags, ", "))
+	}
+	if len(a.Fields) > 0 {
+		fmt.Printf("Fields:\n")
+		for _, f := range a.FieldList() {
+			fmt.Printf("  %-20s %s (%s)\n", f.Key, f.Value, f.Type)
+		}
 	}
-	defer f.Close()
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+	if a.Uploaded {
+		fmt.Printf("Uploaded:   %t (%s)\n", a.Uploaded, a.Destination)
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func printAmbiguousOptions(amb *storage.AmbiguousIDError) {
@@ -138,11 +158,11 @@ func safePrefix(s string, n int) string {
 }
 
 // outputArchive outputs single archive in machine-readable format
-func outputArchive(a *storage.Archive, format string, verify bool) error {
+func outputArchive(w io.Writer, a *storage.Archive, format string, verify bool) error {
 	// Create enriched archive with verified checksum if requested
 	archiveData := *a
 	if verify && a.Status == "present" && a.Checksum != "" {
-		if computed, err := computeSHA256(a.Path); err == nil {
+		if computed, err := storage.FileChecksum(a.Path); err == nil {
 			metadata := fmt.Sprintf(`{"checksum_verified":%t,"computed_checksum":"%s"}`, computed == a.Checksum, computed)
 			archiveData.Metadata = metadata
 		}
@@ -150,13 +170,13 @@ func outputArchive(a *storage.Archive, format string, verify bool) error {
 
 	switch format {
 	case "json":
-		enc := json.NewEncoder(os.Stdout)
+		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
 		return enc.Encode(&archiveData)
 	case "csv":
-		return outputArchiveCSV(&archiveData)
+		return outputArchiveCSV(w, &archiveData)
 	case "yaml":
-		enc := yaml.NewEncoder(os.Stdout)
+		enc := yaml.NewEncoder(w)
 		defer enc.Close()
 		return enc.Encode(&archiveData)
 	default:
@@ -164,8 +184,8 @@ func outputArchive(a *storage.Archive, format string, verify bool) error {
 	}
 }
 
-func outputArchiveCSV(a *storage.Archive) error {
-	writer := csv.NewWriter(os.Stdout)
+func outputArchiveCSV(w io.Writer, a *storage.Archive) error {
+	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
 	// Write header
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// MetaCmd returns the `meta` command for typed custom fields
+func MetaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "meta",
+		Short: "Set, unset and list typed custom fields on archives",
+		Long: `Custom fields are typed key/value pairs attached to archives. Types are
+string, number, date and bool. The type is inferred from the value unless given
+explicitly as key:type=value. Fields can be filtered with --where on list and
+query save, e.g. --where retention_class=legal or --where project_year>=2024.
+
+The free-form Metadata JSON blob is unaffected.`,
+		Example: `  7zarch-go meta set 01K2E3 retention_class=legal project_year=2024
+  7zarch-go meta set 01K2E3 zip:string=02134 reviewed=2024-06-01
+  7zarch-go meta unset 01K2E3 reviewed
+  7zarch-go meta list 01K2E3
+  7zarch-go meta list`,
+	}
+	cmd.AddCommand(metaSetCmd())
+	cmd.AddCommand(metaUnsetCmd())
+	cmd.AddCommand(metaListCmd())
+	return cmd
+}
+
+func metaSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <id> <key=value...>",
+		Short: "Set fields on an archive (key=value or key:type=value)",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fields := make([]storage.Field, 0, len(args)-1)
+			for _, a := range args[1:] {
+				f, err := storage.ParseFieldAssignment(a)
+				if err != nil {
+					return err
+				}
+				fields = append(fields, f)
+			}
+
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			arc, err := resolveArchiveArg(mgr, args[0])
+			if err != nil {
+				return err
+			}
+			if err := mgr.Registry().SetFields(arc.ID, fields...); err != nil {
+				return err
+			}
+			return printArchiveFields(cmd.OutOrStdout(), mgr, arc)
+		},
+	}
+}
+
+func metaUnsetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset <id> <key...>",
+		Short: "Remove fields from an archive",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			arc, err := resolveArchiveArg(mgr, args[0])
+			if err != nil {
+				return err
+			}
+			removed, err := mgr.Registry().UnsetFields(arc.ID, args[1:]...)
+			if err != nil {
+				return err
+			}
+			if removed == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No matching fields on %s\n", arc.Name)
+			}
+			return printArchiveFields(cmd.OutOrStdout(), mgr, arc)
+		},
+	}
+}
+
+func metaListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list [id]",
+		Short: "List fields of an archive, or all field names with types and counts",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			if len(args) == 1 {
+				arc, err := resolveArchiveArg(mgr, args[0])
+				if err != nil {
+					return err
+				}
+				return printArchiveFields(out, mgr, arc)
+			}
+
+			keys, err := mgr.Registry().ListFieldKeys()
+			if err != nil {
+				return err
+			}
+			if len(keys) == 0 {
+				fmt.Fprintln(out, "No custom fields in use.")
+				return nil
+			}
+			fmt.Fprintf(out, "%-32s %-8s %s\n", "FIELD", "TYPE", "ARCHIVES")
+			for _, k := range keys {
+				fmt.Fprintf(out, "%-32s %-8s %d\n", k.Key, k.Type, k.Count)
+			}
+			return nil
+		},
+	}
+}
+
+func printArchiveFields(out io.Writer, mgr *storage.Manager, arc *storage.Archive) error {
+	fields, err := mgr.Registry().Fields(arc.ID)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		fmt.Fprintf(out, "%s: (no fields)\n", arc.Name)
+		return nil
+	}
+	fmt.Fprintf(out, "%s:\n", arc.Name)
+	for _, f := range (&storage.Archive{Fields: fields}).FieldList() {
+		fmt.Fprintf(out, "  %-24s %-8s %s\n", f.Key, f.Type, f.Value)
+	}
+	return nil
+}
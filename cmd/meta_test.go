@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestMetaSetUnsetList(t *testing.T) {
+	mgr := setupManagedStore(t)
+	arc := &storage.Archive{
+		Name: "contract.7z", Path: filepath.Join(mgr.GetArchivesPath(), "contract.7z"),
+		Managed: true,
+	}
+	if err := mgr.Register(arc); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, metaSetCmd(), arc.UID, "retention_class=legal", "project_year=2024")
+	if err != nil {
+		t.Fatalf("meta set: %v", err)
+	}
+	if !containsAll(out, []string{"retention_class", "legal", "project_year", "number", "2024"}) {
+		t.Fatalf("unexpected set output:\n%s", out)
+	}
+
+	if _, err := runEWithArgs(t, metaSetCmd(), arc.UID, "project_year:number=soon"); err == nil {
+		t.Fatal("expected error for non-numeric number field")
+	}
+
+	if _, err := runEWithArgs(t, metaUnsetCmd(), arc.UID, "retention_class"); err != nil {
+		t.Fatalf("meta unset: %v", err)
+	}
+
+	out, err = runEWithArgs(t, metaListCmd())
+	if err != nil {
+		t.Fatalf("meta list: %v", err)
+	}
+	if !containsAll(out, []string{"FIELD", "project_year", "number"}) || containsAll(out, []string{"retention_class"}) {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+}
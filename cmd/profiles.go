@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,7 +17,7 @@ func ProfilesCmd() *cobra.Command {
 		RunE:  runProfiles,
 	}
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runProfiles(cmd *cobra.Command, args []string) error {
@@ -27,6 +28,12 @@ func runProfiles(cmd *cobra.Command, args []string) error {
 		return profiles[i].Name < profiles[j].Name
 	})
 
+	if p := printerFor(cmd); p.Structured() {
+		return p.Result(struct {
+			Profiles []archive.CompressionProfile `json:"profiles"`
+		}{profiles})
+	}
+
 	out := cmd.OutOrStdout()
 	fmt.Fprintf(out, "Available Compression Profiles\n")
 	fmt.Fprintf(out, "==============================\n\n")
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/display"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/retention"
+	"github.com/spf13/cobra"
+)
+
+// PruneCmd returns the `prune` command that applies retention policies
+func PruneCmd() *cobra.Command {
+	var (
+		flagDryRun  bool
+		flagForce   bool
+		flagPolicy  string
+		flagNoPurge bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Apply retention policies: trash old archives and purge expired trash",
+		Long: `Apply the retention policies from storage.retention_policies.
+
+Each archive is governed by the first policy whose profile, path and pattern
+selectors match it. Within a policy, live archives survive if any keep rule
+(keep_last, keep_daily, keep_weekly, keep_monthly, keep_yearly) keeps them;
+the rest are moved to trash through the normal soft-delete flow. Trashed
+archives are purged once they have been in trash longer than the policy's
+trash_days, or storage.retention_days when no policy matches.`,
+		Example: `  # Preview what would happen
+  7zarch-go prune --dry-run
+
+  # Apply a single policy without prompting
+  7zarch-go prune --policy nightly-db --force`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			policies := cfg.Storage.RetentionPolicies
+			if err := retention.Validate(policies); err != nil {
+				return &errs.ConfigurationError{Setting: "storage.retention_policies", Message: err.Error()}
+			}
+			if flagPolicy != "" && !hasPolicy(policies, flagPolicy) {
+				return &errs.NotFoundError{Resource: "retention policy", ID: flagPolicy}
+			}
+
+			archives, err := mgr.List()
+			if err != nil {
+				return err
+			}
+			plan := retention.Compute(archives, policies, cfg.Storage.RetentionDays, time.Now())
+			if flagNoPurge {
+				plan.Purge = nil
+			}
+			// The plan is computed with every policy so each archive stays
+			// with the policy that claims it; --policy only narrows the result
+			if flagPolicy != "" {
+				plan.Keep = onlyPolicy(plan.Keep, flagPolicy)
+				plan.Trash = onlyPolicy(plan.Trash, flagPolicy)
+				plan.Purge = onlyPolicy(plan.Purge, flagPolicy)
+			}
+
+			out := cmd.OutOrStdout()
+			if len(plan.Trash) == 0 && len(plan.Purge) == 0 {
+				fmt.Fprintln(out, "Nothing to prune.")
+				return nil
+			}
+			printPrunePlan(out, plan)
+
+			if flagDryRun {
+				return nil
+			}
+
+			if !flagForce {
+				fmt.Fprintf(out, "Trash %d and purge %d archives? [y/N]: ", len(plan.Trash), len(plan.Purge))
+				reader := bufio.NewReader(os.Stdin)
+				line, _ := reader.ReadString('\n')
+				line = strings.TrimSpace(strings.ToLower(line))
+				if line != "y" && line != "yes" {
+					fmt.Fprintln(out, "Aborted.")
+					return nil
+				}
+			}
+
+			lock, err := mgr.Lock("prune")
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			trashed := 0
+			for _, d := range plan.Trash {
+				if err := mgr.Trash(d.Archive); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Failed to trash %s: %v\n", d.Archive.Name, err)
+					continue
+				}
+				trashed++
+			}
+			purged := 0
+			for _, d := range plan.Purge {
+				if err := mgr.Purge(d.Archive); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Failed to purge %s: %v\n", d.Archive.Name, err)
+					continue
+				}
+				purged++
+			}
+			fmt.Fprintf(out, "Trashed %d archives, purged %d archives.\n", trashed, purged)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Show actions without making changes")
+	cmd.Flags().BoolVar(&flagForce, "force", false, "Skip confirmation prompts")
+	cmd.Flags().StringVar(&flagPolicy, "policy", "", "Only apply the named policy")
+	cmd.Flags().BoolVar(&flagNoPurge, "no-purge", false, "Only trash live archives; leave trash alone")
+	return cmd
+}
+
+func hasPolicy(policies []config.RetentionPolicy, name string) bool {
+	for _, p := range policies {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func onlyPolicy(decisions []retention.Decision, name string) []retention.Decision {
+	var out []retention.Decision
+	for _, d := range decisions {
+		if d.Policy == name {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func printPrunePlan(out io.Writer, plan *retention.Plan) {
+	if len(plan.Trash) > 0 {
+		fmt.Fprintf(out, "Would trash %d archives (kept %d):\n", len(plan.Trash), len(plan.Keep))
+		for _, d := range plan.Trash {
+			fmt.Fprintf(out, "- %s  %s  %s  [%s: %s]\n", d.Archive.Name, d.Archive.Created.Format("2006-01-02"),
+				display.FormatSize(d.Archive.Size), d.Policy, d.Series)
+		}
+	}
+	if len(plan.Purge) > 0 {
+		fmt.Fprintf(out, "Would purge %d archives from trash:\n", len(plan.Purge))
+		for _, d := range plan.Purge {
+			policy := d.Policy
+			if policy == "" {
+				policy = "retention_days"
+			}
+			fmt.Fprintf(out, "- %s  %s  [%s]\n", d.Archive.Name, d.Reason, policy)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/pflag"
+)
+
+func TestPruneAppliesPolicies(t *testing.T) {
+	mgr := setupManagedStore(t, func(cfg *config.Config) {
+		cfg.Storage.RetentionPolicies = []config.RetentionPolicy{
+			{Name: "nightly", Pattern: "nightly-*.7z", KeepLast: 2},
+		}
+	})
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		name := "nightly-" + string(rune('a'+i)) + ".7z"
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		if err := os.WriteFile(p, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		arc := &storage.Archive{
+			UID: "uid-nightly-" + name, Name: name, Path: p, Size: 10,
+			Created: now.AddDate(0, 0, -i), Managed: true, Status: "present", SourcePath: "/data/nightly",
+		}
+		if err := mgr.Registry().Add(arc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Trashed long ago: purged via retention_days
+	old := now.AddDate(0, 0, -90)
+	if err := mgr.Registry().Add(&storage.Archive{
+		UID: "uid-expired", Name: "expired.7z", Path: filepath.Join(mgr.GetTrashPath(), "expired.7z"),
+		Created: old, Managed: true, Status: "deleted", DeletedAt: &old,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithFlags(t, PruneCmd(), func(f *pflag.FlagSet) { _ = f.Set("dry-run", "true") })
+	if err != nil {
+		t.Fatalf("prune --dry-run: %v", err)
+	}
+	if !containsAll(out, []string{"Would trash 2 archives", "nightly-c.7z", "nightly-d.7z", "Would purge 1", "expired.7z"}) {
+		t.Fatalf("unexpected dry-run output:\n%s", out)
+	}
+	if a, _ := mgr.Get("nightly-d.7z"); a.Status != "present" {
+		t.Fatal("dry run must not change anything")
+	}
+
+	lock, err := mgr.Lock("db reorganize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runEWithFlags(t, PruneCmd(), func(f *pflag.FlagSet) { _ = f.Set("force", "true") })
+	var locked *storage.LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("prune while locked: err = %v, want the registry lock error", err)
+	}
+	_ = lock.Release()
+
+	out, err = runEWithFlags(t, PruneCmd(), func(f *pflag.FlagSet) { _ = f.Set("force", "true") })
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if !strings.Contains(out, "Trashed 2 archives, purged 1 archives.") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	for name, want := range map[string]string{"nightly-a.7z": "present", "nightly-b.7z": "present", "nightly-c.7z": "deleted", "nightly-d.7z": "deleted"} {
+		a, err := mgr.Get(name)
+		if err != nil || a.Status != want {
+			t.Errorf("%s: status=%v err=%v, want %s", name, a, err, want)
+		}
+	}
+	if _, err := mgr.Get("expired.7z"); err == nil {
+		t.Error("expired trash entry should be purged")
+	}
+}
+
+func TestPrunePolicyLeavesOtherPoliciesAlone(t *testing.T) {
+	mgr := setupManagedStore(t, func(cfg *config.Config) {
+		cfg.Storage.RetentionPolicies = []config.RetentionPolicy{
+			{Name: "db", Pattern: "db-*.7z", KeepLast: 3},
+			{Name: "all", Pattern: "*.7z", KeepLast: 1},
+		}
+	})
+
+	now := time.Now()
+	for i, name := range []string{"db-a.7z", "db-b.7z", "db-c.7z", "home-a.7z", "home-b.7z"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		if err := os.WriteFile(p, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		source := "/data/" + strings.SplitN(name, "-", 2)[0]
+		if err := mgr.Registry().Add(&storage.Archive{
+			UID: "uid-" + name, Name: name, Path: p, Size: 10,
+			Created: now.AddDate(0, 0, -i), Managed: true, Status: "present", SourcePath: source,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// db-* belong to the first policy, so applying "all" must not count them
+	out, err := runEWithFlags(t, PruneCmd(), func(f *pflag.FlagSet) {
+		_ = f.Set("policy", "all")
+		_ = f.Set("force", "true")
+	})
+	if err != nil {
+		t.Fatalf("prune --policy all: %v", err)
+	}
+	if !strings.Contains(out, "Trashed 1 archives") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	for name, want := range map[string]string{"db-a.7z": "present", "db-b.7z": "present", "db-c.7z": "present", "home-a.7z": "present", "home-b.7z": "deleted"} {
+		if a, err := mgr.Get(name); err != nil || a.Status != want {
+			t.Errorf("%s: status=%v err=%v, want %s", name, a, err, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,6 +9,8 @@ import (
 	"text/tabwriter"
 
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/query"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
@@ -58,7 +61,16 @@ This allows you to save complex filter combinations for reuse.`,
   7zarch-go query save "big-media" --profile=media --larger-than=100000000
   
   # Save a query for old unuploaded archives
-  7zarch-go query save "old-unuploaded" --not-uploaded --older-than=30d`,
+  7zarch-go query save "old-unuploaded" --not-uploaded --older-than=30d
+
+  # Save a query for one client's archives
+  7zarch-go query save "acme" --tag=client:acme
+
+  # Save a filter expression
+  7zarch-go query save "big-unsent" --filter "size > 1GB and not uploaded"
+
+  # Save a query on custom fields
+  7zarch-go query save "legal-recent" --where retention_class=legal --where project_year>=2024`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: runQuerySave,
 	}
@@ -74,12 +86,16 @@ This allows you to save complex filter combinations for reuse.`,
 	cmd.Flags().String("profile", "", "Filter by profile (media|documents|balanced)")
 	cmd.Flags().Int64("larger-than", 0, "Filter by size larger than bytes")
 	cmd.Flags().Bool("deleted", false, "Filter for deleted archives only")
+	cmd.Flags().StringSlice("tag", nil, "Filter by tag (repeatable; all must match)")
+	cmd.Flags().String("filter", "", "Filter expression, e.g. 'size > 1GB and profile = media and not uploaded'")
+	cmd.Flags().StringArray("where", nil, "Filter by custom field comparison, e.g. project_year>=2024 (repeatable)")
 	
 	// Search integration flags
 	cmd.Flags().String("search", "", "Include search terms in the saved query")
 	cmd.Flags().String("search-field", "", "Search specific field (name|path|metadata)")
 	cmd.Flags().Bool("search-regex", false, "Use regex pattern matching for search")
 	cmd.Flags().Bool("search-case-sensitive", false, "Case-sensitive search")
+	cmd.Flags().Bool("search-fuzzy", false, "Tolerate typos in search terms")
 
 	return cmd
 }
@@ -96,13 +112,14 @@ Queries are sorted by most recently used first.`,
   7zarch-go query list
   
   # List with JSON output for scripting
-  7zarch-go query list --output json`,
+  7zarch-go --format json query list`,
 		RunE: runQueryList,
 	}
 
 	cmd.Flags().String("output", "", "Output format: table|json (default: table)")
+	deprecateOutputFlag(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func queryRunCmd() *cobra.Command {
@@ -120,7 +137,10 @@ the standard archive display format.`,
   7zarch-go query run my-docs --table
   
   # Run query with JSON output
-  7zarch-go query run my-docs --output json`,
+  7zarch-go --format json query run my-docs
+
+  # Largest 20 matches
+  7zarch-go query run my-docs --sort size --limit 20`,
 		Args: cobra.ExactArgs(1),
 		RunE: runQueryRun,
 	}
@@ -132,9 +152,11 @@ the standard archive display format.`,
 	cmd.Flags().Bool("tree", false, "Use tree display mode")
 	cmd.Flags().Bool("dashboard", false, "Use dashboard display mode")
 	cmd.Flags().String("output", "", "Output format: table|json|csv|yaml")
+	deprecateOutputFlag(cmd)
 	cmd.Flags().Bool("details", false, "Show detailed information")
+	addPageFlags(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func queryDeleteCmd() *cobra.Command {
@@ -166,53 +188,53 @@ and the actual filter configuration that will be applied.`,
   7zarch-go query show my-docs
   
   # Show with JSON output
-  7zarch-go query show my-docs --output json`,
+  7zarch-go --format json query show my-docs`,
 		Args: cobra.ExactArgs(1),
 		RunE: runQueryShow,
 	}
 
 	cmd.Flags().String("output", "", "Output format: table|json (default: table)")
+	deprecateOutputFlag(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runQuerySave(cmd *cobra.Command, args []string) error {
 	queryName := args[0]
 
-	// Collect filters from flags
-	filters := make(map[string]string)
-
-	if getBool(cmd, "not-uploaded") {
-		filters["not-uploaded"] = "true"
-	}
-	if pattern := getString(cmd, "pattern"); pattern != "" {
-		filters["pattern"] = pattern
+	// Collect filter flags into a single expression
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	where, _ := cmd.Flags().GetStringArray("where")
+	flags := filter.Flags{
+		NotUploaded: getBool(cmd, "not-uploaded"),
+		Pattern:     getString(cmd, "pattern"),
+		OlderThan:   getString(cmd, "older-than"),
+		Managed:     getBool(cmd, "managed"),
+		External:    getBool(cmd, "external"),
+		Missing:     getBool(cmd, "missing"),
+		Deleted:     getBool(cmd, "deleted"),
+		Status:      getString(cmd, "status"),
+		Profile:     getString(cmd, "profile"),
+		Tags:        tags,
+		Where:       where,
 	}
-	if olderThan := getString(cmd, "older-than"); olderThan != "" {
-		filters["older-than"] = olderThan
-	}
-	if getBool(cmd, "managed") {
-		filters["managed"] = "true"
-	}
-	if getBool(cmd, "external") {
-		filters["external"] = "true"
-	}
-	if getBool(cmd, "missing") {
-		filters["missing"] = "true"
-	}
-	if status := getString(cmd, "status"); status != "" {
-		filters["status"] = status
+	if largerThan := getInt64(cmd, "larger-than"); largerThan > 0 {
+		flags.LargerThan = fmt.Sprintf("%d", largerThan)
 	}
-	if profile := getString(cmd, "profile"); profile != "" {
-		filters["profile"] = profile
+	expr, err := flags.Expression()
+	if err != nil {
+		return err
 	}
-	if largerThan := getInt64(cmd, "larger-than"); largerThan > 0 {
-		filters["larger-than"] = fmt.Sprintf("%d", largerThan)
+	f, err := filter.Parse(filter.And(expr, getString(cmd, "filter")))
+	if err != nil {
+		return err
 	}
-	if getBool(cmd, "deleted") {
-		filters["deleted"] = "true"
+
+	filters := make(map[string]string)
+	if !f.Empty() {
+		filters[query.FilterKey] = f.String()
 	}
-	
+
 	// Add search terms if provided
 	if search := getString(cmd, "search"); search != "" {
 		filters["search"] = search
@@ -226,6 +248,9 @@ func runQuerySave(cmd *cobra.Command, args []string) error {
 	if getBool(cmd, "search-case-sensitive") {
 		filters["search-case-sensitive"] = "true"
 	}
+	if getBool(cmd, "search-fuzzy") {
+		filters["search-fuzzy"] = "true"
+	}
 
 	if len(filters) == 0 {
 		return fmt.Errorf("no filters specified - provide at least one filter flag")
@@ -251,8 +276,8 @@ func runQuerySave(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to save query: %w", err)
 	}
 
-	fmt.Printf("✅ Query '%s' saved successfully\n", queryName)
-	fmt.Printf("Filters: %s\n", formatFilters(filters))
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Query '%s' saved successfully\n", queryName)
+	fmt.Fprintf(cmd.OutOrStdout(), "Filters: %s\n", formatFilters(filters))
 
 	return nil
 }
@@ -279,10 +304,19 @@ func runQueryList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list queries: %w", err)
 	}
 
+	if p := printerFor(cmd); p.Structured() {
+		if queries == nil {
+			queries = []*query.Query{}
+		}
+		return p.Result(struct {
+			Queries []*query.Query `json:"queries"`
+		}{queries})
+	}
+
 	// Check output format
 	outputFormat := getString(cmd, "output")
 	if outputFormat == "json" {
-		enc := json.NewEncoder(os.Stdout)
+		enc := json.NewEncoder(printerFor(cmd).Text())
 		enc.SetIndent("", "  ")
 		return enc.Encode(queries)
 	}
@@ -341,23 +375,34 @@ func runQueryRun(cmd *cobra.Command, args []string) error {
 	queryManager := query.NewQueryManager(storageManager.Registry().DB(), resolver)
 
 	// Execute the query
-	archives, err := queryManager.Run(queryName)
+	var opts listFilters
+	readPageFlags(cmd, &opts)
+	c, err := opts.criteria()
+	if err != nil {
+		return err
+	}
+	page, err := queryManager.RunPage(context.Background(), queryName, c)
 	if err != nil {
 		return fmt.Errorf("failed to run query: %w", err)
 	}
+	archives := page.Archives
+	if p := printerFor(cmd); p.Structured() {
+		return p.Result(listed(archives, page.Next))
+	}
+	defer printNextPageHint(page)
 
 	// Check for output format first
 	outputFormat := getString(cmd, "output")
 	if outputFormat != "" {
 		switch outputFormat {
 		case "json":
-			enc := json.NewEncoder(os.Stdout)
+			enc := json.NewEncoder(printerFor(cmd).Text())
 			enc.SetIndent("", "  ")
 			return enc.Encode(archives)
 		case "csv":
-			return outputCSV(archives)
+			return outputCSV(printerFor(cmd).Text(), archives)
 		case "yaml":
-			return outputYAML(archives)
+			return outputYAML(printerFor(cmd).Text(), archives)
 		default:
 			return fmt.Errorf("unsupported output format: %s", outputFormat)
 		}
@@ -399,7 +444,7 @@ func runQueryDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to delete query: %w", err)
 	}
 
-	fmt.Printf("✅ Query '%s' deleted successfully\n", queryName)
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Query '%s' deleted successfully\n", queryName)
 
 	return nil
 }
@@ -428,10 +473,16 @@ func runQueryShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get query: %w", err)
 	}
 
+	if p := printerFor(cmd); p.Structured() {
+		return p.Result(struct {
+			Query any `json:"query"`
+		}{query})
+	}
+
 	// Check output format
 	outputFormat := getString(cmd, "output")
 	if outputFormat == "json" {
-		enc := json.NewEncoder(os.Stdout)
+		enc := json.NewEncoder(printerFor(cmd).Text())
 		enc.SetIndent("", "  ")
 		return enc.Encode(query)
 	}
@@ -454,18 +505,24 @@ func runQueryShow(cmd *cobra.Command, args []string) error {
 
 // formatFilters creates a readable string representation of filter map
 func formatFilters(filters map[string]string) string {
-	if len(filters) == 0 {
-		return "none"
-	}
-
 	var parts []string
-	for key, value := range filters {
+	if expr, err := query.Expression(filters); err == nil && expr != "" {
+		parts = append(parts, expr)
+	}
+	for _, key := range []string{"search", "search-field", "search-regex", "search-case-sensitive", "search-fuzzy"} {
+		value, ok := filters[key]
+		if !ok {
+			continue
+		}
 		if value == "true" {
 			parts = append(parts, fmt.Sprintf("--%s", key))
 		} else {
 			parts = append(parts, fmt.Sprintf("--%s=%s", key, value))
 		}
 	}
+	if len(parts) == 0 {
+		return "none"
+	}
 
 	return strings.Join(parts, " ")
 }
\ No newline at end of file
@@ -2,11 +2,10 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
 
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
 )
@@ -31,6 +30,9 @@ func RestoreCmd() *cobra.Command {
 				return fmt.Errorf("failed to init storage: %w", err)
 			}
 			defer mgr.Close()
+			if err := cmdutil.ConfigureManager(mgr, cfg); err != nil {
+				return err
+			}
 
 			resolver := storage.NewResolver(mgr.Registry())
 			arc, err := resolver.Resolve(id)
@@ -40,54 +42,26 @@ func RestoreCmd() *cobra.Command {
 			if arc.Status != "deleted" {
 				return fmt.Errorf("archive '%s' is not deleted (status=%s)", arc.Name, arc.Status)
 			}
-
-			target := arc.OriginalPath
-			if target == "" {
-				name := arc.Name
-				if name == "" {
-					name = filepath.Base(arc.Path)
-				}
-				target = mgr.GetManagedPath(name)
-			}
+			target := mgr.RestoreTarget(arc)
+			p := printerFor(cmd)
+			result := archiveAction{Action: "restored", Archive: arc, From: arc.Path, To: target, DryRun: flagDryRun}
 
 			// Plan
 			if flagDryRun {
-				cmd.Printf("Would restore %s -> %s\n", arc.Path, target)
-				return nil
+				fmt.Fprintf(p.Text(), "Would restore %s -> %s\n", arc.Path, target)
+				return p.Result(result)
 			}
 
-			// Ensure parent dir exists
-			// #nosec G301: restrict permissions on restored directory
-			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
-				return fmt.Errorf("failed to prepare destination: %w", err)
-			}
-
-			// If managed archive, file lives in trash and needs moving back
-			if arc.Managed {
-				if err := moveOrCopy(arc.Path, target); err != nil {
-					return fmt.Errorf("failed to restore file: %w", err)
-				}
-				arc.Path = target
-			} else {
-				// External soft delete: file likely remained in place; just flip status
-				arc.Path = target
-			}
-
-			// Update registry state
-			arc.Status = "present"
-			arc.DeletedAt = nil
-			now := time.Now()
-			arc.LastSeen = &now
-			if err := mgr.Registry().Update(arc); err != nil {
-				return fmt.Errorf("failed to update registry: %w", err)
+			if err := mgr.Restore(arc, flagForce); err != nil {
+				return err
 			}
-			cmd.Printf("✅ Restored %s to %s\n", arc.Name, target)
-			return nil
+			fmt.Fprintf(p.Text(), "✅ Restored %s to %s\n", arc.Name, target)
+			return p.Result(result)
 		},
 	}
 
 	cmd.Flags().BoolVar(&flagForce, "force", false, "Overwrite existing file if it exists at original location")
 	cmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "Show actions without making changes")
 
-	return cmd
+	return output.Annotate(cmd)
 }
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/schedule"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// SchedulesCmd returns the `schedules` command showing configured schedules
+// and their run history
+func SchedulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedules",
+		Short: "Show scheduled tasks and their run history",
+		Long: `Show the schedules configured under schedules: in the config, which
+'7zarch-go daemon' runs, and the history of their runs.`,
+	}
+	cmd.AddCommand(schedulesListCmd())
+	cmd.AddCommand(schedulesHistoryCmd())
+	cmd.AddCommand(schedulesRunCmd())
+	return cmd
+}
+
+// loadSchedules opens the job queue and compiles the configured schedules
+func loadSchedules() ([]*schedule.Entry, *storage.Manager, func(), error) {
+	cfg, mgr, cleanup, err := cmdutil.InitJobQueue()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	entries, err := schedule.Compile(cfg.Schedules)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, &errs.ConfigurationError{Setting: "schedules", Message: err.Error()}
+	}
+	return entries, mgr, cleanup, nil
+}
+
+func schedulesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List schedules with their next and last runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, mgr, cleanup, err := loadSchedules()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			if len(entries) == 0 {
+				fmt.Fprintln(out, "No schedules configured.")
+				return nil
+			}
+			now := time.Now()
+			fmt.Fprintf(out, "%-20s %-7s %-15s %-16s %-16s %s\n", "NAME", "TASK", "CRON", "NEXT", "LAST", "RESULT")
+			for _, e := range entries {
+				last, err := mgr.Registry().LastScheduleRun(e.Name)
+				if err != nil {
+					return err
+				}
+				lastDue, result := "—", "—"
+				if last != nil {
+					lastDue = last.Due.Local().Format("2006-01-02 15:04")
+					result = runResult(last)
+				}
+				fmt.Fprintf(out, "%-20s %-7s %-15s %-16s %-16s %s\n", truncate(e.Name, 20), e.Task, e.Cron,
+					formatNext(e.Spec.Next(now)), lastDue, result)
+			}
+			return nil
+		},
+	}
+}
+
+func schedulesHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [schedule]",
+		Short: "Show past runs, newest first",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			runs, err := mgr.Registry().ScheduleRuns(name, getInt(cmd, "limit"))
+			if err != nil {
+				return err
+			}
+			printScheduleRuns(cmd.OutOrStdout(), runs)
+			return nil
+		},
+	}
+	cmd.Flags().Int("limit", 20, "Maximum number of runs to show (0 = all)")
+	return cmd
+}
+
+func printScheduleRuns(out io.Writer, runs []*storage.ScheduleRun) {
+	if len(runs) == 0 {
+		fmt.Fprintln(out, "No runs yet.")
+		return
+	}
+	fmt.Fprintf(out, "%-16s %-20s %-6s %-9s %s\n", "DUE", "SCHEDULE", "JOB", "DURATION", "RESULT")
+	for _, run := range runs {
+		job, duration := "—", "—"
+		if run.JobID != 0 {
+			job = fmt.Sprintf("%d", run.JobID)
+		}
+		if run.Job != nil && run.Job.Started != nil {
+			duration = run.Job.Duration().Round(time.Second).String()
+		}
+		fmt.Fprintf(out, "%-16s %-20s %-6s %-9s %s\n", run.Due.Local().Format("2006-01-02 15:04"),
+			truncate(run.Schedule, 20), job, duration, runResult(run))
+	}
+}
+
+// runResult describes how a schedule run went
+func runResult(run *storage.ScheduleRun) string {
+	switch {
+	case run.Skipped != "":
+		return "skipped: " + run.Skipped
+	case run.Job == nil:
+		return "job removed"
+	case run.Job.Status == storage.JobFailed && run.Job.Error != "":
+		return "failed: " + run.Job.Error
+	default:
+		return run.Job.Status
+	}
+}
+
+func schedulesRunCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <schedule>",
+		Short: "Queue a schedule's task now",
+		Long: `Queue a schedule's task now rather than waiting for it to fall due. The
+run is recorded in its history, and the schedule next runs at its first
+time after now.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, mgr, cleanup, err := loadSchedules()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			for _, e := range entries {
+				if e.Name != args[0] {
+					continue
+				}
+				run, err := schedule.RunNow(mgr.Registry(), e, time.Now())
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "📥 Queued job %d for %s; it runs when a worker or daemon picks it up\n", run.JobID, e.Name)
+				return nil
+			}
+			return &errs.NotFoundError{Resource: "schedule", ID: args[0]}
+		},
+	}
+}
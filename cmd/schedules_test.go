@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestScrubReportsMissingAndCorrupt(t *testing.T) {
+	mgr := setupManagedStore(t)
+	for name, content := range map[string]string{"good.7z": "good", "bad.7z": "bad", "gone.7z": "gone"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := storage.FileChecksum(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.Add(name, p, int64(len(content)), "Media", sum, "", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(mgr.GetArchivesPath(), "bad.7z"), []byte("bit rot"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(mgr.GetArchivesPath(), "gone.7z")); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, ScrubCmd())
+	if err == nil {
+		t.Fatalf("expected scrub to fail:\n%s", out)
+	}
+	if !containsAll(out, []string{"bad.7z", "checksum mismatch", "gone.7z", "missing at", "Scrubbed 3 archives: 1 ok, 1 missing, 1 checksum mismatches"}) {
+		t.Errorf("unexpected scrub output:\n%s", out)
+	}
+	if a, _ := mgr.Registry().Get("gone.7z"); a.Status != "missing" {
+		t.Errorf("gone.7z status = %s, want missing", a.Status)
+	}
+}
+
+func TestSchedulesListRunAndHistory(t *testing.T) {
+	mgr := setupManagedStore(t, func(c *config.Config) {
+		c.Schedules = []config.Schedule{{Name: "weekly-scrub", Cron: "0 4 * * sun", Task: "scrub"}}
+	})
+
+	out, err := runEWithArgs(t, schedulesListCmd())
+	if err != nil || !containsAll(out, []string{"weekly-scrub", "scrub", "0 4 * * sun"}) {
+		t.Fatalf("schedules list = %q, %v", out, err)
+	}
+
+	if out, err := runEWithArgs(t, schedulesRunCmd(), "weekly-scrub"); err != nil || !strings.Contains(out, "Queued job 1") {
+		t.Fatalf("schedules run = %q, %v", out, err)
+	}
+	if _, err := runEWithArgs(t, schedulesRunCmd(), "nightly"); err == nil {
+		t.Error("expected an error for an unknown schedule")
+	}
+	if j, err := mgr.Registry().Job(1); err != nil || strings.Join(j.Args, " ") != "scrub" {
+		t.Fatalf("queued job = %+v, %v", j, err)
+	}
+
+	out, err = runEWithArgs(t, schedulesHistoryCmd(), "weekly-scrub")
+	if err != nil || !containsAll(out, []string{"weekly-scrub", "queued"}) {
+		t.Errorf("schedules history = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// ScrubCmd returns the `scrub` command that checks every archive's file
+// against the registry
+func ScrubCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scrub",
+		Short: "Check that archives are present and match their recorded checksums",
+		Long: `Check every archive in the registry, or those matching the filter flags:
+the file must exist, and its SHA256 must match the checksum recorded when it
+was created. Each archive's status and last-seen time are updated.
+
+Exits with an error when any archive is missing or fails its checksum, so a
+scheduled scrub shows up as failed.`,
+		Example: `  7zarch-go scrub
+  7zarch-go scrub --profile media --older-than 90d`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := batchFilter(cmd)
+			if err != nil {
+				return err
+			}
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			archives, err := f.Select(mgr.Registry())
+			if err != nil {
+				return fmt.Errorf("failed to list archives: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			var checked, ok, missing, corrupt, unverified int
+			for _, a := range archives {
+				if a.Status == "deleted" {
+					continue
+				}
+				checked++
+				res, err := mgr.Scrub(a)
+				if err != nil {
+					return fmt.Errorf("failed to scrub %s: %w", a.Name, err)
+				}
+				switch {
+				case a.Status == "missing":
+					missing++
+					fmt.Fprintf(out, "❌ %s (%s): missing at %s\n", a.Name, shortUID(a), a.Path)
+				case a.Checksum == "":
+					unverified++
+				case !res.Verified:
+					corrupt++
+					fmt.Fprintf(out, "❌ %s (%s): checksum mismatch\n", a.Name, shortUID(a))
+				default:
+					ok++
+				}
+			}
+
+			fmt.Fprintf(out, "🔍 Scrubbed %d archives: %d ok, %d missing, %d checksum mismatches", checked, ok, missing, corrupt)
+			if unverified > 0 {
+				fmt.Fprintf(out, ", %d without a checksum", unverified)
+			}
+			fmt.Fprintln(out)
+			if missing+corrupt > 0 {
+				return fmt.Errorf("%d archives failed the scrub", missing+corrupt)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSlice("tag", nil, "Only archives carrying this tag (repeatable; all must match)")
+	addListFilterFlags(cmd)
+	return cmd
+}
+
+func shortUID(a *storage.Archive) string {
+	if len(a.UID) > 12 {
+		return a.UID[:12]
+	}
+	return a.UID
+}
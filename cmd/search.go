@@ -1,24 +1,32 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/display"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/search"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 func SearchCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "search",
 		Short: "Search archives by content with full-text and field-specific capabilities",
-		Long: `Perform powerful full-text search across archive metadata with field-specific 
-filtering and regex support.
+		Long: `Perform full-text search across archive name, path, profile, metadata and
+tags, with field-specific filtering and regex support.
 
-The search engine provides sub-500ms performance on large archive collections
-and supports both simple text queries and advanced pattern matching.`,
+Search uses a SQLite full-text index that is kept current as archives change,
+so results are available immediately without reindexing. Results are ranked
+by relevance (BM25), with name matches weighted highest.`,
 		Example: `  # Full-text search across all fields
   7zarch-go search "project backup 2024"
   
@@ -32,8 +40,8 @@ and supports both simple text queries and advanced pattern matching.`,
   # Case-sensitive search with result limit
   7zarch-go search "Project" --case-sensitive --limit 10
   
-  # Combined with filters (coming in Phase 4)
-  7zarch-go search "backup" --profile=documents --managed`,
+  # Combined with a filter expression
+  7zarch-go search query "backup" --filter "profile = documents and managed"`,
 	}
 
 	cmd.AddCommand(searchQueryCmd())
@@ -48,10 +56,30 @@ func searchQueryCmd() *cobra.Command {
 		Short: "Search archives with specified query",
 		Long: `Execute a search query against the archive metadata.
 
-Supports full-text search across all fields or field-specific search
-with optional regex pattern matching.`,
+Query syntax:
+  backup 2024          archives containing both terms
+  "family photos"      exact phrase
+  proj*                prefix match
+  name:backup          term in one field (name, path, profile, metadata, tags)
+  media OR video       either term
+  photos NOT raw       exclude a term (also: photos -raw)
+  (a OR b) c           grouping
+
+Operators are upper case. Matching ignores case and punctuation unless
+--case-sensitive is given. With --regex the query is a regular expression
+matched against the field instead.
+
+With --fuzzy, terms also match indexed words a small number of edits away
+and the last term matches as a prefix; exact matches rank first.
+
+Results are ranked by relevance, name matches first; JSON and YAML output
+include each result's score.`,
 		Example: `  # Search for archives containing "backup" and "2024"
   7zarch-go search query "backup 2024"
+
+  # Prefix, phrase and boolean queries
+  7zarch-go search query "proj* NOT draft"
+  7zarch-go search query '"family photos" OR name:vacation'
   
   # Search only in archive names
   7zarch-go search query --field=name "project"
@@ -60,19 +88,33 @@ with optional regex pattern matching.`,
   7zarch-go search query --field=path --regex "/Users/.*/Documents/.*"
   
   # Case-sensitive search
-  7zarch-go search query "Project" --case-sensitive`,
+  7zarch-go search query "Project" --case-sensitive
+
+  # Tolerate typos; the last word may be partly typed
+  7zarch-go search query --fuzzy "vacaton phot"
+
+  # Restrict results to a tag
+  7zarch-go search query "invoices" --tag=client:acme
+
+  # Restrict results with a filter expression
+  7zarch-go search query "invoices" --filter "size > 100MB and not uploaded"`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: runSearchQuery,
 	}
 
 	// Search options
-	cmd.Flags().String("field", "", "Search specific field (name|path|metadata)")
+	cmd.Flags().String("field", "", "Search specific field (name|path|profile|metadata|tags)")
+	cmd.Flags().StringSlice("tag", nil, "Only archives carrying this tag (repeatable; all must match)")
+	cmd.Flags().String("filter", "", "Filter expression applied to results, e.g. 'size > 1GB and not uploaded'")
 	cmd.Flags().Bool("regex", false, "Use regex pattern matching")
 	cmd.Flags().Bool("case-sensitive", false, "Case-sensitive search")
+	cmd.Flags().Bool("fuzzy", false, "Tolerate typos and match the last term as a prefix")
 	cmd.Flags().Int("limit", 0, "Maximum number of results (0 = no limit)")
+	cmd.Flags().Bool("all-vaults", false, "Search every configured vault")
 
 	// Output options
 	cmd.Flags().String("output", "", "Output format: table|json|csv|yaml")
+	deprecateOutputFlag(cmd)
 	cmd.Flags().Bool("details", false, "Show detailed information")
 
 	// Display mode options
@@ -82,7 +124,7 @@ with optional regex pattern matching.`,
 	cmd.Flags().Bool("tree", false, "Use tree display mode")
 	cmd.Flags().Bool("dashboard", false, "Use dashboard display mode")
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func searchReindexCmd() *cobra.Command {
@@ -91,8 +133,8 @@ func searchReindexCmd() *cobra.Command {
 		Short: "Rebuild the search index",
 		Long: `Rebuild the search index from current archive data.
 
-This is useful when archives have been modified outside of 7zarch-go
-or when search performance degrades due to index fragmentation.`,
+The index is maintained automatically as archives are added, changed and
+removed, so this is only needed to repair an index that has been damaged.`,
 		Example: `  # Rebuild search index
   7zarch-go search reindex`,
 		RunE: runSearchReindex,
@@ -115,46 +157,71 @@ func runSearchQuery(cmd *cobra.Command, args []string) error {
 		Field:         getString(cmd, "field"),
 		UseRegex:      getBool(cmd, "regex"),
 		CaseSensitive: getBool(cmd, "case-sensitive"),
+		Fuzzy:         getBool(cmd, "fuzzy"),
 		MaxResults:    getInt(cmd, "limit"),
 	}
-
-	// Initialize search engine
-	cfg, err := config.Load()
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	expr, err := filter.Flags{Tags: tags}.Expression()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
-
-	storageManager, err := storage.NewManager(cfg.Storage.ManagedPath)
+	f, err := filter.Parse(filter.And(expr, getString(cmd, "filter")))
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage manager: %w", err)
-	}
-	defer storageManager.Close()
-
-	searchEngine := search.NewSearchEngine(storageManager.Registry())
-
-	// Ensure search index is available
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		return fmt.Errorf("failed to initialize search index: %w", err)
+		return err
 	}
 
 	// Perform search with timing
 	startTime := time.Now()
-	results, err := searchEngine.SearchWithOptions(query, opts)
+	var scored []search.Result
+	if getBool(cmd, "all-vaults") {
+		scored, err = searchAllVaults(query, opts)
+	} else {
+		scored, err = searchVault(query, opts)
+	}
 	if err != nil {
-		return fmt.Errorf("search failed: %w", err)
+		return err
 	}
 	searchTime := time.Since(startTime)
 
+	var results []*storage.Archive
+	hits := []searchHit{}
+	var scores map[int64]float64
+	if !getBool(cmd, "all-vaults") {
+		// Row ids repeat across vaults; merged results are already ranked
+		scores = make(map[int64]float64, len(scored))
+	}
+	for _, r := range scored {
+		if !f.Match(r.Archive) {
+			continue
+		}
+		results = append(results, r.Archive)
+		hits = append(hits, searchHit{Archive: r.Archive, Score: r.Score})
+		if scores != nil {
+			scores[r.Archive.ID] = r.Score
+		}
+	}
+
+	if p := printerFor(cmd); p.Structured() {
+		return p.Result(struct {
+			Query   string      `json:"query"`
+			Results []searchHit `json:"results"`
+		}{query, hits})
+	}
+
 	// Check for output format first
 	outputFormat := getString(cmd, "output")
 	if outputFormat != "" {
 		switch outputFormat {
 		case "json":
-			return outputJSON(results)
+			enc := json.NewEncoder(printerFor(cmd).Text())
+			enc.SetIndent("", "  ")
+			return enc.Encode(hits)
 		case "csv":
-			return outputCSV(results)
+			return outputCSV(printerFor(cmd).Text(), results)
 		case "yaml":
-			return outputYAML(results)
+			enc := yaml.NewEncoder(printerFor(cmd).Text())
+			defer enc.Close()
+			return enc.Encode(hits)
 		default:
 			return fmt.Errorf("unsupported output format: %s", outputFormat)
 		}
@@ -180,6 +247,9 @@ func runSearchQuery(cmd *cobra.Command, args []string) error {
 	if opts.UseRegex {
 		fmt.Printf(" (regex)")
 	}
+	if opts.Fuzzy {
+		fmt.Printf(" (fuzzy)")
+	}
 	if opts.MaxResults > 0 && len(results) >= opts.MaxResults {
 		fmt.Printf(" (limited to %d)", opts.MaxResults)
 	}
@@ -192,10 +262,79 @@ func runSearchQuery(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("\n\n")
 
+	// Display modes show results best match first
+	if mode := determineDisplayMode(cmd); mode != display.ModeAuto {
+		displayManager := newDisplayManager()
+		return displayManager.Render(results, display.Options{
+			Mode:        mode,
+			Details:     getBool(cmd, "details"),
+			ShowHeaders: mode != display.ModeCompact,
+			SortBy:      []string{"relevance"},
+			Scores:      scores,
+		})
+	}
+
 	// Display results using existing archive display logic
 	return printGroupedArchives(results, getBool(cmd, "details"))
 }
 
+// searchVault searches the selected vault
+func searchVault(query string, opts search.SearchOptions) ([]search.Result, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	storageManager, err := storage.NewManager(cfg.Storage.ManagedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage manager: %w", err)
+	}
+	defer storageManager.Close()
+
+	scored, err := search.NewSearchEngine(storageManager.Registry()).SearchScored(query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return scored, nil
+}
+
+// searchAllVaults searches every vault and ranks the results together
+func searchAllVaults(query string, opts search.SearchOptions) ([]search.Result, error) {
+	mgrs, cleanup, err := cmdutil.OpenVaults()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	var all []search.Result
+	for name, mgr := range mgrs {
+		scored, err := search.NewSearchEngine(mgr.Registry()).SearchScored(query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("search failed in vault %s: %w", name, err)
+		}
+		for _, r := range scored {
+			r.Archive.Vault = name
+		}
+		all = append(all, scored...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Score != all[j].Score {
+			return all[i].Score > all[j].Score
+		}
+		return all[i].Archive.Vault < all[j].Archive.Vault
+	})
+	if opts.MaxResults > 0 && len(all) > opts.MaxResults {
+		all = all[:opts.MaxResults]
+	}
+	return all, nil
+}
+
+// searchHit is an archive in search output with its relevance score
+type searchHit struct {
+	*storage.Archive `yaml:",inline"`
+	Score            float64 `json:"score" yaml:"score"`
+}
+
 func runSearchReindex(cmd *cobra.Command, args []string) error {
 	// Initialize search engine
 	cfg, err := config.Load()
@@ -211,12 +350,7 @@ func runSearchReindex(cmd *cobra.Command, args []string) error {
 
 	searchEngine := search.NewSearchEngine(storageManager.Registry())
 
-	// Ensure search table exists
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		return fmt.Errorf("failed to initialize search table: %w", err)
-	}
-
-	fmt.Printf("🔄 Rebuilding search index...\n")
+	fmt.Fprintf(cmd.OutOrStdout(), "🔄 Rebuilding search index...\n")
 	
 	startTime := time.Now()
 	if err := searchEngine.Reindex(); err != nil {
@@ -224,7 +358,7 @@ func runSearchReindex(cmd *cobra.Command, args []string) error {
 	}
 	indexTime := time.Since(startTime)
 
-	fmt.Printf("✅ Search index rebuilt in %v\n", indexTime)
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Search index rebuilt in %v\n", indexTime)
 	
 	return nil
 }
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// ServeCmd returns the `serve` command that exposes the registry over a
+// local HTTP/JSON API.
+func ServeCmd() *cobra.Command {
+	var (
+		flagListen string
+		flagToken  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the registry over a token-authenticated HTTP/JSON API and web UI",
+		Long: `Run in the foreground and serve the registry over HTTP for scripts and
+dashboards. Endpoints list, show and search archives, run saved queries,
+trigger integrity tests and checksum verification, and move archives to and
+from trash; archives have the same JSON shape as in the CLI's --format json
+results.
+
+A web UI for browsing, searching, health and trash/restore is served at the
+root URL. It is built into the binary and loads nothing from the network.
+
+Every API request must send "Authorization: Bearer <token>". The token comes
+from --token, then server.token in the config; without either a random token
+is generated and printed at startup.`,
+		Example: `  # Serve on the default loopback address
+  7zarch-go serve
+
+  # Choose the address and token
+  7zarch-go serve --listen 127.0.0.1:8080 --token s3cret
+
+  # Query it
+  curl -H "Authorization: Bearer s3cret" http://127.0.0.1:8080/api/v1/archives?limit=10`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			listen := flagListen
+			if listen == "" {
+				listen = cfg.Server.Listen
+			}
+			token := flagToken
+			if token == "" {
+				token = cfg.Server.Token
+			}
+			generated := token == ""
+			if generated {
+				if token, err = generateToken(); err != nil {
+					return err
+				}
+			}
+
+			srv, err := server.New(mgr, server.Options{
+				Token: token,
+				Logf: func(format string, a ...interface{}) {
+					fmt.Fprintf(out, "%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			ln, err := net.Listen("tcp", listen)
+			if err != nil {
+				return &errs.ConfigurationError{Setting: "server.listen", Value: listen, Message: err.Error()}
+			}
+			if host, _, err := net.SplitHostPort(listen); err == nil {
+				if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+					fmt.Fprintf(os.Stderr, "⚠️  Warning: listening on %s exposes the API beyond this machine\n", listen)
+				}
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			fmt.Fprintf(out, "🌐 Serving %s on http://%s/api/v1 (Ctrl+C to stop)\n", mgr.GetBasePath(), ln.Addr())
+			if generated {
+				fmt.Fprintf(out, "🖥️  Web UI: http://%s/#token=%s\n", ln.Addr(), token)
+				fmt.Fprintf(out, "🔑 API token: %s\n", token)
+				fmt.Fprintf(out, "💡 Set server.token in the config to keep it across restarts\n")
+			} else {
+				fmt.Fprintf(out, "🖥️  Web UI: http://%s/\n", ln.Addr())
+			}
+			return srv.Serve(ctx, ln)
+		},
+	}
+
+	cmd.Flags().StringVar(&flagListen, "listen", "", "Address to listen on (default server.listen, 127.0.0.1:7070)")
+	cmd.Flags().StringVar(&flagToken, "token", "", "Bearer token clients must send (default server.token, else generated)")
+	return cmd
+}
+
+// generateToken returns a random 128-bit token
+func generateToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
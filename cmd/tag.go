@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// TagCmd returns the `tag` command for labelling archives
+func TagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Add, remove and list archive tags",
+		Long: `Tags are free-form labels attached to archives, typically in key:value form
+such as client:acme or project:website. Tags are lower-cased and may not
+contain spaces or commas. Use --tag on list, search, batch and query save to
+select archives by tag.`,
+		Example: `  7zarch-go tag add 01K2E3 client:acme project:website
+  7zarch-go tag remove 01K2E3 project:website
+  7zarch-go tag list 01K2E3
+  7zarch-go tag list`,
+	}
+	cmd.AddCommand(tagAddCmd())
+	cmd.AddCommand(tagRemoveCmd())
+	cmd.AddCommand(tagListCmd())
+	return cmd
+}
+
+func tagAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <id> <tag...>",
+		Short: "Attach tags to an archive",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			arc, err := resolveArchiveArg(mgr, args[0])
+			if err != nil {
+				return err
+			}
+			tags, err := storage.NormalizeTags(args[1:])
+			if err != nil {
+				return err
+			}
+			if err := mgr.Registry().AddTags(arc.ID, tags...); err != nil {
+				return err
+			}
+			return printArchiveTags(cmd.OutOrStdout(), mgr, arc)
+		},
+	}
+}
+
+func tagRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <id> <tag...>",
+		Aliases: []string{"rm"},
+		Short:   "Detach tags from an archive",
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			arc, err := resolveArchiveArg(mgr, args[0])
+			if err != nil {
+				return err
+			}
+			removed, err := mgr.Registry().RemoveTags(arc.ID, args[1:]...)
+			if err != nil {
+				return err
+			}
+			if removed == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No matching tags on %s\n", arc.Name)
+			}
+			return printArchiveTags(cmd.OutOrStdout(), mgr, arc)
+		},
+	}
+}
+
+func tagListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list [id]",
+		Short: "List tags of an archive, or all tags with counts",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			if len(args) == 1 {
+				arc, err := resolveArchiveArg(mgr, args[0])
+				if err != nil {
+					return err
+				}
+				return printArchiveTags(out, mgr, arc)
+			}
+
+			counts, err := mgr.Registry().ListTags()
+			if err != nil {
+				return err
+			}
+			if len(counts) == 0 {
+				fmt.Fprintln(out, "No tags in use.")
+				return nil
+			}
+			fmt.Fprintf(out, "%-32s %s\n", "TAG", "ARCHIVES")
+			for _, tc := range counts {
+				fmt.Fprintf(out, "%-32s %d\n", tc.Tag, tc.Count)
+			}
+			return nil
+		},
+	}
+}
+
+// resolveArchiveArg resolves an archive ID the same way `show` does
+func resolveArchiveArg(mgr *storage.Manager, input string) (*storage.Archive, error) {
+	resolver := storage.NewResolver(mgr.Registry())
+	arc, err := resolver.Resolve(input)
+	if err != nil {
+		if amb, ok := err.(*storage.AmbiguousIDError); ok {
+			printAmbiguousOptions(amb)
+		}
+		return nil, cmdutil.HandleResolverError(err, input)
+	}
+	return arc, nil
+}
+
+func printArchiveTags(out io.Writer, mgr *storage.Manager, arc *storage.Archive) error {
+	tags, err := mgr.Registry().Tags(arc.ID)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Fprintf(out, "%s: (no tags)\n", arc.Name)
+		return nil
+	}
+	fmt.Fprintf(out, "%s: %s\n", arc.Name, strings.Join(tags, ", "))
+	return nil
+}
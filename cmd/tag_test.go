@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestTagAddRemoveList(t *testing.T) {
+	mgr := setupManagedStore(t)
+	arc := &storage.Archive{
+		Name: "acme-site.7z", Path: filepath.Join(mgr.GetArchivesPath(), "acme-site.7z"),
+		Managed: true,
+	}
+	if err := mgr.Register(arc); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, tagAddCmd(), arc.UID, "Client:Acme", "project:website")
+	if err != nil {
+		t.Fatalf("tag add: %v", err)
+	}
+	if !containsAll(out, []string{"acme-site.7z", "client:acme", "project:website"}) {
+		t.Fatalf("unexpected add output:\n%s", out)
+	}
+
+	if _, err := runEWithArgs(t, tagAddCmd(), arc.UID, "bad tag"); err == nil {
+		t.Fatal("expected error for tag with a space")
+	}
+
+	out, err = runEWithArgs(t, tagRemoveCmd(), arc.UID, "project:website")
+	if err != nil {
+		t.Fatalf("tag remove: %v", err)
+	}
+	if strings.Contains(out, "project:website") {
+		t.Fatalf("tag still listed after remove:\n%s", out)
+	}
+
+	out, err = runEWithArgs(t, tagListCmd())
+	if err != nil {
+		t.Fatalf("tag list: %v", err)
+	}
+	if !containsAll(out, []string{"TAG", "client:acme", "1"}) {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,10 @@ import (
 	"time"
 
 	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/output"
+	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
@@ -19,6 +24,7 @@ var (
 	testRemote    bool
 	testDirectory bool
 	maxConcurrent int
+	testTimeout   time.Duration
 )
 
 func TestCmd() *cobra.Command {
@@ -27,6 +33,11 @@ func TestCmd() *cobra.Command {
 		Short: "Test archive integrity",
 		Long: `Test the integrity of archives by verifying structure, checksums, and metadata.
 Can test single archives or entire directories concurrently.`,
+		Example: `  7zarch-go test backup.7z
+  7zarch-go test --directory /backups
+
+  # Queue a long directory test for the background worker
+  7zarch-go test --directory --background /backups`,
 		Args: cobra.ExactArgs(1),
 		RunE: runTest,
 	}
@@ -36,26 +47,52 @@ Can test single archives or entire directories concurrently.`,
 	cmd.Flags().BoolVarP(&testDirectory, "directory", "d", false, "Test all archives in directory")
 	cmd.Flags().IntVar(&maxConcurrent, "concurrent", 10, "Max concurrent tests")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be tested")
+	cmd.Flags().DurationVar(&testTimeout, "timeout", 10*time.Minute, "Give up on an archive whose test takes longer than this (0 = no limit)")
+	addBackgroundFlag(cmd)
 
-	return cmd
+	return output.Annotate(cmd)
 }
 
 func runTest(cmd *cobra.Command, args []string) error {
 	target := args[0]
 
+	if getBool(cmd, "background") {
+		return queueJob(cmd, args)
+	}
+
+	p := printerFor(cmd)
 	if dryRun {
-		return runTestDryRun(target)
+		return runTestDryRun(p, target)
 	}
 
 	if testDirectory {
-		return runTestDirectory(target)
+		return runTestDirectory(p, target)
 	}
 
-	return runTestSingle(target)
+	return runTestSingle(p, target)
+}
+
+// testedArchive is one archive's test result in structured output; the
+// result is missing for a dry run
+type testedArchive struct {
+	Path string `json:"path"`
+	*archive.TestResult
+}
+
+// testReport is the structured result of test. NDJSON streams each archive
+// as an "archive" event and leaves them out of the result.
+type testReport struct {
+	Archives []testedArchive `json:"archives,omitempty"`
+	Tested   int             `json:"tested"`
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+	DryRun   bool            `json:"dry_run,omitempty"`
 }
 
-func runTestDryRun(target string) error {
-	fmt.Printf("DRY RUN MODE - No tests will be executed\n\n")
+func runTestDryRun(p *output.Printer, target string) error {
+	out := p.Text()
+	report := testReport{DryRun: true}
+	fmt.Fprintf(out, "DRY RUN MODE - No tests will be executed\n\n")
 
 	if testDirectory {
 		// Find archives in directory
@@ -64,58 +101,66 @@ func runTestDryRun(target string) error {
 			return fmt.Errorf("failed to find archives: %w", err)
 		}
 
-		fmt.Printf("Would test %d archives in %s:\n", len(archives), target)
+		fmt.Fprintf(out, "Would test %d archives in %s:\n", len(archives), target)
 		for _, arch := range archives {
-			fmt.Printf("  - %s\n", filepath.Base(arch))
+			fmt.Fprintf(out, "  - %s\n", filepath.Base(arch))
+			report.Archives = append(report.Archives, testedArchive{Path: arch})
 		}
-		fmt.Printf("\nTests to run:\n")
-		fmt.Printf("  ✓ Archive structure integrity\n")
-		fmt.Printf("  ✓ Checksum verification\n")
-		fmt.Printf("  ✓ Metadata validation\n")
-		fmt.Printf("  ✓ Extraction test\n")
-		fmt.Printf("\nMax concurrent tests: %d\n", maxConcurrent)
+		fmt.Fprintf(out, "\nTests to run:\n")
+		fmt.Fprintf(out, "  ✓ Archive structure integrity\n")
+		fmt.Fprintf(out, "  ✓ Checksum verification\n")
+		fmt.Fprintf(out, "  ✓ Metadata validation\n")
+		fmt.Fprintf(out, "  ✓ Extraction test\n")
+		fmt.Fprintf(out, "\nMax concurrent tests: %d\n", maxConcurrent)
 	} else {
-		fmt.Printf("Would test archive: %s\n", target)
-		fmt.Printf("\nTests to run:\n")
-		fmt.Printf("  ✓ Archive structure integrity\n")
-		fmt.Printf("  ✓ Checksum verification\n")
-		fmt.Printf("  ✓ Metadata validation\n")
-		fmt.Printf("  ✓ Extraction test\n")
+		fmt.Fprintf(out, "Would test archive: %s\n", target)
+		report.Archives = append(report.Archives, testedArchive{Path: target})
+		fmt.Fprintf(out, "\nTests to run:\n")
+		fmt.Fprintf(out, "  ✓ Archive structure integrity\n")
+		fmt.Fprintf(out, "  ✓ Checksum verification\n")
+		fmt.Fprintf(out, "  ✓ Metadata validation\n")
+		fmt.Fprintf(out, "  ✓ Extraction test\n")
 	}
 
 	if testRemote {
-		fmt.Printf("\nExecution mode: Remote (on TrueNAS)\n")
+		fmt.Fprintf(out, "\nExecution mode: Remote (on TrueNAS)\n")
 	} else {
-		fmt.Printf("\nExecution mode: Local\n")
+		fmt.Fprintf(out, "\nExecution mode: Local\n")
 	}
 
-	return nil
+	return p.Result(report)
 }
 
-func runTestSingle(archivePath string) error {
-	fmt.Printf("Testing archive: %s\n\n", filepath.Base(archivePath))
+func runTestSingle(p *output.Printer, archivePath string) error {
+	out := p.Text()
+	fmt.Fprintf(out, "Testing archive: %s\n\n", filepath.Base(archivePath))
 
 	manager := archive.NewManager()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	ctx, cancel := withTimeout(context.Background(), testTimeout)
 	defer cancel()
 
 	// Run tests
 	result, err := manager.Test(ctx, archivePath)
 	if err != nil {
+		notifyTested(map[string]*archive.TestResult{archivePath: {Errors: []string{err.Error()}}})
 		return fmt.Errorf("test failed: %w", err)
 	}
 
 	// Display results
-	printTestResult(archivePath, result)
+	printTestResult(out, archivePath, result)
+	notifyTested(map[string]*archive.TestResult{archivePath: result})
 
+	report := testReport{Archives: []testedArchive{{archivePath, result}}, Tested: 1}
 	if !result.Passed {
-		return fmt.Errorf("archive verification failed")
+		report.Failed = 1
+		return p.Failure(report, fmt.Errorf("archive verification failed"))
 	}
-
-	return nil
+	report.Passed = 1
+	return p.Result(report)
 }
 
-func runTestDirectory(dir string) error {
+func runTestDirectory(p *output.Printer, dir string) error {
+	out := p.Text()
 	// Find all archives
 	archives, err := findArchives(dir)
 	if err != nil {
@@ -123,14 +168,17 @@ func runTestDirectory(dir string) error {
 	}
 
 	if len(archives) == 0 {
-		fmt.Printf("No archives found in %s\n", dir)
-		return nil
+		fmt.Fprintf(out, "No archives found in %s\n", dir)
+		return p.Result(testReport{})
 	}
 
-	fmt.Printf("Testing %d archives in %s\n\n", len(archives), dir)
+	fmt.Fprintf(out, "Testing %d archives in %s\n\n", len(archives), dir)
 
 	// Create progress bar
 	bar := progressbar.Default(int64(len(archives)))
+	if p.Structured() {
+		bar = progressbar.DefaultSilent(int64(len(archives)))
+	}
 
 	// Results storage
 	results := make([]*archive.TestResult, len(archives))
@@ -150,7 +198,7 @@ func runTestDirectory(dir string) error {
 
 			// Test archive (per-archive timeout for parity with single mode)
 			manager := archive.NewManager()
-			ctxArchive, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			ctxArchive, cancel := withTimeout(ctx, testTimeout)
 			defer cancel()
 			result, err := manager.Test(ctxArchive, archivePath)
 			if err != nil {
@@ -166,7 +214,7 @@ func runTestDirectory(dir string) error {
 			_ = bar.Add(1) // best-effort UI update
 			resultsMu.Unlock()
 
-			return nil
+			return p.Event("archive", testedArchive{archivePath, result})
 		})
 	}
 
@@ -176,24 +224,33 @@ func runTestDirectory(dir string) error {
 	}
 
 	_ = bar.Finish() // best-effort UI cleanup
-	fmt.Printf("\n")
+	fmt.Fprintf(out, "\n")
 
 	// Print summary
-	printBatchSummary(archives, results)
+	printBatchSummary(out, archives, results)
+	tested := make(map[string]*archive.TestResult, len(archives))
+	for i, path := range archives {
+		tested[path] = results[i]
+	}
+	notifyTested(tested)
 
 	// Check if any failed
-	failedCount := 0
-	for _, result := range results {
+	report := testReport{Tested: len(archives)}
+	for i, result := range results {
 		if !result.Passed {
-			failedCount++
+			report.Failed++
+		}
+		if !p.Streaming() {
+			report.Archives = append(report.Archives, testedArchive{archives[i], result})
 		}
 	}
+	report.Passed = report.Tested - report.Failed
 
-	if failedCount > 0 {
-		return fmt.Errorf("%d archives failed verification", failedCount)
+	if report.Failed > 0 {
+		return p.Failure(report, fmt.Errorf("%d archives failed verification", report.Failed))
 	}
 
-	return nil
+	return p.Result(report)
 }
 
 func findArchives(dir string) ([]string, error) {
@@ -211,31 +268,31 @@ func findArchives(dir string) ([]string, error) {
 	return archives, err
 }
 
-func printTestResult(_ string, result *archive.TestResult) {
+func printTestResult(out io.Writer, _ string, result *archive.TestResult) {
 	if result.Passed {
-		fmt.Printf("✅ PASS: Archive integrity verified\n")
-		fmt.Printf("  Archive structure: VALID\n")
+		fmt.Fprintf(out, "✅ PASS: Archive integrity verified\n")
+		fmt.Fprintf(out, "  Archive structure: VALID\n")
 		if result.ChecksumValid {
-			fmt.Printf("  Checksums: ALL MATCH (%d files verified)\n", result.FilesVerified)
+			fmt.Fprintf(out, "  Checksums: ALL MATCH (%d files verified)\n", result.FilesVerified)
 		}
 		if result.MetadataValid {
-			fmt.Printf("  Metadata: CONSISTENT\n")
+			fmt.Fprintf(out, "  Metadata: CONSISTENT\n")
 		}
-		fmt.Printf("  Extraction: SUCCESS\n")
-		fmt.Printf("  Completeness: ALL ARTIFACTS PRESENT\n")
+		fmt.Fprintf(out, "  Extraction: SUCCESS\n")
+		fmt.Fprintf(out, "  Completeness: ALL ARTIFACTS PRESENT\n")
 	} else {
-		fmt.Printf("❌ FAIL: Archive verification failed\n")
+		fmt.Fprintf(out, "❌ FAIL: Archive verification failed\n")
 		if len(result.Errors) > 0 {
-			fmt.Printf("  Errors:\n")
+			fmt.Fprintf(out, "  Errors:\n")
 			for _, err := range result.Errors {
-				fmt.Printf("    - %s\n", err)
+				fmt.Fprintf(out, "    - %s\n", err)
 			}
 		}
 	}
-	fmt.Printf("\n")
+	fmt.Fprintf(out, "\n")
 }
 
-func printBatchSummary(archives []string, results []*archive.TestResult) {
+func printBatchSummary(out io.Writer, archives []string, results []*archive.TestResult) {
 	passed := 0
 	failed := 0
 	totalFiles := 0
@@ -249,23 +306,56 @@ func printBatchSummary(archives []string, results []*archive.TestResult) {
 		totalFiles += result.FilesVerified
 	}
 
-	fmt.Printf("Batch Summary:\n")
-	fmt.Printf("- Total archives tested: %d\n", len(archives))
-	fmt.Printf("- Passed: %d (%.1f%%)\n", passed, float64(passed)/float64(len(archives))*100)
+	fmt.Fprintf(out, "Batch Summary:\n")
+	fmt.Fprintf(out, "- Total archives tested: %d\n", len(archives))
+	fmt.Fprintf(out, "- Passed: %d (%.1f%%)\n", passed, float64(passed)/float64(len(archives))*100)
 	if failed > 0 {
-		fmt.Printf("- Failed: %d (%.1f%%)\n", failed, float64(failed)/float64(len(archives))*100)
+		fmt.Fprintf(out, "- Failed: %d (%.1f%%)\n", failed, float64(failed)/float64(len(archives))*100)
 
 		// List failed archives
-		fmt.Printf("\nFailed archives:\n")
+		fmt.Fprintf(out, "\nFailed archives:\n")
 		for i, result := range results {
 			if !result.Passed {
-				fmt.Printf("  ❌ %s\n", filepath.Base(archives[i]))
+				fmt.Fprintf(out, "  ❌ %s\n", filepath.Base(archives[i]))
 			}
 		}
 	}
-	fmt.Printf("- Total files verified: %d\n", totalFiles)
+	fmt.Fprintf(out, "- Total files verified: %d\n", totalFiles)
 
 	if passed == len(archives) {
-		fmt.Printf("\n✅ All archives passed verification!\n")
+		fmt.Fprintf(out, "\n✅ All archives passed verification!\n")
+	}
+}
+
+// notifyTested runs the post_test hooks for tested archives through the
+// storage manager, as the server does. Archives the registry knows are
+// described in full, others only by path.
+func notifyTested(results map[string]*archive.TestResult) {
+	if cfg, err := config.Load(); err != nil || len(cfg.Hooks.PostTest) == 0 {
+		return
+	}
+	_, mgr, cleanup, err := cmdutil.InitStorageManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: %v\n", err)
+		return
+	}
+	defer cleanup()
+
+	for path, result := range results {
+		abs, _ := filepath.Abs(path)
+		a := &storage.Archive{Name: filepath.Base(path), Path: abs}
+		if known, err := mgr.Registry().GetByPath(abs); err == nil {
+			a = known
+		}
+		mgr.NotifyTested(a, result.Passed, result.Errors)
+	}
+}
+
+// withTimeout is context.WithTimeout where a zero or negative timeout means
+// no limit
+func withTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
 	}
+	return context.WithTimeout(parent, timeout)
 }
@@ -11,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
 	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/output"
 	"github.com/adamstac/7zarch-go/internal/storage"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -73,6 +75,11 @@ func trashListCmd() *cobra.Command {
 				out = append(out, a)
 			}
 
+			if p := printerFor(cmd); p.Structured() {
+				return p.Result(struct {
+					Archives []trashRow `json:"archives"`
+				}{trashRows(out, cfg.Storage.RetentionDays)})
+			}
 			if flagOutput != "" {
 				return outputTrashList(out, flagOutput, cfg.Storage.RetentionDays, cmd.OutOrStdout())
 			}
@@ -101,7 +108,8 @@ func trashListCmd() *cobra.Command {
 	cmd.Flags().IntVar(&flagWithinDays, "within-days", 0, "Show items purging within N days (0=all)")
 	cmd.Flags().StringVar(&flagBefore, "before", "", "Only show items deleted before YYYY-MM-DD")
 	cmd.Flags().StringVar(&flagOutput, "output", "", "Output format: json|csv|yaml (default: human-readable)")
-	return cmd
+	deprecateOutputFlag(cmd)
+	return output.Annotate(cmd)
 }
 
 func trashPurgeCmd() *cobra.Command {
@@ -116,12 +124,11 @@ func trashPurgeCmd() *cobra.Command {
 		Use:   "purge",
 		Short: "Permanently delete trashed archives past retention",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, _ := config.Load()
-			mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
+			cfg, mgr, cleanup, err := cmdutil.InitStorageManager()
 			if err != nil {
-				return fmt.Errorf("failed to init storage: %w", err)
+				return err
 			}
-			defer mgr.Close()
+			defer cleanup()
 
 			archives, err := mgr.List()
 			if err != nil {
@@ -193,16 +200,21 @@ func trashPurgeCmd() *cobra.Command {
 				return nil
 			}
 
-			trashDir := mgr.GetTrashPath()
+			lock, err := mgr.Lock("trash purge")
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			purged := 0
 			for _, a := range eligible {
-				// Remove file if it appears under trash (managed archives)
-				if a.Managed && strings.HasPrefix(a.Path, trashDir+string(os.PathSeparator)) {
-					_ = os.Remove(a.Path)
+				if err := mgr.Purge(a); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "⚠️  Failed to purge %s: %v\n", a.Name, err)
+					continue
 				}
-				// Remove from registry
-				_ = mgr.Registry().Delete(a.Name)
+				purged++
 			}
-			fmt.Fprintf(cmd.OutOrStdout(), "Purged %d archives.\n", len(eligible))
+			fmt.Fprintf(cmd.OutOrStdout(), "Purged %d archives.\n", purged)
 			return nil
 		},
 	}
@@ -276,7 +288,25 @@ type trashRow struct {
 
 // outputTrashList outputs trash list in machine-readable format
 func outputTrashList(archives []*storage.Archive, format string, retentionDays int, out io.Writer) error {
+	rows := trashRows(archives, retentionDays)
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		return outputTrashCSV(rows, out)
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		defer enc.Close()
+		return enc.Encode(rows)
+	default:
+		return fmt.Errorf("unsupported output format: %s (supported: json, csv, yaml)", format)
+	}
+}
 
+// trashRows describes trashed archives with their purge dates
+func trashRows(archives []*storage.Archive, retentionDays int) []trashRow {
 	rows := make([]trashRow, 0, len(archives))
 	for _, a := range archives {
 		var purgeStr string
@@ -295,21 +325,7 @@ func outputTrashList(archives []*storage.Archive, format string, retentionDays i
 			DaysLeft:  days,
 		})
 	}
-
-	switch format {
-	case "json":
-		enc := json.NewEncoder(out)
-		enc.SetIndent("", "  ")
-		return enc.Encode(rows)
-	case "csv":
-		return outputTrashCSV(rows, out)
-	case "yaml":
-		enc := yaml.NewEncoder(out)
-		defer enc.Close()
-		return enc.Encode(rows)
-	default:
-		return fmt.Errorf("unsupported output format: %s (supported: json, csv, yaml)", format)
-	}
+	return rows
 }
 
 func outputTrashCSV(rows []trashRow, out io.Writer) error {
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+// UndoCmd returns the `undo` command reversing a journaled change
+func UndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo [event-id]",
+		Short: "Reverse a change recorded in the log",
+		Long: `Reverse a change from 'log': a move is moved back, a delete is restored from
+trash, tags and metadata are put back as they were. Without an event ID the
+most recent change that has not been undone is reversed, so repeated calls
+step back through the log.
+
+Additions and removals cannot be undone, nor can a change to a field that a
+later event changed again; undo the later event first. Undoing is itself
+journaled, so an undo can be undone.`,
+		Example: `  7zarch-go undo
+  7zarch-go undo 42 --dry-run`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			var id int64
+			if len(args) == 1 {
+				id, err = strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid event id: %s", args[0])
+				}
+			} else {
+				last, err := mgr.Registry().LastUndoable()
+				if err != nil {
+					return err
+				}
+				id = last.ID
+			}
+
+			plan, err := mgr.PlanUndo(id)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			ev := plan.Event
+			verb := "Undoing"
+			if getBool(cmd, "dry-run") {
+				verb = "Would undo"
+			}
+			fmt.Fprintf(out, "%s #%d %s of %s (%s)\n", verb, ev.ID, ev.Action, ev.ArchiveName, ev.Time.Format("2006-01-02 15:04:05"))
+			if plan.From != "" {
+				fmt.Fprintf(out, "  move %s -> %s\n", plan.From, plan.To)
+			}
+			for _, c := range plan.Changes {
+				fmt.Fprintf(out, "  %s\n", c)
+			}
+			if getBool(cmd, "dry-run") {
+				return nil
+			}
+
+			undo, err := mgr.Undo(plan)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "✅ Undone as event #%d\n", undo.ID)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("dry-run", false, "Show what would change without changing anything")
+	return cmd
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/display"
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func UsageCmd() *cobra.Command {
+	var months int
+	var trend string
+	var allVaults bool
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report managed storage usage against quotas and disk, with growth trend",
+		Long: `Report what managed storage holds against its quota and the free disk
+space, archives added per month, and the daily growth rate with projections
+of when the quota or disk will be reached.`,
+		Example: `  7zarch-go usage
+  7zarch-go usage --months 12 --trend 30d
+  7zarch-go usage --all-vaults`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			window, err := filter.ParseAge(trend)
+			if err != nil || window <= 0 {
+				return &errs.ValidationError{Field: "--trend", Value: trend, Message: "use a duration such as 30d or 12w"}
+			}
+			now := time.Now()
+			out := cmd.OutOrStdout()
+
+			if !allVaults {
+				cfg, mgr, cleanup, err := cmdutil.InitStorageManager()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+				rep, err := mgr.UsageReport(months, window, now)
+				if err != nil {
+					return err
+				}
+				printUsageReport(out, cfg.Storage.Vault, rep)
+				return nil
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			mgrs, cleanup, err := cmdutil.OpenVaults()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			for i, name := range cfg.Storage.VaultNames() {
+				rep, err := mgrs[name].UsageReport(months, window, now)
+				if err != nil {
+					return fmt.Errorf("vault %s: %w", name, err)
+				}
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				printUsageReport(out, name, rep)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&months, "months", 6, "Months of growth history to show")
+	cmd.Flags().StringVar(&trend, "trend", "90d", "Window the daily growth rate is measured over")
+	cmd.Flags().BoolVar(&allVaults, "all-vaults", false, "Report every configured vault")
+	return cmd
+}
+
+func printUsageReport(out io.Writer, vault string, rep *storage.UsageReport) {
+	q := rep.Quota
+	fmt.Fprintf(out, "📊 Storage usage: vault %s\n", vault)
+	fmt.Fprintf(out, "Path:      %s\n", rep.Path)
+
+	archives := fmt.Sprintf("%d", rep.Archives)
+	if rep.Trashed > 0 {
+		archives += fmt.Sprintf(" (%d in trash)", rep.Trashed)
+	}
+	if q.MaxArchives > 0 {
+		archives += fmt.Sprintf(" of %d quota (%.0f%%)", q.MaxArchives, percent(int64(rep.Archives), int64(q.MaxArchives)))
+	}
+	fmt.Fprintf(out, "Archives:  %s\n", archives)
+
+	size := display.FormatSize(rep.Bytes)
+	if rep.TrashedBytes > 0 {
+		size += fmt.Sprintf(" (%s in trash)", display.FormatSize(rep.TrashedBytes))
+	}
+	if q.MaxBytes > 0 {
+		size += fmt.Sprintf(" of %s quota (%.0f%%)", display.FormatSize(q.MaxBytes), percent(rep.Bytes, q.MaxBytes))
+	}
+	fmt.Fprintf(out, "Size:      %s\n", size)
+
+	if rep.Total > 0 {
+		disk := fmt.Sprintf("%s free of %s", display.FormatSize(int64(rep.Free)), display.FormatSize(int64(rep.Total)))
+		if q.MinFree > 0 {
+			disk += fmt.Sprintf(", keeping %s free", display.FormatSize(q.MinFree))
+		}
+		fmt.Fprintf(out, "Disk:      %s\n", disk)
+	}
+
+	fmt.Fprintf(out, "\nAdded per month:\n")
+	for _, m := range rep.Months {
+		fmt.Fprintf(out, "  %s  %4d archives  %10s\n", m.Month.Format("2006-01"), m.Archives, "+"+display.FormatSize(m.Bytes))
+	}
+
+	days := int(rep.Window.Hours() / 24)
+	fmt.Fprintf(out, "\nTrend: +%s/day over the last %d days\n", display.FormatSize(int64(rep.DailyGrowth)), days)
+	if rep.DailyGrowth <= 0 {
+		return
+	}
+	if q.MaxBytes > 0 {
+		fmt.Fprintf(out, "  Size quota reached %s\n", inDays(rep.DaysUntil(q.MaxBytes-rep.Bytes)))
+	}
+	if rep.Total > 0 {
+		fmt.Fprintf(out, "  Disk full %s\n", inDays(rep.DaysUntil(int64(rep.Free)-q.MinFree)))
+	}
+}
+
+func percent(n, of int64) float64 {
+	return float64(n) / float64(of) * 100
+}
+
+func inDays(days int) string {
+	if days <= 0 {
+		return "now"
+	}
+	return fmt.Sprintf("in about %d days", days)
+}
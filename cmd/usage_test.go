@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestUsageAndMoveQuota(t *testing.T) {
+	mgr := setupManagedStore(t, func(c *config.Config) {
+		c.Storage.QuotaSize = "1KB"
+		c.Storage.QuotaArchives = 10
+	})
+	held := &storage.Archive{Name: "held.7z", Path: filepath.Join(mgr.GetArchivesPath(), "held.7z"), Size: 800,
+		Created: time.Now(), Managed: true}
+	if err := mgr.Register(held); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, UsageCmd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsAll(out, []string{"vault default", "1 of 10 quota (10%)", "800 B of 1.0 KB quota (78%)", "Added per month:", "Trend: +"}) {
+		t.Fatalf("unexpected usage report:\n%s", out)
+	}
+
+	// Moving a 400 byte external archive in would exceed the size quota
+	src := filepath.Join(t.TempDir(), "big.7z")
+	if err := os.WriteFile(src, make([]byte, 400), 0600); err != nil {
+		t.Fatal(err)
+	}
+	big := &storage.Archive{Name: "big.7z", Path: src, Size: 400, Created: time.Now()}
+	if err := mgr.Register(big); err != nil {
+		t.Fatal(err)
+	}
+	_, err = runEWithArgs(t, MasMoveCmd(), big.UID)
+	var quotaErr *storage.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	if _, statErr := os.Stat(src); statErr != nil {
+		t.Errorf("archive moved despite the quota: %v", statErr)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// AddVaultFlag adds the global --vault flag to root. Every command loading
+// config then works against the chosen vault.
+func AddVaultFlag(root *cobra.Command) {
+	var vault string
+	root.PersistentFlags().StringVar(&vault, "vault", "", "Vault to use (default: storage.default_vault, else managed_path)")
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		config.SelectVault(vault)
+		_, err := config.Load()
+		return err
+	}
+}
+
+func VaultCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "vault", Short: "Named managed storage roots (vaults)"}
+	cmd.AddCommand(vaultListCmd())
+	return cmd
+}
+
+func vaultListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List configured vaults with their paths and archive counts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			mgrs, cleanup, err := cmdutil.OpenVaults()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "%-2s%-12s  %8s  %s\n", "", "VAULT", "ARCHIVES", "PATH")
+			for _, name := range cfg.Storage.VaultNames() {
+				mgr := mgrs[name]
+				archives, err := mgr.List()
+				if err != nil {
+					return fmt.Errorf("vault %s: %w", name, err)
+				}
+				marker := ""
+				if name == cfg.Storage.Vault {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "%-2s%-12s  %8d  %s\n", marker, name, len(archives), mgr.GetBasePath())
+			}
+			return nil
+		},
+	}
+}
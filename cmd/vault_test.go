@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestVaultListAndMoveToVault(t *testing.T) {
+	cold := t.TempDir()
+	mgr := setupManagedStore(t, func(c *config.Config) {
+		c.Storage.Vaults = map[string]config.VaultConfig{"cold": {Path: cold}}
+	})
+	t.Cleanup(func() { config.SelectVault("") })
+
+	path := filepath.Join(mgr.GetArchivesPath(), "photos.7z")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	arc := &storage.Archive{Name: "photos.7z", Path: path, Size: 4, Created: time.Now(), Managed: true, Tags: []string{"photos"}}
+	if err := mgr.Register(arc); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runEWithArgs(t, vaultListCmd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsAll(out, []string{"* default", "cold", cold}) {
+		t.Fatalf("unexpected vault list:\n%s", out)
+	}
+
+	move := MasMoveCmd()
+	_ = move.Flags().Set("to-vault", "cold")
+	out, err = runEWithArgs(t, move, arc.UID)
+	if err != nil || !strings.Contains(out, "Moved photos.7z to vault cold") {
+		t.Fatalf("move --to-vault: %v\n%s", err, out)
+	}
+	if _, err := mgr.Registry().GetByUID(arc.UID); err == nil {
+		t.Error("archive still registered in the default vault")
+	}
+
+	// The archive now lives in the cold vault's registry and storage
+	config.SelectVault("cold")
+	_, coldMgr, cleanup, err := cmdutil.InitStorageManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	moved, err := coldMgr.Registry().GetByUID(arc.UID)
+	if err != nil || !moved.HasTags("photos") || !strings.HasPrefix(moved.Path, cold) {
+		t.Fatalf("archive in cold vault = %+v, %v", moved, err)
+	}
+
+	move = MasMoveCmd()
+	_ = move.Flags().Set("to-vault", "cold")
+	if _, err := runEWithArgs(t, move, arc.UID); err == nil {
+		t.Error("expected an error moving an archive into its own vault")
+	}
+	move = MasMoveCmd()
+	_ = move.Flags().Set("to-vault", "archive")
+	if _, err := runEWithArgs(t, move, arc.UID); err == nil {
+		t.Error("expected an error for an unknown vault")
+	}
+}
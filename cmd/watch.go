@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// WatchCmd returns the `watch` command that keeps managed storage and the
+// registry in sync while running.
+func WatchCmd() *cobra.Command {
+	var (
+		flagDebounce     time.Duration
+		flagSettle       time.Duration
+		flagPoll         bool
+		flagPollInterval time.Duration
+		flagOnce         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch managed storage and keep the registry in sync",
+		Long: `Run in the foreground and watch the managed archives directory.
+
+Archives copied into managed storage by hand are registered, archives that
+reappear are marked present with a fresh last-seen time, and archives removed
+outside of 7zarch-go are marked missing. Events are debounced and files that
+are still growing are re-checked before being registered.`,
+		Example: `  # Watch managed storage until interrupted
+  7zarch-go watch
+
+  # Use polling (e.g. for network mounts)
+  7zarch-go watch --poll --poll-interval 10s
+
+  # Reconcile once and exit
+  7zarch-go watch --once`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, mgr, cleanup, err := cmdutil.InitStorageManager()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			out := cmd.OutOrStdout()
+			if flagOnce {
+				report, err := mgr.Reconcile()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "✅ Sync complete: %d present, %d registered, %d missing\n",
+					report.Seen, report.Registered, report.Missing)
+				for _, e := range report.Errors {
+					fmt.Fprintf(out, "⚠️  %v\n", e)
+				}
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			daemon := watch.NewDaemon(mgr, watch.Options{
+				Debounce:     flagDebounce,
+				Settle:       flagSettle,
+				Poll:         flagPoll,
+				PollInterval: flagPollInterval,
+				Logf: func(format string, a ...interface{}) {
+					fmt.Fprintf(out, "%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+				},
+			})
+
+			fmt.Fprintf(out, "👀 Watching %s (Ctrl+C to stop)\n", mgr.GetArchivesPath())
+			return daemon.Run(ctx)
+		},
+	}
+
+	cmd.Flags().DurationVar(&flagDebounce, "debounce", 2*time.Second, "Quiet period before a changed file is synced")
+	cmd.Flags().DurationVar(&flagSettle, "settle", 500*time.Millisecond, "Size-stability check interval for files being written")
+	cmd.Flags().BoolVar(&flagPoll, "poll", false, "Use polling instead of native file notifications")
+	cmd.Flags().DurationVar(&flagPollInterval, "poll-interval", watch.DefaultPollInterval, "Polling interval when --poll is set")
+	cmd.Flags().BoolVar(&flagOnce, "once", false, "Reconcile managed storage once and exit")
+
+	return cmd
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/cmdutil"
+	"github.com/adamstac/7zarch-go/internal/jobs"
+	"github.com/spf13/cobra"
+)
+
+// WorkerCmd returns the `worker` command that runs queued background jobs
+func WorkerCmd() *cobra.Command {
+	var (
+		flagConcurrency map[string]int
+		flagPoll        time.Duration
+		flagOnce        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Short: "Run queued background jobs",
+		Long: `Run commands queued with --background until stopped. Each job runs as
+its own process with its output written to a log ('7zarch-go jobs logs').
+
+How many jobs of each type run at once is set with --concurrency, then
+jobs.concurrency in the config; by default one create, two tests, one batch
+and two uploads. Several workers may share a queue; the limits apply across
+all of them.
+
+The worker ignores hangups, so it keeps running when the SSH session that
+started it drops. Stopping it with Ctrl+C or SIGTERM interrupts running jobs
+and puts them back in the queue, and jobs left running by a worker that was
+killed are picked up again the next time a worker starts.`,
+		Example: `  # Run jobs as they are queued
+  7zarch-go worker
+
+  # Run everything queued, then exit
+  7zarch-go worker --once
+
+  # Allow two archives to be created at once
+  7zarch-go worker --concurrency create=2`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, mgr, cleanup, err := cmdutil.InitJobQueue()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			concurrency := make(map[string]int)
+			for typ, n := range cfg.Jobs.Concurrency {
+				concurrency[typ] = n
+			}
+			for typ, n := range flagConcurrency {
+				concurrency[typ] = n
+			}
+
+			out := cmd.OutOrStdout()
+			w := jobs.NewWorker(mgr, jobs.Options{
+				Concurrency: concurrency,
+				Poll:        flagPoll,
+				Once:        flagOnce,
+				Logf: func(format string, a ...interface{}) {
+					fmt.Fprintf(out, "%s %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+				},
+			})
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			// Keep running when the terminal goes away
+			signal.Ignore(syscall.SIGHUP, syscall.SIGPIPE)
+
+			if flagOnce {
+				fmt.Fprintf(out, "⚙️  Running queued jobs from %s\n", mgr.GetBasePath())
+			} else {
+				fmt.Fprintf(out, "⚙️  Waiting for jobs in %s (Ctrl+C to stop)\n", mgr.GetBasePath())
+			}
+			return w.Run(ctx)
+		},
+	}
+
+	cmd.Flags().StringToIntVar(&flagConcurrency, "concurrency", nil, "Most jobs of a type to run at once, e.g. create=1,test=4 (default jobs.concurrency)")
+	cmd.Flags().DurationVar(&flagPoll, "poll", 2*time.Second, "How often to check for new jobs and cancel requests")
+	cmd.Flags().BoolVar(&flagOnce, "once", false, "Exit once no queued job can be started")
+	return cmd
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -105,7 +106,10 @@ func TestCompleteTrashWorkflow(t *testing.T) {
 	}
 
 	opts := listFilters{onlyDeleted: true}
-	filtered := applyAllFilters(archives, opts)
+	filtered, err := applyAllFilters(archives, opts)
+	if err != nil {
+		t.Fatalf("Failed to filter archives: %v", err)
+	}
 
 	if len(filtered) != 1 {
 		t.Errorf("Expected 1 deleted archive, got %d", len(filtered))
@@ -225,7 +229,7 @@ func TestMachineReadableOutput(t *testing.T) {
 	// Test JSON output
 	t.Run("JSON Output", func(t *testing.T) {
 		archives := []*storage.Archive{archive}
-		err := outputJSON(archives)
+		err := outputJSON(io.Discard, archives)
 		if err != nil {
 			t.Errorf("JSON output failed: %v", err)
 		}
@@ -234,7 +238,7 @@ func TestMachineReadableOutput(t *testing.T) {
 	// Test CSV output
 	t.Run("CSV Output", func(t *testing.T) {
 		archives := []*storage.Archive{archive}
-		err := outputCSV(archives)
+		err := outputCSV(io.Discard, archives)
 		if err != nil {
 			t.Errorf("CSV output failed: %v", err)
 		}
@@ -243,7 +247,7 @@ func TestMachineReadableOutput(t *testing.T) {
 	// Test YAML output
 	t.Run("YAML Output", func(t *testing.T) {
 		archives := []*storage.Archive{archive}
-		err := outputYAML(archives)
+		err := outputYAML(io.Discard, archives)
 		if err != nil {
 			t.Errorf("YAML output failed: %v", err)
 		}
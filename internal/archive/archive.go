@@ -2,8 +2,6 @@ package archive
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
 )
 
 // Archive represents a 7z archive with metadata
@@ -65,12 +65,24 @@ type CreateOptions struct {
 	// Config-driven thresholds (percent values); 0 means use defaults
 	MediaThreshold int
 	DocsThreshold  int
+	// Out receives the content analysis and progress notes; nil discards them
+	Out io.Writer
+}
+
+// partialPath returns where Create writes the archive for output until it
+// is complete
+func partialPath(output string) string {
+	return filepath.Join(filepath.Dir(output), "."+filepath.Base(output)+".partial")
 }
 
 // Create creates a new archive
 func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, error) {
 	var profile CompressionProfile
 	var err error
+	out := opts.Out
+	if out == nil {
+		out = io.Discard
+	}
 
 	// Always analyze content to educate the user, with config-driven thresholds
 	mediaTh := opts.MediaThreshold
@@ -84,28 +96,28 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 	stats, recommended, analyzeErr := AnalyzeContentWithThresholds(opts.Source, mediaTh, docsTh)
 	if analyzeErr != nil {
 		// Don't fail on analysis error, just skip the educational output
-		fmt.Printf("⚠️  Content analysis unavailable: %v\n\n", analyzeErr)
+		fmt.Fprintf(out, "⚠️  Content analysis unavailable: %v\n\n", analyzeErr)
 	} else {
 		// Show content breakdown to educate user
-		fmt.Printf("📊 Content Analysis:\n")
-		fmt.Printf("  Total: %d files, %.1f MB\n", stats.TotalFiles, float64(stats.TotalBytes)/(1024*1024))
+		fmt.Fprintf(out, "📊 Content Analysis:\n")
+		fmt.Fprintf(out, "  Total: %d files, %.1f MB\n", stats.TotalFiles, float64(stats.TotalBytes)/(1024*1024))
 		if stats.MediaFiles > 0 {
 			mediaPercent := float64(stats.MediaBytes) / float64(stats.TotalBytes) * 100
-			fmt.Printf("  Media: %d files (%.1f%%), %.1f MB\n", stats.MediaFiles, mediaPercent, float64(stats.MediaBytes)/(1024*1024))
+			fmt.Fprintf(out, "  Media: %d files (%.1f%%), %.1f MB\n", stats.MediaFiles, mediaPercent, float64(stats.MediaBytes)/(1024*1024))
 		}
 		if stats.DocumentFiles > 0 {
 			docPercent := float64(stats.DocumentBytes) / float64(stats.TotalBytes) * 100
-			fmt.Printf("  Documents: %d files (%.1f%%), %.1f MB\n", stats.DocumentFiles, docPercent, float64(stats.DocumentBytes)/(1024*1024))
+			fmt.Fprintf(out, "  Documents: %d files (%.1f%%), %.1f MB\n", stats.DocumentFiles, docPercent, float64(stats.DocumentBytes)/(1024*1024))
 		}
 		if stats.CompressedFiles > 0 {
 			compPercent := float64(stats.CompressedBytes) / float64(stats.TotalBytes) * 100
-			fmt.Printf("  Compressed: %d files (%.1f%%), %.1f MB\n", stats.CompressedFiles, compPercent, float64(stats.CompressedBytes)/(1024*1024))
+			fmt.Fprintf(out, "  Compressed: %d files (%.1f%%), %.1f MB\n", stats.CompressedFiles, compPercent, float64(stats.CompressedBytes)/(1024*1024))
 		}
 		if stats.OtherFiles > 0 {
 			otherPercent := float64(stats.OtherBytes) / float64(stats.TotalBytes) * 100
-			fmt.Printf("  Other: %d files (%.1f%%), %.1f MB\n", stats.OtherFiles, otherPercent, float64(stats.OtherBytes)/(1024*1024))
+			fmt.Fprintf(out, "  Other: %d files (%.1f%%), %.1f MB\n", stats.OtherFiles, otherPercent, float64(stats.OtherBytes)/(1024*1024))
 		}
-		fmt.Printf("\n")
+		fmt.Fprintf(out, "\n")
 	}
 
 	// Determine which compression profile to use
@@ -116,9 +128,9 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 		if !exists {
 			return nil, fmt.Errorf("unknown compression profile: %s", opts.Profile)
 		}
-		fmt.Printf("🎯 Using Profile: %s\n", profile.Name)
-		fmt.Printf("   %s\n", profile.Description)
-		fmt.Printf("   Settings: Level %d, Dictionary %s, Fast bytes %d\n\n",
+		fmt.Fprintf(out, "🎯 Using Profile: %s\n", profile.Name)
+		fmt.Fprintf(out, "   %s\n", profile.Description)
+		fmt.Fprintf(out, "   Settings: Level %d, Dictionary %s, Fast bytes %d\n\n",
 			profile.Level, profile.DictionarySize, profile.FastBytes)
 	} else if opts.CompressionLevel > 0 {
 		// Manual compression level specified - use traditional mode
@@ -132,9 +144,9 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 
 		// Educational message about available optimizations
 		if analyzeErr == nil {
-			fmt.Printf("💡 Optimization Tip: Based on your content, --profile %s might be faster\n",
+			fmt.Fprintf(out, "💡 Optimization Tip: Based on your content, --profile %s might be faster\n",
 				strings.ToLower(recommended.Name))
-			fmt.Printf("   Run '7zarch-go profiles' to see all available profiles\n\n")
+			fmt.Fprintf(out, "   Run '7zarch-go profiles' to see all available profiles\n\n")
 		}
 	} else {
 		// Smart compression by default - use recommended profile
@@ -142,20 +154,29 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 			// Fallback to balanced if analysis failed
 			profile, _ = GetProfile("balanced")
 		} else {
-			fmt.Printf("🎯 Using Smart Profile: %s\n", recommended.Name)
-			fmt.Printf("   %s\n", recommended.Description)
-			fmt.Printf("   Settings: Level %d, Dictionary %s, Fast bytes %d\n\n",
+			fmt.Fprintf(out, "🎯 Using Smart Profile: %s\n", recommended.Name)
+			fmt.Fprintf(out, "   %s\n", recommended.Description)
+			fmt.Fprintf(out, "   Settings: Level %d, Dictionary %s, Fast bytes %d\n\n",
 				recommended.Level, recommended.DictionarySize, recommended.FastBytes)
 
 			profile = recommended
 		}
 	}
 
+	// 7z writes to a partial file beside the output, renamed once complete,
+	// so a canceled or killed run never leaves a truncated archive in place.
+	// A partial file left by a killed run would be updated rather than
+	// replaced, so remove it first.
+	partial := partialPath(opts.Output)
+	if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove partial archive: %w", err)
+	}
+
 	// Build 7z command
 	args := []string{"a"}
 
 	// Add output file
-	args = append(args, opts.Output)
+	args = append(args, partial)
 
 	// Force overwrite without prompting
 	args = append(args, "-y")
@@ -189,8 +210,13 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 	cmd := exec.CommandContext(ctx, "7z", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		_ = os.Remove(partial)
 		return nil, fmt.Errorf("7z failed: %w\nOutput: %s", err, string(output))
 	}
+	if err := os.Rename(partial, opts.Output); err != nil {
+		_ = os.Remove(partial)
+		return nil, fmt.Errorf("failed to move archive into place: %w", err)
+	}
 
 	// Get archive info
 	info, err := os.Stat(opts.Output)
@@ -199,7 +225,7 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 	}
 
 	// Calculate checksum
-	checksum, err := calculateFileChecksum(opts.Output)
+	checksum, err := storage.FileChecksum(opts.Output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate checksum: %w", err)
 	}
@@ -226,17 +252,17 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 		// Create log file
 		logPath := archive.Path + ".log"
 		if err := CreateLogFile(logPath, archive, opts.Source); err != nil {
-			fmt.Printf("Warning: Failed to create log: %v\n", err)
+			fmt.Fprintf(out, "Warning: Failed to create log: %v\n", err)
 		} else {
-			fmt.Printf("Log created: %s\n", logPath)
+			fmt.Fprintf(out, "Log created: %s\n", logPath)
 		}
 
 		// Create checksum file
 		checksumPath := archive.Path + ".sha256"
 		if err := CreateChecksumFile(checksumPath, archive); err != nil {
-			fmt.Printf("Warning: Failed to create checksum: %v\n", err)
+			fmt.Fprintf(out, "Warning: Failed to create checksum: %v\n", err)
 		} else {
-			fmt.Printf("Checksum created: %s\n", checksumPath)
+			fmt.Fprintf(out, "Checksum created: %s\n", checksumPath)
 		}
 	}
 
@@ -245,12 +271,12 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Archive, err
 
 // TestResult contains the results of archive testing
 type TestResult struct {
-	Passed        bool
-	ChecksumValid bool
-	MetadataValid bool
-	FilesVerified int
-	Errors        []string
-	Duration      time.Duration
+	Passed        bool          `json:"passed"`
+	ChecksumValid bool          `json:"checksum_valid"`
+	MetadataValid bool          `json:"metadata_valid"`
+	FilesVerified int           `json:"files_verified"`
+	Errors        []string      `json:"errors"`
+	Duration      time.Duration `json:"duration_ns"`
 }
 
 // Test verifies archive integrity
@@ -343,7 +369,7 @@ func (m *Manager) verifyChecksum(archivePath, checksumFile string) error {
 	expectedChecksum := parts[0]
 
 	// Calculate actual checksum
-	actualChecksum, err := calculateFileChecksum(archivePath)
+	actualChecksum, err := storage.FileChecksum(archivePath)
 	if err != nil {
 		return fmt.Errorf("failed to calculate checksum: %w", err)
 	}
@@ -392,22 +418,6 @@ func (m *Manager) listArchiveFiles(ctx context.Context, archivePath string) (int
 
 // Helper functions
 
-func calculateFileChecksum(path string) (string, error) {
-	// #nosec G304: path comes from validated CLI argument or managed registry
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
 func calculateDirectorySize(path string) (int64, error) {
 	var size int64
 	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
@@ -1,5 +1,7 @@
 package archive
 
+import "testing"
+
 type sltCase struct {
 	in   string
 	want int
@@ -29,3 +31,23 @@ func Example_countPathsInSlt() {
 	}
 	// Output:
 }
+
+func TestParseSltMembers(t *testing.T) {
+	out := "Listing archive: a.7z\n\n--\nPath = a.7z\nType = 7z\n\n----------\n" +
+		"Path = docs\nSize = 0\nAttributes = D....\n\n" +
+		"Path = docs/readme.txt\nSize = 12\nAttributes = A....\nCRC = 1A2B3C4D\n\n" +
+		"Path = photo.jpg\nSize = 2048\nFolder = -\nCRC = DEADBEEF\n"
+	got := parseSltMembers(out)
+	want := []Member{
+		{Path: "docs/readme.txt", Size: 12, CRC: "1A2B3C4D"},
+		{Path: "photo.jpg", Size: 2048, CRC: "DEADBEEF"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d members, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("member %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
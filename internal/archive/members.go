@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Member is a single file entry inside an archive
+type Member struct {
+	Path string
+	Size int64
+	CRC  string
+}
+
+// ListMembers returns the file entries of an archive using 7z's structured
+// listing. Directories are omitted.
+func (m *Manager) ListMembers(ctx context.Context, archivePath string) ([]Member, error) {
+	cmd := exec.CommandContext(ctx, "7z", "l", "-slt", "-scsUTF-8", archivePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w\nOutput: %s", err, string(output))
+	}
+	return parseSltMembers(string(output)), nil
+}
+
+// parseSltMembers parses `7z l -slt` output into members. The archive's own
+// header block (before the "----------" separator) is skipped.
+func parseSltMembers(output string) []Member {
+	var members []Member
+	var cur *Member
+	isDir := false
+	inEntries := !strings.Contains(output, "\n----------")
+
+	flush := func() {
+		if cur != nil && !isDir {
+			members = append(members, *cur)
+		}
+		cur = nil
+		isDir = false
+	}
+
+	for _, raw := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "----------" {
+			inEntries = true
+			continue
+		}
+		if !inEntries {
+			continue
+		}
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			key, value = strings.TrimSuffix(line, " ="), ""
+		}
+		switch key {
+		case "Path":
+			flush()
+			cur = &Member{Path: value}
+		case "Size":
+			if cur != nil {
+				cur.Size, _ = strconv.ParseInt(value, 10, 64)
+			}
+		case "CRC":
+			if cur != nil {
+				cur.CRC = value
+			}
+		case "Folder":
+			isDir = value == "+"
+		case "Attributes":
+			isDir = isDir || strings.HasPrefix(value, "D")
+		}
+	}
+	flush()
+	return members
+}
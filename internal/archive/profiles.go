@@ -8,13 +8,13 @@ import (
 
 // CompressionProfile defines optimal 7z parameters for different content types
 type CompressionProfile struct {
-	Name           string
-	Description    string
-	Level          int    // -mx parameter
-	DictionarySize string // -md parameter
-	FastBytes      int    // -mfb parameter
-	SolidMode      bool   // -ms parameter
-	Algorithm      string // compression algorithm
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Level          int    `json:"level"`           // -mx parameter
+	DictionarySize string `json:"dictionary_size"` // -md parameter
+	FastBytes      int    `json:"fast_bytes"`      // -mfb parameter
+	SolidMode      bool   `json:"solid_mode"`      // -ms parameter
+	Algorithm      string `json:"algorithm"`       // compression algorithm
 }
 
 // Predefined compression profiles
@@ -199,3 +199,25 @@ func recommendProfileWithThresholds(stats *ContentStats, mediaThreshold int, doc
 	}
 	return profiles["balanced"]
 }
+
+// Typical archived-to-source ratios by kind of content, used to estimate an
+// archive's size before any history exists. They err on the large side so
+// space checks do not pass archives that then run out of room.
+const (
+	mediaRatio    = 1.0 // already compressed
+	documentRatio = 0.5
+	otherRatio    = 0.8
+)
+
+// EstimateSize predicts the size of an archive of the analyzed content.
+// ratio is the archived-to-source ratio seen before with the profile; 0
+// estimates each kind of content with a typical ratio instead.
+func EstimateSize(stats *ContentStats, ratio float64) int64 {
+	if ratio > 0 {
+		return int64(float64(stats.TotalBytes) * ratio)
+	}
+	est := float64(stats.MediaBytes+stats.CompressedBytes)*mediaRatio +
+		float64(stats.DocumentBytes)*documentRatio +
+		float64(stats.OtherBytes)*otherRatio
+	return int64(est)
+}
@@ -29,3 +29,13 @@ func Test_recommendProfileWithThresholds(t *testing.T) {
 		t.Errorf("balanced: want Balanced, got %s", got.Name)
 	}
 }
+
+func TestEstimateSize(t *testing.T) {
+	stats := &ContentStats{TotalBytes: 1000, MediaBytes: 400, DocumentBytes: 400, OtherBytes: 200}
+	if got := EstimateSize(stats, 0); got != 400+200+160 {
+		t.Errorf("without history: got %d", got)
+	}
+	if got := EstimateSize(stats, 0.25); got != 250 {
+		t.Errorf("with history ratio 0.25: got %d", got)
+	}
+}
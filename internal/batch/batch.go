@@ -2,14 +2,11 @@ package batch
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/adamstac/7zarch-go/internal/storage"
@@ -30,6 +27,7 @@ type ManagerInterface interface {
 	Delete(uid string) error
 	Registry() *storage.Registry
 	GetBasePath() string
+	MoveArchive(archive *storage.Archive, dst string, update func(*storage.Archive)) error
 }
 
 type Processor struct {
@@ -84,66 +82,13 @@ func (p *Processor) moveArchive(archive *storage.Archive, destBasePath string) e
 		dest = filepath.Join(dest, name)
 	}
 
-	// Create destination directory if needed
-	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dest), err)
-	}
-
-	// Prevent accidental overwrite
-	if info, err := os.Stat(dest); err == nil && !info.IsDir() {
-		return fmt.Errorf("destination file already exists: %s", dest)
-	}
-
-	// Attempt rename first (fastest for same filesystem)
-	if err := os.Rename(archive.Path, dest); err != nil {
-		// Handle cross-device rename (EXDEV) with copy+remove fallback
-		var linkErr *os.LinkError
-		if errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV) {
-			if err := p.copyFile(archive.Path, dest); err != nil {
-				return fmt.Errorf("copy fallback failed: %w", err)
-			}
-			if err := os.Remove(archive.Path); err != nil {
-				return fmt.Errorf("cleanup source failed after copy: %w", err)
-			}
-		} else {
-			return fmt.Errorf("failed to move %s to %s: %w", archive.Path, dest, err)
-		}
-	}
-
-	// Update registry with new path and managed status
-	archive.Path = dest
-	// Check if destination is under managed storage
-	rel, _ := filepath.Rel(p.manager.GetBasePath(), dest)
-	up := ".." + string(os.PathSeparator)
-	archive.Managed = rel != ".." && !strings.HasPrefix(rel, up)
-
-	return p.manager.Registry().Update(archive)
-}
-
-// copyFile copies src to dst with mode preservation
-func (p *Processor) copyFile(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	if _, err = io.Copy(out, in); err != nil {
-		return err
-	}
-	if err = out.Sync(); err != nil {
-		return err
-	}
-	if info, err := os.Stat(src); err == nil {
-		_ = os.Chmod(dst, info.Mode())
-	}
-	return nil
+	// Move the file and record the new path and managed status together;
+	// the manager repairs a move interrupted by a crash
+	return p.manager.MoveArchive(archive, dest, func(a *storage.Archive) {
+		rel, _ := filepath.Rel(p.manager.GetBasePath(), dest)
+		up := ".." + string(os.PathSeparator)
+		a.Managed = rel != ".." && !strings.HasPrefix(rel, up)
+	})
 }
 
 func (p *Processor) processWithProgress(ctx context.Context, archives []*storage.Archive, callback ProgressCallback, operation func(*storage.Archive) error) error {
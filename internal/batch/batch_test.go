@@ -52,6 +52,14 @@ func (m *mockManager) GetBasePath() string {
 	return m.basePath
 }
 
+func (m *mockManager) MoveArchive(archive *storage.Archive, dst string, update func(*storage.Archive)) error {
+	archive.Path = dst
+	if update != nil {
+		update(archive)
+	}
+	return m.registry.Update(archive)
+}
+
 func TestProcessor_Move_Success(t *testing.T) {
 	// Skip this test since it requires actual file system operations
 	// Focus on testing the core batch processing logic instead
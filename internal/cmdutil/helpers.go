@@ -2,9 +2,12 @@ package cmdutil
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/adamstac/7zarch-go/internal/config"
 	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/hooks"
 	"github.com/adamstac/7zarch-go/internal/storage"
 )
 
@@ -15,11 +18,58 @@ func InitStorageManager() (*config.Config, *storage.Manager, func(), error) {
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	return openManager(cfg)
+}
+
+// InitVaultManager is InitStorageManager for a named vault rather than the
+// one selected with --vault
+func InitVaultManager(name string) (*config.Config, *storage.Manager, func(), error) {
+	cfg, err := config.LoadVault(name)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return openManager(cfg)
+}
 
+// InitJobQueue opens the default vault, whose registry holds the background
+// job queue for every vault
+func InitJobQueue() (*config.Config, *storage.Manager, func(), error) {
+	return InitVaultManager(config.DefaultVaultName)
+}
+
+// OpenVaults opens a storage manager for every configured vault, keyed by
+// vault name
+func OpenVaults() (map[string]*storage.Manager, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	mgrs := make(map[string]*storage.Manager)
+	cleanup := func() {
+		for _, m := range mgrs {
+			m.Close()
+		}
+	}
+	for _, name := range cfg.Storage.VaultNames() {
+		_, mgr, _, err := InitVaultManager(name)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("vault %s: %w", name, err)
+		}
+		mgrs[name] = mgr
+	}
+	return mgrs, cleanup, nil
+}
+
+func openManager(cfg *config.Config) (*config.Config, *storage.Manager, func(), error) {
 	mgr, err := storage.NewManager(cfg.Storage.ManagedPath)
 	if err != nil {
 		return cfg, nil, nil, fmt.Errorf("failed to initialize storage manager: %w", err)
 	}
+	if err := ConfigureManager(mgr, cfg); err != nil {
+		mgr.Close()
+		return cfg, nil, nil, err
+	}
 
 	cleanup := func() {
 		mgr.Close()
@@ -28,6 +78,61 @@ func InitStorageManager() (*config.Config, *storage.Manager, func(), error) {
 	return cfg, mgr, cleanup, nil
 }
 
+// ConfigureManager applies storage settings from config to a manager
+func ConfigureManager(mgr *storage.Manager, cfg *config.Config) error {
+	layout, err := storage.ParseLayout(cfg.Storage.AutoOrganize)
+	if err != nil {
+		return &errs.ConfigurationError{
+			Setting: "storage.auto_organize",
+			Value:   cfg.Storage.AutoOrganize,
+			Message: "supported values: flat, by_date, by_type",
+		}
+	}
+	mgr.SetLayout(layout)
+
+	mgr.SetBackupDir(cfg.Storage.BackupDir)
+
+	quota, err := QuotaFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	mgr.SetQuota(quota)
+
+	runner, err := Hooks(cfg)
+	if err != nil {
+		return err
+	}
+	mgr.SetLifecycleHook(runner.LifecycleHook())
+
+	if cfg.Storage.BackupInterval != "" {
+		interval, err := filter.ParseAge(cfg.Storage.BackupInterval)
+		if err != nil || interval <= 0 {
+			return &errs.ConfigurationError{
+				Setting: "storage.backup_interval",
+				Value:   cfg.Storage.BackupInterval,
+				Message: "use a duration such as 12h, 1d or 1w",
+			}
+		}
+		keep := cfg.Storage.BackupKeep
+		if keep < 1 {
+			keep = 1
+		}
+		// A failed backup should not stop the command that opened storage
+		if _, err := mgr.BackupIfDue(interval, keep); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Warning: automatic registry backup failed: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// Hooks returns a runner for the lifecycle hooks configured under hooks:
+func Hooks(cfg *config.Config) (*hooks.Runner, error) {
+	if err := hooks.Validate(cfg.Hooks); err != nil {
+		return nil, &errs.ConfigurationError{Setting: "hooks", Message: err.Error()}
+	}
+	return hooks.New(cfg.Hooks, hooks.Options{Vault: cfg.Storage.Vault}), nil
+}
+
 // LoadConfigOrDefault attempts to load config but falls back to defaults
 // Used in create command and similar cases where config errors are non-fatal
 func LoadConfigOrDefault() *config.Config {
@@ -50,4 +155,27 @@ func HandleResolverError(err error, id string) error {
 		return errs.NewArchiveNotFound(id)
 	}
 	return err
-}
\ No newline at end of file
+}
+
+// QuotaFromConfig reads the quota and free space settings of the configured
+// vault
+func QuotaFromConfig(cfg *config.Config) (storage.Quota, error) {
+	quota := storage.Quota{MaxArchives: cfg.Storage.QuotaArchives}
+	for _, s := range []struct {
+		setting, value string
+		dst            *int64
+	}{
+		{"storage.quota_size", cfg.Storage.QuotaSize, &quota.MaxBytes},
+		{"storage.min_free_space", cfg.Storage.MinFreeSpace, &quota.MinFree},
+	} {
+		if s.value == "" {
+			continue
+		}
+		n, err := filter.ParseSize(s.value)
+		if err != nil || n < 0 {
+			return quota, &errs.ConfigurationError{Setting: s.setting, Value: s.value, Message: "use a size such as 500MB or 2TB"}
+		}
+		*s.dst = n
+	}
+	return quota, nil
+}
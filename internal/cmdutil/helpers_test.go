@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/adamstac/7zarch-go/internal/storage"
@@ -48,6 +49,45 @@ func TestInitStorageManager(t *testing.T) {
 	cleanup()
 }
 
+func TestInitStorageManagerPeriodicBackup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	managed := filepath.Join(home, "managed")
+	backups := filepath.Join(home, "registry-backups")
+	configContent := `storage:
+  managed_path: "` + managed + `"
+  backup_interval: "1d"
+  backup_keep: 3
+  backup_dir: "` + backups + `"
+`
+	if err := os.WriteFile(filepath.Join(home, ".7zarch-go-config"), []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, cleanup, err := InitStorageManager()
+		if err != nil {
+			t.Fatalf("InitStorageManager failed: %v", err)
+		}
+		cleanup()
+	}
+	list, err := storage.ListBackups(backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected one backup within the interval, got %d", len(list))
+	}
+
+	bad := strings.Replace(configContent, `"1d"`, `"soon"`, 1)
+	if err := os.WriteFile(filepath.Join(home, ".7zarch-go-config"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := InitStorageManager(); err == nil || !strings.Contains(err.Error(), "backup_interval") {
+		t.Fatalf("expected backup_interval configuration error, got %v", err)
+	}
+}
+
 func TestLoadConfigOrDefault(t *testing.T) {
 	// Test with invalid config path (should return defaults)
 	oldHome := os.Getenv("HOME")
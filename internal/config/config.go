@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	errs "github.com/adamstac/7zarch-go/internal/errors"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,6 +20,10 @@ type Config struct {
 	TrueNAS     TrueNASConfig            `yaml:"truenas"`
 	Presets     map[string]PresetConfig  `yaml:"presets"`
 	Storage     StorageConfig            `yaml:"storage"`
+	Server      ServerConfig             `yaml:"server"`
+	Jobs        JobsConfig               `yaml:"jobs"`
+	Schedules   []Schedule               `yaml:"schedules"`
+	Hooks       HooksConfig              `yaml:"hooks"`
 }
 
 type CompressionConfig struct {
@@ -65,6 +73,53 @@ type TrueNASConfig struct {
 	Timeout     int    `yaml:"timeout"`
 }
 
+// JobsConfig configures the background job worker
+type JobsConfig struct {
+	Concurrency map[string]int `yaml:"concurrency"` // most jobs of each type run at once, e.g. create: 1
+}
+
+// Schedule is a recurring task run by `daemon`
+type Schedule struct {
+	Name string   `yaml:"name"`
+	Cron string   `yaml:"cron"` // five-field cron expression, or @daily, @hourly, ...
+	Task string   `yaml:"task"` // create, scrub, prune or upload
+	Args []string `yaml:"args"` // arguments and flags for the task
+	// Vault the task runs in; empty for the default vault
+	Vault string `yaml:"vault"`
+	// Working directory relative paths in Args resolve against; empty for
+	// the home directory
+	Dir string `yaml:"dir"`
+	// SkipMissed drops runs that fell due while the daemon was stopped
+	// instead of running the latest of them when it starts
+	SkipMissed bool `yaml:"skip_missed"`
+}
+
+// HooksConfig lists the hooks run on each archive lifecycle event
+type HooksConfig struct {
+	PreCreate  []Hook `yaml:"pre_create"` // a failing pre_create hook stops the create
+	PostCreate []Hook `yaml:"post_create"`
+	PostTest   []Hook `yaml:"post_test"`
+	OnDelete   []Hook `yaml:"on_delete"`
+	OnPurge    []Hook `yaml:"on_purge"`
+}
+
+// Hook runs a shell command or POSTs JSON to a URL; set one of the two
+type Hook struct {
+	Command string            `yaml:"command"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // extra request headers, e.g. Authorization
+	Timeout string            `yaml:"timeout"` // per attempt, e.g. "10s"; default 30s
+	Retries int               `yaml:"retries"` // further attempts after a failure
+	// FailedOnly runs a post_test hook only when the test failed
+	FailedOnly bool `yaml:"failed_only"`
+}
+
+// ServerConfig configures the HTTP API of the serve command
+type ServerConfig struct {
+	Listen string `yaml:"listen"` // address to listen on, e.g. 127.0.0.1:7070
+	Token  string `yaml:"token"`  // bearer token clients must present
+}
+
 type PresetConfig struct {
 	Profile       string   `yaml:"profile"`
 	Comprehensive bool     `yaml:"comprehensive"`
@@ -80,6 +135,60 @@ type StorageConfig struct {
 	RegisterExternal  bool   `yaml:"register_external"`
 	AutoOrganize      string `yaml:"auto_organize"` // flat, by_date, by_type
 	RetentionDays     int    `yaml:"retention_days"`
+	// Retention policies applied by `prune`; the first matching policy wins
+	RetentionPolicies []RetentionPolicy `yaml:"retention_policies"`
+	// Automatic registry backups: when storage is opened and the newest
+	// backup is older than BackupInterval (e.g. "24h", "7d"; empty disables)
+	BackupInterval string `yaml:"backup_interval"`
+	BackupKeep     int    `yaml:"backup_keep"` // backups kept by rotation
+	BackupDir      string `yaml:"backup_dir"`  // default <managed_path>/backups
+	// Quotas for managed storage, enforced by create and move: total size
+	// (e.g. "500GB") and archive count; empty or 0 is unlimited
+	QuotaSize     string `yaml:"quota_size"`
+	QuotaArchives int    `yaml:"quota_archives"`
+	// Disk space create and move must leave free (e.g. "10GB")
+	MinFreeSpace string `yaml:"min_free_space"`
+	// Named vaults are further managed storage roots, each with its own
+	// registry. managed_path is the vault named "default".
+	Vaults       map[string]VaultConfig `yaml:"vaults"`
+	DefaultVault string                 `yaml:"default_vault"` // vault used without --vault
+	// Vault is the vault ManagedPath points at, set by UseVault
+	Vault string `yaml:"-"`
+}
+
+// VaultConfig is a named managed storage root. Empty settings fall back to
+// the storage-wide ones, except BackupDir, which defaults to <path>/backups,
+// and the quotas, which each vault sets for itself.
+type VaultConfig struct {
+	Path          string `yaml:"path"`
+	AutoOrganize  string `yaml:"auto_organize"`
+	BackupDir     string `yaml:"backup_dir"`
+	QuotaSize     string `yaml:"quota_size"`
+	QuotaArchives int    `yaml:"quota_archives"`
+	MinFreeSpace  string `yaml:"min_free_space"`
+}
+
+// RetentionPolicy selects archives and says how many of each series to keep.
+// A series is the archives made from one source, so keep_last: 3 keeps three
+// archives per source. Keep rules combine like restic/borg: an archive
+// survives if any rule keeps it. A policy with no keep rules never trashes
+// live archives and only sets how long trashed matches stay before being
+// purged.
+type RetentionPolicy struct {
+	Name    string `yaml:"name"`
+	Profile string `yaml:"profile"` // compression profile (case-insensitive)
+	Path    string `yaml:"path"`    // archives located (or originally located) under this directory
+	Pattern string `yaml:"pattern"` // glob on archive name, e.g. "db-*.7z"
+	Tag     string `yaml:"tag"`     // archives carrying this tag (case-insensitive)
+	Source  string `yaml:"source"`  // archives created from sources under this directory
+
+	KeepLast    int `yaml:"keep_last"`
+	KeepDaily   int `yaml:"keep_daily"`
+	KeepWeekly  int `yaml:"keep_weekly"`
+	KeepMonthly int `yaml:"keep_monthly"`
+	KeepYearly  int `yaml:"keep_yearly"`
+
+	TrashDays int `yaml:"trash_days"` // days in trash before purge (0 = retention_days)
 }
 
 // DefaultConfig returns the default configuration
@@ -121,6 +230,10 @@ func DefaultConfig() *Config {
 			RegisterExternal:  true,
 			AutoOrganize:      "flat",
 			RetentionDays:     30,
+			BackupKeep:        7,
+		},
+		Server: ServerConfig{
+			Listen: "127.0.0.1:7070",
 		},
 		Presets: map[string]PresetConfig{
 			"podcast": {
@@ -142,8 +255,76 @@ func DefaultConfig() *Config {
 	}
 }
 
-// Load loads configuration from ~/.7zarch-go-config
+// DefaultVaultName names the vault at storage.managed_path
+const DefaultVaultName = "default"
+
+// selectedVault is the vault chosen with the global --vault flag
+var selectedVault string
+
+// SelectVault makes Load point storage at the named vault; "" selects
+// storage.default_vault
+func SelectVault(name string) { selectedVault = name }
+
+// VaultNames returns the configured vault names, "default" first
+func (s *StorageConfig) VaultNames() []string {
+	names := []string{DefaultVaultName}
+	for name := range s.Vaults {
+		if name != DefaultVaultName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// UseVault points ManagedPath and the settings that follow it at the named
+// vault; "" selects storage.default_vault
+func (c *Config) UseVault(name string) error {
+	s := &c.Storage
+	if name == "" {
+		name = s.DefaultVault
+	}
+	if name == "" || (name == DefaultVaultName && s.Vaults[name].Path == "") {
+		s.Vault = DefaultVaultName
+		return nil
+	}
+	v, ok := s.Vaults[name]
+	if !ok || v.Path == "" {
+		return &errs.ConfigurationError{
+			Setting: "storage.vaults",
+			Value:   name,
+			Message: fmt.Sprintf("unknown vault (configured: %s)", strings.Join(s.VaultNames(), ", ")),
+		}
+	}
+	s.Vault = name
+	s.ManagedPath = v.Path
+	if v.AutoOrganize != "" {
+		s.AutoOrganize = v.AutoOrganize
+	}
+	s.BackupDir = v.BackupDir
+	s.QuotaSize, s.QuotaArchives = v.QuotaSize, v.QuotaArchives
+	if v.MinFreeSpace != "" {
+		s.MinFreeSpace = v.MinFreeSpace
+	}
+	return nil
+}
+
+// Load loads configuration from ~/.7zarch-go-config and points storage at
+// the selected vault
 func Load() (*Config, error) {
+	return LoadVault(selectedVault)
+}
+
+// LoadVault loads configuration with storage pointed at the named vault
+func LoadVault(name string) (*Config, error) {
+	config, err := load()
+	if err != nil {
+		return config, err
+	}
+	return config, config.UseVault(name)
+}
+
+func load() (*Config, error) {
 	config := DefaultConfig()
 
 	// Get config file path
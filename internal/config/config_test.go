@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -143,4 +144,59 @@ func TestLoadConfig_InvalidYAML(t *testing.T) {
 	if cfg.Storage.RetentionDays != 30 {
 		t.Errorf("Expected default RetentionDays 30, got %d", cfg.Storage.RetentionDays)
 	}
-}
\ No newline at end of file
+}
+func TestUseVault(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configContent := `storage:
+  managed_path: /data/default
+  auto_organize: flat
+  backup_dir: /data/backups
+  quota_size: 1TB
+  min_free_space: 10GB
+  default_vault: ssd
+  vaults:
+    ssd:
+      path: /mnt/ssd/archives
+    hdd:
+      path: /mnt/hdd/archives
+      auto_organize: by_date
+      quota_archives: 500
+`
+	if err := os.WriteFile(filepath.Join(home, ".7zarch-go-config"), []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SelectVault("") })
+
+	if names := DefaultConfig().Storage.VaultNames(); len(names) != 1 || names[0] != DefaultVaultName {
+		t.Errorf("VaultNames without vaults = %v", names)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage.Vault != "ssd" || cfg.Storage.ManagedPath != "/mnt/ssd/archives" || cfg.Storage.BackupDir != "" {
+		t.Errorf("default_vault not applied: %+v", cfg.Storage)
+	}
+	if got := cfg.Storage.VaultNames(); len(got) != 3 || got[0] != "default" || got[1] != "hdd" || got[2] != "ssd" {
+		t.Errorf("VaultNames = %v", got)
+	}
+
+	SelectVault("hdd")
+	cfg, _ = Load()
+	if cfg.Storage.ManagedPath != "/mnt/hdd/archives" || cfg.Storage.AutoOrganize != "by_date" ||
+		cfg.Storage.QuotaArchives != 500 || cfg.Storage.QuotaSize != "" || cfg.Storage.MinFreeSpace != "10GB" {
+		t.Errorf("--vault hdd not applied: %+v", cfg.Storage)
+	}
+
+	cfg, _ = LoadVault(DefaultVaultName)
+	if cfg.Storage.Vault != DefaultVaultName || cfg.Storage.ManagedPath != "/data/default" || cfg.Storage.BackupDir != "/data/backups" ||
+		cfg.Storage.QuotaSize != "1TB" {
+		t.Errorf("default vault = %+v", cfg.Storage)
+	}
+
+	if _, err := LoadVault("tape"); err == nil || !strings.Contains(err.Error(), "default, hdd, ssd") {
+		t.Errorf("unknown vault error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package display
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/adamstac/7zarch-go/internal/storage"
@@ -31,6 +32,9 @@ type Options struct {
 	GroupBy      string
 	SortBy       []string
 	ColorEnabled bool
+	// Scores are search relevance scores by archive ID; with SortBy
+	// "relevance" archives are shown best match first
+	Scores map[int64]float64
 }
 
 // Context provides environmental information for display decisions
@@ -84,9 +88,32 @@ func (m *Manager) Render(archives []*storage.Archive, opts Options) error {
 		opts.Width = m.context.TerminalWidth
 	}
 
+	if opts.Scores != nil && sortsByRelevance(opts.SortBy) {
+		archives = SortByRelevance(archives, opts.Scores)
+	}
+
 	return display.Render(archives, opts)
 }
 
+func sortsByRelevance(keys []string) bool {
+	for _, k := range keys {
+		if k == "relevance" {
+			return true
+		}
+	}
+	return false
+}
+
+// SortByRelevance returns archives ordered by descending score, keeping the
+// given order for equal scores
+func SortByRelevance(archives []*storage.Archive, scores map[int64]float64) []*storage.Archive {
+	sorted := append([]*storage.Archive(nil), archives...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i].ID] > scores[sorted[j].ID]
+	})
+	return sorted
+}
+
 // detectBestMode determines the optimal display mode based on context
 func (m *Manager) detectBestMode(opts Options) Mode {
 	ctx := m.context
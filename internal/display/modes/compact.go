@@ -113,6 +113,9 @@ func (cd *CompactDisplay) printCompactArchive(archive *storage.Archive, opts dis
 		if archive.Managed {
 			location = "MANAGED"
 		}
+		if archive.Vault != "" {
+			location = archive.Vault
+		}
 
 		fmt.Printf("%-12s  %-25s  %8s  %-8s  %3s  %-8s  %s\n",
 			id, name, size, profile, age, location, status)
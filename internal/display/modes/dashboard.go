@@ -53,6 +53,11 @@ func (dd *DashboardDisplay) Render(archives []*storage.Archive, opts display.Opt
 	// Print profile distribution
 	dd.printProfileDistribution(stats)
 
+	// Print tag distribution (always when grouping by tag, otherwise only if tags are in use)
+	if opts.GroupBy == "tag" || len(stats.TagDistribution) > 0 {
+		dd.printTagDistribution(stats)
+	}
+
 	// Print recent activity
 	dd.printRecentActivity(stats)
 
@@ -75,6 +80,9 @@ type Statistics struct {
 	ExternalSize        int64
 	ProfileDistribution map[string]int
 	ProfileSizes        map[string]int64
+	TagDistribution     map[string]int
+	TagSizes            map[string]int64
+	UntaggedCount       int
 	OldestArchive       *storage.Archive
 	NewestArchive       *storage.Archive
 	LargestArchive      *storage.Archive
@@ -88,6 +96,8 @@ func (dd *DashboardDisplay) generateStatistics(archives []*storage.Archive) Stat
 	stats := Statistics{
 		ProfileDistribution: make(map[string]int),
 		ProfileSizes:        make(map[string]int64),
+		TagDistribution:     make(map[string]int),
+		TagSizes:            make(map[string]int64),
 	}
 
 	var allActive []*storage.Archive
@@ -125,6 +135,15 @@ func (dd *DashboardDisplay) generateStatistics(archives []*storage.Archive) Stat
 		stats.ProfileDistribution[profile]++
 		stats.ProfileSizes[profile] += archive.Size
 
+		// Tag distribution
+		if len(archive.Tags) == 0 {
+			stats.UntaggedCount++
+		}
+		for _, tag := range archive.Tags {
+			stats.TagDistribution[tag]++
+			stats.TagSizes[tag] += archive.Size
+		}
+
 		// Track extremes
 		if stats.OldestArchive == nil || archive.Created.Before(stats.OldestArchive.Created) {
 			stats.OldestArchive = archive
@@ -298,6 +317,46 @@ func (dd *DashboardDisplay) printProfileDistribution(stats Statistics) {
 	fmt.Printf("└────────────────────────────────────────────────────────────────────────────────┘\n")
 }
 
+// printTagDistribution shows the most used tags and how many archives are untagged
+func (dd *DashboardDisplay) printTagDistribution(stats Statistics) {
+	fmt.Printf("\n┌─ TAGS ─────────────────────────────────────────────────────────────────────────┐\n")
+
+	type tagStat struct {
+		name  string
+		count int
+		size  int64
+	}
+
+	var tags []tagStat
+	for name, count := range stats.TagDistribution {
+		tags = append(tags, tagStat{name: name, count: count, size: stats.TagSizes[name]})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].count != tags[j].count {
+			return tags[i].count > tags[j].count
+		}
+		return tags[i].name < tags[j].name
+	})
+
+	const maxTags = 10
+	for i, tag := range tags {
+		if i == maxTags {
+			fmt.Printf("│  … and %d more tags\n", len(tags)-maxTags)
+			break
+		}
+		percent := float64(tag.count) / float64(stats.Total) * 100
+		fmt.Printf("│  %-24s: %3d archives  %15s  (%5.1f%%)\n",
+			tag.name, tag.count, display.FormatSize(tag.size), percent)
+	}
+	if stats.UntaggedCount > 0 {
+		percent := float64(stats.UntaggedCount) / float64(stats.Total) * 100
+		fmt.Printf("│  %-24s: %3d archives  %15s  (%5.1f%%)\n", "(untagged)", stats.UntaggedCount, "", percent)
+	}
+
+	fmt.Printf("└────────────────────────────────────────────────────────────────────────────────┘\n")
+}
+
 // printRecentActivity shows recently created archives
 func (dd *DashboardDisplay) printRecentActivity(stats Statistics) {
 	if len(stats.RecentArchives) == 0 {
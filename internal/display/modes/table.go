@@ -57,6 +57,16 @@ func (td *TableDisplay) Render(archives []*storage.Archive, opts display.Options
 
 	// Configure columns based on terminal width and options
 	columns := td.selectColumns(opts)
+	if hasVaults(archives) {
+		vault := Column{
+			Name:  "Vault",
+			Width: 10,
+			Format: func(a *storage.Archive) string {
+				return display.TruncateString(a.Vault, 10)
+			},
+		}
+		columns = append([]Column{vault}, columns...)
+	}
 
 	// Print active archives
 	if len(managedActive) > 0 {
@@ -78,6 +88,16 @@ func (td *TableDisplay) Render(archives []*storage.Archive, opts display.Options
 	return nil
 }
 
+// hasVaults reports whether archives come from a cross-vault listing
+func hasVaults(archives []*storage.Archive) bool {
+	for _, a := range archives {
+		if a.Vault != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // printSummary prints the archive summary header
 func (td *TableDisplay) printSummary(total, managed, external, missing, deleted int) {
 	fmt.Printf("📦 Archives (%d found)\n", total)
@@ -37,12 +37,18 @@ func (td *TreeDisplay) Render(archives []*storage.Archive, opts display.Options)
 		return nil
 	}
 
-	// Group archives by directory structure
-	tree := td.buildDirectoryTree(archives)
-
 	// Print summary header
 	td.printSummary(archives)
 
+	if opts.GroupBy == "tag" {
+		fmt.Printf("\nTags:\n")
+		td.printTree(td.buildTagTree(archives), "", true, opts)
+		return nil
+	}
+
+	// Group archives by directory structure
+	tree := td.buildDirectoryTree(archives)
+
 	// Print the tree
 	fmt.Printf("\nDirectory Structure:\n")
 	td.printTree(tree, "", true, opts)
@@ -75,6 +81,47 @@ func (td *TreeDisplay) buildDirectoryTree(archives []*storage.Archive) *Director
 	return root
 }
 
+// buildTagTree groups archives by tag. key:value tags nest the value under
+// the key (client → acme); an archive with several tags appears under each.
+func (td *TreeDisplay) buildTagTree(archives []*storage.Archive) *DirectoryNode {
+	root := &DirectoryNode{
+		Name:     "Tags",
+		Children: make(map[string]*DirectoryNode),
+		IsRoot:   true,
+	}
+
+	for _, archive := range archives {
+		if len(archive.Tags) == 0 {
+			td.addToGroup(root, []string{"Untagged"}, archive)
+			continue
+		}
+		for _, tag := range archive.Tags {
+			td.addToGroup(root, strings.SplitN(tag, ":", 2), archive)
+		}
+	}
+
+	return root
+}
+
+// addToGroup adds an archive under the node reached by following parts
+func (td *TreeDisplay) addToGroup(root *DirectoryNode, parts []string, archive *storage.Archive) {
+	current := root
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if _, exists := current.Children[part]; !exists {
+			current.Children[part] = &DirectoryNode{
+				Name:     part,
+				Path:     part,
+				Children: make(map[string]*DirectoryNode),
+			}
+		}
+		current = current.Children[part]
+	}
+	current.Archives = append(current.Archives, archive)
+}
+
 // addToTree adds an archive to the appropriate place in the tree
 func (td *TreeDisplay) addToTree(root *DirectoryNode, archive *storage.Archive) {
 	// Determine the grouping path
@@ -0,0 +1,208 @@
+// Package dupes finds duplicate archives in the registry, either byte-identical
+// (same checksum) or near-identical (same member files, different compression).
+package dupes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Kind distinguishes how a duplicate group was detected
+type Kind string
+
+const (
+	Exact Kind = "exact" // identical checksums
+	Near  Kind = "near"  // overlapping member listings
+)
+
+// DefaultThreshold is the minimum member overlap for near-duplicates
+const DefaultThreshold = 0.9
+
+// Group is a set of archives holding the same content. Keep is the copy
+// worth keeping; Redundant are candidates for trashing.
+type Group struct {
+	Kind        Kind
+	Keep        *storage.Archive
+	Redundant   []*storage.Archive
+	Similarity  float64 // 1.0 for exact groups, lowest pairwise overlap for near groups
+	Reclaimable int64   // bytes freed by trashing Redundant
+}
+
+// Lister returns the member files of an archive
+type Lister func(a *storage.Archive) ([]archive.Member, error)
+
+// FindExact groups present archives sharing a checksum
+func FindExact(archives []*storage.Archive) []Group {
+	byChecksum := make(map[string][]*storage.Archive)
+	for _, a := range archives {
+		if a.Status != "present" || a.Checksum == "" {
+			continue
+		}
+		byChecksum[a.Checksum] = append(byChecksum[a.Checksum], a)
+	}
+
+	var groups []Group
+	for _, members := range byChecksum {
+		if len(members) < 2 {
+			continue
+		}
+		groups = append(groups, newGroup(Exact, members, 1.0))
+	}
+	sortGroups(groups)
+	return groups
+}
+
+// FindNear groups present archives whose member listings overlap by at least
+// threshold (Jaccard index over path, size and CRC). Archives already in an
+// exact group are represented by their kept copy only. Archives that cannot
+// be listed are skipped and reported in the returned errors.
+func FindNear(archives []*storage.Archive, exact []Group, list Lister, threshold float64) ([]Group, []error) {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultThreshold
+	}
+
+	redundant := make(map[int64]bool)
+	for _, g := range exact {
+		for _, a := range g.Redundant {
+			redundant[a.ID] = true
+		}
+	}
+
+	type entry struct {
+		arc  *storage.Archive
+		sigs map[string]struct{}
+	}
+	var entries []entry
+	var errs []error
+	for _, a := range archives {
+		if a.Status != "present" || redundant[a.ID] {
+			continue
+		}
+		members, err := list(a)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.Name, err))
+			continue
+		}
+		if len(members) == 0 {
+			continue
+		}
+		entries = append(entries, entry{arc: a, sigs: signatures(members)})
+	}
+
+	// Union-find over pairs that clear the threshold
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	lowest := make(map[[2]int]float64)
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			if entries[i].arc.Checksum != "" && entries[i].arc.Checksum == entries[j].arc.Checksum {
+				continue
+			}
+			sim := jaccard(entries[i].sigs, entries[j].sigs)
+			if sim < threshold {
+				continue
+			}
+			parent[find(j)] = find(i)
+			lowest[[2]int{i, j}] = sim
+		}
+	}
+
+	clusters := make(map[int][]int)
+	for i := range entries {
+		root := find(i)
+		clusters[root] = append(clusters[root], i)
+	}
+
+	var groups []Group
+	for _, idx := range clusters {
+		if len(idx) < 2 {
+			continue
+		}
+		sim := 1.0
+		members := make([]*storage.Archive, 0, len(idx))
+		for _, i := range idx {
+			members = append(members, entries[i].arc)
+			for _, j := range idx {
+				if s, ok := lowest[[2]int{i, j}]; ok && s < sim {
+					sim = s
+				}
+			}
+		}
+		groups = append(groups, newGroup(Near, members, sim))
+	}
+	sortGroups(groups)
+	return groups, errs
+}
+
+// TotalReclaimable sums reclaimable bytes across groups
+func TotalReclaimable(groups []Group) int64 {
+	var total int64
+	for _, g := range groups {
+		total += g.Reclaimable
+	}
+	return total
+}
+
+// newGroup picks the copy to keep and computes reclaimable space. Managed
+// archives are preferred, then the oldest, then the lowest ID.
+func newGroup(kind Kind, members []*storage.Archive, similarity float64) Group {
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.Managed != b.Managed {
+			return a.Managed
+		}
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+	g := Group{Kind: kind, Keep: members[0], Redundant: members[1:], Similarity: similarity}
+	for _, a := range g.Redundant {
+		g.Reclaimable += a.Size
+	}
+	return g
+}
+
+// sortGroups orders groups by reclaimable space, largest first
+func sortGroups(groups []Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Reclaimable != groups[j].Reclaimable {
+			return groups[i].Reclaimable > groups[j].Reclaimable
+		}
+		return groups[i].Keep.ID < groups[j].Keep.ID
+	})
+}
+
+func signatures(members []archive.Member) map[string]struct{} {
+	sigs := make(map[string]struct{}, len(members))
+	for _, m := range members {
+		sigs[m.Path+"\x00"+strconv.FormatInt(m.Size, 10)+"\x00"+m.CRC] = struct{}{}
+	}
+	return sigs
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
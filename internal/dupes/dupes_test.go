@@ -0,0 +1,86 @@
+package dupes
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func arc(id int64, name, checksum string, size int64, managed bool, age time.Duration) *storage.Archive {
+	return &storage.Archive{
+		ID: id, Name: name, Checksum: checksum, Size: size, Managed: managed,
+		Status: "present", Created: time.Now().Add(-age),
+	}
+}
+
+func TestFindExact(t *testing.T) {
+	archives := []*storage.Archive{
+		arc(1, "a.7z", "aaa", 100, false, time.Hour),
+		arc(2, "a-copy.7z", "aaa", 100, true, time.Minute),
+		arc(3, "a-again.7z", "aaa", 100, false, 2*time.Hour),
+		arc(4, "b.7z", "bbb", 50, true, time.Hour),
+	}
+	trashed := arc(5, "a-trashed.7z", "aaa", 100, true, time.Hour)
+	trashed.Status = "deleted"
+	archives = append(archives, trashed)
+
+	groups := FindExact(archives)
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.Keep.ID != 2 {
+		t.Errorf("expected managed copy to be kept, got %s", g.Keep.Name)
+	}
+	if len(g.Redundant) != 2 || g.Reclaimable != 200 {
+		t.Errorf("unexpected group: redundant=%d reclaimable=%d", len(g.Redundant), g.Reclaimable)
+	}
+}
+
+func TestFindNear(t *testing.T) {
+	common := []archive.Member{
+		{Path: "a.txt", Size: 1, CRC: "01"},
+		{Path: "b.txt", Size: 2, CRC: "02"},
+		{Path: "c.txt", Size: 3, CRC: "03"},
+	}
+	listings := map[int64][]archive.Member{
+		1: common,
+		2: common, // same files, different compression
+		3: {{Path: "other.txt", Size: 9, CRC: "09"}},
+	}
+	archives := []*storage.Archive{
+		arc(1, "ultra.7z", "x1", 300, true, time.Hour),
+		arc(2, "fast.7z", "x2", 500, false, time.Hour),
+		arc(3, "other.7z", "x3", 10, true, time.Hour),
+		arc(4, "broken.7z", "x4", 10, true, time.Hour),
+	}
+	list := func(a *storage.Archive) ([]archive.Member, error) {
+		if m, ok := listings[a.ID]; ok {
+			return m, nil
+		}
+		return nil, errors.New("cannot list")
+	}
+
+	groups, errs := FindNear(archives, nil, list, 0.9)
+	if len(errs) != 1 {
+		t.Errorf("expected 1 listing error, got %v", errs)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 near group, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.Kind != Near || g.Keep.ID != 1 || g.Reclaimable != 500 || g.Similarity != 1 {
+		t.Errorf("unexpected group: %+v", g)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := signatures([]archive.Member{{Path: "1"}, {Path: "2"}, {Path: "3"}})
+	b := signatures([]archive.Member{{Path: "1"}, {Path: "2"}, {Path: "4"}})
+	if got := jaccard(a, b); got != 0.5 {
+		t.Fatalf("jaccard = %v, want 0.5", got)
+	}
+}
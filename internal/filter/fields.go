@@ -0,0 +1,308 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// kind is how a field's values are parsed and compared
+type kind int
+
+const (
+	kindText kind = iota // exact, case-sensitive
+	kindFold             // case-insensitive (profile, status)
+	kindSize             // byte counts with units
+	kindTime             // dates and timestamps
+	kindAge              // time since created
+	kindBool             // true/false
+	kindTag              // membership in the archive's tags
+)
+
+type fieldSpec struct {
+	kind   kind
+	column string // SQL expression for the field
+	get    func(a *storage.Archive) string
+}
+
+var fields = map[string]fieldSpec{
+	"name":        {kindText, "name", func(a *storage.Archive) string { return a.Name }},
+	"path":        {kindText, "path", func(a *storage.Archive) string { return a.Path }},
+	"uid":         {kindText, "uid", func(a *storage.Archive) string { return a.UID }},
+	"checksum":    {kindText, "COALESCE(checksum, '')", func(a *storage.Archive) string { return a.Checksum }},
+	"destination": {kindText, "COALESCE(destination, '')", func(a *storage.Archive) string { return a.Destination }},
+	"source":      {kindText, "COALESCE(source_path, '')", func(a *storage.Archive) string { return a.SourcePath }},
+	"profile":     {kindFold, "COALESCE(profile, '')", func(a *storage.Archive) string { return a.Profile }},
+	"status":      {kindFold, "status", func(a *storage.Archive) string { return a.Status }},
+	"size":        {kind: kindSize, column: "size"},
+	"created":     {kind: kindTime, column: "created"},
+	"age":         {kind: kindAge, column: "created"},
+	"uploaded":    {kind: kindBool, column: "uploaded"},
+	"managed":     {kind: kindBool, column: "managed"},
+	"tag":         {kind: kindTag},
+}
+
+// shorthands are bare words that stand for a whole comparison
+var shorthands = map[string][3]string{
+	"uploaded": {"uploaded", "=", "true"},
+	"managed":  {"managed", "=", "true"},
+	"external": {"managed", "=", "false"},
+	"present":  {"status", "=", "present"},
+	"missing":  {"status", "=", "missing"},
+	"deleted":  {"status", "=", "deleted"},
+}
+
+// FieldNames lists the fields an expression can compare, for help and errors
+func FieldNames() []string {
+	names := make([]string, 0, len(fields)+1)
+	for name := range fields {
+		names = append(names, name)
+	}
+	names = append(names, "meta.<key>")
+	sort.Strings(names)
+	return names
+}
+
+// newComparison validates a comparison and builds its matcher and SQL
+func newComparison(field, op, raw string, now time.Time) (*comparison, error) {
+	field = strings.ToLower(field)
+	c := &comparison{field: field, op: op, raw: raw}
+
+	if strings.HasPrefix(field, "meta.") {
+		return c, c.buildMeta(strings.TrimPrefix(field, "meta."))
+	}
+	spec, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q (fields: %s)", field, strings.Join(FieldNames(), ", "))
+	}
+
+	switch spec.kind {
+	case kindText, kindFold:
+		return c, c.buildText(spec)
+	case kindSize:
+		return c, c.buildSize(spec)
+	case kindTime:
+		return c, c.buildTime(spec)
+	case kindAge:
+		return c, c.buildAge(spec, now)
+	case kindBool:
+		return c, c.buildBool(spec)
+	case kindTag:
+		return c, c.buildTag()
+	}
+	return nil, fmt.Errorf("unsupported field %q", field)
+}
+
+func (c *comparison) unsupported() error {
+	return fmt.Errorf("operator %s is not supported for %s", c.op, c.field)
+}
+
+func (c *comparison) buildText(spec fieldSpec) error {
+	fold := spec.kind == kindFold
+	want, col := c.raw, spec.column
+	if fold {
+		want, col = strings.ToLower(want), "LOWER("+col+")"
+	}
+	value := func(a *storage.Archive) string {
+		if fold {
+			return strings.ToLower(spec.get(a))
+		}
+		return spec.get(a)
+	}
+	switch c.op {
+	case "=", "!=":
+		c.matchFn = func(a *storage.Archive) bool { return (value(a) == want) == (c.op == "=") }
+		c.setSQL(col+" "+c.op+" ?", want)
+	case "~", "!~":
+		if _, err := filepath.Match(want, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", c.raw, err)
+		}
+		c.matchFn = func(a *storage.Archive) bool {
+			ok, _ := filepath.Match(want, value(a))
+			return ok == (c.op == "~")
+		}
+		// GLOB lets * cross path separators, so it only narrows; match decides
+		if c.op == "~" && !strings.Contains(want, `\`) {
+			c.setSQL(col+" GLOB ?", want)
+			c.exact = false
+		}
+	default:
+		return c.unsupported()
+	}
+	return nil
+}
+
+func (c *comparison) buildSize(spec fieldSpec) error {
+	n, err := ParseSize(c.raw)
+	if err != nil {
+		return err
+	}
+	if !isOrdered(c.op) {
+		return c.unsupported()
+	}
+	c.matchFn = func(a *storage.Archive) bool { return compare(cmpInt(a.Size, n), c.op) }
+	c.setSQL(spec.column+" "+c.op+" ?", n)
+	return nil
+}
+
+func (c *comparison) buildTime(spec fieldSpec) error {
+	t, err := storage.ParseDate(c.raw)
+	if err != nil {
+		return err
+	}
+	if !isOrdered(c.op) {
+		return c.unsupported()
+	}
+	// A plain date compares calendar days (UTC); anything else compares instants
+	if !strings.Contains(c.raw, "T") && !strings.Contains(c.raw, " ") && len(c.raw) == len("2006-01-02") {
+		day := t.Format("2006-01-02")
+		c.matchFn = func(a *storage.Archive) bool {
+			return compare(strings.Compare(a.Created.UTC().Format("2006-01-02"), day), c.op)
+		}
+		c.setSQL("date("+spec.column+") "+c.op+" ?", day)
+		return nil
+	}
+	c.matchFn = func(a *storage.Archive) bool { return compare(a.Created.Compare(t), c.op) }
+	c.setSQL("julianday("+spec.column+") "+c.op+" julianday(?)", t.UTC().Format(time.RFC3339Nano))
+	return nil
+}
+
+func (c *comparison) buildAge(spec fieldSpec, now time.Time) error {
+	d, err := ParseAge(c.raw)
+	if err != nil {
+		return err
+	}
+	if c.op != "<" && c.op != "<=" && c.op != ">" && c.op != ">=" {
+		return c.unsupported()
+	}
+	c.matchFn = func(a *storage.Archive) bool { return compare(cmpInt(int64(now.Sub(a.Created)), int64(d)), c.op) }
+	// Older than d means created before now-d, so the operator flips
+	cutoff := now.Add(-d).UTC().Format(time.RFC3339Nano)
+	c.setSQL("julianday("+spec.column+") "+flip(c.op)+" julianday(?)", cutoff)
+	return nil
+}
+
+func (c *comparison) buildBool(spec fieldSpec) error {
+	b, err := strconv.ParseBool(c.raw)
+	if err != nil {
+		return fmt.Errorf("%s expects true or false, got %q", c.field, c.raw)
+	}
+	if c.op != "=" && c.op != "!=" {
+		return c.unsupported()
+	}
+	want := b == (c.op == "=")
+	get := func(a *storage.Archive) bool { return a.Uploaded }
+	if c.field == "managed" {
+		get = func(a *storage.Archive) bool { return a.Managed }
+	}
+	c.matchFn = func(a *storage.Archive) bool { return get(a) == want }
+	if want {
+		c.setSQL(spec.column + " = 1")
+	} else {
+		c.setSQL(spec.column + " = 0")
+	}
+	return nil
+}
+
+func (c *comparison) buildTag() error {
+	want := strings.ToLower(strings.TrimSpace(c.raw))
+	var has func(a *storage.Archive) bool
+	var cond string
+	switch c.op {
+	case "=", "!=":
+		has = func(a *storage.Archive) bool { return a.HasTags(want) }
+		cond = "t.tag = ?"
+	case "~", "!~":
+		if _, err := filepath.Match(want, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", c.raw, err)
+		}
+		has = func(a *storage.Archive) bool {
+			for _, tag := range a.Tags {
+				if ok, _ := filepath.Match(want, tag); ok {
+					return true
+				}
+			}
+			return false
+		}
+		cond = "t.tag GLOB ?"
+	default:
+		return c.unsupported()
+	}
+	positive := c.op == "=" || c.op == "~"
+	c.matchFn = func(a *storage.Archive) bool { return has(a) == positive }
+	exists := "EXISTS (SELECT 1 FROM archive_tags t WHERE t.archive_id = archives.id AND " + cond + ")"
+	if !positive {
+		exists = "NOT " + exists
+	}
+	c.setSQL(exists, want)
+	return nil
+}
+
+func (c *comparison) buildMeta(key string) error {
+	if c.op == "~" || c.op == "!~" {
+		return c.unsupported()
+	}
+	cond, err := storage.ParseCondition(key + c.op + c.raw)
+	if err != nil {
+		return err
+	}
+	c.field = "meta." + cond.Key
+	c.matchFn = cond.Matches
+	// Typed comparisons on archive_meta are evaluated in Go
+	return nil
+}
+
+func isOrdered(op string) bool {
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func flip(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+func cmpInt(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compare(cmp int, op string) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+// Package filter implements the expression language used to select archives
+// in list, query, batch and search, for example
+//
+//	size > 1GB and profile = media and not uploaded
+//	(tag = client:acme or tag = client:globex) and age > 90d
+//	name ~ "*backup*" and meta.retention_class = legal
+//
+// Comparisons are field <op> value with op one of = != < <= > >= and ~ / !~
+// for glob matches. Terms combine with and, or, not and parentheses. The bare
+// words uploaded, managed, external, present, missing and deleted are
+// shorthands for the obvious comparison.
+//
+// Expressions compile to a SQL WHERE clause where the registry schema allows
+// (everything except custom meta.* fields); the rest is evaluated in Go.
+package filter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Filter is a compiled expression. The zero value and the empty expression
+// match every archive.
+type Filter struct {
+	root node
+}
+
+// Parse compiles an expression. Relative comparisons such as age > 30d are
+// resolved against the current time.
+func Parse(expr string) (*Filter, error) {
+	return ParseAt(expr, time.Now())
+}
+
+// ParseAt compiles an expression with age comparisons relative to now
+func ParseAt(expr string, now time.Time) (*Filter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return &Filter{}, nil
+	}
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	p := &parser{tokens: tokens, now: now}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("invalid filter: %w", p.errorf(t, "unexpected %q (missing and/or?)", t.text))
+	}
+	return &Filter{root: root}, nil
+}
+
+// String returns the expression in canonical form
+func (f *Filter) String() string {
+	if f == nil || f.root == nil {
+		return ""
+	}
+	if or, ok := f.root.(*orNode); ok {
+		return or.left.String() + " or " + or.right.String()
+	}
+	return f.root.String()
+}
+
+// Empty reports whether the filter matches everything
+func (f *Filter) Empty() bool { return f == nil || f.root == nil }
+
+// Match reports whether an archive satisfies the expression
+func (f *Filter) Match(a *storage.Archive) bool {
+	return f.Empty() || f.root.match(a)
+}
+
+// Apply returns the archives that match, preserving order
+func (f *Filter) Apply(archives []*storage.Archive) []*storage.Archive {
+	if f.Empty() {
+		return archives
+	}
+	out := make([]*storage.Archive, 0, len(archives))
+	for _, a := range archives {
+		if f.root.match(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// SQL returns a WHERE clause over the archives table that every matching
+// archive satisfies, with its arguments. exact is true when the clause alone
+// decides the result; otherwise results still need Apply. An empty clause
+// means no narrowing was possible.
+func (f *Filter) SQL() (where string, args []interface{}, exact bool) {
+	if f.Empty() {
+		return "", nil, true
+	}
+	return f.root.sql()
+}
+
+// Criteria narrows c to the filter for Registry.Find: the SQL part becomes
+// Where and, when SQL cannot decide alone, Match evaluates the rest in Go.
+// Any existing Where in c is kept.
+func (f *Filter) Criteria(c storage.Criteria) storage.Criteria {
+	where, args, exact := f.SQL()
+	if where != "" {
+		if c.Where != "" {
+			where = "(" + c.Where + ") AND (" + where + ")"
+			args = append(append([]interface{}{}, c.Args...), args...)
+		}
+		c.Where, c.Args = where, args
+	}
+	if !exact {
+		match := f.Match
+		if prev := c.Match; prev != nil {
+			match = func(a *storage.Archive) bool { return prev(a) && f.Match(a) }
+		}
+		c.Match = match
+	}
+	return c
+}
+
+// Select loads every matching archive from the registry, newest first,
+// pushing what it can into SQL and evaluating the remainder in Go
+func (f *Filter) Select(reg *storage.Registry) ([]*storage.Archive, error) {
+	page, err := reg.Find(context.Background(), f.Criteria(storage.Criteria{}))
+	if err != nil {
+		return nil, err
+	}
+	return page.Archives, nil
+}
+
+// And joins expressions with "and", skipping empty ones. Expressions that
+// contain "or" are parenthesized so precedence is preserved.
+func And(exprs ...string) string {
+	var parts []string
+	for _, e := range exprs {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(e), " or ") {
+			e = "(" + e + ")"
+		}
+		parts = append(parts, e)
+	}
+	return strings.Join(parts, " and ")
+}
@@ -0,0 +1,186 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func sampleArchives() []*storage.Archive {
+	year, _ := storage.ParseFieldAssignment("project_year=2024")
+	legal, _ := storage.ParseFieldAssignment("retention_class=legal")
+	return []*storage.Archive{
+		{Name: "photos.7z", Path: "/data/photos.7z", Size: 3 << 30, Profile: "Media", Status: "present", Managed: true,
+			Created: now.Add(-100 * 24 * time.Hour), Tags: []string{"client:acme"}},
+		{Name: "docs-backup.7z", Path: "/data/docs-backup.7z", Size: 10 << 20, Profile: "Documents", Status: "present",
+			Uploaded: true, Created: now.Add(-2 * 24 * time.Hour), Tags: []string{"client:globex", "project:site"},
+			Fields: map[string]storage.Field{year.Key: year, legal.Key: legal}},
+		{Name: "old.7z", Path: "/ext/old.7z", Size: 500, Profile: "Balanced", Status: "missing",
+			Created: time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)},
+	}
+}
+
+func names(archives []*storage.Archive) string {
+	var out []string
+	for _, a := range archives {
+		out = append(out, a.Name)
+	}
+	sort.Strings(out)
+	return fmt.Sprint(out)
+}
+
+func TestFilterMatch(t *testing.T) {
+	cases := map[string]string{
+		"": "[docs-backup.7z old.7z photos.7z]",
+		"size > 1GB and profile = media and not uploaded": "[photos.7z]",
+		"size <= 10MB":                          "[docs-backup.7z old.7z]",
+		"uploaded or missing":                   "[docs-backup.7z old.7z]",
+		"external":                              "[docs-backup.7z old.7z]",
+		"not (managed or uploaded)":             "[old.7z]",
+		`name ~ "*backup*"`:                     "[docs-backup.7z]",
+		"name !~ *.7z":                          "[]",
+		"path ~ /data/*":                        "[docs-backup.7z photos.7z]",
+		"tag = client:acme":                     "[photos.7z]",
+		"tag ~ client:* and tag != client:acme": "[docs-backup.7z]",
+		"tag !~ project:*":                      "[old.7z photos.7z]",
+		"age > 30d":                             "[old.7z photos.7z]",
+		"age < 1w":                              "[docs-backup.7z]",
+		"created = 2024-06-01":                  "[old.7z]",
+		"created < 2025-01-01T00:00:00Z":        "[old.7z]",
+		"meta.project_year >= 2024":             "[docs-backup.7z]",
+		"meta.retention_class = legal and uploaded = true": "[docs-backup.7z]",
+		"STATUS = Missing OR Profile = MEDIA":              "[old.7z photos.7z]",
+	}
+	for expr, want := range cases {
+		f, err := ParseAt(expr, now)
+		if err != nil {
+			t.Errorf("%q: %v", expr, err)
+			continue
+		}
+		if got := names(f.Apply(sampleArchives())); got != want {
+			t.Errorf("%q: got %s, want %s", expr, got, want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"size >",
+		"colour = red",
+		"size > lots",
+		"age = 3d",
+		"uploaded = maybe",
+		"name < b",
+		"(managed",
+		"managed uploaded",
+		`name = "open`,
+		"size => 1",
+		"meta.x ~ y",
+		"and managed",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	f, err := Parse(`(tag = client:acme or tag = "client:big co") and size>1GB`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(tag = client:acme or tag = "client:big co") and size > 1GB`
+	if f.String() != want {
+		t.Fatalf("got %q, want %q", f.String(), want)
+	}
+	if _, err := Parse(f.String()); err != nil {
+		t.Fatalf("canonical form does not parse: %v", err)
+	}
+}
+
+func TestFlagsExpression(t *testing.T) {
+	expr, err := Flags{
+		NotUploaded: true, Pattern: "*.7z", OlderThan: "30d", Profile: "media",
+		LargerThan: "100MB", SmallerThan: "2GB", Tags: []string{"Client:Acme"}, Where: []string{"project_year>=2024"},
+	}.Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "not uploaded and name ~ *.7z and age > 30d and profile = media and size > 100MB and size < 2GB and tag = client:acme and meta.project_year >= 2024"
+	if expr != want {
+		t.Fatalf("got %q\nwant %q", expr, want)
+	}
+	if _, err := (Flags{LargerThan: "huge"}).Expression(); err == nil {
+		t.Fatal("expected error for invalid size")
+	}
+}
+
+func TestParseSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{"1048576": 1 << 20, "500K": 500 << 10, "100MB": 100 << 20, "1.5GB": 3 << 29, "2tib": 2 << 40}
+	for in, want := range sizes {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	ages := map[string]time.Duration{"12h": 12 * time.Hour, "30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "1y": 365 * 24 * time.Hour}
+	for in, want := range ages {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
+
+// TestSelectMatchesApply checks that SQL pushdown selects the same archives
+// as evaluating the expression in Go
+func TestSelectMatchesApply(t *testing.T) {
+	mgr, err := storage.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	reg := mgr.Registry()
+
+	for i, a := range sampleArchives() {
+		a.UID = fmt.Sprintf("uid-%d", i)
+		a.Path = filepath.Join(mgr.GetArchivesPath(), a.Name)
+		if err := reg.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := reg.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{
+		"size > 1GB and profile = media and not uploaded",
+		"uploaded or missing",
+		"not (managed or uploaded)",
+		`name ~ "*backup*"`,
+		"tag = client:acme or tag ~ project:*",
+		"not tag = client:acme",
+		"age > 30d",
+		"created = 2024-06-01",
+		"created >= 2024-06-01T08:00:00Z and created < 2024-06-02",
+		"meta.project_year >= 2024 or managed",
+		"status != missing and not meta.retention_class = legal",
+	} {
+		f, err := ParseAt(expr, now)
+		if err != nil {
+			t.Fatalf("%q: %v", expr, err)
+		}
+		got, err := f.Select(reg)
+		if err != nil {
+			t.Fatalf("%q: Select: %v", expr, err)
+		}
+		if names(got) != names(f.Apply(all)) {
+			t.Errorf("%q: SQL selected %s, Go matched %s", expr, names(got), names(f.Apply(all)))
+		}
+	}
+}
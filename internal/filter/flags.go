@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Flags are the classic filter flags shared by list, query save, batch and
+// search. Expression turns them into the equivalent filter expression.
+type Flags struct {
+	NotUploaded bool
+	Pattern     string // glob on the archive name
+	OlderThan   string // duration
+	NewerThan   string // duration
+	Managed     bool
+	External    bool
+	Missing     bool
+	Deleted     bool
+	Status      string
+	Profile     string
+	LargerThan  string // size, e.g. 100MB
+	SmallerThan string // size
+	Tags        []string
+	Where       []string // custom field conditions, e.g. project_year>=2024
+}
+
+// Expression returns the flags as an expression, validating values
+func (fl Flags) Expression() (string, error) {
+	var terms []string
+	add := func(field, op, value string) {
+		terms = append(terms, field+" "+op+" "+Quote(value))
+	}
+
+	if fl.NotUploaded {
+		terms = append(terms, "not uploaded")
+	}
+	if fl.Pattern != "" {
+		add("name", "~", fl.Pattern)
+	}
+	if fl.OlderThan != "" {
+		add("age", ">", fl.OlderThan)
+	}
+	if fl.NewerThan != "" {
+		add("age", "<", fl.NewerThan)
+	}
+	switch {
+	case fl.Managed && fl.External:
+		// Both means either; no restriction
+	case fl.Managed:
+		terms = append(terms, "managed")
+	case fl.External:
+		terms = append(terms, "external")
+	}
+	if fl.Missing {
+		terms = append(terms, "missing")
+	}
+	if fl.Deleted {
+		terms = append(terms, "deleted")
+	}
+	if fl.Status != "" {
+		add("status", "=", fl.Status)
+	}
+	if fl.Profile != "" {
+		add("profile", "=", fl.Profile)
+	}
+	if fl.LargerThan != "" {
+		add("size", ">", fl.LargerThan)
+	}
+	if fl.SmallerThan != "" {
+		add("size", "<", fl.SmallerThan)
+	}
+	for _, tag := range fl.Tags {
+		t, err := storage.NormalizeTag(tag)
+		if err != nil {
+			return "", err
+		}
+		add("tag", "=", t)
+	}
+	for _, w := range fl.Where {
+		cond, err := storage.ParseCondition(w)
+		if err != nil {
+			return "", err
+		}
+		add("meta."+cond.Key, cond.Op, cond.Value)
+	}
+
+	expr := And(terms...)
+	if _, err := Parse(expr); err != nil {
+		return "", fmt.Errorf("invalid filter flags: %w", err)
+	}
+	return expr, nil
+}
@@ -0,0 +1,205 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// node is one part of a parsed expression
+type node interface {
+	match(a *storage.Archive) bool
+	// sql returns a WHERE fragment that every matching archive satisfies.
+	// exact reports whether the fragment is equivalent to match; when it is
+	// not, the fragment only narrows the candidates.
+	sql() (clause string, args []interface{}, exact bool)
+	String() string
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ x node }
+
+func (n *andNode) match(a *storage.Archive) bool { return n.left.match(a) && n.right.match(a) }
+func (n *orNode) match(a *storage.Archive) bool  { return n.left.match(a) || n.right.match(a) }
+func (n *notNode) match(a *storage.Archive) bool { return !n.x.match(a) }
+
+func (n *andNode) sql() (string, []interface{}, bool) {
+	lc, la, le := n.left.sql()
+	rc, ra, re := n.right.sql()
+	switch {
+	case lc == "":
+		return rc, ra, false
+	case rc == "":
+		return lc, la, false
+	}
+	return "(" + lc + " AND " + rc + ")", append(la, ra...), le && re
+}
+
+func (n *orNode) sql() (string, []interface{}, bool) {
+	lc, la, le := n.left.sql()
+	rc, ra, re := n.right.sql()
+	if lc == "" || rc == "" {
+		return "", nil, false
+	}
+	return "(" + lc + " OR " + rc + ")", append(la, ra...), le && re
+}
+
+func (n *notNode) sql() (string, []interface{}, bool) {
+	c, a, exact := n.x.sql()
+	if c == "" || !exact {
+		return "", nil, false
+	}
+	return "NOT " + c, a, true
+}
+
+func (n *andNode) String() string { return n.left.String() + " and " + n.right.String() }
+func (n *orNode) String() string  { return "(" + n.left.String() + " or " + n.right.String() + ")" }
+func (n *notNode) String() string { return "not " + n.x.String() }
+
+// comparison is a single field <op> value test
+type comparison struct {
+	field string
+	op    string
+	raw   string
+
+	matchFn func(a *storage.Archive) bool
+
+	clause string
+	args   []interface{}
+	exact  bool
+}
+
+func (c *comparison) setSQL(clause string, args ...interface{}) {
+	c.clause, c.args, c.exact = clause, args, true
+}
+
+func (c *comparison) sql() (string, []interface{}, bool) { return c.clause, c.args, c.exact }
+
+func (c *comparison) String() string { return c.field + " " + c.op + " " + Quote(c.raw) }
+
+func (c *comparison) match(a *storage.Archive) bool { return c.matchFn(a) }
+
+// parser is a recursive descent parser over the token stream:
+//
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | primary
+//	primary    = "(" expr ")" | field op value | shorthand
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("not") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "expected ')'")
+		}
+		return x, nil
+	case tokWord:
+		if isKeyword(t.text) {
+			return nil, p.errorf(t, "unexpected %q", t.text)
+		}
+	case tokEOF:
+		return nil, p.errorf(t, "unexpected end of expression")
+	default:
+		return nil, p.errorf(t, "expected a field, got %q", t.text)
+	}
+
+	if p.peek().kind != tokOp {
+		sh, ok := shorthands[strings.ToLower(t.text)]
+		if !ok {
+			return nil, p.errorf(t, "expected an operator after %q", t.text)
+		}
+		c, err := newComparison(sh[0], sh[1], sh[2], p.now)
+		if err != nil {
+			return nil, p.errorf(t, "%v", err)
+		}
+		return c, nil
+	}
+
+	op := p.next()
+	v := p.next()
+	if v.kind != tokWord && v.kind != tokString {
+		return nil, p.errorf(v, "expected a value after %s %s", t.text, op.text)
+	}
+	c, err := newComparison(t.text, op.text, v.text, p.now)
+	if err != nil {
+		return nil, p.errorf(t, "%v", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits are binary multiples; K, KB and KiB all mean 1024 bytes
+var sizeUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+// ParseSize parses a byte count such as 1048576, 500K, 100MB or 1.5GB
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	mult, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q (use B, KB, MB, GB or TB)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// ParseAge parses a duration with d (days), w (weeks) and y (365 days) in
+// addition to the units time.ParseDuration understands
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	day := 24 * time.Hour
+	for suffix, unit := range map[string]time.Duration{"d": day, "w": 7 * day, "y": 365 * day} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 12h, 30d, 2w, 1y)", s)
+	}
+	return d, nil
+}
+
+// Quote returns v as a single expression value, quoting it when it would
+// not lex as one word
+func Quote(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n()\"'=!<>~") && !isKeyword(v) {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+func isKeyword(w string) bool {
+	switch strings.ToLower(w) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+// Package hooks runs the commands and webhooks configured for archive
+// lifecycle events. A command hook runs through the shell with environment
+// variables describing the archive and the event's JSON payload on stdin; a
+// webhook receives the same payload as a POST. Each attempt has a timeout
+// and failed hooks are retried with backoff.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Lifecycle events hooks can be attached to
+const (
+	PreCreate  = "pre_create"
+	PostCreate = "post_create"
+	PostTest   = "post_test"
+	OnDelete   = "on_delete"
+	OnPurge    = "on_purge"
+)
+
+// DefaultTimeout bounds each attempt of a hook without a timeout
+const DefaultTimeout = 30 * time.Second
+
+// retryDelay is the wait before the first retry; it doubles after each
+var retryDelay = time.Second
+
+// Payload describes an event to a hook
+type Payload struct {
+	Event   string            `json:"event"`
+	Time    time.Time         `json:"time"`
+	Vault   string            `json:"vault,omitempty"`
+	Archive *storage.Archive  `json:"archive,omitempty"`
+	Details map[string]string `json:"details,omitempty"` // event specifics, e.g. source for pre_create, passed for post_test
+}
+
+// Options configures a runner
+type Options struct {
+	// Vault is reported to hooks
+	Vault string
+	// Output receives command hooks' output and warnings; nil is stderr
+	Output io.Writer
+}
+
+// Runner runs the hooks configured for each event
+type Runner struct {
+	cfg    config.HooksConfig
+	opts   Options
+	client *http.Client
+}
+
+// New creates a runner for the configured hooks
+func New(cfg config.HooksConfig, opts Options) *Runner {
+	if opts.Output == nil {
+		opts.Output = os.Stderr
+	}
+	return &Runner{cfg: cfg, opts: opts, client: &http.Client{}}
+}
+
+// Validate checks hooks for obvious mistakes
+func Validate(cfg config.HooksConfig) error {
+	for _, event := range []string{PreCreate, PostCreate, PostTest, OnDelete, OnPurge} {
+		for i, h := range hooksFor(cfg, event) {
+			name := fmt.Sprintf("%s[%d]", event, i)
+			if (h.Command == "") == (h.URL == "") {
+				return fmt.Errorf("%s: set either command or url", name)
+			}
+			if h.URL != "" {
+				u, err := url.Parse(h.URL)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return fmt.Errorf("%s: url %q must be an http or https URL", name, h.URL)
+				}
+			}
+			if h.Timeout != "" {
+				if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+					return fmt.Errorf("%s: invalid timeout %q", name, h.Timeout)
+				}
+			}
+			if h.Retries < 0 {
+				return fmt.Errorf("%s: retries must not be negative", name)
+			}
+		}
+	}
+	return nil
+}
+
+func hooksFor(cfg config.HooksConfig, event string) []config.Hook {
+	switch event {
+	case PreCreate:
+		return cfg.PreCreate
+	case PostCreate:
+		return cfg.PostCreate
+	case PostTest:
+		return cfg.PostTest
+	case OnDelete:
+		return cfg.OnDelete
+	case OnPurge:
+		return cfg.OnPurge
+	}
+	return nil
+}
+
+// Has reports whether any hook is configured for event
+func (r *Runner) Has(event string) bool {
+	return len(hooksFor(r.cfg, event)) > 0
+}
+
+// Run runs event's hooks in order and returns what failed after retries.
+// Every hook runs even when an earlier one fails.
+func (r *Runner) Run(ctx context.Context, event string, a *storage.Archive, details map[string]string) error {
+	hooks := hooksFor(r.cfg, event)
+	if len(hooks) == 0 {
+		return nil
+	}
+	p := &Payload{Event: event, Time: time.Now(), Vault: r.opts.Vault, Archive: a, Details: details}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, h := range hooks {
+		if h.FailedOnly && details["passed"] == "true" {
+			continue
+		}
+		if err := r.runWithRetries(ctx, h, p, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s hook %d (%s): %w", event, i+1, describe(h), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notify runs event's hooks for something that has already happened, so
+// failures are only reported as warnings
+func (r *Runner) Notify(ctx context.Context, event string, a *storage.Archive, details map[string]string) {
+	if err := r.Run(ctx, event, a, details); err != nil {
+		fmt.Fprintf(r.opts.Output, "⚠️  Warning: %v\n", err)
+	}
+}
+
+// LifecycleHook runs the on_delete, on_purge and post_test hooks for
+// archives the storage manager deletes, purges and reports tested
+func (r *Runner) LifecycleHook() storage.LifecycleHook {
+	return func(event string, a *storage.Archive, details map[string]string) {
+		switch event {
+		case storage.LifecycleDelete:
+			r.Notify(context.Background(), OnDelete, a, details)
+		case storage.LifecyclePurge:
+			r.Notify(context.Background(), OnPurge, a, details)
+		case storage.LifecycleTest:
+			r.Notify(context.Background(), PostTest, a, details)
+		}
+	}
+}
+
+func (r *Runner) runWithRetries(ctx context.Context, h config.Hook, p *Payload, body []byte) error {
+	timeout := DefaultTimeout
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		var err error
+		if h.URL != "" {
+			err = r.post(attemptCtx, h, body)
+		} else {
+			err = r.command(attemptCtx, h, p, body)
+		}
+		if attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		cancel()
+		if err == nil || attempt >= h.Retries {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post sends the payload to a webhook; any non-2xx response is a failure
+func (r *Runner) post(ctx context.Context, h config.Hook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "7zarch-go")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// command runs a command hook through the shell with the payload on stdin
+func (r *Runner) command(ctx context.Context, h config.Hook, p *Payload, body []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+	cmd.Env = append(os.Environ(), Env(p)...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout, cmd.Stderr = r.opts.Output, r.opts.Output
+	cmd.WaitDelay = time.Second
+	return cmd.Run()
+}
+
+// Env returns the environment variables describing an event to a command
+// hook: SEVENZARCH_EVENT, the archive's fields and each detail, upper-cased
+func Env(p *Payload) []string {
+	env := []string{"SEVENZARCH_EVENT=" + p.Event, "SEVENZARCH_VAULT=" + p.Vault}
+	if a := p.Archive; a != nil {
+		env = append(env,
+			"SEVENZARCH_UID="+a.UID,
+			"SEVENZARCH_NAME="+a.Name,
+			"SEVENZARCH_PATH="+a.Path,
+			"SEVENZARCH_SIZE="+strconv.FormatInt(a.Size, 10),
+			"SEVENZARCH_PROFILE="+a.Profile,
+			"SEVENZARCH_CHECKSUM="+a.Checksum,
+			"SEVENZARCH_STATUS="+a.Status,
+			"SEVENZARCH_MANAGED="+strconv.FormatBool(a.Managed),
+		)
+	}
+	for k, v := range p.Details {
+		env = append(env, "SEVENZARCH_"+strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
+
+func describe(h config.Hook) string {
+	if h.URL != "" {
+		return "POST " + h.URL
+	}
+	return h.Command
+}
@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func init() { retryDelay = time.Millisecond }
+
+func TestWebhookPostsPayload(t *testing.T) {
+	var got Payload
+	var auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, contentType = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	r := New(config.HooksConfig{PostCreate: []config.Hook{{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer s3cret"}}}},
+		Options{Vault: "photos"})
+	a := &storage.Archive{UID: "abc123", Name: "photos.7z", Path: "/archives/photos.7z", Size: 42}
+	if err := r.Run(context.Background(), PostCreate, a, map[string]string{"source": "/data/photos"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got.Event != PostCreate || got.Vault != "photos" || got.Archive == nil || got.Archive.UID != "abc123" || got.Details["source"] != "/data/photos" {
+		t.Errorf("payload = %+v", got)
+	}
+	if auth != "Bearer s3cret" || contentType != "application/json" {
+		t.Errorf("headers = %q, %q", auth, contentType)
+	}
+
+	// Events without hooks do nothing
+	if err := r.Run(context.Background(), OnPurge, a, nil); err != nil {
+		t.Errorf("Run without hooks: %v", err)
+	}
+}
+
+func TestWebhookRetriesAndTimesOut(t *testing.T) {
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+
+	r := New(config.HooksConfig{OnDelete: []config.Hook{{URL: flaky.URL, Retries: 2}}}, Options{})
+	if err := r.Run(context.Background(), OnDelete, &storage.Archive{Name: "a.7z"}, nil); err != nil {
+		t.Fatalf("Run with retries: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("got %d calls, want 3", calls.Load())
+	}
+
+	calls.Store(0)
+	r = New(config.HooksConfig{OnDelete: []config.Hook{{URL: flaky.URL, Retries: 1}}}, Options{})
+	err := r.Run(context.Background(), OnDelete, &storage.Archive{Name: "a.7z"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("err = %v, want a 503 after 2 attempts", err)
+	}
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer slow.Close()
+	defer close(release)
+	r = New(config.HooksConfig{OnDelete: []config.Hook{{URL: slow.URL, Timeout: "50ms"}}}, Options{})
+	if err := r.Run(context.Background(), OnDelete, &storage.Archive{Name: "a.7z"}, nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
+
+func TestCommandHookEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "hook.out")
+	r := New(config.HooksConfig{PostTest: []config.Hook{
+		{Command: `echo "$SEVENZARCH_EVENT $SEVENZARCH_NAME $SEVENZARCH_PASSED" > ` + out},
+		{Command: "exit 3"},
+		{Command: "touch " + out + ".failed", FailedOnly: true},
+	}}, Options{Output: &strings.Builder{}})
+
+	err := r.Run(context.Background(), PostTest, &storage.Archive{Name: "docs.7z"}, map[string]string{"passed": "true"})
+	if err == nil || !strings.Contains(err.Error(), "post_test hook 2") {
+		t.Errorf("err = %v, want hook 2 to fail", err)
+	}
+	data, _ := os.ReadFile(out)
+	if got := strings.TrimSpace(string(data)); got != "post_test docs.7z true" {
+		t.Errorf("hook saw %q", got)
+	}
+	if _, err := os.Stat(out + ".failed"); err == nil {
+		t.Error("failed_only hook ran for a passing test")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, bad := range []config.HooksConfig{
+		{PreCreate: []config.Hook{{}}},
+		{PostCreate: []config.Hook{{Command: "true", URL: "http://example.com"}}},
+		{OnDelete: []config.Hook{{URL: "example.com/hook"}}},
+		{OnPurge: []config.Hook{{Command: "true", Timeout: "soon"}}},
+		{PostTest: []config.Hook{{Command: "true", Retries: -1}}},
+	} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+	if err := Validate(config.HooksConfig{OnDelete: []config.Hook{{URL: "https://chat.example.com/hook", Timeout: "5s", Retries: 3}}}); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
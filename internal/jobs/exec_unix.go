@@ -0,0 +1,21 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// command starts a job in its own process group, so it is not hung up with
+// the worker's terminal and canceling it stops the processes it started,
+// such as 7z, along with it
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	return cmd
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package jobs
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// command starts a job in its own process group so console signals sent to
+// the worker do not reach it; canceling kills it
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	return cmd
+}
@@ -0,0 +1,262 @@
+// Package jobs runs commands queued in the registry's job table. A worker
+// claims queued jobs up to a per-type concurrency limit, runs each as a
+// child process writing to the job's log, and records how it ended. Jobs
+// left running by a worker that died are requeued when a worker starts, so
+// a dropped SSH session or a reboot does not lose them.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// DefaultConcurrency is how many jobs of each type may run at once unless
+// configured. Compression is CPU and disk bound, so one create runs at a time.
+var DefaultConcurrency = map[string]int{
+	"create": 1,
+	"test":   2,
+	"batch":  1,
+	"upload": 2,
+}
+
+// Executor runs one job, writing its output to log, until it finishes or
+// ctx is canceled
+type Executor func(ctx context.Context, job *storage.Job, log io.Writer) error
+
+// Options configures a worker
+type Options struct {
+	// Concurrency overrides DefaultConcurrency per job type; types in
+	// neither run one at a time
+	Concurrency map[string]int
+	// Poll is how often the queue and cancel requests are checked
+	Poll time.Duration
+	// Once exits when no job is running and none can be claimed
+	Once bool
+	// Exec runs a job; nil runs this executable with the job's arguments
+	Exec Executor
+	// Logf receives one line per job state change; nil discards output
+	Logf func(format string, args ...interface{})
+}
+
+// Worker runs queued jobs
+type Worker struct {
+	mgr  *storage.Manager
+	opts Options
+	wg   sync.WaitGroup
+	done chan struct{} // receives when a job finishes
+}
+
+// NewWorker creates a worker over the job queue in mgr's registry
+func NewWorker(mgr *storage.Manager, opts Options) *Worker {
+	if opts.Poll <= 0 {
+		opts.Poll = 2 * time.Second
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	if opts.Exec == nil {
+		opts.Exec = CommandExecutor("")
+	}
+	return &Worker{mgr: mgr, opts: opts, done: make(chan struct{}, 1)}
+}
+
+// Limit returns how many jobs of a type may run at once
+func (w *Worker) Limit(jobType string) int {
+	if n, ok := w.opts.Concurrency[jobType]; ok {
+		return n
+	}
+	if n, ok := DefaultConcurrency[jobType]; ok {
+		return n
+	}
+	return 1
+}
+
+// Run claims and runs jobs until ctx is done, or with Once until the queue
+// is drained. Jobs still running when ctx is done are stopped and requeued.
+func (w *Worker) Run(ctx context.Context) error {
+	reg := w.mgr.Registry()
+	if err := w.recover(); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	running := 0
+	ticker := time.NewTicker(w.opts.Poll)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			job, err := reg.ClaimJob(w.Limit)
+			if err != nil {
+				w.opts.Logf("⚠️  failed to claim a job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			mu.Lock()
+			running++
+			mu.Unlock()
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				w.run(ctx, job)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				select {
+				case w.done <- struct{}{}:
+				default:
+				}
+			}()
+		}
+
+		mu.Lock()
+		idle := running == 0
+		mu.Unlock()
+		if w.opts.Once && idle {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			w.wg.Wait()
+			return nil
+		case <-w.done:
+		case <-ticker.C:
+			if err := w.recover(); err != nil {
+				w.opts.Logf("⚠️  %v", err)
+			}
+		}
+	}
+}
+
+// recover requeues jobs whose worker exited, logging each
+func (w *Worker) recover() error {
+	recovered, err := w.mgr.Registry().RecoverJobs()
+	for _, j := range recovered {
+		w.opts.Logf("↩️  job %d (%s) was left running by pid %d; requeued", j.ID, j.Type, j.PID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to recover jobs: %w", err)
+	}
+	return nil
+}
+
+// run executes a claimed job and records how it ended. Stopping the worker
+// requeues the job; a cancel request marks it canceled.
+func (w *Worker) run(workerCtx context.Context, job *storage.Job) {
+	reg := w.mgr.Registry()
+	logPath := w.mgr.JobLogPath(job.ID)
+	log, err := openLog(logPath)
+	if err != nil {
+		w.opts.Logf("❌ job %d: %v", job.ID, err)
+		_ = reg.FinishJob(job.ID, storage.JobFailed, err.Error())
+		return
+	}
+	defer log.Close()
+
+	w.opts.Logf("▶️  job %d (%s) started: %s", job.ID, job.Type, strings.Join(job.Args, " "))
+	fmt.Fprintf(log, "=== attempt %d started %s: %s\n", job.Attempts, time.Now().Format(time.RFC3339), strings.Join(job.Args, " "))
+
+	ctx, cancel := context.WithCancel(workerCtx)
+	defer cancel()
+	var canceled bool
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		ticker := time.NewTicker(w.opts.Poll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if requested, err := reg.CancelRequested(job.ID); err == nil && requested {
+					canceled = true
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	runErr := w.opts.Exec(ctx, job, log)
+	cancel()
+	<-watchDone
+	elapsed := time.Since(start).Round(time.Second)
+
+	switch {
+	case canceled:
+		fmt.Fprintf(log, "=== canceled after %s\n", elapsed)
+		w.opts.Logf("⏹️  job %d canceled", job.ID)
+		err = reg.FinishJob(job.ID, storage.JobCanceled, "canceled")
+	case workerCtx.Err() != nil:
+		fmt.Fprintf(log, "=== interrupted after %s; requeued\n", elapsed)
+		w.opts.Logf("↩️  job %d interrupted; requeued", job.ID)
+		err = reg.RequeueJob(job.ID)
+	case runErr != nil:
+		fmt.Fprintf(log, "=== failed after %s: %v\n", elapsed, runErr)
+		w.opts.Logf("❌ job %d failed: %v", job.ID, runErr)
+		err = reg.FinishJob(job.ID, storage.JobFailed, runErr.Error())
+	default:
+		fmt.Fprintf(log, "=== succeeded after %s\n", elapsed)
+		w.opts.Logf("✅ job %d succeeded in %s", job.ID, elapsed)
+		err = reg.FinishJob(job.ID, storage.JobSucceeded, "")
+	}
+	if err != nil {
+		w.opts.Logf("⚠️  job %d: %v", job.ID, err)
+	}
+}
+
+// CommandExecutor runs jobs as child processes of exe with the job's
+// arguments in its directory; "" means this executable. Canceling
+// interrupts the child and the processes it started, killing them if they
+// have not exited within a grace period.
+func CommandExecutor(exe string) Executor {
+	return func(ctx context.Context, job *storage.Job, log io.Writer) error {
+		path := exe
+		if path == "" {
+			var err error
+			if path, err = os.Executable(); err != nil {
+				return fmt.Errorf("failed to find executable: %w", err)
+			}
+		}
+		cmd := command(ctx, path, job.Args...)
+		cmd.Dir = job.Dir
+		cmd.Stdout, cmd.Stderr = log, log
+		cmd.WaitDelay = killGrace
+		err := cmd.Run()
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return fmt.Errorf("exit status %d", exitErr.ExitCode())
+		}
+		return err
+	}
+}
+
+// killGrace is how long an interrupted job has to exit before it is killed
+const killGrace = 30 * time.Second
+
+// openLog opens a job's log for appending, creating its directory
+func openLog(path string) (*os.File, error) {
+	// #nosec G301: restrict permissions on created directories
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	// #nosec G302: job logs are private to the user
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job log: %w", err)
+	}
+	return f, nil
+}
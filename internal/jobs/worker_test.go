@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func newManager(t *testing.T) *storage.Manager {
+	t.Helper()
+	mgr, err := storage.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	return mgr
+}
+
+func TestWorkerRunsQueueOnce(t *testing.T) {
+	mgr := newManager(t)
+	reg := mgr.Registry()
+	ok, _ := reg.EnqueueJob([]string{"create", "--", "/data"}, "/home/me")
+	bad, _ := reg.EnqueueJob([]string{"test", "--", "broken.7z"}, "")
+
+	w := NewWorker(mgr, Options{
+		Once: true,
+		Poll: 10 * time.Millisecond,
+		Exec: func(ctx context.Context, job *storage.Job, log io.Writer) error {
+			fmt.Fprintf(log, "running %s in %s\n", strings.Join(job.Args, " "), job.Dir)
+			if job.Type == "test" {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+	})
+	if err := w.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if j, _ := reg.Job(ok.ID); j.Status != storage.JobSucceeded || j.Finished == nil {
+		t.Errorf("create job = %+v, want succeeded", j)
+	}
+	if j, _ := reg.Job(bad.ID); j.Status != storage.JobFailed || j.Error != "exit status 1" {
+		t.Errorf("test job = %+v, want failed", j)
+	}
+
+	log, err := os.ReadFile(mgr.JobLogPath(ok.ID))
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	for _, want := range []string{"=== attempt 1 started", "running create -- /data in /home/me", "=== succeeded"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestWorkerCancelAndShutdown(t *testing.T) {
+	mgr := newManager(t)
+	reg := mgr.Registry()
+	canceled, _ := reg.EnqueueJob([]string{"test", "--", "a.7z"}, "")
+	interrupted, _ := reg.EnqueueJob([]string{"create", "--", "/data"}, "")
+
+	started := make(chan int64, 2)
+	w := NewWorker(mgr, Options{
+		Poll: 10 * time.Millisecond,
+		Exec: func(ctx context.Context, job *storage.Job, log io.Writer) error {
+			started <- job.ID
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("jobs did not start")
+		}
+	}
+
+	if _, err := reg.CancelJob(canceled.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if j, _ := reg.Job(canceled.ID); j.Status == storage.JobCanceled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("canceled job kept running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Stopping the worker puts the running job back in the queue
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if j, _ := reg.Job(interrupted.ID); j.Status != storage.JobQueued || j.Attempts != 1 {
+		t.Errorf("interrupted job = %+v, want queued", j)
+	}
+}
+
+func TestWorkerCanceledCreateRerunsCleanly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// A stand-in 7z writes part of the archive, then blocks while the block
+	// file exists and otherwise finishes it
+	bin, src, out := t.TempDir(), t.TempDir(), t.TempDir()
+	block := filepath.Join(bin, "block")
+	script := "#!/bin/sh\nprintf partial > \"$2\"\nif [ -f " + block + " ]; then exec sleep 30; fi\nprintf complete > \"$2\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "7z"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(block, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := newManager(t)
+	reg := mgr.Registry()
+	output := filepath.Join(out, "a.7z")
+	job, _ := reg.EnqueueJob([]string{"create", "--", src}, "")
+	opts := Options{
+		Poll: 10 * time.Millisecond,
+		Exec: func(ctx context.Context, job *storage.Job, log io.Writer) error {
+			_, err := archive.NewManager().Create(ctx, archive.CreateOptions{Source: src, Output: output})
+			return err
+		},
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewWorker(mgr, opts).Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if partials, _ := filepath.Glob(filepath.Join(out, ".*.partial")); len(partials) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("create did not start writing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := reg.CancelJob(job.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	for {
+		if j, _ := reg.Job(job.ID); j.Status == storage.JobCanceled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("canceled create kept running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 0 {
+		t.Fatalf("canceled create left %v behind", entries)
+	}
+
+	// The rerun writes the archive under its own name, not a versioned one
+	_ = os.Remove(block)
+	if _, err := reg.RetryJob(job.ID); err != nil {
+		t.Fatalf("RetryJob: %v", err)
+	}
+	opts.Once = true
+	if err := NewWorker(mgr, opts).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if j, _ := reg.Job(job.ID); j.Status != storage.JobSucceeded {
+		t.Fatalf("rerun = %+v, want succeeded", j)
+	}
+	entries, _ := os.ReadDir(out)
+	data, _ := os.ReadFile(output)
+	if len(entries) != 1 || string(data) != "complete" {
+		t.Fatalf("after rerun: entries %v, archive %q", entries, data)
+	}
+}
+
+func TestWorkerLimit(t *testing.T) {
+	w := NewWorker(newManager(t), Options{Concurrency: map[string]int{"create": 3}})
+	if got := w.Limit("create"); got != 3 {
+		t.Errorf("Limit(create) = %d, want configured 3", got)
+	}
+	if got := w.Limit("test"); got != DefaultConcurrency["test"] {
+		t.Errorf("Limit(test) = %d, want default %d", got, DefaultConcurrency["test"])
+	}
+	if got := w.Limit("other"); got != 1 {
+		t.Errorf("Limit(other) = %d, want 1", got)
+	}
+}
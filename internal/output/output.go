@@ -0,0 +1,236 @@
+// Package output writes command results for scripts. With --format json a
+// command prints one JSON document; with --format ndjson it prints one JSON
+// object per line, streaming items as they happen and ending with the
+// result. Every document carries SchemaVersion so consumers can detect
+// incompatible changes.
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/spf13/cobra"
+)
+
+// SchemaVersion is bumped whenever a field is renamed or removed or its
+// meaning changes. New fields may appear without a bump.
+const SchemaVersion = 1
+
+// Format is how a command reports its result
+type Format string
+
+// Supported formats
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat validates a --format value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Text, JSON, NDJSON:
+		return f, nil
+	case "":
+		return Text, nil
+	}
+	return "", &errs.ValidationError{Field: "format", Value: s, Message: "supported formats: text, json, ndjson"}
+}
+
+// Structured reports whether the format is for machines
+func (f Format) Structured() bool { return f == JSON || f == NDJSON }
+
+// String implements pflag.Value
+func (f *Format) String() string { return string(*f) }
+
+// Set implements pflag.Value
+func (f *Format) Set(s string) error {
+	parsed, err := ParseFormat(s)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// Type implements pflag.Value
+func (f *Format) Type() string { return "format" }
+
+// Document is a command's result. In NDJSON streams it is the last line,
+// with Type "result" or "error".
+type Document struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	Type          string `json:"type,omitempty"`
+	OK            bool   `json:"ok"`
+	Data          any    `json:"data,omitempty"`
+	Error         *Error `json:"error,omitempty"`
+}
+
+// Event is an item streamed before the result in NDJSON output
+type Event struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	Type          string `json:"type"`
+	Data          any    `json:"data"`
+}
+
+// Error describes a failure
+type Error struct {
+	Code    string `json:"code"` // validation, not_found, invalid_operation, filesystem, database, configuration or error
+	Message string `json:"message"`
+}
+
+// Printer writes a command's result in the chosen format. It is safe for
+// concurrent use.
+type Printer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	text    io.Writer
+	format  Format
+	command string
+}
+
+// New creates a printer writing documents to w. text receives the
+// human-readable output: w itself for Text, normally stderr otherwise so
+// progress stays visible without corrupting the document.
+func New(w, text io.Writer, format Format, command string) *Printer {
+	if !format.Structured() {
+		text = w
+	}
+	return &Printer{w: w, text: text, format: format, command: command}
+}
+
+// Structured reports whether the printer writes documents
+func (p *Printer) Structured() bool { return p.format.Structured() }
+
+// Streaming reports whether items are written as events as they happen
+func (p *Printer) Streaming() bool { return p.format == NDJSON }
+
+// Text returns where human-readable output goes
+func (p *Printer) Text() io.Writer { return p.text }
+
+// Event streams an item in NDJSON; other formats ignore it, so the result
+// should hold everything a JSON consumer needs
+func (p *Printer) Event(kind string, data any) error {
+	if !p.Streaming() {
+		return nil
+	}
+	return p.write(&Event{SchemaVersion: SchemaVersion, Command: p.command, Type: kind, Data: data})
+}
+
+// Result writes the successful result of the command
+func (p *Printer) Result(data any) error {
+	if !p.Structured() {
+		return nil
+	}
+	return p.write(p.document(true, data, nil))
+}
+
+// Failure writes a result describing a failure, such as a test that found
+// a corrupt archive, and returns err marked as reported so it is not
+// written a second time. In Text format it returns err unchanged.
+func (p *Printer) Failure(data any, err error) error {
+	if !p.Structured() {
+		return err
+	}
+	if werr := p.write(p.document(false, data, err)); werr != nil {
+		return werr
+	}
+	return &reportedError{err}
+}
+
+// Error writes a document for a command that failed without a result
+func (p *Printer) Error(err error) error {
+	if !p.Structured() || IsReported(err) {
+		return nil
+	}
+	return p.write(p.document(false, nil, err))
+}
+
+func (p *Printer) document(ok bool, data any, err error) *Document {
+	doc := &Document{SchemaVersion: SchemaVersion, Command: p.command, OK: ok, Data: data}
+	if err != nil {
+		doc.Error = &Error{Code: Code(err), Message: err.Error()}
+	}
+	if p.Streaming() {
+		doc.Type = "result"
+		if err != nil {
+			doc.Type = "error"
+		}
+	}
+	return doc
+}
+
+func (p *Printer) write(v any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	enc := json.NewEncoder(p.w)
+	if p.format == JSON {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s output: %w", p.format, err)
+	}
+	return nil
+}
+
+// reportedError is an error whose document has already been written
+type reportedError struct{ error }
+
+func (e *reportedError) Unwrap() error { return e.error }
+
+// IsReported reports whether err was returned by Failure
+func IsReported(err error) bool {
+	var r *reportedError
+	return errors.As(err, &r)
+}
+
+// Code classifies err for Error.Code
+func Code(err error) string {
+	var (
+		validation *errs.ValidationError
+		notFound   *errs.NotFoundError
+		invalidOp  *errs.InvalidOperationError
+		fs         *errs.FileSystemError
+		db         *errs.DatabaseError
+		cfg        *errs.ConfigurationError
+	)
+	switch {
+	case errors.As(err, &validation):
+		return "validation"
+	case errors.As(err, &notFound):
+		return "not_found"
+	case errors.As(err, &invalidOp):
+		return "invalid_operation"
+	case errors.As(err, &fs):
+		return "filesystem"
+	case errors.As(err, &db):
+		return "database"
+	case errors.As(err, &cfg):
+		return "configuration"
+	}
+	return "error"
+}
+
+// annotation marks commands that write their own result
+const annotation = "output.structured"
+
+// Annotate marks cmd as writing its own result with a Printer. Commands
+// without the mark get a plain success or error document.
+func Annotate(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[annotation] = "true"
+	return cmd
+}
+
+// Annotated reports whether cmd writes its own result
+func Annotated(cmd *cobra.Command) bool {
+	return cmd != nil && cmd.Annotations[annotation] == "true"
+}
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	errs "github.com/adamstac/7zarch-go/internal/errors"
+	"github.com/spf13/cobra"
+)
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": Text, "text": Text, "json": JSON, "ndjson": NDJSON} {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	_, err := ParseFormat("yaml")
+	var verr *errs.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ParseFormat(yaml) error = %v, want ValidationError", err)
+	}
+}
+
+func TestTextFormatWritesNoDocument(t *testing.T) {
+	var buf bytes.Buffer
+	p := New(&buf, nil, Text, "profiles")
+	if p.Text() != &buf {
+		t.Fatal("text output should go to the result writer in text format")
+	}
+	if err := p.Result(map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	_ = p.Event("progress", 1)
+	failed := errors.New("boom")
+	if err := p.Failure(nil, failed); err != failed {
+		t.Fatalf("Failure in text format = %v, want the error unchanged", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestJSONDocument(t *testing.T) {
+	var buf, text bytes.Buffer
+	p := New(&buf, &text, JSON, "db status")
+	if p.Text() != &text {
+		t.Fatal("text output should go to the text writer in json format")
+	}
+	_ = p.Event("progress", 1) // JSON has no events
+	if err := p.Result(map[string]int{"pending": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Document
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	if doc.SchemaVersion != SchemaVersion || doc.Command != "db status" || !doc.OK || doc.Type != "" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if doc.Data["pending"] != 2 {
+		t.Fatalf("unexpected data: %v", doc.Data)
+	}
+}
+
+func TestNDJSONStream(t *testing.T) {
+	var buf bytes.Buffer
+	p := New(&buf, nil, NDJSON, "test")
+	for i := 1; i <= 2; i++ {
+		if err := p.Event("archive", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := p.Failure(map[string]int{"failed": 1}, &errs.NotFoundError{Resource: "archive", ID: "x"})
+	if !IsReported(err) {
+		t.Fatalf("Failure should return a reported error, got %v", err)
+	}
+	if err := p.Error(err); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]any
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %q is not JSON: %v", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 2 events and 1 result (Error must skip reported errors)", len(lines))
+	}
+	for i, want := range []string{"archive", "archive", "error"} {
+		if lines[i]["type"] != want || lines[i]["schema_version"] != float64(SchemaVersion) {
+			t.Fatalf("line %d = %v, want type %q", i, lines[i], want)
+		}
+	}
+	last := lines[2]
+	if last["ok"] != false || last["error"].(map[string]any)["code"] != "not_found" {
+		t.Fatalf("unexpected result line: %v", last)
+	}
+}
+
+func TestCode(t *testing.T) {
+	cases := map[string]error{
+		"validation":        &errs.ValidationError{Field: "f"},
+		"not_found":         fmt.Errorf("wrapped: %w", &errs.NotFoundError{Resource: "archive"}),
+		"invalid_operation": &errs.InvalidOperationError{Operation: "restore"},
+		"filesystem":        &errs.FileSystemError{Operation: "read", Path: "/x", Err: errors.New("denied")},
+		"database":          &errs.DatabaseError{Operation: "query", Err: errors.New("locked")},
+		"configuration":     &errs.ConfigurationError{Setting: "hooks"},
+		"error":             errors.New("plain"),
+	}
+	for want, err := range cases {
+		if got := Code(err); got != want {
+			t.Errorf("Code(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	cmd := &cobra.Command{Use: "list"}
+	if Annotated(cmd) || Annotated(nil) {
+		t.Fatal("commands are not annotated by default")
+	}
+	if Annotate(cmd) != cmd || !Annotated(cmd) {
+		t.Fatal("Annotate should mark the command")
+	}
+}
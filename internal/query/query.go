@@ -1,21 +1,18 @@
 package query
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
+	"github.com/adamstac/7zarch-go/internal/filter"
 	"github.com/adamstac/7zarch-go/internal/search"
 	"github.com/adamstac/7zarch-go/internal/storage"
 )
 
-const (
-	migrationQueryID   = "0004_query_system"
-	migrationQueryName = "Add queries table for saved query support"
-)
-
 // Query represents a saved filter configuration
 type Query struct {
 	Name     string            `json:"name"`
@@ -47,10 +44,8 @@ func (qm *QueryManager) Save(name string, filters map[string]string) error {
 	if name == "" {
 		return fmt.Errorf("query name cannot be empty")
 	}
-
-	// Ensure query table exists
-	if err := qm.ensureQueryTable(); err != nil {
-		return fmt.Errorf("failed to ensure query table: %w", err)
+	if _, err := compileFilters(filters); err != nil {
+		return err
 	}
 
 	// Serialize filters to JSON
@@ -74,10 +69,6 @@ func (qm *QueryManager) Save(name string, filters map[string]string) error {
 
 // List returns all saved queries
 func (qm *QueryManager) List() ([]*Query, error) {
-	if err := qm.ensureQueryTable(); err != nil {
-		return nil, fmt.Errorf("failed to ensure query table: %w", err)
-	}
-
 	rows, err := qm.db.Query(`
 		SELECT name, filters, created, last_used, use_count 
 		FROM queries 
@@ -123,10 +114,16 @@ func (qm *QueryManager) List() ([]*Query, error) {
 
 // Run executes a saved query and returns matching archives
 func (qm *QueryManager) Run(name string) ([]*storage.Archive, error) {
-	if err := qm.ensureQueryTable(); err != nil {
-		return nil, fmt.Errorf("failed to ensure query table: %w", err)
+	page, err := qm.RunPage(context.Background(), name, storage.Criteria{})
+	if err != nil {
+		return nil, err
 	}
+	return page.Archives, nil
+}
 
+// RunPage executes a saved query and returns one page of matching archives.
+// Ordering and paging come from c; the query supplies the filter.
+func (qm *QueryManager) RunPage(ctx context.Context, name string, c storage.Criteria) (*storage.Page, error) {
 	// Get the query
 	var filtersJSON string
 	err := qm.db.QueryRow(`SELECT filters FROM queries WHERE name = ?`, name).Scan(&filtersJSON)
@@ -155,15 +152,11 @@ func (qm *QueryManager) Run(name string) ([]*storage.Archive, error) {
 	}
 
 	// Execute the filters using the resolver
-	return qm.executeFilters(filters)
+	return qm.executeFilters(ctx, filters, c)
 }
 
 // Delete removes a saved query
 func (qm *QueryManager) Delete(name string) error {
-	if err := qm.ensureQueryTable(); err != nil {
-		return fmt.Errorf("failed to ensure query table: %w", err)
-	}
-
 	result, err := qm.db.Exec(`DELETE FROM queries WHERE name = ?`, name)
 	if err != nil {
 		return fmt.Errorf("failed to delete query: %w", err)
@@ -183,10 +176,6 @@ func (qm *QueryManager) Delete(name string) error {
 
 // Get retrieves a specific saved query
 func (qm *QueryManager) Get(name string) (*Query, error) {
-	if err := qm.ensureQueryTable(); err != nil {
-		return nil, fmt.Errorf("failed to ensure query table: %w", err)
-	}
-
 	var query Query
 	var filtersJSON string
 	var createdUnix int64
@@ -220,16 +209,88 @@ func (qm *QueryManager) Get(name string) (*Query, error) {
 	return &query, nil
 }
 
+// FilterKey is the saved filter entry holding a filter expression. Queries
+// saved before expressions existed store one entry per list flag instead;
+// Expression converts those.
+const FilterKey = "filter"
+
+// Expression returns the filter expression described by a saved filter map.
+// Search entries are not part of the expression and are skipped.
+func Expression(filters map[string]string) (string, error) {
+	var flags filter.Flags
+	var extra []string
+	for key, value := range filters {
+		switch key {
+		case "not-uploaded":
+			flags.NotUploaded = value == "true"
+		case "uploaded":
+			if value == "true" {
+				extra = append(extra, "uploaded")
+			} else if value == "false" {
+				flags.NotUploaded = true
+			}
+		case "pattern":
+			flags.Pattern = value
+		case "older-than":
+			flags.OlderThan = value
+		case "managed":
+			flags.Managed = value == "true"
+			flags.External = flags.External || value == "false"
+		case "external":
+			flags.External = value == "true"
+		case "missing":
+			flags.Missing = value == "true"
+		case "deleted":
+			flags.Deleted = value == "true"
+		case "status":
+			flags.Status = value
+		case "profile":
+			flags.Profile = value
+		case "larger-than":
+			flags.LargerThan = value
+		case "tag":
+			flags.Tags = strings.Split(value, ",")
+		case "where":
+			flags.Where = strings.Split(value, "\n")
+		}
+	}
+	expr, err := flags.Expression()
+	if err != nil {
+		return "", err
+	}
+	expr = filter.And(append([]string{expr, filters[FilterKey]}, extra...)...)
+	if _, err := filter.Parse(expr); err != nil {
+		return "", err
+	}
+	return expr, nil
+}
+
+// compileFilters parses the expression of a saved filter map
+func compileFilters(filters map[string]string) (*filter.Filter, error) {
+	expr, err := Expression(filters)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Parse(expr)
+}
+
 // executeFilters converts filter map to archive list using the resolver and search engine
-func (qm *QueryManager) executeFilters(filters map[string]string) ([]*storage.Archive, error) {
-	var archives []*storage.Archive
-	var err error
+func (qm *QueryManager) executeFilters(ctx context.Context, filters map[string]string, c storage.Criteria) (*storage.Page, error) {
+	f, err := compileFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	registry := qm.resolver.Registry()
+	if registry == nil {
+		return nil, fmt.Errorf("registry not available")
+	}
+	c = f.Criteria(c)
 
 	// Check if this query includes search terms
 	if searchTerm, hasSearch := filters["search"]; hasSearch {
 		// Use search engine for the base archive set
 		searchOpts := search.SearchOptions{}
-		
+
 		// Apply search options from filters
 		if field, ok := filters["search-field"]; ok {
 			searchOpts.Field = field
@@ -240,147 +301,71 @@ func (qm *QueryManager) executeFilters(filters map[string]string) ([]*storage.Ar
 		if _, ok := filters["search-case-sensitive"]; ok {
 			searchOpts.CaseSensitive = true
 		}
-
-		// Ensure search table exists
-		if err := qm.searchEngine.EnsureSearchTable(); err != nil {
-			return nil, fmt.Errorf("failed to initialize search: %w", err)
+		if _, ok := filters["search-fuzzy"]; ok {
+			searchOpts.Fuzzy = true
 		}
 
-		archives, err = qm.searchEngine.SearchWithOptions(searchTerm, searchOpts)
+		archives, err := qm.searchEngine.SearchWithOptions(searchTerm, searchOpts)
 		if err != nil {
 			return nil, fmt.Errorf("search failed: %w", err)
 		}
-	} else {
-		// No search terms, get all archives from registry
-		registry := qm.resolver.Registry()
-		if registry == nil {
-			return nil, fmt.Errorf("registry not available")
+		// Page through the hits in the registry alongside the filter
+		c.UIDs = make([]string, len(archives))
+		for i, a := range archives {
+			c.UIDs[i] = a.UID
 		}
-
-		archives, err = registry.List()
-		if err != nil {
-			return nil, fmt.Errorf("failed to list archives: %w", err)
+		// Without an explicit sort the hits keep their relevance order
+		if c.Sort == "" {
+			return findRanked(ctx, registry, c)
 		}
 	}
 
-	// Apply additional filters (non-search filters)
-	filtered := make([]*storage.Archive, 0)
-	for _, archive := range archives {
-		if qm.matchesFilters(archive, filters) {
-			filtered = append(filtered, archive)
-		}
-	}
-
-	return filtered, nil
-}
-
-// matchesFilters checks if an archive matches the saved filter criteria
-func (qm *QueryManager) matchesFilters(archive *storage.Archive, filters map[string]string) bool {
-	for key, value := range filters {
-		switch key {
-		case "status":
-			if archive.Status != value {
-				return false
-			}
-		case "profile":
-			if archive.Profile != value {
-				return false
-			}
-		case "managed":
-			if value == "true" && !archive.Managed {
-				return false
-			}
-			if value == "false" && archive.Managed {
-				return false
-			}
-		case "uploaded":
-			if value == "true" && !archive.Uploaded {
-				return false
-			}
-			if value == "false" && archive.Uploaded {
-				return false
-			}
-		case "missing":
-			if value == "true" && archive.Status != "missing" {
-				return false
-			}
-		case "deleted":
-			if value == "true" && archive.Status != "deleted" {
-				return false
-			}
-		case "larger-than":
-			// Parse the size value
-			if threshold, err := strconv.ParseInt(value, 10, 64); err == nil {
-				if archive.Size <= threshold {
-					return false
-				}
-			}
-		case "not-uploaded":
-			if value == "true" && archive.Uploaded {
-				return false
-			}
-		// Skip search-specific filters - they're handled separately
-		case "search", "search-field", "search-regex", "search-case-sensitive":
-			continue
-		// Note: More complex filters like pattern, older-than
-		// can be implemented as needed
-		}
+	// Select from the registry with the filter pushed down
+	page, err := registry.Find(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
 	}
-	return true
+	return page, nil
 }
 
-// ensureQueryTable creates the queries table if it doesn't exist
-func (qm *QueryManager) ensureQueryTable() error {
-	_, err := qm.db.Exec(`
-		CREATE TABLE IF NOT EXISTS queries (
-			name TEXT PRIMARY KEY,
-			filters TEXT NOT NULL,
-			created INTEGER NOT NULL,
-			last_used INTEGER,
-			use_count INTEGER DEFAULT 0
-		)
-	`)
-	return err
-}
-
-// IsQueryMigrationApplied checks if the query system migration has been applied
-func IsQueryMigrationApplied(db *sql.DB) (bool, error) {
-	row := db.QueryRow(`SELECT 1 FROM schema_migrations WHERE id = ?`, migrationQueryID)
-	var one int
-	switch err := row.Scan(&one); err {
-	case sql.ErrNoRows:
-		return false, nil
-	case nil:
-		return true, nil
-	default:
-		return false, err
+// findRanked selects the archives in c.UIDs that pass the filter and pages
+// through them in that order, best match first. Cursors follow a sort column,
+// so ranked pages are addressed by offset and have no Next cursor.
+func findRanked(ctx context.Context, registry *storage.Registry, c storage.Criteria) (*storage.Page, error) {
+	if c.After != "" {
+		return nil, fmt.Errorf("cursor paging needs an explicit sort; page ranked search results by offset")
 	}
-}
-
-// ApplyQueryMigration applies the query system migration
-func ApplyQueryMigration(db *sql.DB) error {
-	// Create queries table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS queries (
-			name TEXT PRIMARY KEY,
-			filters TEXT NOT NULL,
-			created INTEGER NOT NULL,
-			last_used INTEGER,
-			use_count INTEGER DEFAULT 0
-		)
-	`)
+	limit, offset := c.Limit, c.Offset
+	c.Limit, c.Offset = 0, 0
+	page, err := registry.Find(ctx, c)
 	if err != nil {
-		return fmt.Errorf("failed to create queries table: %w", err)
+		return nil, fmt.Errorf("failed to list archives: %w", err)
 	}
 
-	// Mark migration as applied
-	_, err = db.Exec(`
-		INSERT OR REPLACE INTO schema_migrations (id, name, applied_at) 
-		VALUES (?, ?, ?)
-	`, migrationQueryID, migrationQueryName, time.Now().Unix())
-	if err != nil {
-		return fmt.Errorf("failed to mark query migration as applied: %w", err)
+	byUID := make(map[string]*storage.Archive, len(page.Archives))
+	for _, a := range page.Archives {
+		byUID[a.UID] = a
+	}
+	archives := make([]*storage.Archive, 0, len(byUID))
+	for _, uid := range c.UIDs {
+		if a, ok := byUID[uid]; ok {
+			archives = append(archives, a)
+			delete(byUID, uid)
+		}
+	}
+	if c.Ascending {
+		for i, j := 0, len(archives)-1; i < j; i, j = i+1, j-1 {
+			archives[i], archives[j] = archives[j], archives[i]
+		}
 	}
 
-	return nil
-}
\ No newline at end of file
+	if offset >= len(archives) {
+		archives = nil
+	} else {
+		archives = archives[offset:]
+	}
+	if limit > 0 && len(archives) > limit {
+		archives = archives[:limit]
+	}
+	return &storage.Page{Archives: archives}, nil
+}
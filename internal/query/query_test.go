@@ -1,8 +1,10 @@
 package query
 
 import (
+	"context"
 	"database/sql"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -19,14 +21,16 @@ func setupTestDB(t *testing.T) (*sql.DB, string) {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
-	// Create schema_migrations table
-	_, err = db.Exec(`CREATE TABLE schema_migrations (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		applied_at INTEGER NOT NULL
+	// Create the queries table added by registry migration 0004
+	_, err = db.Exec(`CREATE TABLE queries (
+		name TEXT PRIMARY KEY,
+		filters TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		last_used INTEGER,
+		use_count INTEGER DEFAULT 0
 	)`)
 	if err != nil {
-		t.Fatalf("Failed to create schema_migrations table: %v", err)
+		t.Fatalf("Failed to create queries table: %v", err)
 	}
 
 	// Create basic archives table for resolver
@@ -159,39 +163,6 @@ func TestQueryManager_SaveEmpty(t *testing.T) {
 	}
 }
 
-func TestApplyQueryMigration(t *testing.T) {
-	db, _ := setupTestDB(t)
-	defer db.Close()
-
-	// Apply the migration
-	err := ApplyQueryMigration(db)
-	if err != nil {
-		t.Fatalf("Failed to apply query migration: %v", err)
-	}
-
-	// Verify the queries table was created
-	row := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='queries'")
-	var tableName string
-	err = row.Scan(&tableName)
-	if err != nil {
-		t.Fatalf("Queries table was not created: %v", err)
-	}
-
-	if tableName != "queries" {
-		t.Errorf("Expected table name 'queries', got '%s'", tableName)
-	}
-
-	// Verify the migration was recorded
-	applied, err := IsQueryMigrationApplied(db)
-	if err != nil {
-		t.Fatalf("Failed to check migration status: %v", err)
-	}
-
-	if !applied {
-		t.Error("Migration was not recorded as applied")
-	}
-}
-
 func TestTimeHandling(t *testing.T) {
 	db, _ := setupTestDB(t)
 	defer db.Close()
@@ -223,4 +194,121 @@ func TestTimeHandling(t *testing.T) {
 	if query.LastUsed != nil {
 		t.Errorf("Expected LastUsed to be nil for new query, got %v", query.LastUsed)
 	}
-}
\ No newline at end of file
+}
+// newFilterTestManager returns a query manager over a migrated registry
+// holding the given archives
+func newFilterTestManager(t *testing.T, archives ...*storage.Archive) *QueryManager {
+	t.Helper()
+	registry, err := storage.NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+	t.Cleanup(func() { _ = registry.Close() })
+	for _, a := range archives {
+		if err := registry.Add(a); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	return NewQueryManager(registry.DB(), storage.NewResolver(registry))
+}
+
+// runFilters returns the names of the archives a filter map selects
+func runFilters(t *testing.T, qm *QueryManager, filters map[string]string, c storage.Criteria) []string {
+	t.Helper()
+	page, err := qm.executeFilters(context.Background(), filters, c)
+	if err != nil {
+		t.Fatalf("executeFilters failed: %v", err)
+	}
+	names := make([]string, len(page.Archives))
+	for i, a := range page.Archives {
+		names[i] = a.Name
+	}
+	return names
+}
+
+func TestExecuteFiltersTag(t *testing.T) {
+	qm := newFilterTestManager(t,
+		&storage.Archive{UID: "01K2E3TAGS01", Name: "a.7z", Path: "/archives/a.7z", Tags: []string{"client:acme", "project:website"}},
+		&storage.Archive{UID: "01K2E3TAGS02", Name: "b.7z", Path: "/archives/b.7z"},
+	)
+
+	if got := runFilters(t, qm, map[string]string{"tag": "client:acme,project:website"}, storage.Criteria{}); !reflect.DeepEqual(got, []string{"a.7z"}) {
+		t.Errorf("expected only the archive with both tags, got %v", got)
+	}
+	if got := runFilters(t, qm, map[string]string{"tag": "client:other"}, storage.Criteria{}); len(got) != 0 {
+		t.Errorf("expected no archives with client:other, got %v", got)
+	}
+}
+
+func TestExecuteFiltersWhere(t *testing.T) {
+	year, _ := storage.ParseFieldAssignment("project_year=2024")
+	class, _ := storage.ParseFieldAssignment("retention_class=legal")
+	qm := newFilterTestManager(t,
+		&storage.Archive{UID: "01K2E3WHER01", Name: "a.7z", Path: "/archives/a.7z"},
+		&storage.Archive{UID: "01K2E3WHER02", Name: "b.7z", Path: "/archives/b.7z"},
+	)
+	a, err := qm.resolver.Registry().Get("a.7z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := qm.resolver.Registry().SetFields(a.ID, year, class); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := map[string]string{"where": "retention_class=legal\nproject_year>=2024"}
+	if got := runFilters(t, qm, filters, storage.Criteria{}); !reflect.DeepEqual(got, []string{"a.7z"}) {
+		t.Errorf("expected the archive matching both conditions, got %v", got)
+	}
+	if got := runFilters(t, qm, map[string]string{"where": "project_year>2024"}, storage.Criteria{}); len(got) != 0 {
+		t.Errorf("expected no archives with project_year>2024, got %v", got)
+	}
+}
+
+func TestExecuteFiltersExpression(t *testing.T) {
+	qm := newFilterTestManager(t,
+		&storage.Archive{UID: "01K2E3EXPR01", Name: "big.7z", Path: "/archives/big.7z", Size: 2 << 30, Profile: "Media", Managed: true, Status: "present"},
+		&storage.Archive{UID: "01K2E3EXPR02", Name: "small.7z", Path: "/archives/small.7z", Size: 1 << 20, Profile: "Media", Managed: true, Status: "present"},
+	)
+
+	if got := runFilters(t, qm, map[string]string{FilterKey: "size > 1GB and profile = media and not uploaded"}, storage.Criteria{}); !reflect.DeepEqual(got, []string{"big.7z"}) {
+		t.Errorf("expected only big.7z, got %v", got)
+	}
+	// Legacy keys and an expression combine with and
+	if got := runFilters(t, qm, map[string]string{FilterKey: "size > 1GB", "managed": "false"}, storage.Criteria{}); len(got) != 0 {
+		t.Errorf("managed archives should not match managed=false, got %v", got)
+	}
+}
+
+func TestExecuteFiltersKeepsSearchRanking(t *testing.T) {
+	// The better match is the older archive, so created order would put it last
+	now := time.Now()
+	qm := newFilterTestManager(t,
+		&storage.Archive{UID: "01K2E3RANK01", Name: "invoices", Path: "/archives/invoices.7z", Metadata: "scanned paperwork", Created: now.Add(-time.Hour)},
+		&storage.Archive{UID: "01K2E3RANK02", Name: "notes", Path: "/archives/notes.7z", Metadata: "invoices for the year", Created: now},
+		&storage.Archive{UID: "01K2E3RANK03", Name: "photos", Path: "/archives/photos.7z", Created: now},
+	)
+	filters := map[string]string{"search": "invoices"}
+
+	if got := runFilters(t, qm, filters, storage.Criteria{}); !reflect.DeepEqual(got, []string{"invoices", "notes"}) {
+		t.Errorf("expected hits in relevance order, got %v", got)
+	}
+	if got := runFilters(t, qm, filters, storage.Criteria{Offset: 1, Limit: 1}); !reflect.DeepEqual(got, []string{"notes"}) {
+		t.Errorf("expected the second hit on the second page, got %v", got)
+	}
+	if got := runFilters(t, qm, filters, storage.Criteria{Sort: storage.SortCreated}); !reflect.DeepEqual(got, []string{"notes", "invoices"}) {
+		t.Errorf("expected an explicit sort to override relevance, got %v", got)
+	}
+}
+
+func TestExpressionFromLegacyFilters(t *testing.T) {
+	expr, err := Expression(map[string]string{"profile": "media", "not-uploaded": "true", "search": "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr != "not uploaded and profile = media" {
+		t.Fatalf("unexpected expression %q", expr)
+	}
+	if _, err := Expression(map[string]string{FilterKey: "size >"}); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+}
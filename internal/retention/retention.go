@@ -0,0 +1,251 @@
+// Package retention decides which archives to trash and which trashed
+// archives to purge according to configured retention policies.
+package retention
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Decision is a planned action for a single archive
+type Decision struct {
+	Archive *storage.Archive
+	Policy  string // matching policy name ("" for the global retention_days)
+	Series  string // source or name lineage the archive was counted in (live archives only)
+	Reason  string
+}
+
+// Plan lists what a prune run would do
+type Plan struct {
+	Keep  []Decision // live archives kept by a policy
+	Trash []Decision // live archives no policy keeps
+	Purge []Decision // trashed archives past their retention
+}
+
+// Validate checks policies for obvious mistakes
+func Validate(policies []config.RetentionPolicy) error {
+	for i, p := range policies {
+		name := policyName(p, i)
+		if p.Pattern != "" {
+			if _, err := filepath.Match(p.Pattern, ""); err != nil {
+				return fmt.Errorf("policy %s: invalid pattern %q: %w", name, p.Pattern, err)
+			}
+		}
+		if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 || p.KeepYearly < 0 || p.TrashDays < 0 {
+			return fmt.Errorf("policy %s: keep and trash values must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// Compute builds a prune plan. Each archive is governed by the first policy
+// that matches it. Live archives are only trashed by policies with keep
+// rules, which apply to each series separately; trashed archives are purged
+// after the policy's trash_days, falling back to defaultTrashDays.
+func Compute(archives []*storage.Archive, policies []config.RetentionPolicy, defaultTrashDays int, now time.Time) *Plan {
+	plan := &Plan{}
+	series := make(map[seriesID][]*storage.Archive)
+
+	for _, a := range archives {
+		idx := match(a, policies)
+		switch a.Status {
+		case "deleted":
+			days := defaultTrashDays
+			name := ""
+			if idx >= 0 {
+				name = policyName(policies[idx], idx)
+				if policies[idx].TrashDays > 0 {
+					days = policies[idx].TrashDays
+				}
+			}
+			if a.DeletedAt == nil || days <= 0 {
+				continue
+			}
+			if !a.DeletedAt.Add(time.Duration(days) * 24 * time.Hour).After(now) {
+				plan.Purge = append(plan.Purge, Decision{Archive: a, Policy: name,
+					Reason: fmt.Sprintf("in trash longer than %d days", days)})
+			}
+		case "present":
+			if idx >= 0 && hasKeepRules(policies[idx]) {
+				id := seriesID{idx, seriesKey(a)}
+				series[id] = append(series[id], a)
+			}
+		}
+	}
+
+	ids := make([]seriesID, 0, len(series))
+	for id := range series {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].policy != ids[j].policy {
+			return ids[i].policy < ids[j].policy
+		}
+		return ids[i].key < ids[j].key
+	})
+	for _, id := range ids {
+		keep, trash := apply(series[id], policies[id.policy])
+		name := policyName(policies[id.policy], id.policy)
+		for _, a := range series[id] {
+			if reason, ok := keep[a.ID]; ok {
+				plan.Keep = append(plan.Keep, Decision{Archive: a, Policy: name, Series: id.key, Reason: reason})
+			}
+		}
+		for _, a := range trash {
+			plan.Trash = append(plan.Trash, Decision{Archive: a, Policy: name, Series: id.key, Reason: "not kept by any rule"})
+		}
+	}
+	return plan
+}
+
+// seriesID identifies the archives of one source governed by one policy
+type seriesID struct {
+	policy int
+	key    string
+}
+
+// versionSuffix matches the timestamp storage.VersionedName adds
+var versionSuffix = regexp.MustCompile(`-\d{8}-\d{6}$`)
+
+// seriesKey is the series an archive belongs to: the source it was created
+// from, or for archives without one its name minus any version timestamp
+func seriesKey(a *storage.Archive) string {
+	if a.SourcePath != "" {
+		return a.SourcePath
+	}
+	ext := filepath.Ext(a.Name)
+	return versionSuffix.ReplaceAllString(strings.TrimSuffix(a.Name, ext), "") + ext
+}
+
+// apply runs keep rules over one series (newest first) and returns the kept
+// archive IDs with the first rule that kept them, plus the archives to trash.
+func apply(archives []*storage.Archive, p config.RetentionPolicy) (map[int64]string, []*storage.Archive) {
+	sorted := append([]*storage.Archive(nil), archives...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Created.After(sorted[j].Created) })
+
+	keep := make(map[int64]string)
+	mark := func(a *storage.Archive, reason string) {
+		if _, ok := keep[a.ID]; !ok {
+			keep[a.ID] = reason
+		}
+	}
+
+	for i := 0; i < p.KeepLast && i < len(sorted); i++ {
+		mark(sorted[i], "last")
+	}
+
+	buckets := []struct {
+		n      int
+		reason string
+		key    func(time.Time) string
+	}{
+		{p.KeepDaily, "daily", func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.KeepWeekly, "weekly", func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		}},
+		{p.KeepMonthly, "monthly", func(t time.Time) string { return t.Format("2006-01") }},
+		{p.KeepYearly, "yearly", func(t time.Time) string { return t.Format("2006") }},
+	}
+	for _, b := range buckets {
+		if b.n <= 0 {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, a := range sorted {
+			k := b.key(a.Created.Local())
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			mark(a, b.reason)
+			if len(seen) == b.n {
+				break
+			}
+		}
+	}
+
+	var trash []*storage.Archive
+	for _, a := range sorted {
+		if _, ok := keep[a.ID]; !ok {
+			trash = append(trash, a)
+		}
+	}
+	return keep, trash
+}
+
+// match returns the index of the first policy selecting a, or -1
+func match(a *storage.Archive, policies []config.RetentionPolicy) int {
+	for i, p := range policies {
+		if p.Profile != "" && !strings.EqualFold(p.Profile, a.Profile) {
+			continue
+		}
+		if p.Tag != "" && !hasTag(a, p.Tag) {
+			continue
+		}
+		if p.Source != "" && (a.SourcePath == "" || !under(expandHome(p.Source), a.SourcePath)) {
+			continue
+		}
+		if p.Pattern != "" {
+			if ok, _ := filepath.Match(p.Pattern, a.Name); !ok {
+				continue
+			}
+		}
+		if p.Path != "" {
+			path := a.Path
+			if a.Status == "deleted" && a.OriginalPath != "" {
+				path = a.OriginalPath
+			}
+			if !under(expandHome(p.Path), path) {
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+func hasTag(a *storage.Archive, tag string) bool {
+	for _, t := range a.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKeepRules(p config.RetentionPolicy) bool {
+	return p.KeepLast+p.KeepDaily+p.KeepWeekly+p.KeepMonthly+p.KeepYearly > 0
+}
+
+func policyName(p config.RetentionPolicy, idx int) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprintf("policy-%d", idx+1)
+}
+
+func under(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
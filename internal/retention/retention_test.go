@@ -0,0 +1,141 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestComputeKeepsGrandfatherFatherSon(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	var archives []*storage.Archive
+	// One nightly archive for 60 days
+	for i := 0; i < 60; i++ {
+		archives = append(archives, &storage.Archive{
+			ID:      int64(i + 1),
+			Name:    "db-nightly.7z",
+			Profile: "Documents",
+			Status:  "present",
+			Created: now.AddDate(0, 0, -i),
+		})
+	}
+	// Unrelated archive is never touched
+	archives = append(archives, &storage.Archive{ID: 100, Name: "photos.7z", Profile: "Media", Status: "present", Created: now.AddDate(-1, 0, 0)})
+
+	policies := []config.RetentionPolicy{{
+		Name: "db", Pattern: "db-*.7z", KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 3,
+	}}
+	plan := Compute(archives, policies, 30, now)
+
+	kept := make(map[int64]bool)
+	for _, d := range plan.Keep {
+		kept[d.Archive.ID] = true
+	}
+	for i := int64(1); i <= 7; i++ {
+		if !kept[i] {
+			t.Errorf("expected daily archive %d to be kept", i)
+		}
+	}
+	if kept[100] {
+		t.Error("unmatched archive should not be part of the plan")
+	}
+	for _, d := range plan.Trash {
+		if d.Archive.ID == 100 {
+			t.Fatal("unmatched archive must not be trashed")
+		}
+	}
+	// 7 daily + up to 3 extra weeklies + up to 2 extra monthlies
+	if len(plan.Keep) < 7 || len(plan.Keep) > 12 {
+		t.Errorf("unexpected number kept: %d", len(plan.Keep))
+	}
+	if len(plan.Keep)+len(plan.Trash) != 60 {
+		t.Errorf("keep+trash = %d, want 60", len(plan.Keep)+len(plan.Trash))
+	}
+}
+
+func TestComputePurgesTrashPerPolicy(t *testing.T) {
+	now := time.Now()
+	ago := func(days int) *time.Time { t := now.AddDate(0, 0, -days); return &t }
+	archives := []*storage.Archive{
+		{ID: 1, Name: "tmp-a.7z", Status: "deleted", DeletedAt: ago(3)},
+		{ID: 2, Name: "tmp-b.7z", Status: "deleted", DeletedAt: ago(1)},
+		{ID: 3, Name: "keep.7z", Status: "deleted", DeletedAt: ago(10)},
+		{ID: 4, Name: "old.7z", Status: "deleted", DeletedAt: ago(40)},
+	}
+	policies := []config.RetentionPolicy{{Name: "tmp", Pattern: "tmp-*", TrashDays: 2}}
+
+	plan := Compute(archives, policies, 30, now)
+	got := make(map[int64]bool)
+	for _, d := range plan.Purge {
+		got[d.Archive.ID] = true
+	}
+	if !got[1] || got[2] || got[3] || !got[4] || len(plan.Trash) != 0 {
+		t.Fatalf("unexpected purge set: %v", got)
+	}
+}
+
+func TestComputeKeepsSeriesPerSource(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	var archives []*storage.Archive
+	// Five nightly archives from each of two sources, plus a versioned
+	// series without a recorded source
+	for i := 0; i < 5; i++ {
+		created := now.AddDate(0, 0, -i)
+		archives = append(archives,
+			&storage.Archive{ID: int64(10 + i), Name: storage.VersionedName("db.7z", created), SourcePath: "/srv/db",
+				Status: "present", Created: created, Tags: []string{"nightly"}},
+			&storage.Archive{ID: int64(20 + i), Name: storage.VersionedName("www.7z", created), SourcePath: "/srv/www",
+				Status: "present", Created: created, Tags: []string{"Nightly"}},
+			&storage.Archive{ID: int64(30 + i), Name: storage.VersionedName("logs.7z", created),
+				Status: "present", Created: created, Tags: []string{"nightly"}},
+		)
+	}
+	// Untagged archive from the same source is not governed by the policy
+	archives = append(archives, &storage.Archive{ID: 99, Name: "db-manual.7z", SourcePath: "/srv/db",
+		Status: "present", Created: now.AddDate(0, -1, 0)})
+
+	policies := []config.RetentionPolicy{{Name: "nightly", Tag: "nightly", Source: "/srv", KeepLast: 3}}
+	plan := Compute(archives, policies, 30, now)
+
+	kept := make(map[string]int)
+	for _, d := range plan.Keep {
+		kept[d.Series]++
+	}
+	if kept["/srv/db"] != 3 || kept["/srv/www"] != 3 {
+		t.Errorf("want 3 kept per source, got %v", kept)
+	}
+	if kept["logs.7z"] != 0 {
+		t.Errorf("archives without a source are outside a source policy, got %v", kept)
+	}
+	if len(plan.Trash) != 4 {
+		t.Fatalf("want the 2 oldest of each source trashed, got %d", len(plan.Trash))
+	}
+	for _, d := range plan.Trash {
+		if d.Archive.ID == 99 || d.Archive.Created.After(now.AddDate(0, 0, -3)) {
+			t.Errorf("unexpected trash %s (%s)", d.Archive.Name, d.Series)
+		}
+	}
+
+	// Without a source selector, the unsourced archives form their own
+	// series by name
+	policies[0].Source = ""
+	plan = Compute(archives, policies, 30, now)
+	kept = make(map[string]int)
+	for _, d := range plan.Keep {
+		kept[d.Series]++
+	}
+	if kept["logs.7z"] != 3 || len(plan.Trash) != 6 {
+		t.Errorf("kept %v, trashed %d", kept, len(plan.Trash))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]config.RetentionPolicy{{Pattern: "[bad"}}); err == nil {
+		t.Error("expected invalid pattern error")
+	}
+	if err := Validate([]config.RetentionPolicy{{KeepLast: -1}}); err == nil {
+		t.Error("expected negative keep error")
+	}
+}
@@ -0,0 +1,229 @@
+// Package schedule runs configured tasks on cron schedules. Each time a
+// schedule falls due the scheduler queues its command as a background job
+// and records the run in the registry, which is also how it finds runs
+// missed while it was stopped and runs still in progress.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Tasks are the commands a schedule may run
+var Tasks = []string{"create", "scrub", "prune", "upload"}
+
+// Entry is a configured schedule with its parsed cron expression
+type Entry struct {
+	config.Schedule
+	Spec *Cron
+}
+
+// Compile validates schedules and parses their cron expressions
+func Compile(schedules []config.Schedule) ([]*Entry, error) {
+	seen := make(map[string]bool)
+	entries := make([]*Entry, 0, len(schedules))
+	for i, s := range schedules {
+		if s.Name == "" {
+			return nil, fmt.Errorf("schedule #%d: name is required", i+1)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("schedule %s: duplicate name", s.Name)
+		}
+		seen[s.Name] = true
+		if !validTask(s.Task) {
+			return nil, fmt.Errorf("schedule %s: task %q must be one of %s", s.Name, s.Task, strings.Join(Tasks, ", "))
+		}
+		cron, err := ParseCron(s.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+		entries = append(entries, &Entry{Schedule: s, Spec: cron})
+	}
+	return entries, nil
+}
+
+func validTask(task string) bool {
+	for _, t := range Tasks {
+		if task == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Command returns the command line a schedule runs. prune gets --force
+// unless it is a dry run, as there is nobody to answer its prompt.
+func (e *Entry) Command() []string {
+	argv := []string{e.Task}
+	if e.Vault != "" {
+		argv = append(argv, "--vault="+e.Vault)
+	}
+	prompts := e.Task == "prune"
+	for _, a := range e.Args {
+		argv = append(argv, expandHome(a))
+		if a == "--force" || a == "--dry-run" {
+			prompts = false
+		}
+	}
+	if prompts {
+		argv = append(argv, "--force")
+	}
+	return argv
+}
+
+// WorkDir returns the directory the schedule's command runs in
+func (e *Entry) WorkDir() string {
+	if e.Dir != "" {
+		return expandHome(e.Dir)
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// Options configures a scheduler
+type Options struct {
+	// Logf receives one line per run queued or skipped; nil discards output
+	Logf func(format string, args ...interface{})
+}
+
+// Scheduler queues scheduled tasks as they fall due
+type Scheduler struct {
+	reg     *storage.Registry
+	entries []*Entry
+	started time.Time
+	logf    func(format string, args ...interface{})
+}
+
+// New creates a scheduler queuing jobs in reg. Schedules that have never
+// run first fall due after started.
+func New(reg *storage.Registry, entries []*Entry, started time.Time, opts Options) *Scheduler {
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	return &Scheduler{reg: reg, entries: entries, started: started, logf: opts.Logf}
+}
+
+// Next returns when a schedule next falls due after its last run, or after
+// the scheduler started if it never ran
+func (s *Scheduler) Next(e *Entry) (time.Time, error) {
+	last, err := s.reg.LastScheduleRun(e.Name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	base := s.started
+	if last != nil {
+		base = last.Due.In(s.started.Location()) // cron fields are in local time
+	}
+	return e.Spec.Next(base), nil
+}
+
+// Tick queues every schedule that has fallen due by now. Runs missed while
+// the scheduler was stopped are collapsed into one, which is skipped for
+// schedules with skip_missed; a run is also skipped while the job from an
+// earlier run is still queued or running.
+func (s *Scheduler) Tick(now time.Time) error {
+	var first error
+	for _, e := range s.entries {
+		if err := s.tick(e, now); err != nil && first == nil {
+			first = fmt.Errorf("schedule %s: %w", e.Name, err)
+		}
+	}
+	return first
+}
+
+func (s *Scheduler) tick(e *Entry, now time.Time) error {
+	due, err := s.Next(e)
+	if err != nil || due.IsZero() || due.After(now) {
+		return err
+	}
+	missed := 0
+	for next := e.Spec.Next(due); !next.IsZero() && !next.After(now); next = e.Spec.Next(next) {
+		due = next
+		missed++
+	}
+
+	run := &storage.ScheduleRun{Schedule: e.Name, Due: due, Queued: now}
+	active, err := s.reg.ActiveScheduleJob(e.Name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case due.Before(s.started) && e.SkipMissed:
+		run.Skipped = "missed while the daemon was stopped"
+	case active != nil:
+		run.Skipped = fmt.Sprintf("job %d from an earlier run is still %s", active.ID, active.Status)
+	default:
+		j, err := s.reg.EnqueueJob(e.Command(), e.WorkDir())
+		if err != nil {
+			return err
+		}
+		run.JobID = j.ID
+	}
+	if err := s.reg.RecordScheduleRun(run); err != nil {
+		return err
+	}
+
+	when := due.Format("2006-01-02 15:04")
+	if due.Before(s.started) {
+		when += " (missed"
+		if missed > 0 {
+			when += fmt.Sprintf(", with %d earlier", missed)
+		}
+		when += ")"
+	}
+	if run.Skipped != "" {
+		s.logf("⏭️  %s due %s skipped: %s", e.Name, when, run.Skipped)
+	} else {
+		s.logf("⏰ %s due %s: queued job %d", e.Name, when, run.JobID)
+	}
+	return nil
+}
+
+// RunNow queues a schedule's task at once, as if it had fallen due now,
+// unless the job from an earlier run is still queued or running
+func RunNow(reg *storage.Registry, e *Entry, now time.Time) (*storage.ScheduleRun, error) {
+	active, err := reg.ActiveScheduleJob(e.Name)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, fmt.Errorf("job %d from an earlier run of %s is still %s", active.ID, e.Name, active.Status)
+	}
+	j, err := reg.EnqueueJob(e.Command(), e.WorkDir())
+	if err != nil {
+		return nil, err
+	}
+	run := &storage.ScheduleRun{Schedule: e.Name, Due: now.Truncate(time.Minute), Queued: now, JobID: j.ID, Job: j}
+	return run, reg.RecordScheduleRun(run)
+}
+
+// Run ticks at the start of every minute until ctx is done
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		if err := s.Tick(time.Now()); err != nil {
+			s.logf("⚠️  %v", err)
+		}
+		wait := time.Until(time.Now().Truncate(time.Minute).Add(time.Minute))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
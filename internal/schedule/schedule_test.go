@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/config"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func newRegistry(t *testing.T) *storage.Registry {
+	t.Helper()
+	reg, err := storage.NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { reg.Close() })
+	return reg
+}
+
+func compile(t *testing.T, schedules ...config.Schedule) []*Entry {
+	t.Helper()
+	entries, err := Compile(schedules)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return entries
+}
+
+func TestSchedulerQueuesDueRuns(t *testing.T) {
+	reg := newRegistry(t)
+	entries := compile(t, config.Schedule{Name: "nightly", Cron: "0 2 * * *", Task: "create",
+		Args: []string{"--preset", "backup", "/data"}, Dir: "/srv"})
+	started := time.Date(2025, 1, 15, 1, 0, 0, 0, time.Local)
+	s := New(reg, entries, started, Options{})
+
+	// Nothing is due before 02:00
+	if err := s.Tick(started.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := reg.ScheduleRuns("", 0); len(runs) != 0 {
+		t.Fatalf("queued %d runs early", len(runs))
+	}
+
+	due := time.Date(2025, 1, 15, 2, 0, 0, 0, time.Local)
+	if err := s.Tick(due.Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	run, err := reg.LastScheduleRun("nightly")
+	if err != nil || run == nil || !run.Due.Equal(due) || run.Job == nil {
+		t.Fatalf("last run = %+v, %v", run, err)
+	}
+	if want := []string{"create", "--preset", "backup", "/data"}; !reflect.DeepEqual(run.Job.Args, want) || run.Job.Dir != "/srv" {
+		t.Errorf("job = %v in %q, want %v in /srv", run.Job.Args, run.Job.Dir, want)
+	}
+
+	// The same run is not queued twice
+	if err := s.Tick(due.Add(50 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := reg.ScheduleRuns("nightly", 0); len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+
+	// The next night's run is skipped while the first job is still queued
+	if err := s.Tick(due.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	run, _ = reg.LastScheduleRun("nightly")
+	if run.JobID != 0 || !strings.Contains(run.Skipped, "still queued") {
+		t.Errorf("overlapping run = %+v, want skipped", run)
+	}
+}
+
+func TestSchedulerCatchesUpMissedRuns(t *testing.T) {
+	reg := newRegistry(t)
+	entries := compile(t,
+		config.Schedule{Name: "hourly-scrub", Cron: "@hourly", Task: "scrub"},
+		config.Schedule{Name: "prune", Cron: "@hourly", Task: "prune", SkipMissed: true})
+	stopped := time.Date(2025, 1, 15, 9, 0, 0, 0, time.Local)
+	for _, name := range []string{"hourly-scrub", "prune"} {
+		if err := reg.RecordScheduleRun(&storage.ScheduleRun{Schedule: name, Due: stopped, Queued: stopped}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Restarted at 12:30: 10:00, 11:00 and 12:00 were missed
+	started := time.Date(2025, 1, 15, 12, 30, 0, 0, time.Local)
+	s := New(reg, entries, started, Options{})
+	if err := s.Tick(started); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, _ := reg.ScheduleRuns("hourly-scrub", 0)
+	if len(runs) != 2 || runs[0].JobID == 0 || !runs[0].Due.Equal(time.Date(2025, 1, 15, 12, 0, 0, 0, time.Local)) {
+		t.Fatalf("scrub runs = %+v, want one catch-up run due 12:00", runs)
+	}
+	run, _ := reg.LastScheduleRun("prune")
+	if run.JobID != 0 || !strings.Contains(run.Skipped, "missed") {
+		t.Errorf("skip_missed run = %+v, want skipped", run)
+	}
+
+	next, _ := s.Next(entries[0])
+	if want := time.Date(2025, 1, 15, 13, 0, 0, 0, time.Local); !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+}
+
+func TestCompileAndCommand(t *testing.T) {
+	for _, bad := range [][]config.Schedule{
+		{{Cron: "@daily", Task: "scrub"}},
+		{{Name: "a", Cron: "@daily", Task: "scrub"}, {Name: "a", Cron: "@daily", Task: "scrub"}},
+		{{Name: "a", Cron: "@daily", Task: "delete"}},
+		{{Name: "a", Cron: "every day", Task: "scrub"}},
+	} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Compile(%+v) succeeded", bad)
+		}
+	}
+
+	e := compile(t, config.Schedule{Name: "p", Cron: "@weekly", Task: "prune", Vault: "photos", Args: []string{"--policy", "raw"}})[0]
+	if got, want := e.Command(), []string{"prune", "--vault=photos", "--policy", "raw", "--force"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Command = %v, want %v", got, want)
+	}
+	e = compile(t, config.Schedule{Name: "p", Cron: "@weekly", Task: "prune", Args: []string{"--dry-run"}})[0]
+	if got, want := e.Command(), []string{"prune", "--dry-run"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Command = %v, want %v", got, want)
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	reg := newRegistry(t)
+	e := compile(t, config.Schedule{Name: "scrub", Cron: "@daily", Task: "scrub"})[0]
+	run, err := RunNow(reg, e, time.Now())
+	if err != nil || run.JobID == 0 {
+		t.Fatalf("RunNow = %+v, %v", run, err)
+	}
+	if _, err := RunNow(reg, e, time.Now()); err == nil {
+		t.Error("queued a second run while the first is still queued")
+	}
+}
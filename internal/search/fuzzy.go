@@ -0,0 +1,189 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Fuzzy matching expands each query term to the indexed terms within a small
+// edit distance, so typos still find archives, and matches the last term as a
+// prefix so a partly typed word finds its completions. Phrases and excluded
+// terms stay exact.
+
+// maxFuzzyVariants bounds how many indexed terms one query term expands to
+const maxFuzzyVariants = 16
+
+// maxEdits is the edit distance tolerated for a term of n runes
+func maxEdits(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// fuzzy rewrites q for fuzzy matching against the indexed terms in vocab
+func fuzzy(q *queryNode, vocab []string) *queryNode {
+	last := q
+	for last.op != "" {
+		last = last.right
+	}
+	if !last.phrase {
+		last.prefix = true
+	}
+	return q.expand(vocab)
+}
+
+func (n *queryNode) expand(vocab []string) *queryNode {
+	switch n.op {
+	case "NOT":
+		n.left = n.left.expand(vocab)
+		return n
+	case "AND", "OR":
+		n.left = n.left.expand(vocab)
+		n.right = n.right.expand(vocab)
+		return n
+	}
+	if n.phrase {
+		return n
+	}
+
+	// Split punctuated terms so each word is matched on its own
+	var out *queryNode
+	for i, tok := range n.tokens {
+		leaf := &queryNode{field: n.field, text: tok, tokens: []string{tok}, prefix: n.prefix && i == len(n.tokens)-1}
+		leaf.variants = similarTerms(tok, leaf.prefix, vocab)
+		if out == nil {
+			out = leaf
+		} else {
+			out = &queryNode{op: "AND", left: out, right: leaf}
+		}
+	}
+	return out
+}
+
+// similarTerms returns the indexed terms within edit distance of tok, closest
+// first. For a prefix, terms whose beginning is within distance also count.
+func similarTerms(tok string, prefix bool, vocab []string) []string {
+	tr := []rune(tok)
+	max := maxEdits(len(tr))
+	if max == 0 {
+		return nil
+	}
+
+	type candidate struct {
+		term string
+		dist int
+	}
+	var found []candidate
+	for _, term := range vocab {
+		if term == tok {
+			continue
+		}
+		rs := []rune(term)
+		if prefix && len(rs) > len(tr) {
+			if strings.HasPrefix(term, tok) {
+				continue // already matched by the prefix itself
+			}
+			if d := editDistance(tr, rs[:len(tr)], max); d <= max {
+				found = append(found, candidate{term, d})
+				continue
+			}
+		}
+		if d := editDistance(tr, rs, max); d <= max {
+			found = append(found, candidate{term, d})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].term < found[j].term
+	})
+	if len(found) > maxFuzzyVariants {
+		found = found[:maxFuzzyVariants]
+	}
+	terms := make([]string, len(found))
+	for i, c := range found {
+		terms[i] = c.term
+	}
+	return terms
+}
+
+// editDistance returns the optimal string alignment distance between a and b
+// (insertions, deletions, substitutions and adjacent transpositions), or
+// max+1 once it is known to exceed max
+func editDistance(a, b []rune, max int) int {
+	if abs(len(a)-len(b)) > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// similarity scores how closely an archive matches a fuzzy query, from 1
+// for exact matches towards 0 as more edits were needed
+func (n *queryNode) similarity(a *storage.Archive) float64 {
+	switch n.op {
+	case "AND":
+		return (n.left.similarity(a) + n.right.similarity(a)) / 2
+	case "OR":
+		return max(n.left.similarity(a), n.right.similarity(a))
+	case "NOT":
+		return n.left.similarity(a)
+	}
+	if n.phrase {
+		return 1
+	}
+
+	tr := []rune(n.tokens[0])
+	best := 0.0
+	for _, word := range tokenize(columnText(a, n.field)) {
+		wr := []rune(word)
+		if n.prefix && len(wr) > len(tr) {
+			wr = wr[:len(tr)]
+		}
+		limit := max(len(tr), len(wr))
+		d := editDistance(tr, wr, limit)
+		if s := 1 - float64(d)/float64(limit); s > best {
+			best = s
+		}
+	}
+	return best
+}
@@ -0,0 +1,345 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Search queries are parsed here and rewritten into an FTS MATCH expression
+// for the registry's full-text module, so user input never reaches the FTS
+// parser unescaped and both FTS4 and FTS5 see the same meaning.
+//
+//	backup 2024            both terms (implicit AND)
+//	"family photos"        phrase
+//	proj*                  prefix
+//	name:backup            term restricted to a column
+//	media OR video         either
+//	photos NOT raw         exclusion (also: photos -raw)
+//	(a OR b) c             grouping
+//
+// Operators are upper case, as in SQLite; lower-case and/or/not are terms.
+
+// queryNode is a parsed search query
+type queryNode struct {
+	op          string // AND, OR, NOT or "" for a leaf
+	left, right *queryNode
+
+	// Leaf fields
+	field  string   // column restriction, empty for all columns
+	text   string   // as typed, for case-sensitive checks
+	tokens []string // lower-cased words as the FTS tokenizer sees them
+	prefix bool
+	phrase bool // written in quotes
+	// variants are indexed terms accepted in place of a single-token leaf,
+	// set by fuzzy matching
+	variants []string
+}
+
+type queryToken struct {
+	op   string // (, ), AND, OR, NOT or "" for a leaf
+	leaf *queryNode
+	neg  bool // leaf written as -term
+}
+
+// parseQuery parses a search query; field, when set, restricts every term
+func parseQuery(q, field string) (*queryNode, error) {
+	if field != "" && !isColumn(field) {
+		return nil, fmt.Errorf("unknown search field %q (supported: %s)", field, strings.Join(storage.FullTextColumns, ", "))
+	}
+	toks, err := lexQuery(q, field)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("empty search query")
+	}
+	p := &queryParser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q in search query", p.toks[p.pos].String())
+	}
+	return n, nil
+}
+
+func (t queryToken) String() string {
+	if t.leaf != nil {
+		return t.leaf.text
+	}
+	return t.op
+}
+
+func isColumn(name string) bool {
+	for _, c := range storage.FullTextColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenize splits text into the words the unicode61 tokenizer indexes
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func lexQuery(q, defaultField string) ([]queryToken, error) {
+	var toks []queryToken
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(' || r == ')':
+			toks = append(toks, queryToken{op: string(r)})
+			i++
+			continue
+		}
+
+		neg := false
+		if r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) {
+			neg = true
+			i++
+		}
+
+		field := defaultField
+		j := i
+		for j < len(rs) && unicode.IsLetter(rs[j]) {
+			j++
+		}
+		if j < len(rs) && rs[j] == ':' && isColumn(strings.ToLower(string(rs[i:j]))) {
+			field = strings.ToLower(string(rs[i:j]))
+			i = j + 1
+		}
+
+		var text string
+		quoted := false
+		if i < len(rs) && rs[i] == '"' {
+			end := i + 1
+			for end < len(rs) && rs[end] != '"' {
+				end++
+			}
+			if end == len(rs) {
+				return nil, fmt.Errorf("unterminated phrase in search query")
+			}
+			text, quoted = string(rs[i+1:end]), true
+			i = end + 1
+		} else {
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+				i++
+			}
+			text = string(rs[start:i])
+		}
+
+		prefix := false
+		if i < len(rs) && rs[i] == '*' {
+			prefix = true
+			i++
+		} else if !quoted && strings.HasSuffix(text, "*") {
+			prefix = true
+			text = strings.TrimRight(text, "*")
+		}
+
+		if !quoted && !neg && field == defaultField && !prefix {
+			switch text {
+			case "AND", "OR", "NOT":
+				toks = append(toks, queryToken{op: text})
+				continue
+			}
+		}
+
+		words := tokenize(text)
+		if len(words) == 0 {
+			if text == "" && !quoted {
+				return nil, fmt.Errorf("missing search term after field or '-'")
+			}
+			return nil, fmt.Errorf("search term %q has no letters or digits", text)
+		}
+		toks = append(toks, queryToken{
+			leaf: &queryNode{field: field, text: text, tokens: words, prefix: prefix, phrase: quoted},
+			neg:  neg,
+		})
+	}
+	return toks, nil
+}
+
+type queryParser struct {
+	toks []queryToken
+	pos  int
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *queryParser) parseOr() (*queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.op == "OR"; t = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t == nil || t.op == "OR" || t.op == ")" {
+			return left, nil
+		}
+		if t.op == "AND" {
+			p.pos++
+		}
+		if t := p.peek(); t != nil && t.neg {
+			p.pos++
+			left = &queryNode{op: "NOT", left: left, right: t.leaf}
+			continue
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "AND", left: left, right: right}
+	}
+}
+
+func (p *queryParser) parseNot() (*queryNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t != nil && t.op == "NOT"; t = p.peek() {
+		p.pos++
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "NOT", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parsePrimary() (*queryNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("search query ends unexpectedly")
+	}
+	p.pos++
+	switch {
+	case t.op == "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.peek(); c == nil || c.op != ")" {
+			return nil, fmt.Errorf("missing ')' in search query")
+		}
+		p.pos++
+		return n, nil
+	case t.neg:
+		return nil, fmt.Errorf("-%s needs a term before it to exclude from", t.leaf.text)
+	case t.leaf != nil:
+		return t.leaf, nil
+	}
+	return nil, fmt.Errorf("unexpected %q in search query", t.op)
+}
+
+// match renders the query as a MATCH expression for the given FTS module
+func (n *queryNode) match(module string) string {
+	if n.op != "" {
+		return "(" + n.left.match(module) + " " + n.op + " " + n.right.match(module) + ")"
+	}
+	if len(n.variants) == 0 {
+		return matchTerm(module, n.field, n.tokens, n.prefix)
+	}
+	alts := []string{matchTerm(module, n.field, n.tokens, n.prefix)}
+	for _, v := range n.variants {
+		alts = append(alts, matchTerm(module, n.field, []string{v}, false))
+	}
+	return "(" + strings.Join(alts, " OR ") + ")"
+}
+
+// matchTerm renders one phrase, optionally restricted to a column
+func matchTerm(module, field string, tokens []string, prefix bool) string {
+	star := ""
+	if prefix {
+		star = "*"
+	}
+	if module == "fts5" {
+		col := ""
+		if field != "" {
+			col = field + " : "
+		}
+		return col + `"` + strings.Join(tokens, " ") + `"` + star
+	}
+
+	// FTS4 cannot restrict a quoted phrase to a column, so column phrases
+	// become adjacent column terms
+	if field == "" {
+		return `"` + strings.Join(tokens, " ") + star + `"`
+	}
+	parts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		parts[i] = field + ":" + tok
+	}
+	parts[len(parts)-1] += star
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " NEAR/0 ") + ")"
+}
+
+// matchCase re-checks the query case-sensitively against an archive; the
+// FTS index itself is case-insensitive
+func (n *queryNode) matchCase(a *storage.Archive) bool {
+	switch n.op {
+	case "AND":
+		return n.left.matchCase(a) && n.right.matchCase(a)
+	case "OR":
+		return n.left.matchCase(a) || n.right.matchCase(a)
+	case "NOT":
+		return n.left.matchCase(a) && !n.right.matchCase(a)
+	}
+	return strings.Contains(columnText(a, n.field), n.text)
+}
+
+// columnText returns the indexed text of one column, or of all columns
+func columnText(a *storage.Archive, column string) string {
+	switch column {
+	case "name":
+		return a.Name
+	case "path":
+		return a.Path
+	case "profile":
+		return a.Profile
+	case "metadata":
+		return a.Metadata
+	case "tags":
+		return strings.Join(a.Tags, " ")
+	}
+	return strings.Join([]string{a.Name, a.Path, a.Profile, a.Metadata, strings.Join(a.Tags, " ")}, " ")
+}
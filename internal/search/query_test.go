@@ -0,0 +1,80 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQueryMatch(t *testing.T) {
+	tests := []struct {
+		query, field string
+		fts4, fts5   string
+	}{
+		{"Backup", "", `"backup"`, `"backup"`},
+		{"proj*", "", `"proj*"`, `"proj"*`},
+		{`"family photos"`, "", `"family photos"`, `"family photos"`},
+		{"a b", "", `("a" AND "b")`, `("a" AND "b")`},
+		{"a OR b c", "", `("a" OR ("b" AND "c"))`, `("a" OR ("b" AND "c"))`},
+		{"a -b", "", `("a" NOT "b")`, `("a" NOT "b")`},
+		{"name:client-acme", "", `(name:client NEAR/0 name:acme)`, `name : "client acme"`},
+		{"tax*", "name", `name:tax*`, `name : "tax"*`},
+		{`x" OR 1`, "", "", ""},
+	}
+	for _, tt := range tests {
+		q, err := parseQuery(tt.query, tt.field)
+		if tt.fts4 == "" {
+			if err == nil {
+				t.Errorf("parseQuery(%q) expected error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseQuery(%q) error: %v", tt.query, err)
+			continue
+		}
+		if got := q.match("fts4"); got != tt.fts4 {
+			t.Errorf("fts4 match for %q = %s, want %s", tt.query, got, tt.fts4)
+		}
+		if got := q.match("fts5"); got != tt.fts5 {
+			t.Errorf("fts5 match for %q = %s, want %s", tt.query, got, tt.fts5)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"project", "project", 0},
+		{"projcet", "project", 1},
+		{"vacaton", "vacation", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), 5); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := editDistance([]rune("kitten"), []rune("sitting"), 1); got != 2 {
+		t.Errorf("expected early exit above max, got %d", got)
+	}
+}
+
+func TestSimilarTerms(t *testing.T) {
+	vocab := []string{"photos", "photo", "phonics", "protos", "backup"}
+	got := similarTerms("photso", false, vocab)
+	if strings.Join(got, ",") != "photo,photos,protos" {
+		t.Errorf("similarTerms(photso) = %v, want [photo photos protos]", got)
+	}
+	if got := similarTerms("ab", false, vocab); got != nil {
+		t.Errorf("short terms should not be fuzzed, got %v", got)
+	}
+	// Terms already covered by the prefix are left to the prefix query
+	for _, term := range similarTerms("pho", true, vocab) {
+		if term == "photos" || term == "photo" || term == "phonics" {
+			t.Errorf("prefix variants should not include %s", term)
+		}
+	}
+}
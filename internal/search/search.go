@@ -1,95 +1,72 @@
 package search
 
 import (
-	"database/sql"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/adamstac/7zarch-go/internal/storage"
 )
 
-const (
-	migrationSearchID   = "0005_search_index"
-	migrationSearchName = "Add search_index table for full-text search support"
-)
-
-// SearchEngine provides full-text search across archive metadata
+// SearchEngine provides full-text search across archive metadata using the
+// registry's SQLite full-text index. Triggers keep the index current, so a
+// fresh process can search without reindexing.
 type SearchEngine struct {
 	registry *storage.Registry
-	index    *InvertedIndex
-	mu       sync.RWMutex
 }
 
-// InvertedIndex stores term -> archive UID mappings for fast text search
-type InvertedIndex struct {
-	// terms maps search terms to archive UIDs that contain them
-	terms map[string][]string
-	// fieldTerms maps field names to their term mappings for field-specific search
-	fieldTerms map[string]map[string][]string
-	// cache provides LRU caching for frequent search terms
-	cache *LRUCache
-	// lastUpdate tracks when the index was last rebuilt
-	lastUpdate time.Time
+// SearchOptions configures search behavior
+type SearchOptions struct {
+	Field         string // Specific field to search (name, path, profile, metadata, tags)
+	UseRegex      bool   // Enable regex pattern matching
+	CaseSensitive bool   // Case-sensitive search
+	Fuzzy         bool   // Tolerate typos and match the last term as a prefix
+	MaxResults    int    // Limit number of results (0 = no limit)
 }
 
-// LRUCache provides memory-efficient caching for search results
-type LRUCache struct {
-	capacity int
-	items    map[string]*cacheItem
-	order    *cacheList
-	mu       sync.RWMutex
+// Result is an archive found by a search with its relevance score. Higher
+// scores are better; regex matches are unranked and score 0.
+type Result struct {
+	Archive *storage.Archive
+	Score   float64
 }
 
-type cacheItem struct {
-	key     string
-	value   []*storage.Archive
-	expires time.Time
-	prev    *cacheItem
-	next    *cacheItem
-}
+// Warnings receives the one-time notice that search is running on the FTS4
+// fallback; tests may silence it
+var Warnings io.Writer = os.Stderr
 
-type cacheList struct {
-	head *cacheItem
-	tail *cacheItem
-}
+var fallbackWarning sync.Once
 
-// SearchOptions configures search behavior
-type SearchOptions struct {
-	Field        string // Specific field to search (name, path, metadata)
-	UseRegex     bool   // Enable regex pattern matching
-	CaseSensitive bool   // Case-sensitive search
-	MaxResults   int    // Limit number of results (0 = no limit)
+// warnFallback tells the user, once per process, that this build lacks FTS5
+// and ranks with the slower FTS4 fallback
+func warnFallback() {
+	fallbackWarning.Do(func() {
+		fmt.Fprintf(Warnings, "⚠️  Warning: this build has no SQLite FTS5 support; search ranks with the FTS4 fallback. "+
+			"Build with 'go build -tags sqlite_fts5' for native BM25 ranking.\n")
+	})
 }
 
+// columnWeights scale BM25 scores per column, in storage.FullTextColumns
+// order, so a hit in the name outranks one in the path or tags, which
+// outranks one in the profile or metadata
+var columnWeights = []float64{4, 2, 1, 1, 2}
+
+// BM25 parameters, the same defaults FTS5 uses
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
 // NewSearchEngine creates a new search engine instance
 func NewSearchEngine(registry *storage.Registry) *SearchEngine {
-	return &SearchEngine{
-		registry: registry,
-		index: &InvertedIndex{
-			terms:      make(map[string][]string),
-			fieldTerms: make(map[string]map[string][]string),
-			cache:      NewLRUCache(1000), // Cache up to 1000 search results
-		},
-	}
-}
-
-// NewLRUCache creates a new LRU cache with specified capacity
-func NewLRUCache(capacity int) *LRUCache {
-	cache := &LRUCache{
-		capacity: capacity,
-		items:    make(map[string]*cacheItem),
-		order: &cacheList{
-			head: &cacheItem{},
-			tail: &cacheItem{},
-		},
-	}
-	// Initialize the doubly-linked list
-	cache.order.head.next = cache.order.tail
-	cache.order.tail.prev = cache.order.head
-	return cache
+	return &SearchEngine{registry: registry}
 }
 
 // Search performs full-text search across all archive metadata
@@ -112,36 +89,37 @@ func (se *SearchEngine) SearchRegex(field, pattern string) ([]*storage.Archive,
 	})
 }
 
-// SearchWithOptions performs search with full configuration control
+// SearchWithOptions performs search with full configuration control. Results
+// are ordered best match first.
 func (se *SearchEngine) SearchWithOptions(query string, opts SearchOptions) ([]*storage.Archive, error) {
-	startTime := time.Now()
-
-	// Check cache first for performance
-	cacheKey := fmt.Sprintf("%s:%s:%v:%v", query, opts.Field, opts.UseRegex, opts.CaseSensitive)
-	if cached := se.index.cache.Get(cacheKey); cached != nil {
-		return cached, nil
+	results, err := se.SearchScored(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]*storage.Archive, len(results))
+	for i, r := range results {
+		archives[i] = r.Archive
 	}
+	return archives, nil
+}
 
-	// Ensure index is up to date (check without lock first to avoid deadlock)
-	if err := se.ensureIndexCurrent(); err != nil {
-		return nil, fmt.Errorf("failed to update search index: %w", err)
+// SearchScored searches like SearchWithOptions and returns each archive with
+// its relevance score, best first
+func (se *SearchEngine) SearchScored(query string, opts SearchOptions) ([]Result, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	if opts.Fuzzy && (opts.UseRegex || opts.CaseSensitive) {
+		return nil, fmt.Errorf("fuzzy search cannot be combined with regex or case-sensitive search")
 	}
-	
-	// Now acquire read lock for search operations
-	se.mu.RLock()
-	defer se.mu.RUnlock()
 
-	var results []*storage.Archive
+	var results []Result
 	var err error
-
 	if opts.UseRegex {
 		results, err = se.searchRegex(query, opts)
-	} else if opts.Field != "" {
-		results, err = se.searchField(opts.Field, query, opts)
 	} else {
 		results, err = se.searchFullText(query, opts)
 	}
-
 	if err != nil {
 		return nil, err
 	}
@@ -150,438 +128,223 @@ func (se *SearchEngine) SearchWithOptions(query string, opts SearchOptions) ([]*
 	if opts.MaxResults > 0 && len(results) > opts.MaxResults {
 		results = results[:opts.MaxResults]
 	}
-
-	// Cache results for future queries (expires in 5 minutes)
-	se.index.cache.Set(cacheKey, results, 5*time.Minute)
-
-	// Log performance for optimization (in production, use proper logging)
-	queryTime := time.Since(startTime)
-	if queryTime > 500*time.Millisecond {
-		// This should trigger monitoring alerts in production
-		fmt.Printf("PERF WARNING: Search query took %v (target: <500ms)\n", queryTime)
-	}
-
 	return results, nil
 }
 
-// searchFullText performs cross-field text search
-func (se *SearchEngine) searchFullText(query string, opts SearchOptions) ([]*storage.Archive, error) {
-	query = se.normalizeQuery(query, opts)
-	terms := strings.Fields(query)
-	
-	if len(terms) == 0 {
-		return nil, fmt.Errorf("empty search query")
-	}
-
-	// Find archives that contain all search terms (AND logic)
-	var candidateUIDs []string
-	for i, term := range terms {
-		termUIDs := se.index.terms[term]
-		if len(termUIDs) == 0 {
-			// No archives contain this term, so no results possible
-			return []*storage.Archive{}, nil
-		}
-		
-		if i == 0 {
-			candidateUIDs = termUIDs
-		} else {
-			// Intersect with previous results
-			candidateUIDs = intersectStringSlices(candidateUIDs, termUIDs)
-		}
-		
-		if len(candidateUIDs) == 0 {
-			break // Early termination if no intersection
-		}
+// searchFullText runs the query against the full-text index
+func (se *SearchEngine) searchFullText(query string, opts SearchOptions) ([]Result, error) {
+	q, err := parseQuery(query, opts.Field)
+	if err != nil {
+		return nil, err
 	}
-
-	return se.resolveArchiveUIDs(candidateUIDs)
-}
-
-// searchField performs field-specific search
-func (se *SearchEngine) searchField(field, query string, opts SearchOptions) ([]*storage.Archive, error) {
-	query = se.normalizeQuery(query, opts)
-	
-	fieldIndex, exists := se.index.fieldTerms[field]
-	if !exists {
-		return []*storage.Archive{}, nil
-	}
-
-	terms := strings.Fields(query)
-	var candidateUIDs []string
-	
-	for i, term := range terms {
-		termUIDs := fieldIndex[term]
-		if len(termUIDs) == 0 {
-			return []*storage.Archive{}, nil
-		}
-		
-		if i == 0 {
-			candidateUIDs = termUIDs
-		} else {
-			candidateUIDs = intersectStringSlices(candidateUIDs, termUIDs)
-		}
-		
-		if len(candidateUIDs) == 0 {
-			break
+	if opts.Fuzzy {
+		vocab, err := se.registry.FullTextTerms()
+		if err != nil {
+			return nil, err
 		}
+		q = fuzzy(q, vocab)
 	}
 
-	return se.resolveArchiveUIDs(candidateUIDs)
-}
-
-// searchRegex performs regex-based search
-func (se *SearchEngine) searchRegex(pattern string, opts SearchOptions) ([]*storage.Archive, error) {
-	regex, err := regexp.Compile(pattern)
+	hits, err := se.rank(q.match(se.registry.FullText().Module))
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
-	}
-
-	// Get all archives and filter by regex
-	archives, err := se.registry.List()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list archives: %w", err)
+		return nil, err
 	}
-
-	var results []*storage.Archive
-	for _, archive := range archives {
-		var searchText string
-		
-		switch opts.Field {
-		case "name":
-			searchText = archive.Name
-		case "path":
-			searchText = archive.Path
-		case "metadata":
-			searchText = archive.Metadata
-		default:
-			// Search all fields
-			searchText = fmt.Sprintf("%s %s %s", archive.Name, archive.Path, archive.Metadata)
-		}
-		
-		if !opts.CaseSensitive {
-			searchText = strings.ToLower(searchText)
-		}
-		
-		if regex.MatchString(searchText) {
-			results = append(results, archive)
-		}
+	ids := make([]int64, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
 	}
-
-	return results, nil
-}
-
-// Reindex rebuilds the search index from current archive data
-func (se *SearchEngine) Reindex() error {
-	se.mu.Lock()
-	defer se.mu.Unlock()
-
-	startTime := time.Now()
-
-	// Get all archives
-	archives, err := se.registry.List()
+	archives, err := se.resolveArchiveIDs(ids)
 	if err != nil {
-		return fmt.Errorf("failed to list archives for indexing: %w", err)
+		return nil, err
 	}
-
-	// Clear existing index
-	se.index.terms = make(map[string][]string)
-	se.index.fieldTerms = make(map[string]map[string][]string)
-	
-	// Initialize field indices
-	se.index.fieldTerms["name"] = make(map[string][]string)
-	se.index.fieldTerms["path"] = make(map[string][]string)
-	se.index.fieldTerms["profile"] = make(map[string][]string)
-	se.index.fieldTerms["metadata"] = make(map[string][]string)
-
-	// Build index from archives
-	for _, archive := range archives {
-		se.indexArchive(archive)
+	scores := make(map[int64]float64, len(hits))
+	for _, h := range hits {
+		scores[h.id] = h.score
 	}
 
-	se.index.lastUpdate = time.Now()
-	
-	// Clear cache since index changed
-	se.index.cache = NewLRUCache(1000)
-
-	indexTime := time.Since(startTime)
-	fmt.Printf("Search index rebuilt: %d archives indexed in %v\n", len(archives), indexTime)
-
-	return nil
-}
-
-// indexArchive adds a single archive to the search index
-func (se *SearchEngine) indexArchive(archive *storage.Archive) {
-	// Index individual fields
-	se.indexFieldTerms("name", archive.Name, archive.UID)
-	se.indexFieldTerms("path", archive.Path, archive.UID)
-	se.indexFieldTerms("profile", archive.Profile, archive.UID)
-	se.indexFieldTerms("metadata", archive.Metadata, archive.UID)
-
-	// Index all text for cross-field search
-	allText := fmt.Sprintf("%s %s %s %s", archive.Name, archive.Path, archive.Profile, archive.Metadata)
-	se.indexTerms(allText, archive.UID)
-}
-
-// indexFieldTerms adds terms from a specific field to the field-specific index
-func (se *SearchEngine) indexFieldTerms(field, text, uid string) {
-	terms := se.extractTerms(text)
-	fieldIndex := se.index.fieldTerms[field]
-	
-	for _, term := range terms {
-		if !stringSliceContains(fieldIndex[term], uid) {
-			fieldIndex[term] = append(fieldIndex[term], uid)
+	results := make([]Result, 0, len(archives))
+	for _, a := range archives {
+		if opts.CaseSensitive && !q.matchCase(a) {
+			continue
 		}
-	}
-}
-
-// indexTerms adds terms to the main cross-field index
-func (se *SearchEngine) indexTerms(text, uid string) {
-	terms := se.extractTerms(text)
-	
-	for _, term := range terms {
-		if !stringSliceContains(se.index.terms[term], uid) {
-			se.index.terms[term] = append(se.index.terms[term], uid)
+		score := scores[a.ID]
+		if opts.Fuzzy {
+			// Exact matches outrank ones that needed edits
+			score *= q.similarity(a)
 		}
+		results = append(results, Result{Archive: a, Score: score})
 	}
-}
-
-// extractTerms breaks text into searchable terms
-func (se *SearchEngine) extractTerms(text string) []string {
-	// Normalize to lowercase
-	text = strings.ToLower(text)
-	
-	// Split on whitespace and common separators
-	terms := strings.FieldsFunc(text, func(r rune) bool {
-		return r == ' ' || r == '\t' || r == '\n' || r == '/' || r == '\\' || r == '.' || r == '-' || r == '_'
-	})
-	
-	// Filter out empty terms and very short terms
-	var filtered []string
-	for _, term := range terms {
-		if len(term) >= 2 { // Minimum term length for performance
-			filtered = append(filtered, term)
-		}
+	if opts.Fuzzy {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
 	}
-	
-	return filtered
+	return results, nil
 }
 
-// normalizeQuery prepares query text for searching
-func (se *SearchEngine) normalizeQuery(query string, opts SearchOptions) string {
-	if !opts.CaseSensitive {
-		query = strings.ToLower(query)
-	}
-	return strings.TrimSpace(query)
+type hit struct {
+	id    int64
+	score float64
 }
 
-// ensureIndexCurrent checks if index needs rebuilding
-func (se *SearchEngine) ensureIndexCurrent() error {
-	// If index has never been built, build it now
-	if se.index.lastUpdate.IsZero() {
-		return se.Reindex()
+// rank returns the archives matching the MATCH expression with their BM25
+// scores, best first
+func (se *SearchEngine) rank(match string) ([]hit, error) {
+	ft := se.registry.FullText()
+	if ft.Table == "" {
+		return nil, fmt.Errorf("search index is not available")
 	}
-	// Simple time-based check - in production would check archive modification times
-	if time.Since(se.index.lastUpdate) > 10*time.Minute {
-		return se.Reindex()
-	}
-	return nil
-}
+	db := se.registry.DB()
 
-// resolveArchiveUIDs converts UIDs to Archive objects
-func (se *SearchEngine) resolveArchiveUIDs(uids []string) ([]*storage.Archive, error) {
-	var archives []*storage.Archive
-	
-	for _, uid := range uids {
-		archive, err := se.registry.GetByUID(uid)
+	if ft.Module == "fts5" {
+		weights := make([]string, len(columnWeights))
+		for i, w := range columnWeights {
+			weights[i] = fmt.Sprint(w)
+		}
+		// FTS5 scores are negative, lower is better
+		rows, err := db.Query(fmt.Sprintf(`SELECT rowid, -bm25(%[1]s, %[2]s) AS score FROM %[1]s WHERE %[1]s MATCH ? ORDER BY score DESC, rowid DESC`,
+			ft.Table, strings.Join(weights, ", ")), match)
 		if err != nil {
-			// Archive may have been deleted, skip it
-			continue
+			return nil, fmt.Errorf("failed to search: %w", err)
 		}
-		archives = append(archives, archive)
-	}
-	
-	return archives, nil
-}
-
-// LRU Cache implementation
-
-// Get retrieves an item from cache
-func (c *LRUCache) Get(key string) []*storage.Archive {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	item, exists := c.items[key]
-	if !exists || time.Now().After(item.expires) {
-		if exists {
-			c.removeItem(item)
+		defer rows.Close()
+		var hits []hit
+		for rows.Next() {
+			var h hit
+			if err := rows.Scan(&h.id, &h.score); err != nil {
+				return nil, fmt.Errorf("failed to scan search result: %w", err)
+			}
+			hits = append(hits, h)
 		}
-		return nil
+		return hits, rows.Err()
 	}
 
-	// Move to front (most recently used)
-	c.moveToFront(item)
-	return item.value
-}
-
-// Set stores an item in cache with expiration
-func (c *LRUCache) Set(key string, value []*storage.Archive, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Remove existing item if present
-	if existing, exists := c.items[key]; exists {
-		c.removeItem(existing)
+	// FTS4 has no ranking function, only the statistics to compute one
+	warnFallback()
+	rows, err := db.Query(fmt.Sprintf(`SELECT rowid, matchinfo(%[1]s, 'pcnalx') FROM %[1]s WHERE %[1]s MATCH ?`, ft.Table), match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	var hits []hit
+	for rows.Next() {
+		var h hit
+		var info []byte
+		if err := rows.Scan(&h.id, &info); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		h.score = bm25(info)
+		hits = append(hits, h)
 	}
-
-	// Create new item
-	item := &cacheItem{
-		key:     key,
-		value:   value,
-		expires: time.Now().Add(ttl),
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-
-	// Add to front
-	c.addToFront(item)
-	c.items[key] = item
-
-	// Evict oldest items if over capacity
-	for len(c.items) > c.capacity {
-		oldest := c.order.tail.prev
-		if oldest == c.order.head {
-			// Sanity check to prevent infinite loop
-			break
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id > hits[j].id
+	})
+	return hits, nil
+}
+
+// bm25 scores a row from FTS4 matchinfo 'pcnalx' output: phrase and column
+// counts, row count, average and row token counts per column, then hits in
+// this row, hits in all rows and rows with hits for each phrase and column.
+func bm25(info []byte) float64 {
+	v := make([]uint32, len(info)/4)
+	for i := range v {
+		v[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(v) < 3 {
+		return 0
+	}
+	phrases, cols, total := int(v[0]), int(v[1]), float64(v[2])
+	if len(v) < 3+2*cols+3*phrases*cols {
+		return 0
+	}
+	avg, length, x := v[3:3+cols], v[3+cols:3+2*cols], v[3+2*cols:]
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols; c++ {
+			tf := float64(x[3*(p*cols+c)])
+			if tf == 0 {
+				continue
+			}
+			docs := float64(x[3*(p*cols+c)+2])
+			idf := math.Log((total - docs + 0.5) / (docs + 0.5))
+			if idf <= 0 {
+				idf = 1e-6
+			}
+			norm := 1.0
+			if avg[c] > 0 {
+				norm = 1 - bm25B + bm25B*float64(length[c])/float64(avg[c])
+			}
+			weight := 1.0
+			if c < len(columnWeights) {
+				weight = columnWeights[c]
+			}
+			score += weight * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
 		}
-		c.removeItem(oldest)
 	}
+	return score
 }
 
-// moveToFront moves item to front of LRU list
-func (c *LRUCache) moveToFront(item *cacheItem) {
-	c.removeFromList(item)
-	c.addToFront(item)
-}
-
-// addToFront adds item to front of LRU list
-func (c *LRUCache) addToFront(item *cacheItem) {
-	item.prev = c.order.head
-	item.next = c.order.head.next
-	c.order.head.next.prev = item
-	c.order.head.next = item
-}
-
-// removeItem removes item from cache
-func (c *LRUCache) removeItem(item *cacheItem) {
-	delete(c.items, item.key)
-	c.removeFromList(item)
-}
-
-// removeFromList removes item from LRU list
-func (c *LRUCache) removeFromList(item *cacheItem) {
-	item.prev.next = item.next
-	item.next.prev = item.prev
-}
-
-// Utility functions
-
-// intersectStringSlices returns elements present in both slices
-func intersectStringSlices(a, b []string) []string {
-	set := make(map[string]bool)
-	for _, item := range a {
-		set[item] = true
+// searchRegex performs regex-based search
+func (se *SearchEngine) searchRegex(pattern string, opts SearchOptions) ([]Result, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
-	var result []string
-	for _, item := range b {
-		if set[item] {
-			result = append(result, item)
-		}
+	// Get all archives and filter by regex
+	page, err := se.registry.Find(context.Background(), storage.Criteria{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
 	}
 
-	return result
-}
+	var results []Result
+	for _, archive := range page.Archives {
+		searchText := columnText(archive, opts.Field)
+		if !opts.CaseSensitive {
+			searchText = strings.ToLower(searchText)
+		}
 
-// stringSliceContains checks if slice contains string
-func stringSliceContains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+		if regex.MatchString(searchText) {
+			results = append(results, Result{Archive: archive})
 		}
 	}
-	return false
-}
 
-// Migration support for search index table
-
-// EnsureSearchTable creates search index table if it doesn't exist
-func (se *SearchEngine) EnsureSearchTable() error {
-	_, err := se.registry.DB().Exec(`
-		CREATE TABLE IF NOT EXISTS search_index (
-			term TEXT,
-			archive_uid TEXT,
-			field TEXT,
-			PRIMARY KEY (term, archive_uid, field)
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create search_index table: %w", err)
-	}
+	return results, nil
+}
 
-	// Create index for performance
-	_, err = se.registry.DB().Exec(`
-		CREATE INDEX IF NOT EXISTS idx_search_term ON search_index(term)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create search index: %w", err)
+// Reindex rebuilds the search index from current archive data. The index is
+// maintained as archives change, so this only repairs a damaged index.
+func (se *SearchEngine) Reindex() error {
+	if err := se.registry.RebuildFullText(); err != nil {
+		return fmt.Errorf("failed to rebuild search index: %w", err)
 	}
-
 	return nil
 }
 
-// IsSearchMigrationApplied checks if search migration has been applied
-func IsSearchMigrationApplied(db *sql.DB) (bool, error) {
-	row := db.QueryRow(`SELECT 1 FROM schema_migrations WHERE id = ?`, migrationSearchID)
-	var one int
-	switch err := row.Scan(&one); err {
-	case sql.ErrNoRows:
-		return false, nil
-	case nil:
-		return true, nil
-	default:
-		return false, err
+// resolveArchiveIDs converts row ids to archives, keeping their order
+func (se *SearchEngine) resolveArchiveIDs(ids []int64) ([]*storage.Archive, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
-}
-
-// ApplySearchMigration applies the search system migration
-func ApplySearchMigration(db *sql.DB) error {
-	// Create search_index table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS search_index (
-			term TEXT,
-			archive_uid TEXT,
-			field TEXT,
-			PRIMARY KEY (term, archive_uid, field)
-		)
-	`)
+	page, err := se.registry.Find(context.Background(), storage.Criteria{IDs: ids})
 	if err != nil {
-		return fmt.Errorf("failed to create search_index table: %w", err)
+		return nil, fmt.Errorf("failed to resolve search results: %w", err)
 	}
 
-	// Create performance index
-	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_search_term ON search_index(term)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create search index: %w", err)
+	byID := make(map[int64]*storage.Archive, len(page.Archives))
+	for _, a := range page.Archives {
+		byID[a.ID] = a
 	}
-
-	// Mark migration as applied
-	_, err = db.Exec(`
-		INSERT OR REPLACE INTO schema_migrations (id, name, applied_at) 
-		VALUES (?, ?, ?)
-	`, migrationSearchID, migrationSearchName, time.Now().Unix())
-	if err != nil {
-		return fmt.Errorf("failed to mark search migration as applied: %w", err)
+	archives := make([]*storage.Archive, 0, len(page.Archives))
+	for _, id := range ids {
+		if a, ok := byID[id]; ok {
+			archives = append(archives, a)
+		}
 	}
-
-	return nil
-}
\ No newline at end of file
+	return archives, nil
+}
@@ -3,6 +3,9 @@ package search
 import (
 	"database/sql"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -51,11 +54,6 @@ func TestSearchEngine_FullTextSearch(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	// Ensure search table exists
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test full-text search
 	results, err := searchEngine.Search("project")
 	if err != nil {
@@ -86,10 +84,6 @@ func TestSearchEngine_FieldSpecificSearch(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test field-specific search
 	results, err := searchEngine.SearchField("name", "backup")
 	if err != nil {
@@ -111,10 +105,6 @@ func TestSearchEngine_RegexSearch(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test regex search
 	results, err := searchEngine.SearchRegex("name", ".*-project$")
 	if err != nil {
@@ -136,10 +126,6 @@ func TestSearchEngine_SearchOptions(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test search with options
 	opts := SearchOptions{
 		Field:      "profile",
@@ -162,10 +148,6 @@ func TestSearchEngine_Performance(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test search performance
 	start := time.Now()
 	_, err := searchEngine.Search("project")
@@ -186,10 +168,6 @@ func TestSearchEngine_Reindex(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test reindexing
 	start := time.Now()
 	err := searchEngine.Reindex()
@@ -203,9 +181,13 @@ func TestSearchEngine_Reindex(t *testing.T) {
 		t.Errorf("Reindex took %v, expected <10ms for small dataset", duration)
 	}
 
-	// Verify index was updated
-	if searchEngine.index.lastUpdate.IsZero() {
-		t.Error("Index lastUpdate should be set after reindexing")
+	// Search still works from the rebuilt index
+	results, err := searchEngine.Search("project")
+	if err != nil {
+		t.Fatalf("Search after reindex failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results after reindex, got %d", len(results))
 	}
 }
 
@@ -215,10 +197,6 @@ func TestSearchEngine_EmptyQuery(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test empty query
 	results, err := searchEngine.Search("")
 	if err == nil {
@@ -236,10 +214,6 @@ func TestSearchEngine_NoResults(t *testing.T) {
 
 	searchEngine := NewSearchEngine(registry)
 	
-	if err := searchEngine.EnsureSearchTable(); err != nil {
-		t.Fatalf("Failed to create search table: %v", err)
-	}
-
 	// Test query with no results
 	results, err := searchEngine.Search("nonexistent")
 	if err != nil {
@@ -251,65 +225,229 @@ func TestSearchEngine_NoResults(t *testing.T) {
 	}
 }
 
-func TestLRUCache_Basic(t *testing.T) {
-	cache := NewLRUCache(2)
+func searchNames(t *testing.T, se *SearchEngine, query string, opts SearchOptions) []string {
+	t.Helper()
+	results, err := se.SearchWithOptions(query, opts)
+	if err != nil {
+		t.Fatalf("search %q failed: %v", query, err)
+	}
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSearchEngine_QuerySyntax(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
+	se := NewSearchEngine(registry)
 
-	// Test cache miss
-	result := cache.Get("key1")
-	if result != nil {
-		t.Error("Expected cache miss for 'key1'")
+	tests := []struct {
+		query string
+		opts  SearchOptions
+		want  []string
+	}{
+		{"proj*", SearchOptions{}, []string{"project-backup", "video-project"}},
+		{"repo*", SearchOptions{Field: "name"}, []string{"code-repository"}},
+		{`"family vacation"`, SearchOptions{}, []string{"media-photos"}},
+		{`"vacation family"`, SearchOptions{}, []string{}},
+		{"project backup", SearchOptions{}, []string{"project-backup"}},
+		{"project AND backup", SearchOptions{}, []string{"project-backup"}},
+		{"photos OR video", SearchOptions{}, []string{"media-photos", "video-project"}},
+		{"project NOT video", SearchOptions{}, []string{"project-backup"}},
+		{"project -video", SearchOptions{}, []string{"project-backup"}},
+		{"(photos OR code) documents", SearchOptions{}, []string{"code-repository"}},
+		{"name:backup", SearchOptions{}, []string{"project-backup"}},
+		{`name:"code repository"`, SearchOptions{}, []string{"code-repository"}},
+		{"profile:media project", SearchOptions{}, []string{"video-project"}},
+		{"Project", SearchOptions{CaseSensitive: true}, []string{}},
+		{"Video", SearchOptions{CaseSensitive: true}, []string{"video-project"}},
+	}
+	for _, tt := range tests {
+		got := searchNames(t, se, tt.query, tt.opts)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("search %q: got %v, want %v", tt.query, got, tt.want)
+		}
 	}
+}
 
-	// Test cache set and get
-	testArchives := []*storage.Archive{
-		{Name: "test1"},
-		{Name: "test2"},
+func TestSearchEngine_InvalidQueries(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
+	se := NewSearchEngine(registry)
+
+	for _, q := range []string{"-video", "(project", `"unterminated`, "project OR", "name:", "***"} {
+		if _, err := se.Search(q); err == nil {
+			t.Errorf("expected error for query %q", q)
+		}
 	}
-	
-	cache.Set("key1", testArchives, 5*time.Minute)
-	result = cache.Get("key1")
-	if result == nil {
-		t.Error("Expected cache hit for 'key1'")
+	if _, err := se.SearchField("owner", "x"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestSearchEngine_Ranking(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
+
+	// A name hit outranks a metadata hit
+	for _, a := range []*storage.Archive{
+		{UID: "01K2E3RANK01", Name: "notes", Path: "/archives/notes.7z", Metadata: "invoices for the year"},
+		{UID: "01K2E3RANK02", Name: "invoices", Path: "/archives/invoices.7z", Metadata: "scanned paperwork"},
+	} {
+		if err := registry.Add(a); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	results, err := NewSearchEngine(registry).Search("invoices")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "invoices" {
+		t.Fatalf("expected invoices ranked first, got %v", results)
+	}
+}
+
+func TestSearchEngine_IndexFollowsRegistry(t *testing.T) {
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "test.db")
+	registry, err := storage.NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+
+	a := &storage.Archive{UID: "01K2E3FRESH1", Name: "tax-returns", Path: "/archives/tax-returns.7z", Profile: "documents"}
+	if err := registry.Add(a); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := registry.AddTags(a.ID, "client:acme"); err != nil {
+		t.Fatalf("AddTags failed: %v", err)
+	}
+	registry.Close()
+
+	// A fresh process searches without reindexing
+	registry, err = storage.NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen registry: %v", err)
+	}
+	defer registry.Close()
+	se := NewSearchEngine(registry)
+
+	if got := searchNames(t, se, "tax", SearchOptions{}); len(got) != 1 {
+		t.Fatalf("expected 1 result in fresh process, got %v", got)
+	}
+	if got := searchNames(t, se, "acme", SearchOptions{Field: "tags"}); len(got) != 1 {
+		t.Fatalf("expected tag to be searchable, got %v", got)
+	}
+
+	// Updates, tag removal and deletes are reflected immediately
+	a, err = registry.Get(a.Name)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	a.Profile = "media"
+	a.Metadata = "scanned receipts"
+	if err := registry.Update(a); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := searchNames(t, se, "profile:documents", SearchOptions{}); len(got) != 0 {
+		t.Errorf("expected old profile to be gone, got %v", got)
+	}
+	if got := searchNames(t, se, "receipts", SearchOptions{}); len(got) != 1 {
+		t.Errorf("expected new metadata to be found, got %v", got)
+	}
+	if _, err := registry.RemoveTags(a.ID, "client:acme"); err != nil {
+		t.Fatalf("RemoveTags failed: %v", err)
+	}
+	if got := searchNames(t, se, "acme", SearchOptions{}); len(got) != 0 {
+		t.Errorf("expected removed tag to be gone, got %v", got)
+	}
+	if err := registry.Delete(a.Name); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if got := searchNames(t, se, "receipts", SearchOptions{}); len(got) != 0 {
+		t.Errorf("expected deleted archive to be gone, got %v", got)
+	}
+}
+
+func TestSearchEngine_Fuzzy(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
+	se := NewSearchEngine(registry)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"projcet", []string{"project-backup", "video-project"}}, // transposition
+		{"vacaton", []string{"media-photos"}},                    // deletion
+		{"repos", []string{"code-repository"}},                   // last term is a prefix
+		{"famly phot", []string{"media-photos"}},                 // typo, then typo'd prefix
+		{"project -vidoe", []string{"project-backup", "video-project"}}, // exclusions stay exact
+		{"xyzzy", []string{}},
+	}
+	for _, tt := range tests {
+		got := searchNames(t, se, tt.query, SearchOptions{Fuzzy: true})
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("fuzzy search %q: got %v, want %v", tt.query, got, tt.want)
+		}
 	}
-	if len(result) != 2 {
-		t.Errorf("Expected 2 cached archives, got %d", len(result))
+
+	if _, err := se.SearchWithOptions("projcet", SearchOptions{Fuzzy: true, UseRegex: true}); err == nil {
+		t.Error("expected error combining fuzzy and regex")
 	}
 }
 
-func TestLRUCache_Eviction(t *testing.T) {
-	cache := NewLRUCache(2)
+func TestSearchEngine_FuzzyRanksExactFirst(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
 
-	// Fill cache
-	cache.Set("key1", []*storage.Archive{{Name: "test1"}}, 5*time.Minute)
-	cache.Set("key2", []*storage.Archive{{Name: "test2"}}, 5*time.Minute)
-	cache.Set("key3", []*storage.Archive{{Name: "test3"}}, 5*time.Minute)
+	for _, a := range []*storage.Archive{
+		{UID: "01K2E3FUZZ01", Name: "invoces", Path: "/archives/a.7z"},
+		{UID: "01K2E3FUZZ02", Name: "invoices", Path: "/archives/b.7z"},
+	} {
+		if err := registry.Add(a); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
 
-	// key1 should be evicted
-	if cache.Get("key1") != nil {
-		t.Error("Expected key1 to be evicted")
+	results, err := NewSearchEngine(registry).SearchScored("invoices", SearchOptions{Fuzzy: true})
+	if err != nil {
+		t.Fatalf("SearchScored failed: %v", err)
 	}
-	
-	// key2 and key3 should still be there
-	if cache.Get("key2") == nil {
-		t.Error("Expected key2 to still be cached")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
 	}
-	if cache.Get("key3") == nil {
-		t.Error("Expected key3 to still be cached")
+	if results[0].Archive.Name != "invoices" || results[0].Score <= results[1].Score {
+		t.Errorf("expected exact match ranked first with a higher score, got %s (%.3f), %s (%.3f)",
+			results[0].Archive.Name, results[0].Score, results[1].Archive.Name, results[1].Score)
 	}
 }
 
-func TestLRUCache_Expiration(t *testing.T) {
-	cache := NewLRUCache(10)
+func TestSearchEngine_WarnsOnceOnFTS4Fallback(t *testing.T) {
+	db, registry := setupSearchTestDB(t)
+	defer db.Close()
 
-	// Set item with very short TTL
-	cache.Set("key1", []*storage.Archive{{Name: "test1"}}, 1*time.Millisecond)
-	
-	// Wait for expiration
-	time.Sleep(5 * time.Millisecond)
-	
-	// Should be expired
-	result := cache.Get("key1")
-	if result != nil {
-		t.Error("Expected item to be expired")
+	var buf strings.Builder
+	prev := Warnings
+	Warnings, fallbackWarning = &buf, sync.Once{}
+	defer func() { Warnings = prev }()
+
+	se := NewSearchEngine(registry)
+	for _, q := range []string{"project", "photos"} {
+		if _, err := se.Search(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := 0
+	if registry.FullText().Module == "fts4" {
+		want = 1
+	}
+	if got := strings.Count(buf.String(), "sqlite_fts5"); got != want {
+		t.Fatalf("got %d fallback warnings with %s, want %d:\n%s", got, registry.FullText().Module, want, buf.String())
 	}
-}
\ No newline at end of file
+}
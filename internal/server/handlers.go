@@ -0,0 +1,388 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/archive"
+	"github.com/adamstac/7zarch-go/internal/filter"
+	"github.com/adamstac/7zarch-go/internal/search"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// testTimeout bounds one archive integrity test, as the test command does
+const testTimeout = 10 * time.Minute
+
+// healthWindow is the period the health growth rate is measured over
+const healthWindow = 30 * 24 * time.Hour
+
+// handleList returns one page of archives as `list --output json` does.
+// Filters mirror the list flags; the cursor for the next page is returned in
+// the X-Next-Cursor header.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	flags := filter.Flags{
+		NotUploaded: queryBool(q, "not_uploaded"),
+		Pattern:     q.Get("pattern"),
+		OlderThan:   q.Get("older_than"),
+		NewerThan:   q.Get("newer_than"),
+		Managed:     queryBool(q, "managed"),
+		External:    queryBool(q, "external"),
+		Missing:     queryBool(q, "missing"),
+		Deleted:     queryBool(q, "deleted"),
+		Status:      q.Get("status"),
+		Profile:     q.Get("profile"),
+		LargerThan:  q.Get("larger_than"),
+		SmallerThan: q.Get("smaller_than"),
+		Tags:        q["tag"],
+		Where:       q["where"],
+	}
+	expr, err := flags.Expression()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	f, err := filter.Parse(filter.And(expr, q.Get("filter")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	c, err := pageCriteria(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	page, err := s.mgr.Registry().Find(r.Context(), f.Criteria(c))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	writePage(w, page)
+}
+
+// handleShow returns one archive as `show --output json` does
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleTest runs the integrity test of the test command on an archive
+func (s *Server) handleTest(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), testTimeout)
+	defer cancel()
+	result, err := archive.NewManager().Test(ctx, a.Path)
+	if err != nil {
+		result = &archive.TestResult{Passed: false, Errors: []string{err.Error()}}
+	}
+	// post_test hooks run as they do after the CLI's test
+	s.mgr.NotifyTested(a, result.Passed, result.Errors)
+	writeJSON(w, http.StatusOK, testResponse{UID: a.UID, Path: a.Path, TestResult: result})
+}
+
+// handleVerify re-hashes an archive against its registered checksum, as
+// `show --verify` does, and records whether the file is present
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	scrub, err := s.mgr.Scrub(a)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to verify archive: %v", err)
+		return
+	}
+	res := verifyResponse{UID: a.UID, Path: a.Path, Status: a.Status, Checksum: a.Checksum,
+		Computed: scrub.Computed, Verified: scrub.Verified}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleTrash soft-deletes an archive as `delete` does without --force
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	if a.Status == "deleted" {
+		writeError(w, http.StatusConflict, "archive is already deleted")
+		return
+	}
+	if err := s.mgr.Trash(a); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleRestore brings an archive back from trash as `restore` does; the
+// force parameter replaces a file already at the original location
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	if a.Status != "deleted" {
+		writeError(w, http.StatusConflict, "archive is not deleted (status=%s)", a.Status)
+		return
+	}
+	target := s.mgr.RestoreTarget(a)
+	if _, err := os.Stat(target); err == nil && a.Managed && target != a.Path && !queryBool(r.URL.Query(), "force") {
+		writeError(w, http.StatusConflict, "%s already exists; restore with force to overwrite it", target)
+		return
+	}
+	if err := s.mgr.Restore(a, true); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleHealth summarises the registry: archives by status, and managed
+// storage usage against its quota and the disk
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	res := healthResponse{Path: s.mgr.GetBasePath()}
+	for status, n := range map[string]*int{"present": &res.Present, "missing": &res.Missing, "deleted": &res.Deleted} {
+		count, err := s.mgr.Registry().Count(r.Context(), storage.Criteria{Where: "status = ?", Args: []interface{}{status}})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		*n = count
+		res.Archives += count
+	}
+	rep, err := s.mgr.UsageReport(1, healthWindow, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	res.Usage = usageResponse{
+		Archives:     rep.Archives,
+		Bytes:        rep.Bytes,
+		Trashed:      rep.Trashed,
+		TrashedBytes: rep.TrashedBytes,
+		QuotaBytes:   rep.Quota.MaxBytes,
+		QuotaCount:   rep.Quota.MaxArchives,
+		MinFree:      rep.Quota.MinFree,
+		DiskFree:     rep.Free,
+		DiskTotal:    rep.Total,
+		DailyGrowth:  int64(rep.DailyGrowth),
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleSearch returns ranked results as `search query --output json` does
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := queryInt(q, "limit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	results, err := s.search.SearchScored(q.Get("q"), search.SearchOptions{
+		Field:         q.Get("field"),
+		UseRegex:      queryBool(q, "regex"),
+		CaseSensitive: queryBool(q, "case_sensitive"),
+		Fuzzy:         queryBool(q, "fuzzy"),
+		MaxResults:    limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	hits := make([]searchHit, len(results))
+	for i, res := range results {
+		hits[i] = searchHit{Archive: res.Archive, Score: res.Score}
+	}
+	writeJSON(w, http.StatusOK, hits)
+}
+
+// handleQueries lists saved queries as `query list --output json` does
+func (s *Server) handleQueries(w http.ResponseWriter, r *http.Request) {
+	queries, err := s.queries.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, queries)
+}
+
+// handleQuery returns one saved query
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	qry, err := s.queries.Get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, qry)
+}
+
+// handleRunQuery runs a saved query and pages its archives like handleList
+func (s *Server) handleRunQuery(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, err := s.queries.Get(name); err != nil {
+		writeError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	c, err := pageCriteria(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	page, err := s.queries.RunPage(r.Context(), name, c)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	writePage(w, page)
+}
+
+// resolve finds the archive named by the {id} path segment, accepting any ID
+// the CLI does, and writes the error response when there is none
+func (s *Server) resolve(w http.ResponseWriter, r *http.Request) (*storage.Archive, bool) {
+	id := r.PathValue("id")
+	a, err := s.resolver.Resolve(id)
+	if err == nil {
+		return a, true
+	}
+	var amb *storage.AmbiguousIDError
+	var notFound *storage.ArchiveNotFoundError
+	switch {
+	case errors.As(err, &amb):
+		body := apiError{Error: "archive ID '" + id + "' matches multiple archives; use a longer prefix or full UID"}
+		for _, m := range amb.Matches {
+			body.Matches = append(body.Matches, m.UID)
+		}
+		writeJSON(w, http.StatusConflict, body)
+	case errors.As(err, &notFound):
+		writeError(w, http.StatusNotFound, "archive not found: %s", id)
+	default:
+		writeError(w, http.StatusInternalServerError, "%v", err)
+	}
+	return nil, false
+}
+
+// pageCriteria reads the sort, reverse, limit, offset and after parameters
+// with the defaults of the list flags
+func pageCriteria(q url.Values) (storage.Criteria, error) {
+	sortBy := q.Get("sort")
+	if sortBy == "" {
+		sortBy = string(storage.SortCreated)
+	}
+	key, err := storage.ParseSortKey(sortBy)
+	if err != nil {
+		return storage.Criteria{}, err
+	}
+	limit, err := queryInt(q, "limit")
+	if err != nil {
+		return storage.Criteria{}, err
+	}
+	offset, err := queryInt(q, "offset")
+	if err != nil {
+		return storage.Criteria{}, err
+	}
+	return storage.Criteria{
+		Sort:      key,
+		Ascending: (key == storage.SortName) != queryBool(q, "reverse"),
+		Limit:     limit,
+		Offset:    offset,
+		After:     q.Get("after"),
+	}, nil
+}
+
+// writePage writes a page's archives, with the next page's cursor in a
+// header so the body matches the CLI's JSON
+func writePage(w http.ResponseWriter, page *storage.Page) {
+	if page.Next != "" {
+		w.Header().Set("X-Next-Cursor", page.Next)
+	}
+	archives := page.Archives
+	if archives == nil {
+		archives = []*storage.Archive{}
+	}
+	writeJSON(w, http.StatusOK, archives)
+}
+
+// queryBool reads a boolean parameter; present without a value means true
+func queryBool(q url.Values, name string) bool {
+	if !q.Has(name) {
+		return false
+	}
+	if q.Get(name) == "" {
+		return true
+	}
+	v, _ := strconv.ParseBool(q.Get(name))
+	return v
+}
+
+// queryInt reads a non-negative integer parameter, 0 when absent
+func queryInt(q url.Values, name string) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New(name + " must be a non-negative integer")
+	}
+	return n, nil
+}
+
+// searchHit is a search result as `search query --output json` writes it
+type searchHit struct {
+	*storage.Archive
+	Score float64 `json:"score"`
+}
+
+// testResponse is the result of an integrity test
+type testResponse struct {
+	UID  string `json:"uid"`
+	Path string `json:"path"`
+	*archive.TestResult
+}
+
+// verifyResponse is the result of re-hashing an archive; the checksum fields
+// match the metadata `show --verify --output json` adds
+type verifyResponse struct {
+	UID      string `json:"uid"`
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Checksum string `json:"checksum,omitempty"`
+	Computed string `json:"computed_checksum,omitempty"`
+	Verified bool   `json:"checksum_verified"`
+}
+
+// healthResponse is the registry summary of the health endpoint
+type healthResponse struct {
+	Path     string        `json:"path"`
+	Archives int           `json:"archives"`
+	Present  int           `json:"present"`
+	Missing  int           `json:"missing"`
+	Deleted  int           `json:"deleted"`
+	Usage    usageResponse `json:"usage"`
+}
+
+// usageResponse is managed storage usage as the usage command reports it;
+// zero quotas are unlimited and a zero disk total is unknown
+type usageResponse struct {
+	Archives     int    `json:"archives"`
+	Bytes        int64  `json:"bytes"`
+	Trashed      int    `json:"trashed"`
+	TrashedBytes int64  `json:"trashed_bytes"`
+	QuotaBytes   int64  `json:"quota_bytes"`
+	QuotaCount   int    `json:"quota_archives"`
+	MinFree      int64  `json:"min_free"`
+	DiskFree     uint64 `json:"disk_free"`
+	DiskTotal    uint64 `json:"disk_total"`
+	DailyGrowth  int64  `json:"daily_growth"` // bytes per day over the last 30 days
+}
@@ -0,0 +1,155 @@
+// Package server exposes the registry over a local HTTP/JSON API. Response
+// bodies use the same JSON as the CLI's --output json, so scripts and
+// dashboards can move between the two.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/query"
+	"github.com/adamstac/7zarch-go/internal/search"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Options configures the API server
+type Options struct {
+	// Token is the bearer token every request must present
+	Token string
+	// Logf receives one line per request; nil discards output
+	Logf func(format string, args ...interface{})
+}
+
+// Server serves the registry of one managed storage
+type Server struct {
+	mgr      *storage.Manager
+	resolver *storage.Resolver
+	search   *search.SearchEngine
+	queries  *query.QueryManager
+	opts     Options
+	mux      *http.ServeMux
+}
+
+// New creates an API server over mgr. A token is required; requests
+// without it are rejected.
+func New(mgr *storage.Manager, opts Options) (*Server, error) {
+	if opts.Token == "" {
+		return nil, fmt.Errorf("an API token is required")
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	resolver := storage.NewResolver(mgr.Registry())
+	s := &Server{
+		mgr:      mgr,
+		resolver: resolver,
+		search:   search.NewSearchEngine(mgr.Registry()),
+		queries:  query.NewQueryManager(mgr.Registry().DB(), resolver),
+		opts:     opts,
+		mux:      http.NewServeMux(),
+	}
+	s.routes()
+	return s, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /api/v1/archives", s.handleList)
+	s.mux.HandleFunc("GET /api/v1/archives/{id}", s.handleShow)
+	s.mux.HandleFunc("POST /api/v1/archives/{id}/test", s.handleTest)
+	s.mux.HandleFunc("POST /api/v1/archives/{id}/verify", s.handleVerify)
+	s.mux.HandleFunc("POST /api/v1/archives/{id}/trash", s.handleTrash)
+	s.mux.HandleFunc("POST /api/v1/archives/{id}/restore", s.handleRestore)
+	s.mux.HandleFunc("GET /api/v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /api/v1/search", s.handleSearch)
+	s.mux.HandleFunc("GET /api/v1/queries", s.handleQueries)
+	s.mux.HandleFunc("GET /api/v1/queries/{name}", s.handleQuery)
+	s.mux.HandleFunc("GET /api/v1/queries/{name}/archives", s.handleRunQuery)
+	s.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no such endpoint: %s %s", r.Method, r.URL.Path)
+	})
+	s.mux.Handle("/", uiHandler())
+}
+
+// Handler returns the API and web UI with request logging. API requests
+// must carry the token; the UI's static files hold no data and are served
+// to anyone, the UI then asking for the token.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if !strings.HasPrefix(r.URL.Path, "/api/") || s.authorized(r) {
+			s.mux.ServeHTTP(rec, r)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="7zarch-go"`)
+			writeError(rec, http.StatusUnauthorized, "missing or invalid API token")
+		}
+		s.opts.Logf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// authorized checks the bearer token in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+// Serve accepts connections on ln until ctx is done, then shuts down
+// gracefully
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// statusRecorder remembers the status code written for logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// apiError is the body of every error response
+type apiError struct {
+	Error   string   `json:"error"`
+	Matches []string `json:"matches,omitempty"` // UIDs of an ambiguous ID
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v) // the client may have gone away
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
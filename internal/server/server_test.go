@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/query"
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+const testToken = "s3cret"
+
+func setupServer(t *testing.T) (*httptest.Server, *storage.Manager) {
+	t.Helper()
+	mgr, err := storage.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+	srv, err := New(mgr, Options{Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts, mgr
+}
+
+// get performs an authenticated request and decodes the JSON body into v
+func get(t *testing.T, ts *httptest.Server, method, path string, v interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func TestNewRequiresToken(t *testing.T) {
+	if _, err := New(nil, Options{}); err == nil {
+		t.Fatal("expected an error without a token")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ts, _ := setupServer(t)
+	for _, header := range []string{"", "Bearer wrong", testToken} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/archives", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d", header, resp.StatusCode)
+		}
+	}
+}
+
+func TestListAndShow(t *testing.T) {
+	ts, mgr := setupServer(t)
+	base := time.Now().Add(-time.Hour)
+	var uids []string
+	for i, name := range []string{"a.7z", "b.7z", "c.7z"} {
+		a := &storage.Archive{Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name), Size: int64(100 * (i + 1)),
+			Profile: "documents", Created: base.Add(time.Duration(i) * time.Minute), Managed: true}
+		if err := mgr.Register(a); err != nil {
+			t.Fatal(err)
+		}
+		uids = append(uids, a.UID)
+	}
+
+	var page []*storage.Archive
+	resp := get(t, ts, http.MethodGet, "/api/v1/archives?limit=2", &page)
+	if resp.StatusCode != http.StatusOK || len(page) != 2 || page[0].Name != "c.7z" {
+		t.Fatalf("first page: %d %+v", resp.StatusCode, page)
+	}
+	cursor := resp.Header.Get("X-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+	resp = get(t, ts, http.MethodGet, "/api/v1/archives?limit=2&after="+cursor, &page)
+	if len(page) != 1 || page[0].Name != "a.7z" || resp.Header.Get("X-Next-Cursor") != "" {
+		t.Fatalf("second page: %+v", page)
+	}
+
+	get(t, ts, http.MethodGet, "/api/v1/archives?larger_than=150B&sort=name", &page)
+	if len(page) != 2 || page[0].Name != "b.7z" {
+		t.Errorf("filtered: %+v", page)
+	}
+	if resp := get(t, ts, http.MethodGet, "/api/v1/archives?sort=colour", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad sort: status %d", resp.StatusCode)
+	}
+
+	var a storage.Archive
+	if resp := get(t, ts, http.MethodGet, "/api/v1/archives/"+uids[1], &a); resp.StatusCode != http.StatusOK || a.Name != "b.7z" {
+		t.Errorf("show: %d %+v", resp.StatusCode, a)
+	}
+	var apiErr apiError
+	if resp := get(t, ts, http.MethodGet, "/api/v1/archives/nope", &apiErr); resp.StatusCode != http.StatusNotFound || apiErr.Error == "" {
+		t.Errorf("show missing: %d %+v", resp.StatusCode, apiErr)
+	}
+}
+
+func TestSearchAndQueries(t *testing.T) {
+	ts, mgr := setupServer(t)
+	for _, name := range []string{"podcast-001.7z", "photos.7z"} {
+		a := &storage.Archive{Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name), Created: time.Now(), Managed: true}
+		if err := mgr.Register(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var hits []searchHit
+	get(t, ts, http.MethodGet, "/api/v1/search?q=podcast", &hits)
+	if len(hits) != 1 || hits[0].Name != "podcast-001.7z" {
+		t.Errorf("search: %+v", hits)
+	}
+
+	qm := query.NewQueryManager(mgr.Registry().DB(), storage.NewResolver(mgr.Registry()))
+	if err := qm.Save("photos", map[string]string{"pattern": "photo*"}); err != nil {
+		t.Fatal(err)
+	}
+	var queries []*query.Query
+	get(t, ts, http.MethodGet, "/api/v1/queries", &queries)
+	if len(queries) != 1 || queries[0].Name != "photos" {
+		t.Errorf("queries: %+v", queries)
+	}
+	var page []*storage.Archive
+	get(t, ts, http.MethodGet, "/api/v1/queries/photos/archives", &page)
+	if len(page) != 1 || page[0].Name != "photos.7z" {
+		t.Errorf("run query: %+v", page)
+	}
+	if resp := get(t, ts, http.MethodGet, "/api/v1/queries/nope/archives", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing query: status %d", resp.StatusCode)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ts, mgr := setupServer(t)
+	path := filepath.Join(mgr.GetArchivesPath(), "v.7z")
+	if err := os.WriteFile(path, []byte("archive bytes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := storage.FileChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &storage.Archive{Name: "v.7z", Path: path, Checksum: sum, Created: time.Now(), Managed: true}
+	if err := mgr.Register(a); err != nil {
+		t.Fatal(err)
+	}
+
+	var res verifyResponse
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/verify", &res); resp.StatusCode != http.StatusOK {
+		t.Fatalf("verify: status %d", resp.StatusCode)
+	}
+	if !res.Verified || res.Computed != sum || res.Status != "present" {
+		t.Errorf("verify: %+v", res)
+	}
+
+	if err := os.WriteFile(path, []byte("corrupted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/verify", &res)
+	if res.Verified {
+		t.Errorf("corrupted archive verified: %+v", res)
+	}
+
+	if resp := get(t, ts, http.MethodGet, "/api/v1/archives/"+a.UID+"/verify", nil); resp.StatusCode == http.StatusOK {
+		t.Errorf("GET verify: status %d", resp.StatusCode)
+	}
+}
+
+func TestTestRunsPostTestHooks(t *testing.T) {
+	ts, mgr := setupServer(t)
+	path := filepath.Join(mgr.GetArchivesPath(), "broken.7z")
+	if err := os.WriteFile(path, []byte("not an archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a := &storage.Archive{Name: "broken.7z", Path: path, Size: 14, Created: time.Now(), Managed: true}
+	if err := mgr.Register(a); err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	var details map[string]string
+	mgr.SetLifecycleHook(func(event string, hooked *storage.Archive, d map[string]string) {
+		if hooked.UID == a.UID {
+			events = append(events, event)
+			details = d
+		}
+	})
+
+	var res testResponse
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/test", &res); resp.StatusCode != http.StatusOK {
+		t.Fatalf("test: status %d", resp.StatusCode)
+	}
+	if res.Passed {
+		t.Fatalf("broken archive passed: %+v", res)
+	}
+	if len(events) != 1 || events[0] != storage.LifecycleTest || details["passed"] != "false" || details["error"] == "" {
+		t.Errorf("hook events = %v, details = %v", events, details)
+	}
+}
+
+func TestTrashRestoreAndHealth(t *testing.T) {
+	ts, mgr := setupServer(t)
+	path := filepath.Join(mgr.GetArchivesPath(), "t.7z")
+	if err := os.WriteFile(path, []byte("archive bytes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a := &storage.Archive{Name: "t.7z", Path: path, Size: 13, Created: time.Now(), Managed: true}
+	if err := mgr.Register(a); err != nil {
+		t.Fatal(err)
+	}
+
+	var got storage.Archive
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/trash", &got); resp.StatusCode != http.StatusOK {
+		t.Fatalf("trash: status %d", resp.StatusCode)
+	}
+	if got.Status != "deleted" || filepath.Dir(got.Path) != mgr.GetTrashPath() {
+		t.Errorf("trashed archive: %+v", got)
+	}
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/trash", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("trash twice: status %d", resp.StatusCode)
+	}
+
+	var h healthResponse
+	get(t, ts, http.MethodGet, "/api/v1/health", &h)
+	if h.Archives != 1 || h.Deleted != 1 || h.Usage.Trashed != 1 || h.Usage.TrashedBytes != 13 {
+		t.Errorf("health: %+v", h)
+	}
+
+	// A file at the original path is only replaced with force
+	if err := os.WriteFile(path, []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/restore", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("restore over a file: status %d", resp.StatusCode)
+	}
+	if resp := get(t, ts, http.MethodPost, "/api/v1/archives/"+a.UID+"/restore?force", &got); resp.StatusCode != http.StatusOK {
+		t.Fatalf("restore: status %d", resp.StatusCode)
+	}
+	if got.Status != "present" || got.Path != path {
+		t.Errorf("restored archive: %+v", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "archive bytes" {
+		t.Errorf("restored file holds %q", data)
+	}
+}
+
+func TestUI(t *testing.T) {
+	ts, _ := setupServer(t)
+	for _, path := range []string{"/", "/app.js", "/app.css"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(body) == 0 {
+			t.Errorf("GET %s without a token: status %d", path, resp.StatusCode)
+		}
+		// Everything is served from the binary so the UI works offline
+		if bytes.Contains(body, []byte("src=\"http")) || bytes.Contains(body, []byte("href=\"http")) {
+			t.Errorf("%s loads an external asset", path)
+		}
+	}
+	if resp := get(t, ts, http.MethodGet, "/api/v1/nope", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown endpoint: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,405 @@
+// 7zarch-go web UI: browses the registry through the /api/v1 endpoints of
+// `7zarch-go serve`. No framework or external assets, so it works offline.
+"use strict";
+
+(function () {
+  const tokenKey = "7zarch-go.token";
+  const pageSize = 100;
+  const searchLimit = 500;
+
+  const $ = (id) => document.getElementById(id);
+
+  const state = {
+    token: localStorage.getItem(tokenKey) || "",
+    archives: [],
+    next: "",
+    selected: null,
+    searchTimer: 0,
+    generation: 0, // discards responses to superseded loads
+  };
+
+  // --- API -----------------------------------------------------------------
+
+  class APIError extends Error {
+    constructor(status, message) {
+      super(message);
+      this.status = status;
+    }
+  }
+
+  async function api(method, path, params) {
+    const url = new URL("api/v1/" + path, document.baseURI);
+    for (const [k, v] of Object.entries(params || {})) {
+      if (v !== "" && v !== undefined && v !== null && v !== false) {
+        url.searchParams.set(k, v === true ? "true" : v);
+      }
+    }
+    const resp = await fetch(url, {
+      method,
+      headers: { Authorization: "Bearer " + state.token },
+    });
+    const body = await resp.json().catch(() => ({ error: resp.statusText }));
+    if (resp.status === 401) {
+      signOut("The token was not accepted.");
+      throw new APIError(401, body.error);
+    }
+    if (!resp.ok) {
+      throw new APIError(resp.status, body.error || resp.statusText);
+    }
+    return { body, next: resp.headers.get("X-Next-Cursor") || "" };
+  }
+
+  // --- Formatting ----------------------------------------------------------
+
+  function formatSize(bytes) {
+    const units = ["B", "KB", "MB", "GB", "TB", "PB"];
+    let n = Number(bytes) || 0;
+    let i = 0;
+    while (n >= 1024 && i < units.length - 1) {
+      n /= 1024;
+      i++;
+    }
+    return i === 0 ? n + " B" : n.toFixed(1) + " " + units[i];
+  }
+
+  function formatDate(s) {
+    if (!s) return "";
+    const d = new Date(s);
+    return isNaN(d) ? s : d.toLocaleString();
+  }
+
+  const statusLabels = { present: "OK", missing: "MISS", deleted: "TRASH" };
+
+  function el(tag, props, ...children) {
+    const node = document.createElement(tag);
+    Object.assign(node, props || {});
+    for (const c of children) {
+      node.append(c instanceof Node ? c : String(c));
+    }
+    return node;
+  }
+
+  function showError(err) {
+    const banner = $("error");
+    banner.textContent = err instanceof Error ? err.message : String(err);
+    banner.hidden = false;
+    clearTimeout(showError.timer);
+    showError.timer = setTimeout(() => (banner.hidden = true), 6000);
+  }
+
+  // --- Sign in -------------------------------------------------------------
+
+  function signIn(token) {
+    state.token = token;
+    localStorage.setItem(tokenKey, token);
+    $("login").hidden = true;
+    $("app").hidden = false;
+    loadHealth();
+    loadArchives();
+  }
+
+  function signOut(message) {
+    state.token = "";
+    localStorage.removeItem(tokenKey);
+    $("app").hidden = true;
+    $("login").hidden = false;
+    $("login-error").textContent = message || "";
+    $("login-error").hidden = !message;
+    $("login-token").focus();
+  }
+
+  // --- Health --------------------------------------------------------------
+
+  function bar(used, of) {
+    const pct = Math.min(100, Math.round((used / of) * 100));
+    const fill = el("span");
+    fill.style.width = pct + "%";
+    return el("span", { className: "bar" + (pct >= 90 ? " full" : ""), title: pct + "%" }, fill);
+  }
+
+  function stat(label, value, extra) {
+    const node = el("span", { className: "stat" }, el("b", {}, value), label);
+    if (extra) node.append(extra);
+    return node;
+  }
+
+  async function loadHealth() {
+    try {
+      const { body: h } = await api("GET", "health");
+      $("vault-path").textContent = h.path;
+      const u = h.usage;
+      const items = [
+        stat("archives", h.archives),
+        stat("present", h.present),
+        stat("missing", h.missing),
+        stat("in trash", h.deleted),
+      ];
+      let size = formatSize(u.bytes);
+      if (u.quota_bytes > 0) size += " of " + formatSize(u.quota_bytes);
+      items.push(stat("managed", size, u.quota_bytes > 0 ? bar(u.bytes, u.quota_bytes) : null));
+      if (u.quota_archives > 0) {
+        items.push(stat("archive quota", u.archives + " of " + u.quota_archives, bar(u.archives, u.quota_archives)));
+      }
+      if (u.disk_total > 0) {
+        items.push(stat("disk free", formatSize(u.disk_free), bar(u.disk_total - u.disk_free, u.disk_total)));
+      }
+      items.push(stat("per day", "+" + formatSize(u.daily_growth)));
+      $("health").replaceChildren(...items);
+    } catch (err) {
+      if (err.status !== 401) showError(err);
+    }
+  }
+
+  // --- Archive list --------------------------------------------------------
+
+  function listParams() {
+    const params = { sort: $("sort").value };
+    const status = $("status").value;
+    if (status) params.status = status;
+    const location = $("location").value;
+    if (location) params[location] = true;
+    return params;
+  }
+
+  // matchesFilters applies the status and location filters to search hits,
+  // which the search endpoint does not filter
+  function matchesFilters(a) {
+    const status = $("status").value;
+    const location = $("location").value;
+    if (status && a.status !== status) return false;
+    if (location === "managed" && !a.managed) return false;
+    if (location === "external" && a.managed) return false;
+    return true;
+  }
+
+  async function loadArchives(more) {
+    const generation = ++state.generation;
+    const q = $("search").value.trim();
+    try {
+      let archives, next = "";
+      if (q) {
+        const { body } = await api("GET", "search", { q, limit: searchLimit });
+        archives = body.filter(matchesFilters);
+      } else {
+        const params = Object.assign(listParams(), { limit: pageSize });
+        if (more) params.after = state.next;
+        const resp = await api("GET", "archives", params);
+        archives = resp.body;
+        next = resp.next;
+      }
+      if (generation !== state.generation) return;
+      state.archives = more ? state.archives.concat(archives) : archives;
+      state.next = next;
+      renderRows();
+    } catch (err) {
+      if (err.status !== 401) showError(err);
+    }
+  }
+
+  function renderRows() {
+    const rows = state.archives.map((a) => {
+      const status = el("td", { className: "status-" + a.status }, statusLabels[a.status] || a.status);
+      const tr = el("tr", {},
+        el("td", { className: "name" }, a.name),
+        el("td", { className: "num" }, formatSize(a.size)),
+        el("td", {}, a.profile || ""),
+        el("td", {}, formatDate(a.created)),
+        status,
+        el("td", {}, a.managed ? "managed" : "external"));
+      tr.dataset.uid = a.uid;
+      if (state.selected && state.selected.uid === a.uid) tr.classList.add("selected");
+      tr.addEventListener("click", () => select(a.uid));
+      return tr;
+    });
+    $("rows").replaceChildren(...rows);
+    $("empty").hidden = state.archives.length > 0;
+    $("more").hidden = !state.next;
+  }
+
+  // --- Detail --------------------------------------------------------------
+
+  async function select(uid) {
+    try {
+      const { body } = await api("GET", "archives/" + encodeURIComponent(uid));
+      state.selected = body;
+      $("detail-result").hidden = true;
+      renderDetail();
+      renderRows();
+    } catch (err) {
+      if (err.status !== 401) showError(err);
+    }
+  }
+
+  function renderDetail() {
+    const a = state.selected;
+    if (!a) {
+      $("detail").hidden = true;
+      return;
+    }
+    $("detail").hidden = false;
+    $("detail-name").textContent = a.name;
+
+    const fields = [
+      ["UID", a.uid, "mono"],
+      ["Status", statusLabels[a.status] || a.status, "status-" + a.status],
+      ["Location", a.managed ? "managed" : "external"],
+      ["Path", a.path, "mono"],
+      ["Size", formatSize(a.size)],
+      ["Source size", a.source_size ? formatSize(a.source_size) : ""],
+      ["Profile", a.profile],
+      ["Created", formatDate(a.created)],
+      ["Last seen", formatDate(a.last_seen)],
+      ["Checksum", a.checksum, "mono"],
+      ["Source", a.source_path, "mono"],
+      ["Uploaded", a.uploaded ? (a.destination || "yes") + (a.uploaded_at ? " at " + formatDate(a.uploaded_at) : "") : "no"],
+      ["Deleted", formatDate(a.deleted_at)],
+      ["Original path", a.original_path, "mono"],
+      ["Tags", (a.tags || []).join(", ")],
+    ];
+    for (const [key, value] of Object.entries(a.fields || {})) {
+      fields.push([key, String(value)]);
+    }
+
+    const items = [];
+    for (const [label, value, className] of fields) {
+      if (value === "" || value === undefined || value === null) continue;
+      items.push(el("dt", {}, label), el("dd", { className: className || "" }, value));
+    }
+    $("detail-fields").replaceChildren(...items);
+
+    const deleted = a.status === "deleted";
+    $("action-trash").hidden = deleted;
+    $("action-restore").hidden = !deleted;
+    $("action-test").disabled = a.status !== "present";
+    $("action-verify").disabled = !a.checksum || deleted;
+  }
+
+  function showResult(text, ok) {
+    const out = $("detail-result");
+    out.textContent = text;
+    out.className = "result " + (ok ? "ok" : "fail");
+    out.hidden = false;
+  }
+
+  // run performs an action on the selected archive, disabling the buttons
+  // while it runs
+  async function run(label, fn) {
+    const buttons = document.querySelectorAll(".actions button");
+    buttons.forEach((b) => (b.disabled = true));
+    showResult(label + "…", true);
+    try {
+      await fn(state.selected);
+    } catch (err) {
+      if (err.status !== 401) showResult(err.message, false);
+    } finally {
+      buttons.forEach((b) => (b.disabled = false));
+      renderDetail();
+    }
+  }
+
+  async function testArchive(a) {
+    const { body: r } = await api("POST", "archives/" + encodeURIComponent(a.uid) + "/test");
+    const lines = [r.passed ? "✓ Integrity test passed" : "✗ Integrity test failed"];
+    if (r.files_verified) lines.push(r.files_verified + " files verified");
+    if (r.duration_ns) lines.push("in " + (r.duration_ns / 1e9).toFixed(1) + "s");
+    for (const e of r.errors || []) lines.push(e);
+    showResult(lines.join("\n"), r.passed);
+  }
+
+  async function verifyArchive(a) {
+    const { body: r } = await api("POST", "archives/" + encodeURIComponent(a.uid) + "/verify");
+    if (r.status !== "present") {
+      showResult("✗ File is missing: " + r.path, false);
+    } else if (r.checksum_verified) {
+      showResult("✓ Checksum matches\n" + r.computed_checksum, true);
+    } else {
+      showResult("✗ Checksum mismatch\nexpected " + r.checksum + "\ncomputed " + r.computed_checksum, false);
+    }
+    await refresh(a.uid);
+  }
+
+  async function trashArchive(a) {
+    if (!confirm("Move " + a.name + " to trash?")) {
+      $("detail-result").hidden = true;
+      return;
+    }
+    await api("POST", "archives/" + encodeURIComponent(a.uid) + "/trash");
+    showResult("Moved to trash", true);
+    await refresh(a.uid);
+  }
+
+  async function restoreArchive(a) {
+    try {
+      await api("POST", "archives/" + encodeURIComponent(a.uid) + "/restore");
+    } catch (err) {
+      if (err.status !== 409 || !err.message.includes("already exists") ||
+          !confirm(err.message + "\n\nOverwrite it?")) {
+        throw err;
+      }
+      await api("POST", "archives/" + encodeURIComponent(a.uid) + "/restore", { force: true });
+    }
+    showResult("Restored", true);
+    await refresh(a.uid);
+  }
+
+  // refresh reloads the selected archive, the list and health after a change
+  async function refresh(uid) {
+    const { body } = await api("GET", "archives/" + encodeURIComponent(uid));
+    state.selected = body;
+    await Promise.all([loadArchives(), loadHealth()]);
+  }
+
+  // --- Wiring --------------------------------------------------------------
+
+  $("login-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    signIn($("login-token").value.trim());
+  });
+  $("sign-out").addEventListener("click", () => signOut());
+  $("search").addEventListener("input", () => {
+    clearTimeout(state.searchTimer);
+    state.searchTimer = setTimeout(() => loadArchives(), 250);
+  });
+  for (const id of ["status", "location", "sort"]) {
+    $(id).addEventListener("change", () => loadArchives());
+  }
+  $("more").addEventListener("click", () => loadArchives(true));
+  $("detail-close").addEventListener("click", () => {
+    state.selected = null;
+    renderDetail();
+    renderRows();
+  });
+  $("action-test").addEventListener("click", () => run("Testing", testArchive));
+  $("action-verify").addEventListener("click", () => run("Verifying", verifyArchive));
+  $("action-trash").addEventListener("click", () => run("Moving to trash", trashArchive));
+  $("action-restore").addEventListener("click", () => run("Restoring", restoreArchive));
+
+  document.addEventListener("keydown", (e) => {
+    if (e.target.matches("input, select")) {
+      if (e.key === "Escape") e.target.blur();
+      return;
+    }
+    if (e.key === "/") {
+      e.preventDefault();
+      $("search").focus();
+    } else if (e.key === "Escape" && state.selected) {
+      $("detail-close").click();
+    } else if ((e.key === "j" || e.key === "k") && state.archives.length) {
+      const i = state.archives.findIndex((a) => state.selected && a.uid === state.selected.uid);
+      const next = Math.max(0, Math.min(state.archives.length - 1, i + (e.key === "j" ? 1 : -1)));
+      select(state.archives[next].uid);
+    }
+  });
+
+  // A token in the URL fragment (#token=...) signs in without typing it; the
+  // fragment is never sent to the server and is removed from the address bar
+  const fromHash = new URLSearchParams(location.hash.slice(1)).get("token");
+  if (fromHash) {
+    history.replaceState(null, "", location.pathname + location.search);
+    signIn(fromHash);
+  } else if (state.token) {
+    signIn(state.token);
+  } else {
+    signOut();
+  }
+})();
@@ -23,6 +23,22 @@ type Archive struct {
 	Destination  string     `json:"destination,omitempty"` // where it was uploaded
 	UploadedAt   *time.Time `json:"uploaded_at,omitempty"`
 	Metadata     string     `json:"metadata,omitempty"` // JSON blob for extensibility
+
+	// Lineage: archives created from the same source are versions of one backup
+	SourcePath        string `json:"source_path,omitempty"`
+	SourceFingerprint string `json:"source_fingerprint,omitempty"` // hash of the source tree's names, sizes and mtimes
+	SourceSize        int64  `json:"source_size,omitempty"`        // uncompressed bytes archived, 0 when unknown
+
+	// Tags are free-form labels (e.g. client:acme) stored in archive_tags
+	Tags []string `json:"tags,omitempty"`
+
+	// Fields are typed custom metadata stored in archive_meta; unlike the
+	// Metadata blob they can be filtered with --where
+	Fields map[string]Field `json:"fields,omitempty"`
+
+	// Vault names the vault an archive was read from when several are listed
+	// together; it is not stored in the registry
+	Vault string `json:"vault,omitempty"`
 }
 
 // IsManaged returns true if this archive is in managed storage
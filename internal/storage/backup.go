@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backups are made with VACUUM INTO, which copies the registry inside one
+// read transaction. A backup taken while another process writes is a
+// consistent snapshot rather than a torn copy of the file.
+
+const (
+	backupPrefix = "registry-"
+	backupExt    = ".bak"
+)
+
+// Backup is a registry backup file
+type Backup struct {
+	Path    string
+	Created time.Time
+	Size    int64
+}
+
+// BackupDirFor returns the backup directory for managed storage at basePath:
+// dir when set, otherwise basePath/backups
+func BackupDirFor(basePath, dir string) (string, error) {
+	if dir != "" {
+		return expandHome(dir)
+	}
+	basePath, err := expandHome(basePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(basePath, "backups"), nil
+}
+
+// BackupDir returns where registry backups are kept
+func (m *Manager) BackupDir() string { return m.backupDir }
+
+// SetBackupDir changes where registry backups are kept
+func (m *Manager) SetBackupDir(dir string) {
+	if dir, err := expandHome(dir); err == nil && dir != "" {
+		m.backupDir = dir
+	}
+}
+
+// Backup writes a timestamped backup of the registry to the backup directory
+// and returns its path
+func (m *Manager) Backup() (string, error) {
+	return createBackup(m.registry.db, m.backupDir)
+}
+
+// BackupIfDue backs up the registry when the newest backup is older than
+// interval, then removes all but the newest keep backups. It returns the new
+// backup's path, or "" when none was due.
+func (m *Manager) BackupIfDue(interval time.Duration, keep int) (string, error) {
+	backups, err := ListBackups(m.backupDir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) > 0 && time.Since(backups[0].Created) < interval {
+		return "", nil
+	}
+	path, err := m.Backup()
+	if err != nil {
+		return "", err
+	}
+	if _, err := PruneBackups(m.backupDir, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// createBackup writes a backup of db named for the current time into dir
+func createBackup(db *sql.DB, dir string) (string, error) {
+	name := backupPrefix + time.Now().Format("20060102-150405")
+	path := filepath.Join(dir, name+backupExt)
+	for i := 2; fileExists(path); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, backupExt))
+	}
+	if err := backupDB(db, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// backupDB writes a consistent copy of db to path, which must not exist
+func backupDB(db *sql.DB, path string) error {
+	// #nosec G301: backups hold the registry; restrict permissions
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if fileExists(path) {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up registry: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to set backup permissions: %w", err)
+	}
+	return nil
+}
+
+// ListBackups returns the registry backups in dir, newest first
+func ListBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	var backups []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, name), Created: info.ModTime(), Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Created.Equal(backups[j].Created) {
+			return backups[i].Created.After(backups[j].Created)
+		}
+		return backups[i].Path > backups[j].Path
+	})
+	return backups, nil
+}
+
+// PruneBackups deletes all but the newest keep backups in dir and returns
+// the removed paths
+func PruneBackups(dir string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("backups to keep must be at least 1")
+	}
+	backups, err := ListBackups(dir)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+	var removed []string
+	for _, b := range backups[keep:] {
+		if err := os.Remove(b.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %s: %w", b.Path, err)
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
+
+// VerifyBackup checks that path is an intact SQLite database holding a
+// registry
+func VerifyBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("backup not found: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("backup is not a readable database: %w", err)
+	}
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to check backup: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup failed integrity check: %s", strings.Join(problems, "; "))
+	}
+	if !tableExists(db, "archives") {
+		return fmt.Errorf("backup does not contain a registry")
+	}
+	return nil
+}
+
+// RestoreBackup replaces the registry at dbPath with the backup at path once
+// the backup passes VerifyBackup. The registry being replaced is first backed
+// up into dir; its path is returned. The restored registry is migrated to the
+// current schema the next time it is opened.
+func RestoreBackup(path, dbPath, dir string) (string, error) {
+	if err := VerifyBackup(path); err != nil {
+		return "", err
+	}
+
+	var saved string
+	if fileExists(dbPath) {
+		db, err := openDB(dbPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to open registry: %w", err)
+		}
+		saved, err = createBackup(db, dir)
+		db.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to back up current registry: %w", err)
+		}
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyBackup(path, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return saved, err
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		_ = os.Remove(tmp)
+		return saved, fmt.Errorf("failed to replace registry: %w", err)
+	}
+	// Journals of the replaced database must not be applied to the restored one
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return saved, fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	return saved, nil
+}
+
+func copyBackup(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to sync %s: %w", dst, err)
+	}
+	return out.Close()
+}
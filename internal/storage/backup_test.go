@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackupWhileWriting(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+
+	// Another connection keeps writing while backups are taken
+	writer, err := NewRegistry(mgr.Registry().Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			a := &Archive{UID: generateUID(), Name: "busy.7z", Path: "/tmp/busy.7z", Size: int64(i), Created: time.Now(), Status: "present"}
+			_ = writer.Add(a)
+		}
+	}()
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := mgr.Backup()
+		if err != nil {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("backup %d: %v", i, err)
+		}
+		paths = append(paths, path)
+	}
+	close(stop)
+	wg.Wait()
+
+	for _, p := range paths {
+		if err := VerifyBackup(p); err != nil {
+			t.Errorf("backup %s: %v", p, err)
+		}
+	}
+	if info, err := os.Stat(paths[0]); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("backup mode = %v, %v", info.Mode().Perm(), err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+
+	if path, err := mgr.BackupIfDue(time.Hour, 2); err != nil || path == "" {
+		t.Fatalf("first backup = %q, %v", path, err)
+	}
+	if path, err := mgr.BackupIfDue(time.Hour, 2); err != nil || path != "" {
+		t.Fatalf("backup taken before it was due: %q, %v", path, err)
+	}
+
+	// Age the existing backup so the next one is due
+	backups, _ := ListBackups(mgr.BackupDir())
+	old := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(backups[0].Path, old, old)
+	for i := 0; i < 3; i++ {
+		if _, err := mgr.Backup(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := PruneBackups(mgr.BackupDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[len(removed)-1] != backups[0].Path {
+		t.Fatalf("removed = %v, want two including the oldest %s", removed, backups[0].Path)
+	}
+	if left, _ := ListBackups(mgr.BackupDir()); len(left) != 2 {
+		t.Fatalf("%d backups left, want 2", len(left))
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	base := t.TempDir()
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := &Archive{UID: generateUID(), Name: "keep.7z", Path: "/tmp/keep.7z", Size: 1, Created: time.Now(), Status: "present"}
+	if err := mgr.Registry().Add(keep); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := mgr.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := &Archive{UID: generateUID(), Name: "later.7z", Path: "/tmp/later.7z", Size: 1, Created: time.Now(), Status: "present"}
+	if err := mgr.Registry().Add(later); err != nil {
+		t.Fatal(err)
+	}
+	dbPath, dir := mgr.Registry().Path(), mgr.BackupDir()
+	mgr.Close()
+
+	saved, err := RestoreBackup(backup, dbPath, dir)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if saved == "" || VerifyBackup(saved) != nil {
+		t.Fatalf("previous registry not saved: %q", saved)
+	}
+
+	mgr, err = NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	if _, err := mgr.Registry().Get("keep.7z"); err != nil {
+		t.Errorf("restored registry lost keep.7z: %v", err)
+	}
+	if _, err := mgr.Registry().Get("later.7z"); err == nil {
+		t.Error("restored registry has an archive added after the backup")
+	}
+}
+
+func TestVerifyBackupRejectsDamagedFiles(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	backup, err := mgr.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.bak")
+	if err := os.WriteFile(truncated, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(t.TempDir(), "garbage.bak")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{truncated, garbage, filepath.Join(t.TempDir(), "missing.bak")} {
+		if err := VerifyBackup(p); err == nil {
+			t.Errorf("VerifyBackup(%s) passed", filepath.Base(p))
+		}
+		if _, err := RestoreBackup(p, mgr.Registry().Path(), mgr.BackupDir()); err == nil {
+			t.Errorf("RestoreBackup(%s) passed", filepath.Base(p))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -474,3 +475,118 @@ func BenchmarkMemoryUsage(b *testing.B) {
 		}
 	})
 }
+
+// populateBenchmarkRegistry bulk-inserts n archives in one transaction
+func populateBenchmarkRegistry(b *testing.B, registry *Registry, n int) {
+	b.Helper()
+	tx, err := registry.db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO archives (uid, name, path, size, created, profile, managed, status, uploaded) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		_, err := stmt.Exec(
+			generateUID(),
+			fmt.Sprintf("find-bench-%06d.7z", i),
+			fmt.Sprintf("/bench/find-bench-%06d.7z", i),
+			int64(1024*(i%5000+1)),
+			now.Add(-time.Duration(i)*time.Minute),
+			[]string{"balanced", "media", "documents"}[i%3],
+			i%2 == 0,
+			"present",
+			i%4 == 0,
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	_ = stmt.Close()
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkFind compares loading everything and filtering in Go with
+// pushing filters and paging into SQL
+func BenchmarkFind(b *testing.B) {
+	if testing.Short() {
+		b.Skip("Skipping find benchmarks in short mode")
+	}
+
+	for _, size := range []int{1000, 10000} {
+		registry, tempDir := setupTestRegistry(&testing.T{})
+		populateBenchmarkRegistry(b, registry, size)
+		ctx := context.Background()
+		media := Criteria{Where: "profile = ? AND uploaded = 0", Args: []interface{}{"media"}}
+
+		b.Run(fmt.Sprintf("ListAndFilter%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				archives, err := registry.List()
+				if err != nil {
+					b.Fatal(err)
+				}
+				var page []*Archive
+				for _, a := range archives {
+					if a.Profile == "media" && !a.Uploaded {
+						page = append(page, a)
+						if len(page) == 50 {
+							break
+						}
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("FindFirstPage%d", size), func(b *testing.B) {
+			c := media
+			c.Limit = 50
+			for i := 0; i < b.N; i++ {
+				if _, err := registry.Find(ctx, c); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("FindDeepOffset%d", size), func(b *testing.B) {
+			c := media
+			c.Limit, c.Offset = 50, size/4
+			for i := 0; i < b.N; i++ {
+				if _, err := registry.Find(ctx, c); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("FindKeysetWalk%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c := media
+				c.Limit = 500
+				for {
+					page, err := registry.Find(ctx, c)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if page.Next == "" {
+						break
+					}
+					c.After = page.Next
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Count%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := registry.Count(ctx, media); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		registry.Close()
+		os.RemoveAll(tempDir)
+	}
+}
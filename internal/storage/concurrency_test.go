@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const stressRounds = 40
+
+// TestConcurrentAccessHelper is run as a child process by
+// TestConcurrentAccess. It creates, lists and deletes archives in the
+// registry at STRESS_BASE, keeping the even-numbered ones.
+func TestConcurrentAccessHelper(t *testing.T) {
+	worker := os.Getenv("STRESS_WORKER")
+	if worker == "" {
+		t.Skip("helper process")
+	}
+	if err := stressWorker(os.Getenv("STRESS_BASE"), "proc"+worker); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	base := t.TempDir()
+	seed, err := NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed.Close()
+
+	const procs, goroutines = 3, 3
+	var wg sync.WaitGroup
+	errs := make(chan error, procs+goroutines)
+	for i := 0; i < procs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			child := exec.Command(os.Args[0], "-test.run=^TestConcurrentAccessHelper$")
+			child.Env = append(os.Environ(), "STRESS_WORKER="+strconv.Itoa(i), "STRESS_BASE="+base)
+			if out, err := child.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("process %d: %v\n%s", i, err, out)
+			}
+		}(i)
+	}
+	// Goroutines in this process each open their own manager, as separate
+	// commands would
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := stressWorker(base, "goroutine"+strconv.Itoa(i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	archives, err := mgr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (procs + goroutines) * stressRounds / 2; len(archives) != want {
+		t.Errorf("%d archives left, want %d", len(archives), want)
+	}
+	for _, a := range archives {
+		if n, _ := strconv.Atoi(strings.TrimSuffix(a.Name[strings.LastIndex(a.Name, "-")+1:], ".7z")); n%2 != 0 {
+			t.Errorf("%s should have been deleted", a.Name)
+		}
+	}
+	var check string
+	if err := mgr.Registry().DB().QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Errorf("integrity check = %q, %v", check, err)
+	}
+}
+
+func stressWorker(base, name string) error {
+	mgr, err := NewManager(base)
+	if err != nil {
+		return fmt.Errorf("%s: open: %w", name, err)
+	}
+	defer mgr.Close()
+	for i := 0; i < stressRounds; i++ {
+		archive := fmt.Sprintf("%s-%d.7z", name, i)
+		if err := mgr.Add(archive, "/stress/"+archive, int64(i), "balanced", "", "", true); err != nil {
+			return fmt.Errorf("%s: create %s: %w", name, archive, err)
+		}
+		if _, err := mgr.List(); err != nil {
+			return fmt.Errorf("%s: list: %w", name, err)
+		}
+		if i%2 == 1 {
+			if err := mgr.Delete(archive); err != nil {
+				return fmt.Errorf("%s: delete %s: %w", name, archive, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package storage
+
+import "syscall"
+
+// DiskSpace returns the bytes available to this user and the total size of
+// the filesystem holding path
+func DiskSpace(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	// Field types differ between platforms
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
+
+// sameFilesystem reports whether two existing paths are on one filesystem,
+// so a move between them is a rename that needs no free space
+func sameFilesystem(a, b string) bool {
+	var sa, sb syscall.Stat_t
+	if syscall.Stat(a, &sa) != nil || syscall.Stat(b, &sb) != nil {
+		return false
+	}
+	return sa.Dev == sb.Dev
+}
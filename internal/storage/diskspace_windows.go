@@ -0,0 +1,29 @@
+//go:build windows
+
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// DiskSpace returns the bytes available to this user and the total size of
+// the volume holding path
+func DiskSpace(path string) (free, total uint64, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, nil); err != nil {
+		return 0, 0, err
+	}
+	return free, total, nil
+}
+
+// sameFilesystem reports whether two paths are on one volume, so a move
+// between them is a rename that needs no free space
+func sameFilesystem(a, b string) bool {
+	return strings.EqualFold(filepath.VolumeName(a), filepath.VolumeName(b))
+}
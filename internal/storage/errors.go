@@ -61,6 +61,70 @@ func (e *RegistryError) Error() string {
 		e.Operation, e.Cause)
 }
 
+// LockedError indicates another process holds the registry's exclusive lock
+type LockedError struct {
+	Path      string
+	PID       int
+	Host      string
+	Operation string
+	Since     time.Time
+}
+
+func (e *LockedError) Error() string {
+	holder := "another 7zarch-go process"
+	if e.PID > 0 {
+		holder = fmt.Sprintf("7zarch-go (pid %d on %s", e.PID, e.Host)
+		if e.Operation != "" {
+			holder += fmt.Sprintf(", running '%s'", e.Operation)
+		}
+		if !e.Since.IsZero() {
+			holder += fmt.Sprintf(" since %s", e.Since.Format("2006-01-02 15:04:05"))
+		}
+		holder += ")"
+	}
+	return fmt.Sprintf("Registry is locked by %s.\n💡 Wait for it to finish; if that process is gone, remove %s", holder, e.Path)
+}
+
+// QuotaExceededError indicates an archive would take managed storage past
+// its configured quota
+type QuotaExceededError struct {
+	Path     string // managed storage base path
+	Archives int    // archives held, when the archive count quota is exceeded
+	Bytes    int64  // bytes held, when the size quota is exceeded
+	Adding   int64
+	Quota    Quota
+}
+
+func (e *QuotaExceededError) Error() string {
+	var detail string
+	if e.Quota.MaxArchives > 0 && e.Archives >= e.Quota.MaxArchives {
+		detail = fmt.Sprintf("it already holds %d of %d archives", e.Archives, e.Quota.MaxArchives)
+	} else {
+		detail = fmt.Sprintf("%s used + %s would exceed the %s quota",
+			humanizeSize(e.Bytes), humanizeSize(e.Adding), humanizeSize(e.Quota.MaxBytes))
+	}
+	return fmt.Sprintf("Managed storage at %s is over quota: %s.\n💡 Free space with 'trash purge' or 'prune', raise the quota, or choose another --vault",
+		e.Path, detail)
+}
+
+// InsufficientSpaceError indicates the filesystem cannot take an archive
+// while keeping the configured free space
+type InsufficientSpaceError struct {
+	Path    string
+	Need    int64 // estimated archive size
+	Reserve int64 // space that must stay free
+	Free    uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	need := humanizeSize(e.Need)
+	if e.Reserve > 0 {
+		need += fmt.Sprintf(" plus %s kept free", humanizeSize(e.Reserve))
+	}
+	return fmt.Sprintf("Not enough disk space at %s: need about %s, %s available.\n💡 Free disk space or write the archive elsewhere with --output",
+		e.Path, need, humanizeSize(int64(e.Free)))
+}
+
 // FileVerificationError indicates archive file issues
 type FileVerificationError struct {
 	Archive *Archive
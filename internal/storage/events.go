@@ -0,0 +1,493 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event actions recorded in the journal
+const (
+	ActionAdd     = "add"
+	ActionUpdate  = "update"
+	ActionMove    = "move"
+	ActionDelete  = "delete"  // moved to trash
+	ActionRestore = "restore" // brought back from trash
+	ActionRemove  = "remove"  // row removed from the registry
+	ActionTag     = "tag"
+	ActionUntag   = "untag"
+	ActionMeta    = "meta"
+	ActionUndo    = "undo"
+)
+
+// Event is one journaled change to an archive with the archive's state
+// before and after it. Before is nil for additions, After for removals.
+type Event struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Actor       string    `json:"actor"`             // user@host
+	Command     string    `json:"command,omitempty"` // command line that made the change
+	Action      string    `json:"action"`
+	ArchiveID   int64     `json:"archive_id"`
+	ArchiveUID  string    `json:"archive_uid"`
+	ArchiveName string    `json:"archive_name"`
+	Before      *Archive  `json:"before,omitempty"`
+	After       *Archive  `json:"after,omitempty"`
+	Undoes      int64     `json:"undoes,omitempty"`    // event reverted by this one
+	UndoneBy    int64     `json:"undone_by,omitempty"` // later event reverting this one
+}
+
+// EventFilter selects events; the zero value selects every event
+type EventFilter struct {
+	ArchiveID int64
+	Action    string
+	Since     time.Time
+	Limit     int // 0 means no limit
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var (
+	eventActor = sync.OnceValue(func() string {
+		name := os.Getenv("USER")
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		if host, err := os.Hostname(); err == nil {
+			return name + "@" + host
+		}
+		return name
+	})
+	eventCommand = sync.OnceValue(func() string {
+		if len(os.Args) == 0 {
+			return ""
+		}
+		return strings.Join(append([]string{filepath.Base(os.Args[0])}, redactArgs(os.Args[1:])...), " ")
+	})
+)
+
+// secretFlag matches flags whose values must not be journaled, such as
+// serve --token or an --api-key
+var secretFlag = regexp.MustCompile(`^--?([a-z0-9]+-)*(token|password|passwd|secret|key)$`)
+
+// redactArgs replaces the values of secret-bearing flags with "***", whether
+// given as --token=value or --token value. Arguments after "--" are
+// positional and kept.
+func redactArgs(args []string) []string {
+	out := append([]string(nil), args...)
+	for i := 0; i < len(out); i++ {
+		if out[i] == "--" {
+			break
+		}
+		name, _, hasValue := strings.Cut(out[i], "=")
+		if !secretFlag.MatchString(strings.ToLower(name)) {
+			continue
+		}
+		if hasValue {
+			out[i] = name + "=***"
+		} else if i+1 < len(out) {
+			i++
+			out[i] = "***"
+		}
+	}
+	return out
+}
+
+// mutate runs fn in a transaction and journals its effect on the archive fn
+// returns. id is the archive about to change, 0 for a new one; an empty
+// action is classified from the change.
+func (r *Registry) mutate(id int64, action string, fn func(tx *sql.Tx) (int64, error)) error {
+	return r.mutateEvent(id, &Event{Action: action}, fn)
+}
+
+// mutateEvent is mutate with a prepared event; its ID is set once recorded
+func (r *Registry) mutateEvent(id int64, ev *Event, fn func(tx *sql.Tx) (int64, error)) error {
+	if err := checkLock(r.dbPath); err != nil {
+		return err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	before, err := snapshot(tx, id)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if id, err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := journal(tx, ev, before, id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit change: %w", err)
+	}
+	return nil
+}
+
+// journal records the change from before to the current state of archive id.
+// Changes that only refresh last_seen are not recorded.
+func journal(tx *sql.Tx, ev *Event, before *Archive, id int64) error {
+	if id == 0 && before != nil {
+		id = before.ID
+	}
+	after, err := snapshot(tx, id)
+	if err != nil {
+		return err
+	}
+	if sameState(before, after) {
+		return nil
+	}
+
+	if ev.Action == "" {
+		ev.Action = classify(before, after)
+	}
+	ref := after
+	if ref == nil {
+		ref = before
+	}
+	ev.Time = time.Now()
+	ev.Actor = eventActor()
+	ev.Command = eventCommand()
+	ev.ArchiveID, ev.ArchiveUID, ev.ArchiveName = ref.ID, ref.UID, ref.Name
+	ev.Before, ev.After = before, after
+
+	beforeJSON, err := marshalState(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalState(after)
+	if err != nil {
+		return err
+	}
+	var undoes interface{}
+	if ev.Undoes != 0 {
+		undoes = ev.Undoes
+	}
+	res, err := tx.Exec(`INSERT INTO events (created, actor, command, action, archive_id, archive_uid, archive_name, before, after, undoes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ev.Time, ev.Actor, ev.Command, ev.Action, ev.ArchiveID, ev.ArchiveUID, ev.ArchiveName, beforeJSON, afterJSON, undoes)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	ev.ID, err = res.LastInsertId()
+	return err
+}
+
+// snapshot reads an archive with its tags and fields, or nil if it does not exist
+func snapshot(q querier, id int64) (*Archive, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	archive, err := scanArchive(q.QueryRow(`SELECT `+archiveColumns+` FROM archives WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %d: %w", id, err)
+	}
+
+	rows, err := q.Query(`SELECT tag FROM archive_tags WHERE archive_id = ? ORDER BY tag`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		archive.Tags = append(archive.Tags, tag)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`SELECT key, type, value FROM archive_meta WHERE archive_id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fields: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f Field
+		if err := rows.Scan(&f.Key, &f.Type, &f.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		if archive.Fields == nil {
+			archive.Fields = make(map[string]Field)
+		}
+		archive.Fields[f.Key] = f
+	}
+	return archive, rows.Err()
+}
+
+// archiveState is an archive as stored in the journal. Field types are kept
+// explicitly because Field's JSON form carries only the value.
+type archiveState struct {
+	*Archive
+	Fields map[string]fieldState `json:"fields,omitempty"`
+}
+
+type fieldState struct {
+	Type  FieldType `json:"type"`
+	Value string    `json:"value"`
+}
+
+func encodeState(a *Archive) ([]byte, error) {
+	st := archiveState{Archive: a}
+	for k, f := range a.Fields {
+		if st.Fields == nil {
+			st.Fields = make(map[string]fieldState, len(a.Fields))
+		}
+		st.Fields[k] = fieldState{f.Type, f.Value}
+	}
+	return json.Marshal(st)
+}
+
+func decodeState(data []byte) (*Archive, error) {
+	st := archiveState{Archive: &Archive{}}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	a := st.Archive
+	for k, f := range st.Fields {
+		if a.Fields == nil {
+			a.Fields = make(map[string]Field, len(st.Fields))
+		}
+		a.Fields[k] = Field{Key: k, Type: f.Type, Value: f.Value}
+	}
+	return a, nil
+}
+
+func marshalState(a *Archive) (interface{}, error) {
+	if a == nil {
+		return nil, nil
+	}
+	data, err := encodeState(a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode archive state: %w", err)
+	}
+	return string(data), nil
+}
+
+// sameState reports whether two snapshots differ in nothing but last_seen
+func sameState(a, b *Archive) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := *a, *b
+	x.LastSeen, y.LastSeen = nil, nil
+	ja, _ := encodeState(&x)
+	jb, _ := encodeState(&y)
+	return string(ja) == string(jb)
+}
+
+// classify names the change between two snapshots
+func classify(before, after *Archive) string {
+	switch {
+	case before == nil:
+		return ActionAdd
+	case after == nil:
+		return ActionRemove
+	case before.Status != "deleted" && after.Status == "deleted":
+		return ActionDelete
+	case before.Status == "deleted" && after.Status != "deleted":
+		return ActionRestore
+	case before.Path != after.Path:
+		return ActionMove
+	}
+	return ActionUpdate
+}
+
+// Changes describes what an event changed, one "field: old → new" per entry.
+// Status and path come first as the changes that matter most.
+func (e *Event) Changes() []string {
+	if e.Before == nil || e.After == nil {
+		return nil
+	}
+	var out, rest []string
+	b, a := reflect.ValueOf(*e.Before), reflect.ValueOf(*e.After)
+	t := b.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch name {
+		case "last_seen", "tags", "fields":
+			continue
+		}
+		old, cur := formatValue(b.Field(i)), formatValue(a.Field(i))
+		if old == cur {
+			continue
+		}
+		change := fmt.Sprintf("%s: %s → %s", name, old, cur)
+		if name == "status" || name == "path" {
+			out = append(out, change)
+		} else {
+			rest = append(rest, change)
+		}
+	}
+	if len(out) == 2 {
+		out[0], out[1] = out[1], out[0] // status before path
+	}
+	out = append(out, rest...)
+
+	added, removed := diffStrings(e.Before.Tags, e.After.Tags)
+	for _, tag := range added {
+		out = append(out, "tag +"+tag)
+	}
+	for _, tag := range removed {
+		out = append(out, "tag -"+tag)
+	}
+
+	for _, k := range fieldKeys(e.Before.Fields, e.After.Fields) {
+		old, hadOld := e.Before.Fields[k]
+		cur, hasCur := e.After.Fields[k]
+		switch {
+		case !hadOld:
+			out = append(out, fmt.Sprintf("meta %s = %s", k, cur.Value))
+		case !hasCur:
+			out = append(out, fmt.Sprintf("meta %s unset (was %s)", k, old.Value))
+		case old != cur:
+			out = append(out, fmt.Sprintf("meta %s: %s → %s", k, old.Value, cur.Value))
+		}
+	}
+	return out
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "∅"
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	s := fmt.Sprint(v.Interface())
+	if s == "" {
+		return `""`
+	}
+	return s
+}
+
+// diffStrings returns the entries of b missing from a and of a missing from b
+func diffStrings(a, b []string) (added, removed []string) {
+	for _, s := range b {
+		if !contains(a, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !contains(b, s) {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+const eventColumns = `id, created, actor, command, action, archive_id, archive_uid, archive_name, before, after, COALESCE(undoes, 0),
+	COALESCE((SELECT MAX(u.id) FROM events u WHERE u.undoes = events.id), 0)`
+
+// Events returns journaled events matching the filter, newest first
+func (r *Registry) Events(f EventFilter) ([]*Event, error) {
+	var where []string
+	var args []interface{}
+	if f.ArchiveID != 0 {
+		where = append(where, "archive_id = ?")
+		args = append(args, f.ArchiveID)
+	}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.Since)
+	}
+	query := `SELECT ` + eventColumns + ` FROM events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+	var events []*Event
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// Event returns a single event by id
+func (r *Registry) Event(id int64) (*Event, error) {
+	ev, err := scanEvent(r.db.QueryRow(`SELECT `+eventColumns+` FROM events WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("event not found: %d", id)
+	}
+	return ev, err
+}
+
+// LastUndoable returns the most recent change that can still be undone
+func (r *Registry) LastUndoable() (*Event, error) {
+	ev, err := scanEvent(r.db.QueryRow(`SELECT ` + eventColumns + ` FROM events
+		WHERE action NOT IN ('add', 'remove', 'undo')
+		AND NOT EXISTS (SELECT 1 FROM events u WHERE u.undoes = events.id)
+		ORDER BY id DESC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	return ev, err
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	ev := &Event{}
+	var before, after sql.NullString
+	if err := row.Scan(&ev.ID, &ev.Time, &ev.Actor, &ev.Command, &ev.Action, &ev.ArchiveID, &ev.ArchiveUID,
+		&ev.ArchiveName, &before, &after, &ev.Undoes, &ev.UndoneBy); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan event: %w", err)
+	}
+	for _, s := range []struct {
+		raw sql.NullString
+		dst **Archive
+	}{{before, &ev.Before}, {after, &ev.After}} {
+		if !s.raw.Valid {
+			continue
+		}
+		a, err := decodeState([]byte(s.raw.String))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", ev.ID, err)
+		}
+		*s.dst = a
+	}
+	return ev, nil
+}
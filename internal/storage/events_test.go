@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsRecordMutations(t *testing.T) {
+	reg := TestRegistry(t)
+	a := CreateTestArchive(t, reg, "journal.7z")
+
+	a.Profile = "documents"
+	if err := reg.Update(a); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// Refreshing last_seen alone is not a change worth journaling
+	now := time.Now()
+	a.LastSeen = &now
+	if err := reg.Update(a); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := reg.AddTags(a.ID, "Client:Acme"); err != nil {
+		t.Fatalf("AddTags: %v", err)
+	}
+	if err := reg.SetFields(a.ID, Field{Key: "year", Type: FieldNumber, Value: "2024"}); err != nil {
+		t.Fatalf("SetFields: %v", err)
+	}
+	if err := reg.DeleteByID(a.ID); err != nil {
+		t.Fatalf("DeleteByID: %v", err)
+	}
+
+	events, err := reg.Events(EventFilter{ArchiveID: a.ID})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	var actions []string
+	for _, ev := range events {
+		actions = append(actions, ev.Action)
+	}
+	if got, want := strings.Join(actions, ","), "remove,meta,tag,update,add"; got != want {
+		t.Fatalf("actions = %s, want %s", got, want)
+	}
+
+	meta := events[1]
+	if f := meta.After.Fields["year"]; f.Type != FieldNumber || f.Value != "2024" {
+		t.Errorf("field state not kept: %+v", f)
+	}
+	if got := meta.Changes(); len(got) != 1 || got[0] != "meta year = 2024" {
+		t.Errorf("Changes() = %v", got)
+	}
+	if got := events[3].Changes(); len(got) != 1 || !strings.HasPrefix(got[0], "profile: ") {
+		t.Errorf("update Changes() = %v", got)
+	}
+	if events[0].After != nil || events[0].Before == nil || events[0].ArchiveUID != a.UID {
+		t.Errorf("remove event = %+v", events[0])
+	}
+	if events[4].Actor == "" {
+		t.Error("actor not recorded")
+	}
+
+	if _, err := reg.DB().Exec(`DELETE FROM events`); err == nil {
+		t.Error("events should be append-only")
+	}
+
+	tags, err := reg.Events(EventFilter{Action: ActionTag, Limit: 1})
+	if err != nil || len(tags) != 1 || tags[0].ID != events[2].ID {
+		t.Errorf("filtered events = %v, %v", tags, err)
+	}
+}
+
+func TestUndoMoveAndTrash(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	reg := mgr.Registry()
+
+	orig := filepath.Join(mgr.GetArchivesPath(), "undo.7z")
+	if err := os.WriteFile(orig, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a := &Archive{UID: generateUID(), Name: "undo.7z", Path: orig, Created: time.Now(), Managed: true, Status: "present"}
+	if err := reg.Add(a); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Soft delete into trash as the delete command does
+	trashed := filepath.Join(mgr.GetTrashPath(), "undo.7z")
+	if err := os.Rename(orig, trashed); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	a.Path, a.Status, a.DeletedAt, a.OriginalPath = trashed, "deleted", &now, orig
+	if err := reg.Update(a); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := reg.AddTags(a.ID, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := reg.LastUndoable()
+	if err != nil || last.Action != ActionTag {
+		t.Fatalf("LastUndoable = %+v, %v", last, err)
+	}
+	events, _ := reg.Events(EventFilter{Action: ActionDelete})
+	if len(events) != 1 {
+		t.Fatalf("expected one delete event, got %d", len(events))
+	}
+	plan, err := mgr.PlanUndo(events[0].ID)
+	if err != nil {
+		t.Fatalf("PlanUndo: %v", err)
+	}
+	if plan.From != trashed || plan.To != orig {
+		t.Errorf("plan moves %s -> %s", plan.From, plan.To)
+	}
+	undo, err := mgr.Undo(plan)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if undo.Undoes != events[0].ID {
+		t.Errorf("undo event = %+v", undo)
+	}
+
+	got, err := reg.GetByID(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "present" || got.Path != orig || got.DeletedAt != nil || got.OriginalPath != "" {
+		t.Errorf("archive not restored: %+v", got)
+	}
+	if !got.HasTags("old") {
+		t.Error("later tag should survive the undo")
+	}
+	if _, err := os.Stat(orig); err != nil {
+		t.Errorf("file not moved back: %v", err)
+	}
+	if _, err := mgr.PlanUndo(events[0].ID); err == nil {
+		t.Error("expected error undoing an event twice")
+	}
+
+	// Un-tag
+	plan, err = mgr.PlanUndo(last.ID)
+	if err != nil {
+		t.Fatalf("PlanUndo tag: %v", err)
+	}
+	if _, err := mgr.Undo(plan); err != nil {
+		t.Fatalf("Undo tag: %v", err)
+	}
+	if tags, _ := reg.Tags(a.ID); len(tags) != 0 {
+		t.Errorf("tags after undo = %v", tags)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	reg := mgr.Registry()
+
+	a := CreateTestArchive(t, reg, "conflict.7z", WithStatus("missing"))
+	a.Profile = "media"
+	if err := reg.Update(a); err != nil {
+		t.Fatal(err)
+	}
+	a.Profile = "documents"
+	if err := reg.Update(a); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := reg.Events(EventFilter{ArchiveID: a.ID, Action: ActionUpdate})
+	if _, err := mgr.PlanUndo(events[1].ID); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	if _, err := mgr.PlanUndo(events[0].ID); err != nil {
+		t.Errorf("latest change should undo: %v", err)
+	}
+
+	adds, _ := reg.Events(EventFilter{ArchiveID: a.ID, Action: ActionAdd})
+	if _, err := mgr.PlanUndo(adds[0].ID); err == nil {
+		t.Error("additions cannot be undone")
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"serve --token s3cret --listen :8080", "serve --token *** --listen :8080"},
+		{"serve --token=s3cret", "serve --token=***"},
+		{"upload --password hunter2 --api-key=abc --secret x", "upload --password *** --api-key=*** --secret ***"},
+		{"meta set a.7z --field key=value", "meta set a.7z --field key=value"},
+		{"create --background -- --token literal", "create --background -- --token literal"},
+		{"serve --token", "serve --token"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(redactArgs(strings.Fields(c.in)), " "); got != c.want {
+			t.Errorf("redactArgs(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
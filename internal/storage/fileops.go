@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Operations that touch both an archive's file and its registry row go
+// through the file_ops intent log so a crash between the two can be repaired:
+//
+//  1. a file_ops row records the archive, source, destination and the row
+//     state to apply once the file is in place
+//  2. the file is moved, or removed when there is no destination
+//  3. one transaction updates the archive row and deletes the file_ops row
+//
+// NewManager recovers intents left by processes that have exited: an
+// operation whose file reached its destination is completed, one whose file
+// did not is rolled back.
+
+// Outcomes of recovering an interrupted operation
+const (
+	RecoveryCompleted  = "completed"
+	RecoveryRolledBack = "rolled back"
+	RecoveryLost       = "lost" // neither source nor destination exists
+)
+
+// RecoveredOp is an interrupted file operation repaired by NewManager
+type RecoveredOp struct {
+	ArchiveID int64
+	Src, Dst  string // Dst is empty for a removal
+	Outcome   string
+}
+
+// failpoint is called between the steps of a file operation; tests replace it
+// to simulate a crash at a given step
+var failpoint = func(step string) {}
+
+// MoveArchive moves an archive's file to dst and records the new path, along
+// with any changes update makes to the row. A crash part way is repaired the
+// next time storage is opened. On success a reflects the new state.
+func (m *Manager) MoveArchive(a *Archive, dst string, update func(*Archive)) error {
+	if dst == "" {
+		return fmt.Errorf("destination path is required")
+	}
+	return m.fileOp(a, dst, update)
+}
+
+// RemoveArchiveFile deletes an archive's file and applies update to its row
+// with the same crash safety as MoveArchive. A missing file is not an error.
+func (m *Manager) RemoveArchiveFile(a *Archive, update func(*Archive)) error {
+	return m.fileOp(a, "", update)
+}
+
+// statusPurged marks the after state of an operation that removes the
+// archive's row along with its file. It is never stored in archives.
+const statusPurged = "purged"
+
+// purgeArchiveFile deletes an archive's file and then its registry row with
+// the same crash safety as RemoveArchiveFile
+func (m *Manager) purgeArchiveFile(a *Archive) error {
+	purged := *a
+	return m.fileOp(&purged, "", func(p *Archive) { p.Status = statusPurged })
+}
+
+// RecoveredOps returns the interrupted operations repaired when the manager
+// was opened
+func (m *Manager) RecoveredOps() []RecoveredOp { return m.recovered }
+
+func (m *Manager) fileOp(a *Archive, dst string, update func(*Archive)) error {
+	after := *a
+	if dst != "" {
+		after.Path = dst
+	}
+	if update != nil {
+		update(&after)
+	}
+	if dst == a.Path {
+		if err := m.registry.Update(&after); err != nil {
+			return err
+		}
+		*a = after
+		return nil
+	}
+
+	if dst != "" {
+		if _, err := os.Stat(dst); err == nil {
+			return fmt.Errorf("destination file already exists: %s", dst)
+		}
+		// #nosec G301: restrict permissions on created directories
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+		}
+	}
+
+	opID, err := m.registry.beginFileOp(a.Path, dst, &after)
+	if err != nil {
+		return err
+	}
+	failpoint("intent")
+
+	if dst == "" {
+		if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+			_ = m.registry.endFileOp(opID)
+			return fmt.Errorf("failed to remove %s: %w", a.Path, err)
+		}
+	} else if err := relocateFile(a.Path, dst); err != nil {
+		_ = m.registry.endFileOp(opID)
+		return fmt.Errorf("failed to move %s to %s: %w", a.Path, dst, err)
+	}
+	failpoint("file")
+
+	if err := m.registry.commitFileOp(opID, &after); err != nil {
+		// Put the file back so the row still describes it. If that is not
+		// possible the intent stays and the next start completes the move.
+		if dst != "" && relocateFile(dst, a.Path) == nil {
+			_ = m.registry.endFileOp(opID)
+		}
+		return err
+	}
+	*a = after
+	return nil
+}
+
+// beginFileOp records the intent to move src to dst and then apply after
+func (r *Registry) beginFileOp(src, dst string, after *Archive) (int64, error) {
+	if err := checkLock(r.dbPath); err != nil {
+		return 0, err
+	}
+	state, err := encodeState(after)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode archive state: %w", err)
+	}
+	host, _ := os.Hostname()
+	res, err := r.db.Exec(`INSERT INTO file_ops (started, host, pid, archive_id, src, dst, after) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		time.Now(), host, os.Getpid(), after.ID, src, dst, string(state))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record file operation: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// commitFileOp applies the row state of a finished file operation and clears
+// its intent in one transaction
+func (r *Registry) commitFileOp(opID int64, after *Archive) error {
+	return r.mutate(after.ID, "", func(tx *sql.Tx) (int64, error) {
+		current, err := snapshot(tx, after.ID)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case current == nil:
+		case after.Status == statusPurged:
+			if err := deleteArchive(tx, after.ID); err != nil {
+				return 0, err
+			}
+		default:
+			if err := updateArchive(tx, after); err != nil {
+				return 0, err
+			}
+		}
+		return after.ID, clearFileOp(tx, opID)
+	})
+}
+
+// pendingFileOp is a file operation whose file has moved but whose row
+// state is not yet committed
+type pendingFileOp struct {
+	id       int64
+	src, dst string
+	after    *Archive
+}
+
+// commitFileOps is commitFileOp for several operations in one transaction,
+// journaling each archive's change
+func (r *Registry) commitFileOps(ops []pendingFileOp) error {
+	if err := checkLock(r.dbPath); err != nil {
+		return err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, op := range ops {
+		before, err := snapshot(tx, op.after.ID)
+		if err != nil {
+			return err
+		}
+		if before != nil {
+			if err := updateArchive(tx, op.after); err != nil {
+				return err
+			}
+			if err := journal(tx, &Event{}, before, op.after.ID); err != nil {
+				return err
+			}
+		}
+		if err := clearFileOp(tx, op.id); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit change: %w", err)
+	}
+	return nil
+}
+
+// clearFileOp deletes an intent inside the transaction recording its outcome
+func clearFileOp(tx *sql.Tx, opID int64) error {
+	if _, err := tx.Exec(`DELETE FROM file_ops WHERE id = ?`, opID); err != nil {
+		return fmt.Errorf("failed to clear file operation: %w", err)
+	}
+	return nil
+}
+
+// endFileOp drops an intent whose file operation did not happen
+func (r *Registry) endFileOp(opID int64) error {
+	if _, err := r.db.Exec(`DELETE FROM file_ops WHERE id = ?`, opID); err != nil {
+		return fmt.Errorf("failed to clear file operation: %w", err)
+	}
+	return nil
+}
+
+type fileOp struct {
+	id       int64
+	host     string
+	pid      int
+	src, dst string
+	after    *Archive
+}
+
+// recoverFileOps completes or rolls back operations left by processes that
+// are no longer running. Intents from other hosts are left alone since their
+// processes cannot be checked.
+func (m *Manager) recoverFileOps() ([]RecoveredOp, error) {
+	rows, err := m.registry.db.Query(`SELECT id, host, pid, src, dst, after FROM file_ops ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file operations: %w", err)
+	}
+	var ops []fileOp
+	for rows.Next() {
+		var op fileOp
+		var state string
+		if err := rows.Scan(&op.id, &op.host, &op.pid, &op.src, &op.dst, &state); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan file operation: %w", err)
+		}
+		if op.after, err = decodeState([]byte(state)); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to decode file operation %d: %w", op.id, err)
+		}
+		ops = append(ops, op)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	var recovered []RecoveredOp
+	for _, op := range ops {
+		if op.host != host || (op.pid != os.Getpid() && processAlive(op.pid)) {
+			continue
+		}
+		outcome, err := m.recoverFileOp(op)
+		if err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, RecoveredOp{ArchiveID: op.after.ID, Src: op.src, Dst: op.dst, Outcome: outcome})
+	}
+	return recovered, nil
+}
+
+func (m *Manager) recoverFileOp(op fileOp) (string, error) {
+	srcExists, dstExists := fileExists(op.src), op.dst != "" && fileExists(op.dst)
+	switch {
+	case op.dst == "" && srcExists, op.dst != "" && srcExists && !dstExists:
+		// The file was never touched
+		return RecoveryRolledBack, m.registry.endFileOp(op.id)
+	case srcExists && dstExists:
+		// A cross-device copy was cut short; the source is still whole
+		if err := os.Remove(op.dst); err != nil {
+			return "", fmt.Errorf("failed to remove partial copy %s: %w", op.dst, err)
+		}
+		return RecoveryRolledBack, m.registry.endFileOp(op.id)
+	case op.dst == "" || dstExists:
+		return RecoveryCompleted, m.registry.commitFileOp(op.id, op.after)
+	}
+	return RecoveryLost, m.registry.endFileOp(op.id)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
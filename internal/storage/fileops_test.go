@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestFileOpCrashHelper is run as a child process by TestFileOpCrashRecovery.
+// It starts the operation in FILEOP_OP on an archive and kills itself at the
+// step named in FILEOP_CRASH_STEP.
+func TestFileOpCrashHelper(t *testing.T) {
+	step := os.Getenv("FILEOP_CRASH_STEP")
+	if step == "" {
+		t.Skip("helper process")
+	}
+	mgr, err := NewManager(os.Getenv("FILEOP_BASE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := strconv.ParseInt(os.Getenv("FILEOP_ARCHIVE"), 10, 64)
+	a, err := mgr.Registry().GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failpoint = func(s string) {
+		if s == step {
+			p, _ := os.FindProcess(os.Getpid())
+			_ = p.Kill()
+			select {}
+		}
+	}
+	switch os.Getenv("FILEOP_OP") {
+	case "move":
+		err = mgr.MoveArchive(a, os.Getenv("FILEOP_DST"), nil)
+	case "remove":
+		err = mgr.RemoveArchiveFile(a, func(a *Archive) { a.Status = "deleted" })
+	case "reorganize":
+		mgr.SetLayout(LayoutByType)
+		moves, _, perr := mgr.PlanReorganize()
+		if perr != nil {
+			t.Fatal(perr)
+		}
+		err = mgr.Reorganize(moves)
+	case "purge":
+		err = mgr.Purge(a)
+	}
+	t.Fatalf("operation finished without crashing: %v", err)
+}
+
+func TestFileOpCrashRecovery(t *testing.T) {
+	cases := []struct {
+		op      string
+		step    string
+		outcome string
+	}{
+		{"move", "intent", RecoveryRolledBack},
+		{"move", "file", RecoveryCompleted},
+		{"remove", "intent", RecoveryRolledBack},
+		{"remove", "file", RecoveryCompleted},
+		{"reorganize", "intent", RecoveryRolledBack},
+		{"reorganize", "file", RecoveryCompleted},
+		{"purge", "intent", RecoveryRolledBack},
+		{"purge", "file", RecoveryCompleted},
+	}
+	for _, c := range cases {
+		t.Run(c.op+" crash at "+c.step, func(t *testing.T) {
+			base := t.TempDir()
+			src := filepath.Join(base, "archives", "crash.7z")
+			dst := ""
+			switch c.op {
+			case "move":
+				dst = filepath.Join(base, "elsewhere", "crash.7z")
+			case "reorganize":
+				dst = filepath.Join(base, "archives", "other", "crash.7z")
+			case "purge":
+				src = filepath.Join(base, "trash", "crash.7z")
+			}
+			id := seedFileOpArchive(t, base, src)
+			if c.op == "purge" {
+				trashFileOpArchive(t, base, id)
+			}
+
+			child := exec.Command(os.Args[0], "-test.run=^TestFileOpCrashHelper$")
+			child.Env = append(os.Environ(), "FILEOP_CRASH_STEP="+c.step, "FILEOP_BASE="+base,
+				"FILEOP_ARCHIVE="+strconv.FormatInt(id, 10), "FILEOP_OP="+c.op, "FILEOP_DST="+dst)
+			if out, err := child.CombinedOutput(); err == nil {
+				t.Fatalf("helper was not killed:\n%s", out)
+			}
+
+			mgr, err := NewManager(base)
+			if err != nil {
+				t.Fatalf("NewManager: %v", err)
+			}
+			defer mgr.Close()
+			rec := mgr.RecoveredOps()
+			if len(rec) != 1 || rec[0].Outcome != c.outcome || rec[0].ArchiveID != id {
+				t.Fatalf("recovered = %+v, want one %s", rec, c.outcome)
+			}
+
+			a, err := mgr.Registry().GetByID(id)
+			switch {
+			case c.op == "purge" && c.outcome == RecoveryCompleted:
+				if err == nil {
+					t.Fatal("purged archive is still in the registry")
+				}
+			case err != nil:
+				t.Fatal(err)
+			case c.outcome == RecoveryRolledBack:
+				if a.Path != src {
+					t.Errorf("registry path = %s, want %s", a.Path, src)
+				}
+			case dst != "":
+				if a.Path != dst {
+					t.Errorf("registry path = %s, want %s", a.Path, dst)
+				}
+			default:
+				if a.Path != src || a.Status != "deleted" {
+					t.Errorf("registry path = %s status = %s, want %s deleted", a.Path, a.Status, src)
+				}
+			}
+			if c.outcome == RecoveryRolledBack && !fileExists(src) {
+				t.Errorf("rolled back but %s is gone", src)
+			}
+			if dst != "" && c.outcome == RecoveryCompleted && !fileExists(dst) {
+				t.Errorf("completed but %s is missing", dst)
+			}
+
+			var pending int
+			_ = mgr.Registry().DB().QueryRow(`SELECT COUNT(*) FROM file_ops`).Scan(&pending)
+			if pending != 0 {
+				t.Errorf("%d intents left after recovery", pending)
+			}
+		})
+	}
+}
+
+func TestFileOpRecoversPartialCopy(t *testing.T) {
+	base := t.TempDir()
+	src := filepath.Join(base, "archives", "copy.7z")
+	dst := filepath.Join(base, "other", "copy.7z")
+	id := seedFileOpArchive(t, base, src)
+
+	// A cross-device copy cut short leaves both files; the intent belongs to
+	// a process that no longer exists
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := mgr.Registry().GetByID(id)
+	after := *a
+	after.Path = dst
+	if _, err := mgr.Registry().beginFileOp(src, dst, &after); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = mgr.Registry().DB().Exec(`UPDATE file_ops SET pid = 0`)
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("par"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mgr.Close()
+
+	mgr, err = NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	if rec := mgr.RecoveredOps(); len(rec) != 1 || rec[0].Outcome != RecoveryRolledBack {
+		t.Fatalf("recovered = %+v", rec)
+	}
+	if fileExists(dst) || !fileExists(src) {
+		t.Error("partial copy should be removed and the source kept")
+	}
+}
+
+func TestMoveArchivePutsFileBackOnRegistryFailure(t *testing.T) {
+	base := t.TempDir()
+	src := filepath.Join(base, "archives", "fail.7z")
+	id := seedFileOpArchive(t, base, src)
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	a, _ := mgr.Registry().GetByID(id)
+
+	// Make the final update fail after the file has moved
+	failpoint = func(step string) {
+		if step == "file" {
+			_, _ = mgr.Registry().DB().Exec(`CREATE TRIGGER fail_update BEFORE UPDATE ON archives BEGIN SELECT RAISE(ABORT, 'injected'); END`)
+		}
+	}
+	defer func() { failpoint = func(string) {} }()
+
+	dst := filepath.Join(base, "moved", "fail.7z")
+	if err := mgr.MoveArchive(a, dst, nil); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	if !fileExists(src) || fileExists(dst) {
+		t.Error("file should be back at its source")
+	}
+	if a.Path != src {
+		t.Errorf("archive path changed to %s", a.Path)
+	}
+}
+
+func seedFileOpArchive(t *testing.T, base, path string) int64 {
+	t.Helper()
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	if err := os.WriteFile(path, []byte("payload"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a := &Archive{UID: generateUID(), Name: filepath.Base(path), Path: path, Size: 7, Created: time.Now(), Managed: true, Status: "present"}
+	if err := mgr.Registry().Add(a); err != nil {
+		t.Fatal(err)
+	}
+	return a.ID
+}
+
+// trashFileOpArchive marks a seeded archive as deleted where it lies
+func trashFileOpArchive(t *testing.T, base string, id int64) {
+	t.Helper()
+	mgr, err := NewManager(base)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	a, err := mgr.Registry().GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	MarkDeleted(a.Path)(a)
+	if err := mgr.Registry().Update(a); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortKey is a column Find can order by. Ties are broken by row id so every
+// order is total and keyset cursors are stable.
+type SortKey string
+
+const (
+	SortCreated SortKey = "created"
+	SortName    SortKey = "name"
+	SortSize    SortKey = "size"
+	SortID      SortKey = "id"
+)
+
+// ParseSortKey validates a sort key; empty means SortCreated
+func ParseSortKey(s string) (SortKey, error) {
+	switch SortKey(strings.ToLower(strings.TrimSpace(s))) {
+	case "", SortCreated:
+		return SortCreated, nil
+	case SortName:
+		return SortName, nil
+	case SortSize:
+		return SortSize, nil
+	case SortID:
+		return SortID, nil
+	}
+	return "", fmt.Errorf("invalid sort key %q (supported: created, name, size, id)", s)
+}
+
+// timestampLayout is how the sqlite driver writes time.Time values, so cursor
+// values compare against the stored text exactly
+const timestampLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+// Criteria selects archives for Find. The zero value selects every archive,
+// newest first.
+type Criteria struct {
+	// Where is a SQL condition on the archives table with ? placeholders
+	Where string
+	Args  []interface{}
+	// Match, when set, is evaluated in Go on rows that pass Where. Paging is
+	// then applied after matching, so results stay correct at the cost of
+	// reading every candidate row.
+	Match func(*Archive) bool
+	// UIDs restricts results to the given archives
+	UIDs []string
+	// IDs restricts results to the given row ids
+	IDs []int64
+
+	Sort      SortKey
+	Ascending bool
+	Limit     int    // 0 means no limit
+	Offset    int    // rows to skip; prefer After for deep pages
+	After     string // cursor from a previous Page.Next
+}
+
+// Page is one page of Find results. Next is the cursor for the following
+// page, empty when there are no more results.
+type Page struct {
+	Archives []*Archive
+	Next     string
+}
+
+// Find returns archives matching the criteria using parameterised SQL
+func (r *Registry) Find(ctx context.Context, c Criteria) (*Page, error) {
+	sortKey, err := ParseSortKey(string(c.Sort))
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := c.conditions(sortKey)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := "DESC"
+	if c.Ascending {
+		dir = "ASC"
+	}
+	query := `SELECT ` + archiveColumns + ` FROM archives`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if sortKey == SortID {
+		query += ` ORDER BY id ` + dir
+	} else {
+		query += fmt.Sprintf(` ORDER BY %s %s, id %s`, sortKey, dir, dir)
+	}
+	// Fetch one extra row to learn whether another page follows
+	if c.Match == nil && c.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, c.Limit+1, c.Offset)
+	} else if c.Match == nil && c.Offset > 0 {
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, c.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archives: %w", err)
+	}
+	defer rows.Close()
+
+	archives, err := r.hydrate(scanArchives(rows))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Match != nil {
+		matched := make([]*Archive, 0, len(archives))
+		for _, a := range archives {
+			if c.Match(a) {
+				matched = append(matched, a)
+			}
+		}
+		archives = matched
+		if c.Offset >= len(archives) {
+			archives = nil
+		} else {
+			archives = archives[c.Offset:]
+		}
+	}
+
+	page := &Page{Archives: archives}
+	if c.Limit > 0 && len(archives) > c.Limit {
+		page.Archives = archives[:c.Limit]
+		page.Next = encodeCursor(sortKey, page.Archives[c.Limit-1])
+	}
+	return page, nil
+}
+
+// Count returns how many archives match the criteria, ignoring paging
+func (r *Registry) Count(ctx context.Context, c Criteria) (int, error) {
+	if c.Match != nil {
+		c.Limit, c.Offset, c.After = 0, 0, ""
+		page, err := r.Find(ctx, c)
+		if err != nil {
+			return 0, err
+		}
+		return len(page.Archives), nil
+	}
+
+	c.After = ""
+	where, args, err := c.conditions(SortCreated)
+	if err != nil {
+		return 0, err
+	}
+	query := `SELECT COUNT(*) FROM archives`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	var n int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count archives: %w", err)
+	}
+	return n, nil
+}
+
+// conditions builds the WHERE terms and arguments for the criteria
+func (c Criteria) conditions(sortKey SortKey) ([]string, []interface{}, error) {
+	var where []string
+	var args []interface{}
+
+	if c.Where != "" {
+		where = append(where, "("+c.Where+")")
+		args = append(args, c.Args...)
+	}
+	if c.UIDs != nil {
+		uids, err := json.Marshal(c.UIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, "uid IN (SELECT value FROM json_each(?))")
+		args = append(args, string(uids))
+	}
+	if c.IDs != nil {
+		ids, err := json.Marshal(c.IDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, "id IN (SELECT value FROM json_each(?))")
+		args = append(args, string(ids))
+	}
+	if c.After != "" {
+		key, value, id, err := decodeCursor(c.After)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key != sortKey {
+			return nil, nil, fmt.Errorf("cursor was issued for sort %q, not %q", key, sortKey)
+		}
+		cmp := "<"
+		if c.Ascending {
+			cmp = ">"
+		}
+		if sortKey == SortID {
+			where = append(where, "id "+cmp+" ?")
+			args = append(args, id)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortKey, cmp))
+			args = append(args, value, value, id)
+		}
+	}
+	return where, args, nil
+}
+
+// encodeCursor returns an opaque cursor positioned after a
+func encodeCursor(key SortKey, a *Archive) string {
+	var value string
+	switch key {
+	case SortCreated:
+		value = a.Created.Format(timestampLayout)
+	case SortName:
+		value = a.Name
+	case SortSize:
+		value = strconv.FormatInt(a.Size, 10)
+	}
+	raw := strings.Join([]string{string(key), strconv.FormatInt(a.ID, 10), value}, "\x00")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor into its sort key, column value and row id
+func decodeCursor(cursor string) (SortKey, interface{}, int64, error) {
+	invalid := fmt.Errorf("invalid cursor %q", cursor)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, 0, invalid
+	}
+	parts := strings.SplitN(string(raw), "\x00", 3)
+	if len(parts) != 3 {
+		return "", nil, 0, invalid
+	}
+	key, err := ParseSortKey(parts[0])
+	if err != nil {
+		return "", nil, 0, invalid
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", nil, 0, invalid
+	}
+
+	var value interface{} = parts[2]
+	switch key {
+	case SortCreated:
+		// Validate, but compare as text the way the driver stored it
+		if _, err := time.Parse(timestampLayout, parts[2]); err != nil {
+			return "", nil, 0, invalid
+		}
+	case SortSize:
+		n, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return "", nil, 0, invalid
+		}
+		value = n
+	}
+	return key, value, id, nil
+}
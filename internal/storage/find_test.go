@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func setupFindRegistry(t *testing.T, n int) *Registry {
+	t.Helper()
+	registry, tempDir := setupTestRegistry(t)
+	t.Cleanup(func() {
+		registry.Close()
+		os.RemoveAll(tempDir)
+	})
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		a := &Archive{
+			UID:     fmt.Sprintf("uid-%03d", i),
+			Name:    fmt.Sprintf("archive-%03d.7z", i),
+			Path:    fmt.Sprintf("/archives/archive-%03d.7z", i),
+			Size:    int64((i % 5) * 100),
+			Created: base.Add(time.Duration(i/2) * time.Hour), // pairs share a timestamp
+			Profile: []string{"media", "documents"}[i%2],
+			Status:  "present",
+		}
+		if err := registry.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func names(archives []*Archive) []string {
+	out := make([]string, len(archives))
+	for i, a := range archives {
+		out[i] = a.Name
+	}
+	return out
+}
+
+func TestFindDefaultsToNewestFirst(t *testing.T) {
+	registry := setupFindRegistry(t, 6)
+	page, err := registry.Find(context.Background(), Criteria{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(names(page.Archives))
+	want := "[archive-005.7z archive-004.7z archive-003.7z archive-002.7z archive-001.7z archive-000.7z]"
+	if got != want {
+		t.Fatalf("order = %s, want %s", got, want)
+	}
+	if page.Next != "" {
+		t.Fatalf("unexpected cursor %q without a limit", page.Next)
+	}
+}
+
+func TestFindKeysetPagination(t *testing.T) {
+	registry := setupFindRegistry(t, 25)
+	ctx := context.Background()
+
+	for _, sort := range []SortKey{SortCreated, SortName, SortSize, SortID} {
+		for _, asc := range []bool{false, true} {
+			all, err := registry.Find(ctx, Criteria{Sort: sort, Ascending: asc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var paged []*Archive
+			c := Criteria{Sort: sort, Ascending: asc, Limit: 7}
+			for {
+				page, err := registry.Find(ctx, c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				paged = append(paged, page.Archives...)
+				if page.Next == "" {
+					break
+				}
+				c.After = page.Next
+			}
+			if fmt.Sprint(names(paged)) != fmt.Sprint(names(all.Archives)) {
+				t.Errorf("sort %s asc=%v: paged %v, want %v", sort, asc, names(paged), names(all.Archives))
+			}
+		}
+	}
+}
+
+func TestFindOffsetAndWhere(t *testing.T) {
+	registry := setupFindRegistry(t, 10)
+	ctx := context.Background()
+
+	c := Criteria{Where: "profile = ?", Args: []interface{}{"media"}, Sort: SortName, Ascending: true, Limit: 2, Offset: 1}
+	page, err := registry.Find(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names(page.Archives)); got != "[archive-002.7z archive-004.7z]" {
+		t.Fatalf("page = %s", got)
+	}
+	if page.Next == "" {
+		t.Fatal("expected a cursor for the remaining media archives")
+	}
+
+	n, err := registry.Count(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("count = %d, want 5", n)
+	}
+}
+
+func TestFindMatchPagesAfterFiltering(t *testing.T) {
+	registry := setupFindRegistry(t, 10)
+	ctx := context.Background()
+
+	odd := func(a *Archive) bool { return a.ID%2 == 1 }
+	c := Criteria{Match: odd, Sort: SortID, Ascending: true, Limit: 2}
+	var got []int64
+	for {
+		page, err := registry.Find(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range page.Archives {
+			got = append(got, a.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		c.After = page.Next
+	}
+	if fmt.Sprint(got) != "[1 3 5 7 9]" {
+		t.Fatalf("ids = %v", got)
+	}
+
+	n, err := registry.Count(ctx, Criteria{Match: odd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("count = %d, want 5", n)
+	}
+}
+
+func TestFindByUIDs(t *testing.T) {
+	registry := setupFindRegistry(t, 5)
+	page, err := registry.Find(context.Background(), Criteria{UIDs: []string{"uid-001", "uid-003", "uid-missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(names(page.Archives)); got != "[archive-003.7z archive-001.7z]" {
+		t.Fatalf("archives = %s", got)
+	}
+
+	page, err = registry.Find(context.Background(), Criteria{UIDs: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Archives) != 0 {
+		t.Fatalf("empty UID list should match nothing, got %d", len(page.Archives))
+	}
+}
+
+func TestFindRejectsBadCursor(t *testing.T) {
+	registry := setupFindRegistry(t, 3)
+	ctx := context.Background()
+	if _, err := registry.Find(ctx, Criteria{After: "not-a-cursor"}); err == nil {
+		t.Fatal("expected error for invalid cursor")
+	}
+
+	page, err := registry.Find(ctx, Criteria{Sort: SortName, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Find(ctx, Criteria{Sort: SortSize, After: page.Next}); err == nil {
+		t.Fatal("expected error for cursor from another sort order")
+	}
+	if _, err := ParseSortKey("colour"); err == nil {
+		t.Fatal("expected error for unknown sort key")
+	}
+}
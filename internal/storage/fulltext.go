@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Full-text index over archive name, path, profile, metadata and tags.
+//
+// FTS5 is used when the sqlite driver is built with it (go build -tags
+// sqlite_fts5); otherwise FTS4, which every build includes. Each module gets
+// its own table so a registry opened by builds with different modules stays
+// writable: triggers for the unavailable module are dropped and the available
+// index is rebuilt the next time its triggers are missing.
+
+// FullTextColumns are the indexed columns, in table order
+var FullTextColumns = []string{"name", "path", "profile", "metadata", "tags"}
+
+// FullText describes the registry's full-text index
+type FullText struct {
+	Table  string // virtual table name, rowid = archives.id
+	Module string // fts5 or fts4
+}
+
+// fullTextModules maps each module to its table and the compile option that
+// enables it, in order of preference
+var fullTextModules = []struct {
+	module, table, option string
+}{
+	{"fts5", "archives_fts5", "ENABLE_FTS5"},
+	{"fts4", "archives_fts4", "ENABLE_FTS3"},
+}
+
+// FullText returns the full-text index in use
+func (r *Registry) FullText() FullText { return r.fullText }
+
+// ensureFullText creates or repairs the full-text index and its triggers
+func (r *Registry) ensureFullText() error {
+	var chosen *FullText
+	for _, m := range fullTextModules {
+		if chosen == nil && compileOptionUsed(r.db, m.option) {
+			chosen = &FullText{Table: m.table, Module: m.module}
+			continue
+		}
+		// Another build may have indexed with this module; stop maintaining it
+		for _, t := range fullTextTriggers(m.table) {
+			if _, err := r.db.Exec(`DROP TRIGGER IF EXISTS ` + t.name); err != nil {
+				return fmt.Errorf("failed to drop trigger %s: %w", t.name, err)
+			}
+		}
+	}
+	if chosen == nil {
+		return fmt.Errorf("sqlite driver has no full-text search module")
+	}
+	r.fullText = *chosen
+
+	triggers := fullTextTriggers(chosen.Table)
+	if !triggerExists(r.db, triggers[len(triggers)-1].name) {
+		if err := r.rebuildFullText(true); err != nil {
+			return err
+		}
+	}
+
+	// The vocabulary table lists indexed terms for fuzzy matching
+	ddl := fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s_terms USING fts5vocab(%s, 'row')`, chosen.Table, chosen.Table)
+	if chosen.Module == "fts4" {
+		ddl = fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s_terms USING fts4aux(%s)`, chosen.Table, chosen.Table)
+	}
+	if _, err := r.db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create full-text vocabulary: %w", err)
+	}
+	return nil
+}
+
+// FullTextTerms returns every distinct term in the full-text index
+func (r *Registry) FullTextTerms() ([]string, error) {
+	query := `SELECT term FROM ` + r.fullText.Table + `_terms`
+	if r.fullText.Module == "fts4" {
+		query += ` WHERE col = '*'`
+	}
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search terms: %w", err)
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, fmt.Errorf("failed to scan search term: %w", err)
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+// RebuildFullText repopulates the full-text index from the archives table.
+// Triggers keep it current, so this is only needed to repair it.
+func (r *Registry) RebuildFullText() error {
+	return r.rebuildFullText(false)
+}
+
+func (r *Registry) rebuildFullText(createTriggers bool) error {
+	ft := r.fullText
+	ddl := fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(name, path, profile, metadata, tags, prefix='2 3')`, ft.Table)
+	if ft.Module == "fts4" {
+		ddl = fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts4(name, path, profile, metadata, tags, prefix="2,3", tokenize=unicode61)`, ft.Table)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	stmts := []string{
+		ddl,
+		`DELETE FROM ` + ft.Table,
+		`INSERT INTO ` + ft.Table + ` (rowid, name, path, profile, metadata, tags)
+		SELECT id, name, path, COALESCE(profile, ''), COALESCE(metadata, ''),
+			(SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = archives.id)
+		FROM archives`,
+	}
+	if createTriggers {
+		for _, t := range fullTextTriggers(ft.Table) {
+			stmts = append(stmts, `DROP TRIGGER IF EXISTS `+t.name, t.ddl)
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to build full-text index: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit full-text index: %w", err)
+	}
+	return nil
+}
+
+type fullTextTrigger struct {
+	name, ddl string
+}
+
+// fullTextTriggers returns the triggers that keep table in step with
+// archives and archive_tags. The last one is created last and serves as the
+// marker that the index is complete.
+func fullTextTriggers(table string) []fullTextTrigger {
+	row := func(ref string) string {
+		return fmt.Sprintf(`INSERT INTO %[1]s (rowid, name, path, profile, metadata, tags) VALUES (%[2]s.id, %[2]s.name, %[2]s.path, COALESCE(%[2]s.profile, ''), COALESCE(%[2]s.metadata, ''),
+			(SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = %[2]s.id));`, table, ref)
+	}
+	tags := func(ref string) string {
+		return fmt.Sprintf(`UPDATE %[1]s SET tags = (SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = %[2]s.archive_id) WHERE rowid = %[2]s.archive_id;`, table, ref)
+	}
+	return []fullTextTrigger{
+		{table + "_ai", fmt.Sprintf(`CREATE TRIGGER %s_ai AFTER INSERT ON archives BEGIN %s END`, table, row("new"))},
+		{table + "_ad", fmt.Sprintf(`CREATE TRIGGER %[1]s_ad AFTER DELETE ON archives BEGIN DELETE FROM %[1]s WHERE rowid = old.id; END`, table)},
+		{table + "_au", fmt.Sprintf(`CREATE TRIGGER %[1]s_au AFTER UPDATE OF id, name, path, profile, metadata ON archives BEGIN DELETE FROM %[1]s WHERE rowid = old.id; %[2]s END`, table, row("new"))},
+		{table + "_tai", fmt.Sprintf(`CREATE TRIGGER %s_tai AFTER INSERT ON archive_tags BEGIN %s END`, table, tags("new"))},
+		{table + "_tad", fmt.Sprintf(`CREATE TRIGGER %s_tad AFTER DELETE ON archive_tags BEGIN %s END`, table, tags("old"))},
+	}
+}
+
+func compileOptionUsed(q querier, option string) bool {
+	var used bool
+	if err := q.QueryRow(`SELECT sqlite_compileoption_used(?)`, option).Scan(&used); err != nil {
+		return false
+	}
+	return used
+}
+
+func triggerExists(db *sql.DB, name string) bool {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?`, name).Scan(&n); err != nil {
+		return false
+	}
+	return n > 0
+}
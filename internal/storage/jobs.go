@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Long-running commands can be queued as jobs in the registry and run by a
+// worker process, so they survive the terminal that started them. A job is
+// the command line to run; the worker claims queued jobs, records which
+// process runs them, and requeues jobs whose worker died.
+
+// Job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// Job is a queued command
+type Job struct {
+	ID       int64      `json:"id"`
+	Type     string     `json:"type"` // command name, e.g. create
+	Args     []string   `json:"args"` // command-line arguments, starting with the command name
+	Dir      string     `json:"dir"`  // working directory relative paths in Args resolve against
+	Status   string     `json:"status"`
+	Attempts int        `json:"attempts"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Host     string     `json:"host,omitempty"` // host and pid of the worker running it
+	PID      int        `json:"pid,omitempty"`
+	Cancel   bool       `json:"cancel_requested,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Done reports whether the job has reached a final state
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// Duration is how long the job ran, or has been running
+func (j *Job) Duration() time.Duration {
+	if j.Started == nil {
+		return 0
+	}
+	if j.Finished != nil {
+		return j.Finished.Sub(*j.Started)
+	}
+	return time.Since(*j.Started)
+}
+
+// jobsDDL creates the job queue
+const jobsDDL = `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		args TEXT NOT NULL,
+		dir TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created TIMESTAMP NOT NULL,
+		started TIMESTAMP,
+		finished TIMESTAMP,
+		host TEXT NOT NULL DEFAULT '',
+		pid INTEGER NOT NULL DEFAULT 0,
+		cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+	`
+
+const jobColumns = `id, type, args, dir, status, attempts, created, started, finished, host, pid, cancel_requested, error`
+
+// EnqueueJob queues a command to run in dir; args start with the command name
+func (r *Registry) EnqueueJob(args []string, dir string) (*Job, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("a job needs a command")
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	j := &Job{Type: args[0], Args: args, Dir: dir, Status: JobQueued, Created: time.Now()}
+	res, err := r.db.Exec(`INSERT INTO jobs (type, args, dir, status, created) VALUES (?, ?, ?, ?, ?)`,
+		j.Type, string(encoded), j.Dir, j.Status, j.Created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue job: %w", err)
+	}
+	j.ID, err = res.LastInsertId()
+	return j, err
+}
+
+// Job returns a job by id
+func (r *Registry) Job(id int64) (*Job, error) {
+	j, err := scanJob(r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job not found: %d", id)
+	}
+	return j, err
+}
+
+// Jobs returns jobs newest first, only those in the given states if any
+func (r *Registry) Jobs(limit int, statuses ...string) ([]*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	var args []interface{}
+	if len(statuses) > 0 {
+		query += ` WHERE status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+		for _, s := range statuses {
+			args = append(args, s)
+		}
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob marks the oldest queued job whose type is below its concurrency
+// limit as running on this host and process, and returns it; nil when there
+// is none. limit gives the most jobs of a type that may run at once across
+// all workers.
+func (r *Registry) ClaimJob(limit func(jobType string) int) (*Job, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	running := map[string]int{}
+	rows, err := tx.Query(`SELECT type, COUNT(*) FROM jobs WHERE status = ? GROUP BY type`, JobRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count running jobs: %w", err)
+	}
+	for rows.Next() {
+		var typ string
+		var n int
+		if err := rows.Scan(&typ, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		running[typ] = n
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`SELECT id, type FROM jobs WHERE status = ? ORDER BY id`, JobQueued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queued jobs: %w", err)
+	}
+	var id int64
+	for rows.Next() {
+		var candidate int64
+		var typ string
+		if err := rows.Scan(&candidate, &typ); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if running[typ] < limit(typ) {
+			id = candidate
+			break
+		}
+	}
+	rows.Close()
+	if id == 0 {
+		return nil, nil
+	}
+
+	host, _ := os.Hostname()
+	res, err := tx.Exec(`UPDATE jobs SET status = ?, attempts = attempts + 1, started = ?, finished = NULL, host = ?, pid = ?, error = ''
+		WHERE id = ? AND status = ?`, JobRunning, time.Now(), host, os.Getpid(), id, JobQueued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil // another worker claimed it first
+	}
+	j, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return j, tx.Commit()
+}
+
+// FinishJob records the final state of a running job
+func (r *Registry) FinishJob(id int64, status, errMsg string) error {
+	_, err := r.db.Exec(`UPDATE jobs SET status = ?, finished = ?, error = ? WHERE id = ? AND status = ?`,
+		status, time.Now(), errMsg, id, JobRunning)
+	if err != nil {
+		return fmt.Errorf("failed to finish job %d: %w", id, err)
+	}
+	return nil
+}
+
+// RequeueJob puts a running job back in the queue to be run again from the
+// start, as when its worker stops before it finishes
+func (r *Registry) RequeueJob(id int64) error {
+	_, err := r.db.Exec(`UPDATE jobs SET status = ?, started = NULL, host = '', pid = 0 WHERE id = ? AND status = ?`, JobQueued, id, JobRunning)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job %d: %w", id, err)
+	}
+	return nil
+}
+
+// CancelJob cancels a queued job at once and asks the worker running a
+// running job to stop it
+func (r *Registry) CancelJob(id int64) (*Job, error) {
+	j, err := r.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	switch j.Status {
+	case JobQueued:
+		_, err = r.db.Exec(`UPDATE jobs SET status = ?, finished = ? WHERE id = ? AND status = ?`, JobCanceled, time.Now(), id, JobQueued)
+	case JobRunning:
+		_, err = r.db.Exec(`UPDATE jobs SET cancel_requested = TRUE WHERE id = ?`, id)
+	default:
+		return nil, fmt.Errorf("job %d is already %s", id, j.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job %d: %w", id, err)
+	}
+	return r.Job(id)
+}
+
+// CancelRequested reports whether a running job has been asked to stop
+func (r *Registry) CancelRequested(id int64) (bool, error) {
+	var cancel bool
+	err := r.db.QueryRow(`SELECT cancel_requested FROM jobs WHERE id = ?`, id).Scan(&cancel)
+	return cancel, err
+}
+
+// RetryJob queues a failed or canceled job to run again
+func (r *Registry) RetryJob(id int64) (*Job, error) {
+	j, err := r.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != JobFailed && j.Status != JobCanceled {
+		return nil, fmt.Errorf("job %d is %s; only failed or canceled jobs can be retried", id, j.Status)
+	}
+	_, err = r.db.Exec(`UPDATE jobs SET status = ?, cancel_requested = FALSE, error = '', started = NULL, finished = NULL,
+		host = '', pid = 0 WHERE id = ?`, JobQueued, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job %d: %w", id, err)
+	}
+	return r.Job(id)
+}
+
+// RecoverJobs requeues running jobs whose worker on this host has exited, so
+// they resume when a worker starts again. Jobs from other hosts are left
+// alone since their workers cannot be checked.
+func (r *Registry) RecoverJobs() ([]*Job, error) {
+	jobs, err := r.Jobs(0, JobRunning)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	var recovered []*Job
+	for _, j := range jobs {
+		if j.Host != host || j.PID == os.Getpid() || processAlive(j.PID) {
+			continue
+		}
+		if j.Cancel {
+			err = r.FinishJob(j.ID, JobCanceled, "worker exited")
+		} else {
+			err = r.RequeueJob(j.ID)
+		}
+		if err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, j)
+	}
+	return recovered, nil
+}
+
+// JobLogPath returns the file a job's output is written to
+func (m *Manager) JobLogPath(id int64) string {
+	return filepath.Join(m.basePath, "jobs", fmt.Sprintf("%d.log", id))
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	j := &Job{}
+	var args string
+	var started, finished sql.NullTime
+	if err := row.Scan(&j.ID, &j.Type, &args, &j.Dir, &j.Status, &j.Attempts, &j.Created, &started, &finished,
+		&j.Host, &j.PID, &j.Cancel, &j.Error); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(args), &j.Args); err != nil {
+		return nil, fmt.Errorf("failed to decode job %d arguments: %w", j.ID, err)
+	}
+	if started.Valid {
+		j.Started = &started.Time
+	}
+	if finished.Valid {
+		j.Finished = &finished.Time
+	}
+	return j, nil
+}
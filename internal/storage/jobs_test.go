@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newJobRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestJobQueueClaimRespectsLimits(t *testing.T) {
+	r := newJobRegistry(t)
+
+	c1, _ := r.EnqueueJob([]string{"create", "--", "/data/a"}, "/home/me")
+	c2, _ := r.EnqueueJob([]string{"create", "--", "/data/b"}, "/home/me")
+	t1, err := r.EnqueueJob([]string{"test", "--", "a.7z"}, "/home/me")
+	if err != nil {
+		t.Fatalf("EnqueueJob: %v", err)
+	}
+
+	limit := func(typ string) int { return 1 }
+	got, err := r.ClaimJob(limit)
+	if err != nil || got == nil || got.ID != c1.ID {
+		t.Fatalf("first claim = %+v, %v; want job %d", got, err, c1.ID)
+	}
+	if got.Status != JobRunning || got.Attempts != 1 || got.PID == 0 || got.Started == nil {
+		t.Errorf("claimed job not marked running: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Args, []string{"create", "--", "/data/a"}) || got.Dir != "/home/me" || got.Type != "create" {
+		t.Errorf("claimed job lost its command: %+v", got)
+	}
+
+	// The second create waits for the first; the test job may start
+	got, err = r.ClaimJob(limit)
+	if err != nil || got == nil || got.ID != t1.ID {
+		t.Fatalf("second claim = %+v, %v; want job %d", got, err, t1.ID)
+	}
+	if got, _ := r.ClaimJob(limit); got != nil {
+		t.Fatalf("claimed %d beyond the concurrency limit", got.ID)
+	}
+
+	if err := r.FinishJob(c1.ID, JobSucceeded, ""); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+	got, err = r.ClaimJob(limit)
+	if err != nil || got == nil || got.ID != c2.ID {
+		t.Fatalf("claim after finish = %+v, %v; want job %d", got, err, c2.ID)
+	}
+
+	jobs, err := r.Jobs(0, JobRunning)
+	if err != nil || len(jobs) != 2 || jobs[0].ID != t1.ID || jobs[1].ID != c2.ID {
+		t.Fatalf("running jobs = %v, %v", jobs, err)
+	}
+}
+
+func TestJobCancelAndRetry(t *testing.T) {
+	r := newJobRegistry(t)
+	queued, _ := r.EnqueueJob([]string{"create", "--", "/a"}, "")
+	running, _ := r.EnqueueJob([]string{"test", "--", "a.7z"}, "")
+	if _, err := r.ClaimJob(func(typ string) int {
+		if typ == "test" {
+			return 1
+		}
+		return 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := r.CancelJob(queued.ID)
+	if err != nil || j.Status != JobCanceled {
+		t.Fatalf("cancel queued = %+v, %v", j, err)
+	}
+	j, err = r.CancelJob(running.ID)
+	if err != nil || j.Status != JobRunning || !j.Cancel {
+		t.Fatalf("cancel running = %+v, %v", j, err)
+	}
+	if requested, _ := r.CancelRequested(running.ID); !requested {
+		t.Error("cancel request not recorded")
+	}
+	if _, err := r.RetryJob(running.ID); err == nil {
+		t.Error("retried a running job")
+	}
+
+	if err := r.FinishJob(running.ID, JobCanceled, "canceled"); err != nil {
+		t.Fatal(err)
+	}
+	j, err = r.RetryJob(running.ID)
+	if err != nil || j.Status != JobQueued || j.Cancel || j.Started != nil {
+		t.Fatalf("retry = %+v, %v", j, err)
+	}
+	if _, err := r.CancelJob(9999); err == nil {
+		t.Error("canceled a job that does not exist")
+	}
+}
+
+func TestRecoverJobsRequeuesDeadWorkers(t *testing.T) {
+	r := newJobRegistry(t)
+	orphan, _ := r.EnqueueJob([]string{"create", "--", "/a"}, "")
+	stopping, _ := r.EnqueueJob([]string{"test", "--", "a.7z"}, "")
+	for range 2 {
+		if _, err := r.ClaimJob(func(string) int { return 1 }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.CancelJob(stopping.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs claimed by this process are never recovered
+	if recovered, err := r.RecoverJobs(); err != nil || len(recovered) != 0 {
+		t.Fatalf("recovered own jobs: %v, %v", recovered, err)
+	}
+
+	// Pretend a worker that has since exited claimed them
+	if _, err := r.db.Exec(`UPDATE jobs SET pid = ?`, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := r.RecoverJobs()
+	if err != nil || len(recovered) != 2 {
+		t.Fatalf("RecoverJobs = %v, %v", recovered, err)
+	}
+	if j, _ := r.Job(orphan.ID); j.Status != JobQueued || j.Attempts != 1 {
+		t.Errorf("orphaned job = %+v, want queued", j)
+	}
+	if j, _ := r.Job(stopping.ID); j.Status != JobCanceled {
+		t.Errorf("job asked to stop = %+v, want canceled", j)
+	}
+}
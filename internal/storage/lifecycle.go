@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lifecycle events a LifecycleHook is told about
+const (
+	LifecycleDelete = "delete" // moved to trash or removed for good
+	LifecyclePurge  = "purge"  // removed from trash and the registry
+	LifecycleTest   = "test"   // integrity tested; details has passed and error
+)
+
+// LifecycleHook is called after an archive is deleted, purged or tested.
+// details carries event-specific values and may be nil.
+type LifecycleHook func(event string, a *Archive, details map[string]string)
+
+// SetLifecycleHook sets the hook called after deletes, purges and tests
+func (m *Manager) SetLifecycleHook(hook LifecycleHook) { m.hook = hook }
+
+// NotifyTested tells the lifecycle hook an archive was tested, whether it
+// passed and what failed
+func (m *Manager) NotifyTested(a *Archive, passed bool, errors []string) {
+	m.notify(LifecycleTest, a, map[string]string{
+		"passed": strconv.FormatBool(passed),
+		"error":  strings.Join(errors, "; "),
+	})
+}
+
+func (m *Manager) notify(event string, a *Archive, details map[string]string) {
+	if m.hook != nil {
+		m.hook(event, a, details)
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Version is one archive in a source's history
+type Version struct {
+	Archive   *Archive
+	SizeDelta int64 // size change from the previous version (0 for the first)
+	Changed   bool  // source fingerprint differs from the previous version
+}
+
+// SourceFingerprint hashes the names, sizes, modes and modification times of
+// everything under root. It is cheap to compute and changes whenever the
+// source tree does, without reading file contents.
+func SourceFingerprint(root string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%o\x00%d\n", filepath.ToSlash(rel), info.Size(), info.Mode(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint %s: %w", root, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VersionedName appends a timestamp to an archive file name, e.g.
+// project.7z -> project-20261018-153045.7z
+func VersionedName(name string, t time.Time) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + t.Format("20060102-150405") + ext
+}
+
+// Register records a newly created archive. If a live row already points at
+// the same file (e.g. an overwrite with --force) that row is refreshed instead
+// of adding a second one; otherwise a new version is added.
+func (m *Manager) Register(archive *Archive) error {
+	if archive.Created.IsZero() {
+		archive.Created = time.Now()
+	}
+	if archive.Status == "" {
+		archive.Status = "present"
+	}
+
+	if existing, err := m.registry.GetByPath(archive.Path); err == nil && existing.Status != "deleted" {
+		archive.ID = existing.ID
+		archive.UID = existing.UID
+		return m.registry.mutate(archive.ID, ActionUpdate, func(tx *sql.Tx) (int64, error) {
+			if err := updateArchive(tx, archive); err != nil {
+				return 0, err
+			}
+			// updateArchive does not touch name/created; keep them in step with the new file
+			if _, err := tx.Exec(`UPDATE archives SET name = ?, created = ? WHERE id = ?`, archive.Name, archive.Created, archive.ID); err != nil {
+				return 0, fmt.Errorf("failed to update archive: %w", err)
+			}
+			return archive.ID, nil
+		})
+	}
+
+	if archive.UID == "" {
+		archive.UID = generateUID()
+	}
+	return m.registry.Add(archive)
+}
+
+// History returns every version created from sourcePath, oldest first, with
+// size deltas and whether the source changed between versions.
+func (m *Manager) History(sourcePath string) ([]Version, error) {
+	archives, err := m.registry.ListBySource(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]Version, len(archives))
+	for i, a := range archives {
+		versions[i] = Version{Archive: a, Changed: i == 0}
+		if i > 0 {
+			prev := archives[i-1]
+			versions[i].SizeDelta = a.Size - prev.Size
+			versions[i].Changed = a.SourceFingerprint == "" || a.SourceFingerprint != prev.SourceFingerprint
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryTracksVersions(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fp1, err := SourceFingerprint(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	add := func(name string, size int64, fp string, at time.Time) {
+		t.Helper()
+		err := mgr.Register(&Archive{
+			Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name), Size: size,
+			Created: at, Managed: true, SourcePath: src, SourceFingerprint: fp,
+		})
+		if err != nil {
+			t.Fatalf("Register %s: %v", name, err)
+		}
+	}
+	add("src.7z", 100, fp1, base)
+	add(VersionedName("src.7z", base.Add(time.Minute)), 100, fp1, base.Add(time.Minute))
+
+	if err := os.WriteFile(filepath.Join(src, "b.txt"), []byte("two"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fp2, _ := SourceFingerprint(src)
+	if fp2 == fp1 {
+		t.Fatal("fingerprint should change when the source changes")
+	}
+	add(VersionedName("src.7z", base.Add(2*time.Minute)), 150, fp2, base.Add(2*time.Minute))
+
+	versions, err := mgr.History(src)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(versions))
+	}
+	if versions[1].Changed || versions[1].SizeDelta != 0 {
+		t.Errorf("second version should be unchanged: %+v", versions[1])
+	}
+	if !versions[2].Changed || versions[2].SizeDelta != 50 {
+		t.Errorf("third version should be changed with +50: %+v", versions[2])
+	}
+}
+
+func TestRegisterRefreshesSamePath(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	path := filepath.Join(mgr.GetArchivesPath(), "same.7z")
+	first := &Archive{Name: "same.7z", Path: path, Size: 1}
+	if err := mgr.Register(first); err != nil {
+		t.Fatal(err)
+	}
+	second := &Archive{Name: "same.7z", Path: path, Size: 2}
+	if err := mgr.Register(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.UID != first.UID {
+		t.Fatal("overwrite should keep the existing row")
+	}
+	all, _ := mgr.List()
+	if len(all) != 1 || all[0].Size != 2 {
+		t.Fatalf("expected one refreshed row, got %d", len(all))
+	}
+}
+
+func TestLineageMigrationDropsUniqueName(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Registry as created before lineage support
+	if _, err := db.Exec(`CREATE TABLE archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		managed BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'present',
+		last_seen TIMESTAMP,
+		deleted_at TIMESTAMP,
+		original_path TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO archives (uid, name, path, size, created, checksum, profile, metadata)
+		VALUES ('01OLD', 'old.7z', '/x/old.7z', 5, ?, '', '', '')`, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	reg, err := NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	defer reg.Close()
+
+	if nameIsUnique(reg.db) {
+		t.Fatal("name should no longer be unique")
+	}
+	old, err := reg.GetByUID("01OLD")
+	if err != nil || old.Name != "old.7z" {
+		t.Fatalf("existing row not preserved: %v", err)
+	}
+	if err := reg.Add(&Archive{UID: generateUID(), Name: "old.7z", Path: "/x/old-2.7z", Size: 6, Created: time.Now(), Status: "present"}); err != nil {
+		t.Fatalf("second version rejected: %v", err)
+	}
+}
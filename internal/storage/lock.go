@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Ordinary commands share the registry through SQLite's own locking. Write-
+// heavy operations that move many files or rewrite the schema (reorganize,
+// purge, prune, migrate, rollback, restore) also take an exclusive process
+// lock: a registry.db.lock file naming the holder. A lock left by a process
+// that has exited on this host is stale and is taken over. While the lock is
+// held, other processes' writes fail fast instead of racing the operation.
+
+// staleUnreadableLock is how old a lock file that cannot be parsed must be
+// before it is treated as left behind; a younger one may still be being
+// written by its holder
+const staleUnreadableLock = time.Minute
+
+// Lock is an exclusive process lock on a registry
+type Lock struct {
+	path string
+}
+
+type lockHolder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Operation string    `json:"operation"`
+	Started   time.Time `json:"started"`
+}
+
+// Lock takes the exclusive process lock on the manager's registry for
+// operation. It fails with a *LockedError when another process holds it.
+func (m *Manager) Lock(operation string) (*Lock, error) {
+	return LockRegistry(m.registry.dbPath, operation)
+}
+
+// LockRegistry takes the exclusive process lock on the registry at dbPath
+// for operation, such as "db reorganize". It fails with a *LockedError when
+// another process holds it.
+func LockRegistry(dbPath, operation string) (*Lock, error) {
+	path := dbPath + ".lock"
+	host, _ := os.Hostname()
+	data, err := json.Marshal(lockHolder{PID: os.Getpid(), Host: host, Operation: operation, Started: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	// A second attempt follows the removal of a stale lock
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, werr := f.Write(data)
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %w", errors.Join(werr, cerr))
+			}
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		holder, stale := readLock(path, host)
+		if !stale {
+			return nil, &LockedError{Path: path, PID: holder.PID, Host: holder.Host, Operation: holder.Operation, Since: holder.Started}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("failed to take registry lock %s", path)
+}
+
+// lockOwn is LockRegistry for work this process may already hold the lock
+// for, such as migrating a registry opened under db import. It returns a nil
+// *Lock, which is safe to release, when the lock is ours already.
+func lockOwn(dbPath, operation string) (*Lock, error) {
+	lock, err := LockRegistry(dbPath, operation)
+	var locked *LockedError
+	if errors.As(err, &locked) && ownLock(lockHolder{PID: locked.PID, Host: locked.Host}) {
+		return nil, nil
+	}
+	return lock, err
+}
+
+// checkLock fails with a *LockedError while another process holds the lock
+// on the registry at dbPath
+func checkLock(dbPath string) error {
+	path := dbPath + ".lock"
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	host, _ := os.Hostname()
+	holder, stale := readLock(path, host)
+	if stale || ownLock(holder) {
+		return nil
+	}
+	return &LockedError{Path: path, PID: holder.PID, Host: holder.Host, Operation: holder.Operation, Since: holder.Started}
+}
+
+// ownLock reports whether holder is this process
+func ownLock(holder lockHolder) bool {
+	host, _ := os.Hostname()
+	return holder.PID == os.Getpid() && holder.Host == host
+}
+
+// readLock returns the holder recorded in the lock file at path and whether
+// the lock was left behind by a process on this host that has exited
+func readLock(path, host string) (lockHolder, bool) {
+	var holder lockHolder
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return holder, true
+	}
+	if err != nil || json.Unmarshal(data, &holder) != nil {
+		info, statErr := os.Stat(path)
+		return holder, statErr == nil && time.Since(info.ModTime()) > staleUnreadableLock
+	}
+	return holder, holder.Host == host && holder.PID != os.Getpid() && !processAlive(holder.PID)
+}
+
+// Release gives up the lock
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	return nil
+}
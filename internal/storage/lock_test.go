@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockRegistry(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	lock, err := LockRegistry(dbPath, "db reorganize")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LockRegistry(dbPath, "trash purge")
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second lock = %v, want *LockedError", err)
+	}
+	if locked.PID != os.Getpid() || !strings.Contains(err.Error(), "running 'db reorganize'") {
+		t.Errorf("unexpected lock error: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = LockRegistry(dbPath, "trash purge")
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestLockRegistryStale(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	host, _ := os.Hostname()
+
+	// A lock left by a process that has exited is taken over
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	if err := child.Run(); err != nil {
+		t.Fatal(err)
+	}
+	writeLock(t, dbPath, lockHolder{PID: child.ProcessState.Pid(), Host: host, Operation: "db migrate", Started: time.Now()})
+	lock, err := LockRegistry(dbPath, "db reorganize")
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	_ = lock.Release()
+
+	// Another host's process cannot be checked, so its lock holds
+	writeLock(t, dbPath, lockHolder{PID: child.ProcessState.Pid(), Host: host + "-other", Operation: "db migrate", Started: time.Now()})
+	if _, err := LockRegistry(dbPath, "db reorganize"); err == nil {
+		t.Error("lock held on another host was taken over")
+	}
+
+	// An unreadable lock is only stale once it is old
+	path := dbPath + ".lock"
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockRegistry(dbPath, "db reorganize"); err == nil {
+		t.Error("fresh unreadable lock was taken over")
+	}
+	old := time.Now().Add(-2 * staleUnreadableLock)
+	_ = os.Chtimes(path, old, old)
+	if lock, err = LockRegistry(dbPath, "db reorganize"); err != nil {
+		t.Fatalf("old unreadable lock not taken over: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func writeLock(t *testing.T, dbPath string, holder lockHolder) {
+	t.Helper()
+	data, _ := json.Marshal(holder)
+	if err := os.WriteFile(dbPath+".lock", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWritesFailWhileLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	reg, err := NewRegistry(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Close()
+	host, _ := os.Hostname()
+	add := func(name string) error {
+		return reg.Add(&Archive{UID: generateUID(), Name: name, Path: "/tmp/" + name, Created: time.Now(), Status: "present"})
+	}
+
+	// The test binary's parent is alive, so its lock holds
+	writeLock(t, dbPath, lockHolder{PID: os.Getppid(), Host: host, Operation: "db reorganize", Started: time.Now()})
+	var locked *LockedError
+	if err := add("a.7z"); !errors.As(err, &locked) || locked.Operation != "db reorganize" {
+		t.Fatalf("add while locked = %v, want *LockedError", err)
+	}
+	if _, err := reg.beginFileOp("/a", "/b", &Archive{ID: 1}); !errors.As(err, &locked) {
+		t.Fatalf("file operation while locked = %v, want *LockedError", err)
+	}
+	_ = os.Remove(dbPath + ".lock")
+
+	// The holder itself keeps writing
+	lock, err := LockRegistry(dbPath, "prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if err := add("b.7z"); err != nil {
+		t.Fatalf("add under own lock: %v", err)
+	}
+}
+
+func TestAutoMigrateLocksAndBacksUp(t *testing.T) {
+	path := loadFixture(t, filepath.Join("testdata", "migrations", "0011_file_ops.sql"))
+	host, _ := os.Hostname()
+
+	writeLock(t, path, lockHolder{PID: os.Getppid(), Host: host, Operation: "db restore", Started: time.Now()})
+	var locked *LockedError
+	if _, err := NewRegistry(path); !errors.As(err, &locked) {
+		t.Fatalf("upgrade while locked = %v, want *LockedError", err)
+	}
+	_ = os.Remove(path + ".lock")
+
+	reg, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	reg.Close()
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock left after upgrade: %v", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "backups", "*"))
+	if len(backups) != 1 {
+		t.Fatalf("backups after upgrade = %v, want one", backups)
+	}
+
+	// Opening an up-to-date registry neither locks nor backs up
+	writeLock(t, path, lockHolder{PID: os.Getppid(), Host: host, Operation: "db restore", Started: time.Now()})
+	reg, err = NewRegistry(path)
+	if err != nil {
+		t.Fatalf("open while locked: %v", err)
+	}
+	reg.Close()
+	if again, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "backups", "*")); len(again) != 1 {
+		t.Errorf("backups after reopening = %v", again)
+	}
+}
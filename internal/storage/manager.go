@@ -10,19 +10,20 @@ import (
 
 // Manager handles the managed storage workspace
 type Manager struct {
-	basePath string
-	registry *Registry
+	basePath  string
+	registry  *Registry
+	layout    Layout
+	backupDir string
+	quota     Quota
+	recovered []RecoveredOp
+	hook      LifecycleHook
 }
 
 // NewManager creates a new storage manager
 func NewManager(basePath string) (*Manager, error) {
-	// Expand tilde to home directory
-	if strings.HasPrefix(basePath, "~/") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		basePath = filepath.Join(home, basePath[2:])
+	basePath, err := expandHome(basePath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the managed storage directory
@@ -40,28 +41,52 @@ func NewManager(basePath string) (*Manager, error) {
 	}
 
 	// Initialize the registry
-	dbPath := filepath.Join(basePath, "registry.db")
-	registry, err := NewRegistry(dbPath)
+	registry, err := NewRegistry(filepath.Join(basePath, "registry.db"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize registry: %w", err)
 	}
-	// Mark current schema baseline/identity as applied for observability
-	_ = registry.RecordCurrentSchemaAsApplied()
-
 	// Backfill missing UIDs
 	_ = registry.BackfillUIDs(func() string { return generateUID() })
 
-	return &Manager{
-		basePath: basePath,
-		registry: registry,
-	}, nil
+	m := &Manager{
+		basePath:  basePath,
+		registry:  registry,
+		backupDir: filepath.Join(basePath, "backups"),
+	}
+	// Finish or roll back file operations interrupted by a crash
+	if m.recovered, err = m.recoverFileOps(); err != nil {
+		registry.Close()
+		return nil, fmt.Errorf("failed to recover interrupted operations: %w", err)
+	}
+	return m, nil
+}
+
+// RegistryPath returns the registry database path for a managed storage
+// base path, without opening or migrating it
+func RegistryPath(basePath string) (string, error) {
+	basePath, err := expandHome(basePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(basePath, "registry.db"), nil
+}
+
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[2:]), nil
 }
 
-// GetManagedPath returns the path where an archive should be stored
+// GetManagedPath returns the path where a new archive should be stored.
+// by_type layouts need a profile; use ManagedPathFor when it is known.
 func (m *Manager) GetManagedPath(archiveName string) string {
-	// For now, use flat organization
-	// Later we can add date-based or type-based organization
-	return filepath.Join(m.basePath, "archives", archiveName)
+	return m.ManagedPathFor(archiveName, "", time.Now())
 }
 
 // Add registers a new archive in the registry
@@ -0,0 +1,447 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of a custom metadata field
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldNumber FieldType = "number"
+	FieldDate   FieldType = "date"
+	FieldBool   FieldType = "bool"
+)
+
+// dateLayouts are accepted when parsing date values, most specific first
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z0-9_][a-z0-9_.-]{0,63}$`)
+
+// Field is a typed custom metadata value attached to an archive. Fields live
+// in the archive_meta table; the Metadata JSON blob is kept as it was.
+type Field struct {
+	Key   string
+	Type  FieldType
+	Value string // canonical text form, see ParseFieldValue
+}
+
+// FieldKey is a key in use with its type and the number of archives carrying it
+type FieldKey struct {
+	Key   string    `json:"key"`
+	Type  FieldType `json:"type"`
+	Count int       `json:"count"`
+}
+
+// NormalizeFieldKey lower-cases a key and checks it is a plain identifier
+func NormalizeFieldKey(key string) (string, error) {
+	k := strings.ToLower(strings.TrimSpace(key))
+	if !fieldKeyPattern.MatchString(k) {
+		return "", fmt.Errorf("invalid field name %q (use letters, digits, '_', '.', '-'; max 64)", key)
+	}
+	return k, nil
+}
+
+// ParseFieldType validates a type name
+func ParseFieldType(s string) (FieldType, error) {
+	switch t := FieldType(strings.ToLower(strings.TrimSpace(s))); t {
+	case FieldString, FieldNumber, FieldDate, FieldBool:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown field type %q (supported: string, number, date, bool)", s)
+	}
+}
+
+// ParseFieldValue converts raw text into the canonical form for a type. An
+// empty type infers one: true/false is a bool, anything strconv can parse is
+// a number, YYYY-MM-DD or RFC 3339 is a date, everything else is a string.
+func ParseFieldValue(raw string, typ FieldType) (FieldType, string, error) {
+	raw = strings.TrimSpace(raw)
+	if typ == "" {
+		typ = inferFieldType(raw)
+	}
+	switch typ {
+	case FieldString:
+		return typ, raw, nil
+	case FieldNumber:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return "", "", fmt.Errorf("%q is not a number", raw)
+		}
+		return typ, strconv.FormatFloat(f, 'f', -1, 64), nil
+	case FieldBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("%q is not a bool", raw)
+		}
+		return typ, strconv.FormatBool(b), nil
+	case FieldDate:
+		t, err := ParseDate(raw)
+		if err != nil {
+			return "", "", err
+		}
+		return typ, formatFieldDate(t), nil
+	default:
+		return "", "", fmt.Errorf("unknown field type %q", typ)
+	}
+}
+
+func inferFieldType(raw string) FieldType {
+	switch strings.ToLower(raw) {
+	case "true", "false":
+		return FieldBool
+	}
+	if _, err := strconv.ParseFloat(raw, 64); err == nil {
+		return FieldNumber
+	}
+	if _, err := ParseDate(raw); err == nil {
+		return FieldDate
+	}
+	return FieldString
+}
+
+// ParseDate parses YYYY-MM-DD or RFC 3339 (with or without zone) as UTC
+func ParseDate(raw string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (use YYYY-MM-DD or RFC 3339)", raw)
+}
+
+// formatFieldDate keeps plain dates short and everything else in RFC 3339
+func formatFieldDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ParseFieldAssignment parses key=value or key:type=value
+func ParseFieldAssignment(s string) (Field, error) {
+	eq := strings.Index(s, "=")
+	if eq <= 0 {
+		return Field{}, fmt.Errorf("invalid assignment %q (expected key=value or key:type=value)", s)
+	}
+	key, raw := s[:eq], s[eq+1:]
+	var typ FieldType
+	if colon := strings.Index(key, ":"); colon >= 0 {
+		t, err := ParseFieldType(key[colon+1:])
+		if err != nil {
+			return Field{}, err
+		}
+		key, typ = key[:colon], t
+	}
+	key, err := NormalizeFieldKey(key)
+	if err != nil {
+		return Field{}, err
+	}
+	typ, value, err := ParseFieldValue(raw, typ)
+	if err != nil {
+		return Field{}, fmt.Errorf("%s: %w", key, err)
+	}
+	return Field{Key: key, Type: typ, Value: value}, nil
+}
+
+// Typed returns the value as string, float64, time.Time or bool
+func (f Field) Typed() interface{} {
+	switch f.Type {
+	case FieldNumber:
+		n, _ := strconv.ParseFloat(f.Value, 64)
+		return n
+	case FieldBool:
+		b, _ := strconv.ParseBool(f.Value)
+		return b
+	case FieldDate:
+		t, _ := ParseDate(f.Value)
+		return t
+	default:
+		return f.Value
+	}
+}
+
+// MarshalJSON emits the typed value so numbers and bools stay JSON-native
+func (f Field) MarshalJSON() ([]byte, error) {
+	switch f.Type {
+	case FieldNumber, FieldBool:
+		return json.Marshal(f.Typed())
+	default:
+		return json.Marshal(f.Value)
+	}
+}
+
+// UnmarshalJSON reads the form MarshalJSON writes. JSON keeps numbers and
+// bools apart; a string is a date when it parses as one. Key is left for the
+// caller to fill in from the map key.
+func (f *Field) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	switch x := v.(type) {
+	case float64:
+		f.Type, f.Value = FieldNumber, strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		f.Type, f.Value = FieldBool, strconv.FormatBool(x)
+	case string:
+		if _, perr := ParseDate(x); perr == nil {
+			f.Type, f.Value, err = ParseFieldValue(x, FieldDate)
+		} else {
+			f.Type, f.Value = FieldString, x
+		}
+	default:
+		return fmt.Errorf("field value must be a string, number or bool, got %s", data)
+	}
+	return err
+}
+
+// FieldList returns an archive's fields sorted by key
+func (a *Archive) FieldList() []Field {
+	out := make([]Field, 0, len(a.Fields))
+	for _, f := range a.Fields {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Condition is a comparison against a custom field, e.g. project_year>=2024
+type Condition struct {
+	Key   string
+	Op    string // = != < <= > >=
+	Value string
+}
+
+var conditionOps = []string{">=", "<=", "!=", "=", ">", "<"}
+
+// ParseCondition parses a --where expression
+func ParseCondition(s string) (Condition, error) {
+	idx := strings.IndexAny(s, "=!<>")
+	if idx <= 0 {
+		return Condition{}, fmt.Errorf("invalid condition %q (expected key<op>value with op one of = != < <= > >=)", s)
+	}
+	key, err := NormalizeFieldKey(s[:idx])
+	if err != nil {
+		return Condition{}, err
+	}
+	rest := s[idx:]
+	for _, op := range conditionOps {
+		if strings.HasPrefix(rest, op) {
+			return Condition{Key: key, Op: op, Value: strings.TrimSpace(rest[len(op):])}, nil
+		}
+	}
+	return Condition{}, fmt.Errorf("invalid operator in condition %q", s)
+}
+
+// ParseConditions parses several --where expressions
+func ParseConditions(exprs []string) ([]Condition, error) {
+	out := make([]Condition, 0, len(exprs))
+	for _, e := range exprs {
+		c, err := ParseCondition(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// String renders the condition back to its --where form
+func (c Condition) String() string { return c.Key + c.Op + c.Value }
+
+// Matches reports whether the archive has the field and the comparison holds.
+// The comparison uses the stored field's type; archives without the field,
+// or whose value cannot be compared, never match.
+func (c Condition) Matches(a *Archive) bool {
+	f, ok := a.Fields[c.Key]
+	if !ok {
+		return false
+	}
+	_, want, err := ParseFieldValue(c.Value, f.Type)
+	if err != nil {
+		return false
+	}
+	var cmp int
+	switch f.Type {
+	case FieldNumber:
+		x, _ := strconv.ParseFloat(f.Value, 64)
+		y, _ := strconv.ParseFloat(want, 64)
+		cmp = compareOrdered(x, y)
+	case FieldDate:
+		x, _ := ParseDate(f.Value)
+		y, _ := ParseDate(want)
+		cmp = x.Compare(y)
+	case FieldBool:
+		if c.Op != "=" && c.Op != "!=" {
+			return false
+		}
+		cmp = strings.Compare(f.Value, want)
+	default:
+		cmp = strings.Compare(f.Value, want)
+	}
+	switch c.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// MatchesAll reports whether every condition holds
+func MatchesAll(a *Archive, conds []Condition) bool {
+	for _, c := range conds {
+		if !c.Matches(a) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareOrdered(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// SetFields creates or replaces fields on an archive
+func (r *Registry) SetFields(archiveID int64, fields ...Field) error {
+	return r.mutate(archiveID, ActionMeta, func(tx *sql.Tx) (int64, error) {
+		return archiveID, setFields(tx, archiveID, fields)
+	})
+}
+
+func setFields(q querier, archiveID int64, fields []Field) error {
+	for _, f := range fields {
+		if _, err := q.Exec(`INSERT OR REPLACE INTO archive_meta (archive_id, key, type, value) VALUES (?, ?, ?, ?)`,
+			archiveID, f.Key, string(f.Type), f.Value); err != nil {
+			return fmt.Errorf("failed to set field %s: %w", f.Key, err)
+		}
+	}
+	return nil
+}
+
+// UnsetFields removes fields from an archive and returns how many were removed
+func (r *Registry) UnsetFields(archiveID int64, keys ...string) (int, error) {
+	normalized := make([]string, len(keys))
+	for i, key := range keys {
+		k, err := NormalizeFieldKey(key)
+		if err != nil {
+			return 0, err
+		}
+		normalized[i] = k
+	}
+	removed := 0
+	err := r.mutate(archiveID, ActionMeta, func(tx *sql.Tx) (int64, error) {
+		var err error
+		removed, err = unsetFields(tx, archiveID, normalized)
+		return archiveID, err
+	})
+	return removed, err
+}
+
+func unsetFields(q querier, archiveID int64, keys []string) (int, error) {
+	removed := 0
+	for _, k := range keys {
+		res, err := q.Exec(`DELETE FROM archive_meta WHERE archive_id = ? AND key = ?`, archiveID, k)
+		if err != nil {
+			return removed, fmt.Errorf("failed to unset field %s: %w", k, err)
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// Fields returns the custom fields of one archive keyed by name
+func (r *Registry) Fields(archiveID int64) (map[string]Field, error) {
+	rows, err := r.db.Query(`SELECT key, type, value FROM archive_meta WHERE archive_id = ?`, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fields: %w", err)
+	}
+	defer rows.Close()
+	var out map[string]Field
+	for rows.Next() {
+		var f Field
+		if err := rows.Scan(&f.Key, &f.Type, &f.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		if out == nil {
+			out = make(map[string]Field)
+		}
+		out[f.Key] = f
+	}
+	return out, rows.Err()
+}
+
+// ListFieldKeys returns every field key in use with its type and archive count
+func (r *Registry) ListFieldKeys() ([]FieldKey, error) {
+	rows, err := r.db.Query(`SELECT key, type, COUNT(*) FROM archive_meta GROUP BY key, type ORDER BY key, type`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fields: %w", err)
+	}
+	defer rows.Close()
+	var out []FieldKey
+	for rows.Next() {
+		var fk FieldKey
+		if err := rows.Scan(&fk.Key, &fk.Type, &fk.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		out = append(out, fk)
+	}
+	return out, rows.Err()
+}
+
+// withFields fills Fields on archives loaded by a list query
+func (r *Registry) withFields(archives []*Archive, err error) ([]*Archive, error) {
+	if err != nil || len(archives) == 0 {
+		return archives, err
+	}
+	where, args := archiveIDsClause(archives)
+	rows, err := r.db.Query(`SELECT archive_id, key, type, value FROM archive_meta`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fields: %w", err)
+	}
+	defer rows.Close()
+	byID := make(map[int64]map[string]Field)
+	for rows.Next() {
+		var id int64
+		var f Field
+		if err := rows.Scan(&id, &f.Key, &f.Type, &f.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		if byID[id] == nil {
+			byID[id] = make(map[string]Field)
+		}
+		byID[id][f.Key] = f
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, a := range archives {
+		a.Fields = byID[a.ID]
+	}
+	return archives, nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFieldAssignment(t *testing.T) {
+	cases := []struct {
+		in        string
+		wantType  FieldType
+		wantValue string
+	}{
+		{"retention_class=legal", FieldString, "legal"},
+		{"Project_Year=2024", FieldNumber, "2024"},
+		{"ratio=0.50", FieldNumber, "0.5"},
+		{"reviewed=2024-06-01", FieldDate, "2024-06-01"},
+		{"signed=TRUE", FieldBool, "true"},
+		{"zip:string=02134", FieldString, "02134"},
+	}
+	for _, c := range cases {
+		f, err := ParseFieldAssignment(c.in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if f.Type != c.wantType || f.Value != c.wantValue {
+			t.Errorf("%s: got %s %q, want %s %q", c.in, f.Type, f.Value, c.wantType, c.wantValue)
+		}
+	}
+	for _, bad := range []string{"novalue", "=x", "year:number=soon", "bad key=1", "k:colour=red"} {
+		if _, err := ParseFieldAssignment(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	a := &Archive{Fields: map[string]Field{}}
+	for _, s := range []string{"retention_class=legal", "project_year=2024", "reviewed=2024-06-01", "signed=true"} {
+		f, _ := ParseFieldAssignment(s)
+		a.Fields[f.Key] = f
+	}
+
+	cases := map[string]bool{
+		"retention_class=legal":  true,
+		"retention_class!=legal": false,
+		"project_year>=2024":     true,
+		"project_year>2024":      false,
+		"project_year<2025":      true,
+		"project_year=2024.0":    true,
+		"project_year>=abc":      false,
+		"reviewed<2024-07-01":    true,
+		"reviewed>2024-06-01":    false,
+		"signed=true":            true,
+		"signed>false":           false,
+		"missing_field=1":        false,
+	}
+	for expr, want := range cases {
+		c, err := ParseCondition(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := c.Matches(a); got != want {
+			t.Errorf("%s: got %v, want %v", expr, got, want)
+		}
+	}
+	if _, err := ParseCondition("no-operator"); err == nil {
+		t.Error("expected error for condition without operator")
+	}
+}
+
+func TestRegistryFields(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	reg := mgr.Registry()
+
+	a := &Archive{UID: generateUID(), Name: "a.7z", Path: filepath.Join(mgr.GetArchivesPath(), "a.7z"), Status: "present", Metadata: `{"note":"kept"}`}
+	if err := reg.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	year, _ := ParseFieldAssignment("project_year=2024")
+	class, _ := ParseFieldAssignment("retention_class=legal")
+	if err := reg.SetFields(a.ID, year, class); err != nil {
+		t.Fatalf("SetFields: %v", err)
+	}
+	year, _ = ParseFieldAssignment("project_year=2025")
+	if err := reg.SetFields(a.ID, year); err != nil {
+		t.Fatalf("SetFields overwrite: %v", err)
+	}
+
+	got, err := reg.GetByID(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Fields["project_year"].Value != "2025" || got.Fields["retention_class"].Value != "legal" {
+		t.Fatalf("unexpected fields: %+v", got.Fields)
+	}
+	if got.Metadata != `{"note":"kept"}` {
+		t.Fatalf("metadata blob changed: %q", got.Metadata)
+	}
+
+	data, err := json.Marshal(got.Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"project_year":2025,"retention_class":"legal"}` {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	list, _ := reg.List()
+	if len(list) != 1 || len(list[0].Fields) != 2 {
+		t.Fatalf("list did not load fields: %+v", list)
+	}
+
+	if n, err := reg.UnsetFields(a.ID, "retention_class"); err != nil || n != 1 {
+		t.Fatalf("UnsetFields: n=%d err=%v", n, err)
+	}
+	keys, _ := reg.ListFieldKeys()
+	if len(keys) != 1 || keys[0] != (FieldKey{Key: "project_year", Type: FieldNumber, Count: 1}) {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+}
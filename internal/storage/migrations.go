@@ -1,14 +1,59 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Migration is one versioned change to the registry schema. Up and Down are
+// SQL scripts run in a single transaction together with the schema_migrations
+// bookkeeping; upFunc and downFunc cover steps SQL cannot express, such as
+// table rebuilds, and run after the script in the same transaction.
+type Migration struct {
+	ID          string // NNNN_name; migrations run in ID order
+	Name        string
+	Description string
+	Up          string
+	Down        string
+
+	upFunc, downFunc func(tx *sql.Tx) error
+	// funcVersion is the revision of upFunc and downFunc. Code cannot be
+	// hashed, so bump it whenever either function changes what it does. The
+	// first version is left out of the checksum so earlier records match.
+	funcVersion int
+
+	// present reports whether a registry created before the migration was
+	// recorded already has its effect; it is then recorded without running
+	present func(q querier) bool
+}
+
+// Version returns the numeric prefix of the migration ID
+func (m Migration) Version() int {
+	n, _ := strconv.Atoi(strings.SplitN(m.ID, "_", 2)[0])
+	return n
+}
+
+// Reversible reports whether the migration can be rolled back
+func (m Migration) Reversible() bool { return m.Down != "" || m.downFunc != nil }
+
+// Checksum identifies the migration's SQL and function version; an applied
+// migration whose checksum no longer matches was edited after it ran
+func (m Migration) Checksum() string {
+	data := m.ID + "\x00" + m.Up + "\x00" + m.Down
+	if m.funcVersion > 1 {
+		data += "\x00" + strconv.Itoa(m.funcVersion)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
 const (
 	migrationBaselineID   = "0001_baseline"
 	migrationBaselineName = "Baseline schema"
@@ -24,8 +69,340 @@ const (
 
 	migrationSearchID   = "0005_search_index"
 	migrationSearchName = "Add search_index table for full-text search support"
+
+	migrationLineageID   = "0006_lineage"
+	migrationLineageName = "Add source lineage columns and allow repeated archive names"
+
+	migrationTagsID   = "0007_tags"
+	migrationTagsName = "Add archive_tags table for archive labels"
+
+	migrationMetaID   = "0008_archive_meta"
+	migrationMetaName = "Add archive_meta table for typed custom fields"
+
+	migrationFullTextID   = "0009_fulltext"
+	migrationFullTextName = "Replace search_index with a trigger-maintained full-text index"
+
+	migrationEventsID   = "0010_events"
+	migrationEventsName = "Add events journal of registry changes"
+
+	migrationFileOpsID   = "0011_file_ops"
+	migrationFileOpsName = "Add file_ops journal of in-flight file moves"
+
+	migrationSourceSizeID   = "0012_source_size"
+	migrationSourceSizeName = "Add source_size for compression history"
+
+	migrationJobsID   = "0013_jobs"
+	migrationJobsName = "Add jobs queue for background commands"
+
+	migrationScheduleRunsID   = "0014_schedule_runs"
+	migrationScheduleRunsName = "Add schedule_runs history of scheduled tasks"
 )
 
+// migrations is the registry schema history, oldest first. Applied
+// migrations must not be edited; add a new one instead.
+var migrations = []Migration{
+	{
+		ID:          migrationBaselineID,
+		Name:        migrationBaselineName,
+		Description: "Creates the archives table and its indexes",
+		Up:          fmt.Sprintf(baselineTableDDL, "archives") + ";" + baselineIndexDDL,
+	},
+	{
+		ID:          migrationIdentityID,
+		Name:        migrationIdentityName,
+		Description: "Adds uid, managed, status and last_seen columns to archives",
+		Up: `
+	ALTER TABLE archives ADD COLUMN uid TEXT;
+	ALTER TABLE archives ADD COLUMN managed BOOLEAN DEFAULT FALSE;
+	ALTER TABLE archives ADD COLUMN status TEXT NOT NULL DEFAULT 'present';
+	ALTER TABLE archives ADD COLUMN last_seen TIMESTAMP;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_archives_uid ON archives(uid);
+	`,
+		downFunc:    func(tx *sql.Tx) error { return rebuildArchives(tx, baselineTableDDL, baselineIndexDDL) },
+		funcVersion: 1,
+		present: func(q querier) bool {
+			return columnExists(q, "archives", "uid") && columnExists(q, "archives", "managed") &&
+				columnExists(q, "archives", "status") && columnExists(q, "archives", "last_seen")
+		},
+	},
+	{
+		ID:          migrationTrashID,
+		Name:        migrationTrashName,
+		Description: "Adds deleted_at and original_path columns for trash functionality",
+		Up: `
+	ALTER TABLE archives ADD COLUMN deleted_at TIMESTAMP;
+	ALTER TABLE archives ADD COLUMN original_path TEXT;
+	`,
+		Down: `
+	ALTER TABLE archives DROP COLUMN deleted_at;
+	ALTER TABLE archives DROP COLUMN original_path;
+	`,
+		present: func(q querier) bool {
+			return columnExists(q, "archives", "deleted_at") && columnExists(q, "archives", "original_path")
+		},
+	},
+	{
+		ID:          migrationQueryID,
+		Name:        migrationQueryName,
+		Description: "Adds queries table for saved query functionality",
+		Up:          queriesDDL,
+		Down:        `DROP TABLE IF EXISTS queries;`,
+	},
+	{
+		ID:          migrationSearchID,
+		Name:        migrationSearchName,
+		Description: "Adds search_index table for full-text search functionality",
+		Up:          searchIndexDDL,
+		Down:        dropSearchIndexDDL,
+	},
+	{
+		ID:          migrationLineageID,
+		Name:        migrationLineageName,
+		Description: "Adds source_path/source_fingerprint and drops the UNIQUE constraint on name",
+		upFunc:      migrateLineage,
+		downFunc:    func(tx *sql.Tx) error { return rebuildArchives(tx, preLineageTableDDL, archivesIndexDDL) },
+		funcVersion: 1,
+	},
+	{
+		ID:          migrationTagsID,
+		Name:        migrationTagsName,
+		Description: "Adds archive_tags table for tagging archives",
+		Up:          archiveTagsDDL,
+		Down:        `DROP TABLE IF EXISTS archive_tags;`,
+	},
+	{
+		ID:          migrationMetaID,
+		Name:        migrationMetaName,
+		Description: "Adds archive_meta table for typed, filterable custom fields",
+		Up:          archiveMetaDDL,
+		Down:        `DROP TABLE IF EXISTS archive_meta;`,
+	},
+	{
+		ID:          migrationFullTextID,
+		Name:        migrationFullTextName,
+		Description: "Drops the unused search_index table; search uses an FTS index kept current by triggers",
+		Up:          dropSearchIndexDDL,
+		Down:        searchIndexDDL,
+		downFunc:    dropFullText,
+		funcVersion: 1,
+	},
+	{
+		ID:          migrationEventsID,
+		Name:        migrationEventsName,
+		Description: "Adds the append-only events table behind log and undo",
+		Up:          eventsDDL,
+		Down:        `DROP TABLE IF EXISTS events;`,
+	},
+	{
+		ID:          migrationFileOpsID,
+		Name:        migrationFileOpsName,
+		Description: "Adds the file_ops table used to recover moves interrupted by a crash",
+		Up:          fileOpsDDL,
+		Down:        `DROP TABLE IF EXISTS file_ops;`,
+	},
+	{
+		ID:          migrationSourceSizeID,
+		Name:        migrationSourceSizeName,
+		Description: "Adds the uncompressed source size used to estimate new archives",
+		// 0006 used to rebuild old tables with the then current schema, which
+		// already had the column
+		upFunc: func(tx *sql.Tx) error {
+			if columnExists(tx, "archives", "source_size") {
+				return nil
+			}
+			_, err := tx.Exec(`ALTER TABLE archives ADD COLUMN source_size INTEGER`)
+			return err
+		},
+		Down:        `ALTER TABLE archives DROP COLUMN source_size;`,
+		funcVersion: 1,
+	},
+	{
+		ID:          migrationJobsID,
+		Name:        migrationJobsName,
+		Description: "Adds the jobs table queuing commands for the background worker",
+		Up:          jobsDDL,
+		Down:        `DROP TABLE IF EXISTS jobs;`,
+	},
+	{
+		ID:          migrationScheduleRunsID,
+		Name:        migrationScheduleRunsName,
+		Description: "Adds the schedule_runs table recording when the daemon ran each schedule",
+		Up:          scheduleRunsDDL,
+		Down:        `DROP TABLE IF EXISTS schedule_runs;`,
+	},
+}
+
+// Migrations returns the known schema migrations, oldest first
+func Migrations() []Migration { return append([]Migration(nil), migrations...) }
+
+// baselineTableDDL is the archives table created by 0001; %s is the table
+// name so 0002 can be rolled back by rebuilding into it
+const baselineTableDDL = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	)`
+
+// baselineIndexDDL creates the indexes that come with the baseline table
+const baselineIndexDDL = `
+	CREATE INDEX IF NOT EXISTS idx_archives_created ON archives(created);
+	CREATE INDEX IF NOT EXISTS idx_archives_uploaded ON archives(uploaded);
+	CREATE INDEX IF NOT EXISTS idx_archives_destination ON archives(destination);
+	CREATE INDEX IF NOT EXISTS idx_archives_checksum ON archives(checksum);
+	`
+
+// preLineageTableDDL is the archives table as it was before 0006, with
+// unique names and no source columns
+const preLineageTableDDL = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		managed BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'present',
+		last_seen TIMESTAMP,
+		deleted_at TIMESTAMP,
+		original_path TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	)`
+
+// lineageTableDDL is the archives table as 0006 leaves it, with repeated
+// names and the source columns. It is frozen: later columns are added by
+// their own migrations.
+const lineageTableDDL = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		managed BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'present',
+		last_seen TIMESTAMP,
+		deleted_at TIMESTAMP,
+		original_path TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT,
+		source_path TEXT,
+		source_fingerprint TEXT
+	)`
+
+// queriesDDL creates the saved query table
+const queriesDDL = `
+	CREATE TABLE IF NOT EXISTS queries (
+		name TEXT PRIMARY KEY,
+		filters TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		last_used INTEGER,
+		use_count INTEGER DEFAULT 0
+	);
+	`
+
+// searchIndexDDL creates the term table from 0005
+const searchIndexDDL = `
+	CREATE TABLE IF NOT EXISTS search_index (
+		term TEXT,
+		archive_uid TEXT,
+		field TEXT,
+		PRIMARY KEY (term, archive_uid, field)
+	);
+	CREATE INDEX IF NOT EXISTS idx_search_term ON search_index(term);
+	`
+
+// dropSearchIndexDDL removes the term table from 0005, which was never
+// populated; the full-text index replaces it
+const dropSearchIndexDDL = `
+	DROP INDEX IF EXISTS idx_search_term;
+	DROP TABLE IF EXISTS search_index;
+	`
+
+// eventsDDL creates the append-only change journal. Rows are never updated or
+// deleted; an undo is a new event pointing at the one it reverts.
+const eventsDDL = `
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created TIMESTAMP NOT NULL,
+		actor TEXT NOT NULL,
+		command TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		archive_id INTEGER NOT NULL,
+		archive_uid TEXT NOT NULL DEFAULT '',
+		archive_name TEXT NOT NULL DEFAULT '',
+		before TEXT,
+		after TEXT,
+		undoes INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_events_archive ON events(archive_id);
+	CREATE INDEX IF NOT EXISTS idx_events_undoes ON events(undoes);
+	CREATE TRIGGER IF NOT EXISTS events_no_update BEFORE UPDATE ON events
+	BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;
+	CREATE TRIGGER IF NOT EXISTS events_no_delete BEFORE DELETE ON events
+	BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;
+	`
+
+// fileOpsDDL creates the intent log for file moves and removals. A row is
+// written before the file is touched and deleted in the transaction that
+// updates the archive, so a row whose process has exited marks an
+// interrupted operation.
+const fileOpsDDL = `
+	CREATE TABLE IF NOT EXISTS file_ops (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started TIMESTAMP NOT NULL,
+		host TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		archive_id INTEGER NOT NULL,
+		src TEXT NOT NULL,
+		dst TEXT NOT NULL DEFAULT '',
+		after TEXT NOT NULL
+	);
+	`
+
+// archiveTagsDDL creates the archive_tags table and its tag lookup index
+const archiveTagsDDL = `
+	CREATE TABLE IF NOT EXISTS archive_tags (
+		archive_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (archive_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_archive_tags_tag ON archive_tags(tag);
+	`
+
+// archiveMetaDDL creates the archive_meta key/value table; values are stored
+// as canonical text alongside their type
+const archiveMetaDDL = `
+	CREATE TABLE IF NOT EXISTS archive_meta (
+		archive_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		type TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (archive_id, key)
+	);
+	CREATE INDEX IF NOT EXISTS idx_archive_meta_key ON archive_meta(key);
+	`
+
+// MigrationRunner applies and rolls back schema migrations
 type MigrationRunner struct {
 	db         *sql.DB
 	backupPath string
@@ -33,14 +410,30 @@ type MigrationRunner struct {
 }
 
 func NewMigrationRunner(db *sql.DB, dbPath string) *MigrationRunner {
-	backupDir := filepath.Dir(dbPath)
 	return &MigrationRunner{
 		db:         db,
-		backupPath: backupDir,
+		backupPath: filepath.Join(filepath.Dir(dbPath), "backups"),
 		timeout:    30 * time.Second,
 	}
 }
 
+// OpenMigrationRunner opens the registry database at dbPath without
+// migrating it, so its schema can be inspected or moved to another version.
+// Close releases the database.
+func OpenMigrationRunner(dbPath string) (*MigrationRunner, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("registry database not found: %w", err)
+	}
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return NewMigrationRunner(db, dbPath), nil
+}
+
+// Close closes the runner's database
+func (mr *MigrationRunner) Close() error { return mr.db.Close() }
+
 type PendingMigration struct {
 	ID          string
 	Name        string
@@ -48,186 +441,215 @@ type PendingMigration struct {
 }
 
 func (mr *MigrationRunner) GetPendingMigrations() ([]PendingMigration, error) {
-	registry := &Registry{db: mr.db}
-	if err := registry.EnsureMigrationsTable(); err != nil {
+	applied, err := mr.appliedIDs()
+	if err != nil {
 		return nil, err
 	}
-
 	var pending []PendingMigration
+	for _, m := range migrations {
+		if !applied[m.ID] {
+			pending = append(pending, PendingMigration{ID: m.ID, Name: m.Name, Description: m.Description})
+		}
+	}
+	return pending, nil
+}
 
-	applied, err := registry.IsMigrationApplied(migrationTrashID)
-	if err != nil {
-		return nil, err
+// CreateBackup writes a consistent backup of the database to the runner's
+// backup directory
+func (mr *MigrationRunner) CreateBackup(dbPath string) (string, error) {
+	if dbPath == "" {
+		return "", fmt.Errorf("database path is required for backup")
 	}
-	if !applied {
-		pending = append(pending, PendingMigration{
-			ID:          migrationTrashID,
-			Name:        migrationTrashName,
-			Description: "Adds deleted_at and original_path columns for trash functionality",
-		})
+	return createBackup(mr.db, mr.backupPath)
+}
+
+// SetBackupDir changes where the runner writes backups
+func (mr *MigrationRunner) SetBackupDir(dir string) {
+	if dir, err := expandHome(dir); err == nil && dir != "" {
+		mr.backupPath = dir
 	}
+}
+
+// ApplyPending backs up the database and applies every pending migration
+func (mr *MigrationRunner) ApplyPending(dbPath string) error {
+	return mr.MigrateTo(dbPath, migrations[len(migrations)-1].ID)
+}
 
-	applied, err = registry.IsMigrationApplied(migrationQueryID)
+// MigrationStep is one migration that MigrateTo or Rollback would run
+type MigrationStep struct {
+	Migration
+	Rollback bool
+}
+
+// Plan returns the steps that bring the schema to target, a migration ID or
+// version number: pending migrations up to and including target are applied,
+// and applied migrations after it are rolled back, newest first
+func (mr *MigrationRunner) Plan(target string) ([]MigrationStep, error) {
+	idx, err := migrationIndex(target)
 	if err != nil {
 		return nil, err
 	}
-	if !applied {
-		pending = append(pending, PendingMigration{
-			ID:          migrationQueryID,
-			Name:        migrationQueryName,
-			Description: "Adds queries table for saved query functionality",
-		})
-	}
-
-	applied, err = registry.IsMigrationApplied(migrationSearchID)
+	applied, err := mr.appliedIDs()
 	if err != nil {
 		return nil, err
 	}
-	if !applied {
-		pending = append(pending, PendingMigration{
-			ID:          migrationSearchID,
-			Name:        migrationSearchName,
-			Description: "Adds search_index table for full-text search functionality",
-		})
+	var steps []MigrationStep
+	for i := len(migrations) - 1; i > idx; i-- {
+		if applied[migrations[i].ID] {
+			steps = append(steps, MigrationStep{Migration: migrations[i], Rollback: true})
+		}
 	}
-
-	return pending, nil
+	for _, m := range migrations[:idx+1] {
+		if !applied[m.ID] {
+			steps = append(steps, MigrationStep{Migration: m})
+		}
+	}
+	for _, s := range steps {
+		if s.Rollback && !s.Reversible() {
+			return nil, fmt.Errorf("migration %s cannot be rolled back", s.ID)
+		}
+	}
+	return steps, nil
 }
 
-func (mr *MigrationRunner) CreateBackup(dbPath string) (string, error) {
-	if dbPath == "" {
-		return "", fmt.Errorf("database path is required for backup")
+// PlanRollback returns the steps that roll back the newest n applied migrations
+func (mr *MigrationRunner) PlanRollback(n int) ([]MigrationStep, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to roll back must be at least 1")
 	}
-
-	timestamp := time.Now().Format("20060102-150405")
-	backupName := fmt.Sprintf("registry-%s.bak", timestamp)
-	backupPath := filepath.Join(mr.backupPath, backupName)
-
-	src, err := os.Open(dbPath)
+	applied, err := mr.appliedIDs()
 	if err != nil {
-		return "", fmt.Errorf("failed to open source database: %w", err)
+		return nil, err
 	}
-	defer src.Close()
-
-	if err := os.MkdirAll(mr.backupPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	var steps []MigrationStep
+	for i := len(migrations) - 1; i >= 0 && len(steps) < n; i-- {
+		m := migrations[i]
+		if !applied[m.ID] {
+			continue
+		}
+		if !m.Reversible() {
+			return nil, fmt.Errorf("migration %s cannot be rolled back", m.ID)
+		}
+		steps = append(steps, MigrationStep{Migration: m, Rollback: true})
 	}
-
-	dst, err := os.OpenFile(backupPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return "", fmt.Errorf("failed to create backup file: %w", err)
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no applied migrations to roll back")
 	}
-	defer dst.Close()
+	return steps, nil
+}
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("failed to copy database: %w", err)
+// MigrateTo backs up the database and moves its schema to target
+func (mr *MigrationRunner) MigrateTo(dbPath, target string) error {
+	steps, err := mr.Plan(target)
+	if err != nil {
+		return err
 	}
-
-	return backupPath, nil
+	return mr.Run(dbPath, steps)
 }
 
-func (mr *MigrationRunner) ApplyPending(dbPath string) error {
-	pending, err := mr.GetPendingMigrations()
+// Rollback backs up the database and rolls back the newest n migrations
+func (mr *MigrationRunner) Rollback(dbPath string, n int) error {
+	steps, err := mr.PlanRollback(n)
 	if err != nil {
-		return fmt.Errorf("failed to get pending migrations: %w", err)
+		return err
 	}
+	return mr.Run(dbPath, steps)
+}
 
-	if len(pending) == 0 {
+// Run backs up the database, then runs each step in its own transaction,
+// stopping at the first failure
+func (mr *MigrationRunner) Run(dbPath string, steps []MigrationStep) error {
+	if len(steps) == 0 {
 		return nil
 	}
-
+	if err := mr.VerifyChecksums(); err != nil {
+		return err
+	}
 	backupPath, err := mr.CreateBackup(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to create backup before migration: %w", err)
 	}
-
-	registry := &Registry{db: mr.db}
-
-	for _, migration := range pending {
-		if err := mr.applyMigration(registry, migration); err != nil {
-			return fmt.Errorf("migration %s failed: %w\nBackup preserved at: %s", migration.ID, err, backupPath)
+	for _, s := range steps {
+		if err := runMigration(mr.db, s.Migration, s.Rollback); err != nil {
+			return fmt.Errorf("%w\nBackup preserved at: %s", err, backupPath)
 		}
 	}
-
 	return nil
 }
 
-func (mr *MigrationRunner) applyMigration(registry *Registry, migration PendingMigration) error {
-	tx, err := mr.db.Begin()
+// runMigration applies or rolls back m and updates schema_migrations in the
+// same transaction. A pending migration whose effect is already present is
+// only recorded.
+func runMigration(db *sql.DB, m Migration, down bool) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	if err := migrateStep(tx, m, down); err != nil {
+		_ = tx.Rollback()
+		if down {
+			return fmt.Errorf("rollback of migration %s failed: %w", m.ID, err)
+		}
+		return fmt.Errorf("migration %s failed: %w", m.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.ID, err)
+	}
+	return nil
+}
 
-	switch migration.ID {
-	case migrationTrashID:
-		if !columnExists(mr.db, "archives", "deleted_at") {
-			if _, err := tx.Exec(`ALTER TABLE archives ADD COLUMN deleted_at TIMESTAMP`); err != nil {
-				_ = tx.Rollback()
-				return fmt.Errorf("failed to add deleted_at column: %w", err)
+func migrateStep(tx *sql.Tx, m Migration, down bool) error {
+	if down {
+		if m.Down != "" {
+			if _, err := tx.Exec(m.Down); err != nil {
+				return err
 			}
 		}
-		if !columnExists(mr.db, "archives", "original_path") {
-			if _, err := tx.Exec(`ALTER TABLE archives ADD COLUMN original_path TEXT`); err != nil {
-				_ = tx.Rollback()
-				return fmt.Errorf("failed to add original_path column: %w", err)
+		if m.downFunc != nil {
+			if err := m.downFunc(tx); err != nil {
+				return err
 			}
 		}
-	case migrationQueryID:
-		if !tableExists(mr.db, "queries") {
-			if _, err := tx.Exec(`
-				CREATE TABLE queries (
-					name TEXT PRIMARY KEY,
-					filters TEXT NOT NULL,
-					created INTEGER NOT NULL,
-					last_used INTEGER,
-					use_count INTEGER DEFAULT 0
-				)
-			`); err != nil {
-				_ = tx.Rollback()
-				return fmt.Errorf("failed to create queries table: %w", err)
+		_, err := tx.Exec(`DELETE FROM schema_migrations WHERE id = ?`, m.ID)
+		return err
+	}
+
+	if m.present == nil || !m.present(tx) {
+		if m.Up != "" {
+			if _, err := tx.Exec(m.Up); err != nil {
+				return err
 			}
 		}
-	case migrationSearchID:
-		if !tableExists(mr.db, "search_index") {
-			if _, err := tx.Exec(`
-				CREATE TABLE search_index (
-					term TEXT,
-					archive_uid TEXT,
-					field TEXT,
-					PRIMARY KEY (term, archive_uid, field)
-				)
-			`); err != nil {
-				_ = tx.Rollback()
-				return fmt.Errorf("failed to create search_index table: %w", err)
-			}
-			// Create performance index
-			if _, err := tx.Exec(`
-				CREATE INDEX idx_search_term ON search_index(term)
-			`); err != nil {
-				_ = tx.Rollback()
-				return fmt.Errorf("failed to create search index: %w", err)
+		if m.upFunc != nil {
+			if err := m.upFunc(tx); err != nil {
+				return err
 			}
 		}
-	default:
-		_ = tx.Rollback()
-		return fmt.Errorf("unknown migration: %s", migration.ID)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit migration: %w", err)
 	}
+	_, err := tx.Exec(`INSERT OR REPLACE INTO schema_migrations (id, name, applied_at, checksum) VALUES (?, ?, ?, ?)`,
+		m.ID, m.Name, time.Now(), m.Checksum())
+	return err
+}
 
-	if err := registry.MarkMigrationApplied(migration.ID, migration.Name); err != nil {
-		return fmt.Errorf("failed to mark migration as applied: %w", err)
+// migrationIndex resolves a migration ID, or its version number, to its
+// position in migrations
+func migrationIndex(target string) (int, error) {
+	n, numeric := strconv.Atoi(target)
+	for i, m := range migrations {
+		if m.ID == target || (numeric == nil && m.Version() == n) {
+			return i, nil
+		}
 	}
-
-	return nil
+	return 0, fmt.Errorf("unknown migration version: %s", target)
 }
 
 type AppliedMigration struct {
 	ID        string
 	Name      string
 	AppliedAt time.Time
+	Checksum  string
+	Known     bool // false for migrations from a newer release
+	Modified  bool // the recorded checksum differs from the migration's
 }
 
 func (mr *MigrationRunner) GetAppliedMigrations() ([]AppliedMigration, error) {
@@ -236,18 +658,27 @@ func (mr *MigrationRunner) GetAppliedMigrations() ([]AppliedMigration, error) {
 		return nil, err
 	}
 
-	rows, err := mr.db.Query(`SELECT id, name, applied_at FROM schema_migrations ORDER BY applied_at`)
+	rows, err := mr.db.Query(`SELECT id, name, applied_at, checksum FROM schema_migrations ORDER BY id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
 	}
 	defer rows.Close()
 
+	known := make(map[string]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.ID] = m
+	}
+
 	var applied []AppliedMigration
 	for rows.Next() {
 		var migration AppliedMigration
-		if err := rows.Scan(&migration.ID, &migration.Name, &migration.AppliedAt); err != nil {
+		if err := rows.Scan(&migration.ID, &migration.Name, &migration.AppliedAt, &migration.Checksum); err != nil {
 			return nil, fmt.Errorf("failed to scan migration row: %w", err)
 		}
+		if m, ok := known[migration.ID]; ok {
+			migration.Known = true
+			migration.Modified = migration.Checksum != m.Checksum()
+		}
 		applied = append(applied, migration)
 	}
 
@@ -258,18 +689,82 @@ func (mr *MigrationRunner) GetAppliedMigrations() ([]AppliedMigration, error) {
 	return applied, nil
 }
 
+// VerifyChecksums returns an error naming the first applied migration whose
+// SQL has changed since it ran
+func (mr *MigrationRunner) VerifyChecksums() error {
+	applied, err := mr.GetAppliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		if m.Modified {
+			return fmt.Errorf("migration %s has changed since it was applied (checksum %s, recorded %s)",
+				m.ID, safeChecksum(migrationChecksum(m.ID)), safeChecksum(m.Checksum))
+		}
+	}
+	return nil
+}
+
+func (mr *MigrationRunner) appliedIDs() (map[string]bool, error) {
+	applied, err := mr.GetAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		ids[m.ID] = true
+	}
+	return ids, nil
+}
+
+func migrationChecksum(id string) string {
+	for _, m := range migrations {
+		if m.ID == id {
+			return m.Checksum()
+		}
+	}
+	return ""
+}
+
+func safeChecksum(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
+
 func (m *Manager) NewMigrationRunner() *MigrationRunner {
-	return NewMigrationRunner(m.registry.db, m.registry.Path())
+	mr := NewMigrationRunner(m.registry.db, m.registry.Path())
+	mr.backupPath = m.backupDir
+	return mr
 }
 
-// EnsureMigrationsTable creates the schema_migrations table if missing
+// EnsureMigrationsTable creates the schema_migrations table if missing and
+// records checksums for migrations applied before they were tracked
 func (r *Registry) EnsureMigrationsTable() error {
-	_, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	if _, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
 		id TEXT PRIMARY KEY,
 		name TEXT NOT NULL,
-		applied_at TIMESTAMP NOT NULL
-	)`)
-	return err
+		applied_at TIMESTAMP NOT NULL,
+		checksum TEXT NOT NULL DEFAULT ''
+	)`); err != nil {
+		return err
+	}
+	if !columnExists(r.db, "schema_migrations", "checksum") {
+		if _, err := r.db.Exec(`ALTER TABLE schema_migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add migration checksums: %w", err)
+		}
+	}
+	var missing int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE checksum = ''`).Scan(&missing); err != nil || missing == 0 {
+		return err
+	}
+	for _, m := range migrations {
+		if _, err := r.db.Exec(`UPDATE schema_migrations SET checksum = ? WHERE id = ? AND checksum = ''`, m.Checksum(), m.ID); err != nil {
+			return fmt.Errorf("failed to record migration checksum: %w", err)
+		}
+	}
+	return nil
 }
 
 // IsMigrationApplied checks if a migration id is recorded
@@ -286,141 +781,178 @@ func (r *Registry) IsMigrationApplied(id string) (bool, error) {
 	}
 }
 
-// MarkMigrationApplied records a migration as applied
+// MarkMigrationApplied records a migration as applied without running it
 func (r *Registry) MarkMigrationApplied(id, name string) error {
-	_, err := r.db.Exec(`INSERT OR REPLACE INTO schema_migrations (id, name, applied_at) VALUES (?, ?, ?)`, id, name, time.Now())
+	_, err := r.db.Exec(`INSERT OR REPLACE INTO schema_migrations (id, name, applied_at, checksum) VALUES (?, ?, ?, ?)`,
+		id, name, time.Now(), migrationChecksum(id))
 	return err
 }
 
-// RecordCurrentSchemaAsApplied marks baseline and identity migrations as applied
-// if the current schema already contains their effects (idempotent).
-func (r *Registry) RecordCurrentSchemaAsApplied() error {
+// ApplyPendingMigrations brings the schema up to date. Registries created
+// before a migration was recorded are detected and the migration recorded
+// without running. Pending migrations run under the registry lock, after a
+// backup of an existing registry to the default backup directory.
+func (r *Registry) ApplyPendingMigrations() error {
 	if err := r.EnsureMigrationsTable(); err != nil {
 		return err
 	}
-	// If archives table exists, we can mark baseline applied
+	mr := NewMigrationRunner(r.db, r.dbPath)
+	if err := mr.VerifyChecksums(); err != nil {
+		return err
+	}
+	target := migrations[len(migrations)-1].ID
+	steps, err := mr.Plan(target)
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+
+	lock, err := lockOwn(r.dbPath, "migrate")
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	// Another process may have migrated before we took the lock
+	if steps, err = mr.Plan(target); err != nil || len(steps) == 0 {
+		return err
+	}
 	if tableExists(r.db, "archives") {
-		applied, err := r.IsMigrationApplied(migrationBaselineID)
-		if err != nil {
-			return err
+		if _, err := mr.CreateBackup(r.dbPath); err != nil {
+			return fmt.Errorf("failed to back up registry before migrating: %w", err)
 		}
-		if !applied {
-			if err := r.MarkMigrationApplied(migrationBaselineID, migrationBaselineName); err != nil {
-				return err
-			}
+	}
+	for _, s := range steps {
+		if err := runMigration(r.db, s.Migration, false); err != nil {
+			return err
 		}
 	}
-	// If identity columns exist, mark identity migration applied
-	if columnExists(r.db, "archives", "uid") && columnExists(r.db, "archives", "managed") && columnExists(r.db, "archives", "status") && columnExists(r.db, "archives", "last_seen") {
-		applied, err := r.IsMigrationApplied(migrationIdentityID)
-		if err != nil {
+	return nil
+}
+
+// migrateLineage adds the source columns and removes the UNIQUE constraint on
+// archives.name. SQLite cannot drop a column constraint, so when one is present
+// the table is rebuilt.
+func migrateLineage(tx *sql.Tx) error {
+	if nameIsUnique(tx) {
+		if err := rebuildArchives(tx, lineageTableDDL, archivesIndexDDL); err != nil {
 			return err
 		}
-		if !applied {
-			if err := r.MarkMigrationApplied(migrationIdentityID, migrationIdentityName); err != nil {
-				return err
+	} else {
+		for _, col := range []string{"source_path", "source_fingerprint"} {
+			if !columnExists(tx, "archives", col) {
+				if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE archives ADD COLUMN %s TEXT`, col)); err != nil {
+					return fmt.Errorf("failed to add %s column: %w", col, err)
+				}
 			}
 		}
 	}
+	_, err := tx.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_archives_name ON archives(name);
+		CREATE INDEX IF NOT EXISTS idx_archives_source ON archives(source_path);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create lineage indexes: %w", err)
+	}
 	return nil
 }
 
-// ApplyPendingMigrations runs known migrations that haven't been marked applied yet
-func (r *Registry) ApplyPendingMigrations() error {
-	if err := r.EnsureMigrationsTable(); err != nil {
-		return err
+// rebuildArchives recreates the archives table from ddl, a template taking
+// the table name, copying every column the old and new tables share, then
+// creates indexes with indexDDL
+func rebuildArchives(tx *sql.Tx, ddl, indexDDL string) error {
+	if _, err := tx.Exec(fmt.Sprintf(ddl, "archives_rebuild")); err != nil {
+		return fmt.Errorf("failed to create archives table: %w", err)
 	}
-	// 0001 & 0002 are effectively applied via initSchema + RecordCurrentSchemaAsApplied
-	// 0003: trash support
-	applied, err := r.IsMigrationApplied(migrationTrashID)
+	newCols, err := tableColumns(tx, "archives_rebuild")
 	if err != nil {
 		return err
 	}
-	if !applied {
-		tx, err := r.db.Begin()
-		if err != nil {
-			return err
+	var common []string
+	for _, col := range newCols {
+		if columnExists(tx, "archives", col) {
+			common = append(common, col)
 		}
-		// Add columns if missing
-		if !columnExists(r.db, "archives", "deleted_at") {
-			if _, err := tx.Exec(`ALTER TABLE archives ADD COLUMN deleted_at TIMESTAMP`); err != nil {
-				_ = tx.Rollback() // best-effort rollback
-				return err
-			}
+	}
+	cols := strings.Join(common, ", ")
+
+	steps := []string{
+		fmt.Sprintf(`INSERT INTO archives_rebuild (%s) SELECT %s FROM archives`, cols, cols),
+		`DROP TABLE archives`,
+		`ALTER TABLE archives_rebuild RENAME TO archives`,
+		indexDDL,
+	}
+	for _, stmt := range steps {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild archives table: %w", err)
 		}
-		if !columnExists(r.db, "archives", "original_path") {
-			if _, err := tx.Exec(`ALTER TABLE archives ADD COLUMN original_path TEXT`); err != nil {
-				_ = tx.Rollback() // best-effort rollback
-				return err
+	}
+	return nil
+}
+
+// dropFullText removes the full-text tables and triggers of every module.
+// A table whose module this build lacks cannot be dropped and is left; its
+// triggers are removed so the archives table stays writable.
+func dropFullText(tx *sql.Tx) error {
+	for _, m := range fullTextModules {
+		for _, t := range fullTextTriggers(m.table) {
+			if _, err := tx.Exec(`DROP TRIGGER IF EXISTS ` + t.name); err != nil {
+				return fmt.Errorf("failed to drop trigger %s: %w", t.name, err)
 			}
 		}
-		if err := tx.Commit(); err != nil {
-			return err
+		if !compileOptionUsed(tx, m.option) {
+			continue
 		}
-		if err := r.MarkMigrationApplied(migrationTrashID, migrationTrashName); err != nil {
-			return err
+		for _, table := range []string{m.table + "_terms", m.table} {
+			if _, err := tx.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+				return fmt.Errorf("failed to drop %s: %w", table, err)
+			}
 		}
 	}
-	
-	// 0004: query system support
-	applied, err = r.IsMigrationApplied(migrationQueryID)
+	return nil
+}
+
+// nameIsUnique reports whether archives.name carries a single-column UNIQUE index
+func nameIsUnique(q querier) bool {
+	rows, err := q.Query(`PRAGMA index_list(archives)`)
 	if err != nil {
-		return err
+		return false
 	}
-	if !applied {
-		// Create queries table if missing
-		if !tableExists(r.db, "queries") {
-			if _, err := r.db.Exec(`
-				CREATE TABLE queries (
-					name TEXT PRIMARY KEY,
-					filters TEXT NOT NULL,
-					created INTEGER NOT NULL,
-					last_used INTEGER,
-					use_count INTEGER DEFAULT 0
-				)
-			`); err != nil {
-				return err
-			}
+	var unique []string
+	for rows.Next() {
+		var seq, isUnique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &isUnique, &origin, &partial); err != nil {
+			rows.Close()
+			return false
 		}
-		if err := r.MarkMigrationApplied(migrationQueryID, migrationQueryName); err != nil {
-			return err
+		if isUnique == 1 {
+			unique = append(unique, name)
 		}
 	}
-	
-	// 0005: search index support
-	applied, err = r.IsMigrationApplied(migrationSearchID)
-	if err != nil {
-		return err
-	}
-	if !applied {
-		// Create search_index table if missing
-		if !tableExists(r.db, "search_index") {
-			if _, err := r.db.Exec(`
-				CREATE TABLE search_index (
-					term TEXT,
-					archive_uid TEXT,
-					field TEXT,
-					PRIMARY KEY (term, archive_uid, field)
-				)
-			`); err != nil {
-				return err
-			}
-			// Create performance index
-			if _, err := r.db.Exec(`
-				CREATE INDEX idx_search_term ON search_index(term)
-			`); err != nil {
-				return err
+	rows.Close()
+
+	for _, idx := range unique {
+		var cols []string
+		info, err := q.Query(fmt.Sprintf(`PRAGMA index_info(%q)`, idx))
+		if err != nil {
+			continue
+		}
+		for info.Next() {
+			var seqno, cid int
+			var col string
+			if err := info.Scan(&seqno, &cid, &col); err == nil {
+				cols = append(cols, col)
 			}
 		}
-		if err := r.MarkMigrationApplied(migrationSearchID, migrationSearchName); err != nil {
-			return err
+		info.Close()
+		if len(cols) == 1 && cols[0] == "name" {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func tableExists(db *sql.DB, table string) bool {
-	row := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name=?`, table)
+func tableExists(q querier, table string) bool {
+	row := q.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name=?`, table)
 	var name string
 	if err := row.Scan(&name); err != nil {
 		return false
@@ -428,23 +960,36 @@ func tableExists(db *sql.DB, table string) bool {
 	return name == table
 }
 
-func columnExists(db *sql.DB, table, column string) bool {
-	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+func columnExists(q querier, table, column string) bool {
+	cols, err := tableColumns(q, table)
 	if err != nil {
 		return false
 	}
+	for _, name := range cols {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// tableColumns returns the column names of table in order
+func tableColumns(q querier, table string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
 	defer rows.Close()
+	var cols []string
 	for rows.Next() {
 		var cid int
 		var name, ctype string
 		var notnull, pk int
 		var dflt sql.NullString
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
-			return false
-		}
-		if name == column {
-			return true
+			return nil, fmt.Errorf("failed to scan column of %s: %w", table, err)
 		}
+		cols = append(cols, name)
 	}
-	return false
+	return cols, rows.Err()
 }
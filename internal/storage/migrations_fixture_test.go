@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Each file in testdata/migrations is a registry as an earlier release left
+// it, dumped with sqlite3 .dump: one per schema version, a registry from
+// before migrations were recorded, and a fresh install from the 0005 era.
+func TestMigrateFixtures(t *testing.T) {
+	head := freshSchema(t)
+	files, err := filepath.Glob(filepath.Join("testdata", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".sql"), func(t *testing.T) {
+			path := loadFixture(t, file)
+			db := openRaw(t, path)
+			before := schemaOf(t, db)
+			version := fixtureVersion(db)
+			names := archiveNames(t, db)
+			tags := countRows(db, "archive_tags")
+			jobs, runs := countRows(db, "jobs"), countRows(db, "schedule_runs")
+			db.Close()
+
+			reg, err := NewRegistry(path)
+			if err != nil {
+				t.Fatalf("upgrade: %v", err)
+			}
+			assertSchema(t, "upgraded", schemaOf(t, reg.db), head)
+			assertNames(t, reg.db, names)
+			if got := countRows(reg.db, "archive_tags"); tags > 0 && got != tags {
+				t.Errorf("tags after upgrade = %d, want %d", got, tags)
+			}
+			if got := countRows(reg.db, "jobs"); got != jobs {
+				t.Errorf("jobs after upgrade = %d, want %d", got, jobs)
+			}
+			if got := countRows(reg.db, "schedule_runs"); got != runs {
+				t.Errorf("schedule runs after upgrade = %d, want %d", got, runs)
+			}
+			pending, err := NewMigrationRunner(reg.db, path).GetPendingMigrations()
+			if err != nil || len(pending) != 0 {
+				t.Fatalf("pending after upgrade = %v, %v", pending, err)
+			}
+			reg.Close()
+
+			if version != "" {
+				runner, err := OpenMigrationRunner(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := runner.MigrateTo(path, version); err != nil {
+					t.Fatalf("migrate down to %s: %v", version, err)
+				}
+				delete(before, "schema_migrations")
+				down := schemaOf(t, runner.db)
+				delete(down, "schema_migrations")
+				assertSchema(t, "rolled back", down, before)
+				assertNames(t, runner.db, names)
+				runner.Close()
+			}
+
+			reg, err = NewRegistry(path)
+			if err != nil {
+				t.Fatalf("upgrade after rollback: %v", err)
+			}
+			defer reg.Close()
+			assertSchema(t, "upgraded again", schemaOf(t, reg.db), head)
+			assertNames(t, reg.db, names)
+
+			// The upgraded registry is writable, repeated names included
+			dup := &Archive{UID: generateUID(), Name: names[0], Path: "/tmp/" + names[0], Size: 1, Created: time.Now(), Status: "present"}
+			if err := reg.Add(dup); err != nil {
+				t.Fatalf("add after upgrade: %v", err)
+			}
+			var hits int
+			ft := reg.FullText().Table
+			if err := reg.db.QueryRow(`SELECT COUNT(*) FROM ` + ft + ` WHERE ` + ft + ` MATCH 'projects'`).Scan(&hits); err != nil || hits != 1 {
+				t.Errorf("full-text hits after upgrade = %d, %v", hits, err)
+			}
+		})
+	}
+}
+
+func loadFixture(t *testing.T, file string) string {
+	t.Helper()
+	script, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "registry.db")
+	db := openRaw(t, path)
+	defer db.Close()
+	if _, err := db.Exec(string(script)); err != nil {
+		t.Fatalf("load %s: %v", file, err)
+	}
+	return path
+}
+
+func openRaw(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func freshSchema(t *testing.T) map[string]string {
+	t.Helper()
+	reg, err := NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Close()
+	return schemaOf(t, reg.db)
+}
+
+// fixtureVersion returns the newest migration recorded in db, or "" when it
+// predates schema_migrations
+func fixtureVersion(db *sql.DB) string {
+	var id sql.NullString
+	_ = db.QueryRow(`SELECT MAX(id) FROM schema_migrations`).Scan(&id)
+	return id.String
+}
+
+// schemaOf maps each table to its sorted columns and each named index to its
+// table. Full-text tables, built outside migrations, are left out.
+func schemaOf(t *testing.T, db *sql.DB) map[string]string {
+	t.Helper()
+	rows, err := db.Query(`SELECT type, name, tbl_name FROM sqlite_master
+		WHERE type IN ('table', 'index') AND name NOT LIKE 'sqlite_%' AND tbl_name NOT LIKE 'archives_fts%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type object struct{ kind, name, table string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.kind, &o.name, &o.table); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+
+	schema := map[string]string{}
+	for _, o := range objects {
+		if o.kind == "index" {
+			schema["index "+o.name] = o.table
+			continue
+		}
+		cols, err := tableColumns(db, o.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(cols)
+		schema[o.name] = strings.Join(cols, ",")
+	}
+	return schema
+}
+
+func assertSchema(t *testing.T, stage string, got, want map[string]string) {
+	t.Helper()
+	for name, w := range want {
+		if g, ok := got[name]; !ok {
+			t.Errorf("%s: missing %s", stage, name)
+		} else if g != w {
+			t.Errorf("%s: %s = %s, want %s", stage, name, g, w)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s: unexpected %s", stage, name)
+		}
+	}
+}
+
+func archiveNames(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM archives ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, n)
+	}
+	return names
+}
+
+func assertNames(t *testing.T, db *sql.DB, want []string) {
+	t.Helper()
+	if got := archiveNames(t, db); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("archives = %v, want %v", got, want)
+	}
+}
+
+func countRows(db *sql.DB, table string) int {
+	var n int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n)
+	return n
+}
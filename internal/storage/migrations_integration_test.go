@@ -88,13 +88,13 @@ func TestMigrationRunner_Integration(t *testing.T) {
 		t.Fatal("original_path column not found after migration")
 	}
 
-	// Verify query and search tables were created
+	// Verify the query table was created and the unused search_index replaced
 	if !tableExists(db, "queries") {
 		t.Fatal("queries table not found after migration")
 	}
 
-	if !tableExists(db, "search_index") {
-		t.Fatal("search_index table not found after migration")
+	if tableExists(db, "search_index") {
+		t.Fatal("search_index table should be dropped by the full-text migration")
 	}
 
 	// Verify data was preserved
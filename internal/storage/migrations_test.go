@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -161,3 +162,136 @@ func TestMigrationRunner_GetAppliedMigrations(t *testing.T) {
 		t.Fatalf("migration timestamp seems too old: %v", migration.AppliedAt)
 	}
 }
+
+func TestMigrationRunner_RollbackAndReapply(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	reg, err := NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	reg.Close()
+
+	runner, err := OpenMigrationRunner(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open runner: %v", err)
+	}
+	// Back to 0009_fulltext, however many migrations came later
+	rolled := len(migrations) - 9
+	if err := runner.Rollback(dbPath, rolled); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if tableExists(runner.db, "events") || tableExists(runner.db, "file_ops") || columnExists(runner.db, "archives", "source_size") ||
+		tableExists(runner.db, "jobs") || tableExists(runner.db, "schedule_runs") {
+		t.Fatal("rolled back tables still exist")
+	}
+	pending, err := runner.GetPendingMigrations()
+	if err != nil || len(pending) != rolled || pending[0].ID != migrationEventsID {
+		t.Fatalf("pending after rollback = %v, %v", pending, err)
+	}
+
+	// Migrating to a version by number applies only up to it
+	if err := runner.MigrateTo(dbPath, "10"); err != nil {
+		t.Fatalf("migrate --to 10 failed: %v", err)
+	}
+	if !tableExists(runner.db, "events") || tableExists(runner.db, "file_ops") {
+		t.Fatal("expected events but not file_ops after migrating to 0010")
+	}
+	runner.Close()
+
+	// Opening the registry brings it back to the latest version
+	reg, err = NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen registry: %v", err)
+	}
+	defer reg.Close()
+	if !tableExists(reg.db, "file_ops") {
+		t.Fatal("file_ops not re-created on open")
+	}
+}
+
+func TestMigrationRunner_PlanErrors(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	reg, err := NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer reg.Close()
+	runner := NewMigrationRunner(reg.db, dbPath)
+
+	if _, err := runner.Plan("0042_missing"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+	if _, err := runner.PlanRollback(len(migrations)); err == nil {
+		t.Error("expected error rolling back the baseline")
+	}
+	steps, err := runner.Plan(migrationTagsID)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	last := len(migrations) - 8
+	if len(steps) != last+1 || !steps[0].Rollback || steps[0].ID != migrations[len(migrations)-1].ID || steps[last].ID != migrationMetaID {
+		t.Fatalf("unexpected plan: %+v", steps)
+	}
+}
+
+func TestMigrationChecksumMismatch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "registry.db")
+	reg, err := NewRegistry(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if _, err := reg.db.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE id = ?`, migrationTagsID); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := NewMigrationRunner(reg.db, dbPath).GetAppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range applied {
+		if m.Modified != (m.ID == migrationTagsID) {
+			t.Errorf("%s modified = %v", m.ID, m.Modified)
+		}
+	}
+	reg.Close()
+
+	if _, err := NewRegistry(dbPath); err == nil || !strings.Contains(err.Error(), migrationTagsID) {
+		t.Fatalf("expected checksum error naming %s, got %v", migrationTagsID, err)
+	}
+}
+
+func TestMigrationChecksumCoversFunctions(t *testing.T) {
+	for _, m := range migrations {
+		if (m.upFunc != nil || m.downFunc != nil) && m.funcVersion < 1 {
+			t.Errorf("%s has functions but no funcVersion", m.ID)
+		}
+	}
+
+	m := migrations[5]
+	if m.ID != migrationLineageID {
+		t.Fatalf("expected %s, got %s", migrationLineageID, m.ID)
+	}
+	before := m.Checksum()
+	m.funcVersion++
+	if m.Checksum() == before {
+		t.Fatal("bumping funcVersion should change the checksum")
+	}
+}
+
+func TestLineageRebuildIsFrozen(t *testing.T) {
+	path := loadFixture(t, filepath.Join("testdata", "migrations", "0005_search_index.sql"))
+	runner, err := OpenMigrationRunner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+	if err := runner.MigrateTo(path, migrationLineageID); err != nil {
+		t.Fatalf("migrate to 0006: %v", err)
+	}
+	// Columns of later migrations come from those migrations, not from 0006
+	if !columnExists(runner.db, "archives", "source_path") || columnExists(runner.db, "archives", "source_size") {
+		t.Fatal("0006 should add the source columns and nothing later")
+	}
+	if nameIsUnique(runner.db) {
+		t.Fatal("0006 should drop the unique constraint on name")
+	}
+}
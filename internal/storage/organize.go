@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Layout controls how archives are arranged under managed storage
+type Layout string
+
+const (
+	LayoutFlat   Layout = "flat"    // archives/<name>
+	LayoutByDate Layout = "by_date" // archives/YYYY/MM/<name>
+	LayoutByType Layout = "by_type" // archives/<profile>/<name>
+)
+
+// ParseLayout validates a StorageConfig.AutoOrganize value; empty means flat
+func ParseLayout(s string) (Layout, error) {
+	switch Layout(strings.ToLower(strings.TrimSpace(s))) {
+	case "", LayoutFlat:
+		return LayoutFlat, nil
+	case LayoutByDate:
+		return LayoutByDate, nil
+	case LayoutByType:
+		return LayoutByType, nil
+	default:
+		return "", fmt.Errorf("unknown layout %q (supported: flat, by_date, by_type)", s)
+	}
+}
+
+// SetLayout sets the managed storage layout used for new and reorganized archives
+func (m *Manager) SetLayout(layout Layout) { m.layout = layout }
+
+// Layout returns the managed storage layout in effect
+func (m *Manager) Layout() Layout {
+	if m.layout == "" {
+		return LayoutFlat
+	}
+	return m.layout
+}
+
+// ManagedPathFor returns where an archive belongs under the current layout.
+// profile and created are only consulted by the by_type and by_date layouts.
+func (m *Manager) ManagedPathFor(archiveName, profile string, created time.Time) string {
+	return filepath.Join(m.GetArchivesPath(), layoutDir(m.Layout(), profile, created), archiveName)
+}
+
+// layoutDir returns the relative directory for an archive under a layout
+func layoutDir(layout Layout, profile string, created time.Time) string {
+	switch layout {
+	case LayoutByDate:
+		if created.IsZero() {
+			created = time.Now()
+		}
+		return filepath.Join(created.Format("2006"), created.Format("01"))
+	case LayoutByType:
+		p := strings.ToLower(strings.TrimSpace(profile))
+		if p == "" {
+			p = "other"
+		}
+		return p
+	default:
+		return ""
+	}
+}
+
+// ReorganizeMove is one planned file move
+type ReorganizeMove struct {
+	Archive *Archive
+	From    string
+	To      string
+}
+
+// PlanReorganize lists the moves needed to bring present managed archives
+// into the current layout, and the archives left out because their file is
+// missing. It fails if any destination is already occupied.
+func (m *Manager) PlanReorganize() ([]ReorganizeMove, []*Archive, error) {
+	archives, err := m.registry.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var moves []ReorganizeMove
+	var missing []*Archive
+	targets := make(map[string]string)
+	for _, a := range archives {
+		if !a.Managed || a.Status == "deleted" {
+			continue
+		}
+		if !m.IsManagedPath(a.Path) {
+			continue
+		}
+		name := a.Name
+		if name == "" {
+			name = filepath.Base(a.Path)
+		}
+		to := m.ManagedPathFor(name, a.Profile, a.Created)
+		if to == a.Path {
+			continue
+		}
+		if _, err := os.Stat(a.Path); err != nil {
+			missing = append(missing, a)
+			continue
+		}
+		if other, dup := targets[to]; dup {
+			return nil, nil, fmt.Errorf("archives %s and %s both map to %s", other, a.Name, to)
+		}
+		if _, err := os.Stat(to); err == nil {
+			return nil, nil, fmt.Errorf("destination already exists: %s", to)
+		}
+		targets[to] = a.Name
+		moves = append(moves, ReorganizeMove{Archive: a, From: a.Path, To: to})
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].From < moves[j].From })
+	return moves, missing, nil
+}
+
+// Reorganize moves managed archives and their .log/.sha256 sidecars into the
+// current layout, all or nothing. Each move is recorded in the file_ops
+// journal before its file moves, and the registry paths are committed in one
+// transaction once every file is in place. If a move or the commit fails,
+// files already moved are put back and the registry is left unchanged.
+func (m *Manager) Reorganize(moves []ReorganizeMove) error {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	var done []pendingFileOp
+	undo := func(cause error) error {
+		var failed []string
+		for i := len(done) - 1; i >= 0; i-- {
+			op := done[i]
+			if err := relocateFile(op.dst, op.src); err != nil {
+				// The intent stays, so the next start completes this move
+				failed = append(failed, fmt.Sprintf("%s (%v)", op.dst, err))
+				continue
+			}
+			moveSidecars(op.dst, op.src)
+			_ = m.registry.endFileOp(op.id)
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("%w; could not restore: %s", cause, strings.Join(failed, ", "))
+		}
+		return cause
+	}
+
+	for _, mv := range moves {
+		after := *mv.Archive
+		after.Path = mv.To
+		// #nosec G301: restrict permissions on managed storage directories
+		if err := os.MkdirAll(filepath.Dir(mv.To), 0750); err != nil {
+			return undo(fmt.Errorf("failed to create %s: %w", filepath.Dir(mv.To), err))
+		}
+		opID, err := m.registry.beginFileOp(mv.From, mv.To, &after)
+		if err != nil {
+			return undo(err)
+		}
+		failpoint("intent")
+		if err := relocateFile(mv.From, mv.To); err != nil {
+			_ = m.registry.endFileOp(opID)
+			return undo(fmt.Errorf("failed to move %s: %w", mv.From, err))
+		}
+		failpoint("file")
+		moveSidecars(mv.From, mv.To)
+		done = append(done, pendingFileOp{id: opID, src: mv.From, dst: mv.To, after: &after})
+	}
+
+	if err := m.registry.commitFileOps(done); err != nil {
+		return undo(fmt.Errorf("failed to commit reorganize: %w", err))
+	}
+
+	emptied := make([]string, 0, len(moves))
+	for i, mv := range moves {
+		*mv.Archive = *done[i].after
+		emptied = append(emptied, filepath.Dir(mv.From))
+	}
+	removeEmptiedDirs(m.GetArchivesPath(), emptied)
+	return nil
+}
+
+// sidecarExts are the files kept next to an archive that move with it
+var sidecarExts = []string{".log", ".sha256"}
+
+// moveSidecars moves the sidecars of an archive moved from src to dst. They
+// are not in the registry, so failures are ignored.
+func moveSidecars(src, dst string) {
+	for _, ext := range sidecarExts {
+		if _, err := os.Stat(src + ext); err == nil {
+			_ = relocateFile(src+ext, dst+ext)
+		}
+	}
+}
+
+// relocateFile renames src to dst, falling back to copy+remove across devices
+var relocateFile = func(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("destination already exists: %s", dst)
+	}
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+		return err
+	}
+	// #nosec G304: src and dst are inside managed storage
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// #nosec G304: destination is inside managed storage
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// removeEmptiedDirs removes dirs, and then their parents up to root, once
+// moving archives out has left them empty. Directories that were empty
+// before are not in dirs and are left alone.
+func removeEmptiedDirs(root string, dirs []string) {
+	// Deepest first so parents empty out as children are removed
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		for dir != root && isWithin(root, dir) {
+			if os.Remove(dir) != nil { // fails when not empty or already gone
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+}
+
+// PlaceManaged moves a freshly registered managed archive (and its
+// .log/.sha256 sidecars) to the location the layout prescribes once its
+// profile is known. The move goes through MoveArchive, so the registry row
+// never points at a path the file has left.
+func (m *Manager) PlaceManaged(a *Archive) error {
+	from := a.Path
+	target := m.ManagedPathFor(filepath.Base(from), a.Profile, a.Created)
+	if target == from {
+		return nil
+	}
+	if err := m.MoveArchive(a, target, nil); err != nil {
+		return err
+	}
+	moveSidecars(from, target)
+	removeEmptiedDirs(m.GetArchivesPath(), []string{filepath.Dir(from)})
+	return nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManagedPathForLayouts(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	created := time.Date(2026, 10, 3, 12, 0, 0, 0, time.UTC)
+	root := mgr.GetArchivesPath()
+	cases := []struct {
+		layout  Layout
+		profile string
+		want    string
+	}{
+		{LayoutFlat, "Media", filepath.Join(root, "a.7z")},
+		{LayoutByDate, "Media", filepath.Join(root, "2026", "10", "a.7z")},
+		{LayoutByType, "Media", filepath.Join(root, "media", "a.7z")},
+		{LayoutByType, "", filepath.Join(root, "other", "a.7z")},
+	}
+	for _, c := range cases {
+		mgr.SetLayout(c.layout)
+		if got := mgr.ManagedPathFor("a.7z", c.profile, created); got != c.want {
+			t.Errorf("%s/%q: got %s, want %s", c.layout, c.profile, got, c.want)
+		}
+	}
+
+	if _, err := ParseLayout("by_size"); err == nil {
+		t.Error("expected error for unknown layout")
+	}
+}
+
+func TestReorganizeMovesFilesAndRows(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	for _, name := range []string{"one.7z", "two.7z"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		if err := os.WriteFile(p, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.Add(name, p, 1, "Media", "", "", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(mgr.GetArchivesPath(), "one.7z.sha256"), []byte("sum"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.SetLayout(LayoutByType)
+	moves, missing, err := mgr.PlanReorganize()
+	if err != nil || len(moves) != 2 || len(missing) != 0 {
+		t.Fatalf("PlanReorganize: moves=%d missing=%d err=%v", len(moves), len(missing), err)
+	}
+	if err := mgr.Reorganize(moves); err != nil {
+		t.Fatalf("Reorganize: %v", err)
+	}
+
+	a, err := mgr.Get("one.7z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(mgr.GetArchivesPath(), "media", "one.7z")
+	if a.Path != want {
+		t.Fatalf("registry path = %s, want %s", a.Path, want)
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Fatalf("file not moved: %v", err)
+	}
+	if _, err := os.Stat(want + ".sha256"); err != nil {
+		t.Fatalf("sidecar not moved: %v", err)
+	}
+
+	if moves, _, _ := mgr.PlanReorganize(); len(moves) != 0 {
+		t.Fatalf("expected no further moves, got %d", len(moves))
+	}
+}
+
+func TestReorganizeRollsBackOnFailure(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	var paths []string
+	for _, name := range []string{"a.7z", "b.7z"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		if err := os.WriteFile(p, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.Add(name, p, 1, "Documents", "", "", true); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	if err := os.WriteFile(paths[0]+".log", []byte("log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the second archive move
+	orig := relocateFile
+	defer func() { relocateFile = orig }()
+	calls := 0
+	relocateFile = func(src, dst string) error {
+		if filepath.Ext(src) == ".7z" {
+			calls++
+		}
+		if calls == 2 {
+			return errors.New("injected failure")
+		}
+		return orig(src, dst)
+	}
+
+	mgr.SetLayout(LayoutByDate)
+	moves, _, err := mgr.PlanReorganize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Reorganize(moves); err == nil {
+		t.Fatal("expected reorganize to fail")
+	}
+
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("file not restored: %s", p)
+		}
+		a, err := mgr.Get(filepath.Base(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Path != p {
+			t.Errorf("registry path changed: %s", a.Path)
+		}
+	}
+	if _, err := os.Stat(paths[0] + ".log"); err != nil {
+		t.Error("sidecar not restored")
+	}
+	var intents int
+	_ = mgr.registry.db.QueryRow(`SELECT COUNT(*) FROM file_ops`).Scan(&intents)
+	if intents != 0 {
+		t.Errorf("%d file operations left in the journal", intents)
+	}
+}
+
+func TestReorganizeSkipsMissingFilesAndKeepsUserDirs(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	userDir := filepath.Join(mgr.GetArchivesPath(), "keep-me")
+	if err := os.MkdirAll(userDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(mgr.GetArchivesPath(), "old", "nested")
+	if err := os.MkdirAll(nested, 0750); err != nil {
+		t.Fatal(err)
+	}
+	present := filepath.Join(nested, "here.7z")
+	if err := os.WriteFile(present, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(mgr.GetArchivesPath(), "gone.7z")
+	for name, p := range map[string]string{"here.7z": present, "gone.7z": gone} {
+		if err := mgr.Add(name, p, 1, "Media", "", "", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mgr.SetLayout(LayoutByType)
+	moves, missing, err := mgr.PlanReorganize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || len(missing) != 1 || missing[0].Name != "gone.7z" {
+		t.Fatalf("moves=%d missing=%v", len(moves), missing)
+	}
+	if err := mgr.Reorganize(moves); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, _ := mgr.Get("gone.7z"); a.Path != gone {
+		t.Errorf("missing archive's path was rewritten to %s", a.Path)
+	}
+	if _, err := os.Stat(filepath.Join(mgr.GetArchivesPath(), "old")); !os.IsNotExist(err) {
+		t.Error("directories emptied by the move should be removed")
+	}
+	if _, err := os.Stat(userDir); err != nil {
+		t.Error("an empty directory the run did not touch was removed")
+	}
+}
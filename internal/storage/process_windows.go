@@ -0,0 +1,19 @@
+//go:build windows
+
+package storage
+
+import "os"
+
+// processAlive reports whether a process with the given pid is running;
+// FindProcess opens a handle on Windows and fails for exited processes
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,8 +13,9 @@ import (
 
 // Registry manages the SQLite database for archive metadata
 type Registry struct {
-	db     *sql.DB
-	dbPath string
+	db       *sql.DB
+	dbPath   string
+	fullText FullText
 }
 
 // NewRegistry creates a new registry instance
@@ -26,7 +28,7 @@ func NewRegistry(dbPath string) (*Registry, error) {
 	}
 
 	// Open the database
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := openDB(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -48,30 +50,21 @@ func NewRegistry(dbPath string) (*Registry, error) {
 	return r, nil
 }
 
-// initSchema creates the database tables if they don't exist and applies migrations
-func (r *Registry) initSchema() error {
-	// Create full modern schema for new installations
-	query := `
-	CREATE TABLE IF NOT EXISTS archives (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		uid TEXT UNIQUE,
-		name TEXT UNIQUE NOT NULL,
-		path TEXT NOT NULL,
-		size INTEGER NOT NULL,
-		created TIMESTAMP NOT NULL,
-		checksum TEXT,
-		profile TEXT,
-		managed BOOLEAN DEFAULT FALSE,
-		status TEXT NOT NULL DEFAULT 'present',
-		last_seen TIMESTAMP,
-		deleted_at TIMESTAMP,
-		original_path TEXT,
-		uploaded BOOLEAN DEFAULT FALSE,
-		destination TEXT,
-		uploaded_at TIMESTAMP,
-		metadata TEXT
-	);
+// busyTimeout is how long a connection waits for another connection's write
+// lock before failing with "database is locked"
+const busyTimeout = 5 * time.Second
+
+// openDB opens the registry database at path. WAL mode lets readers such as
+// browse run while another process writes, the busy timeout makes writers
+// wait their turn, and immediate transactions take the write lock up front
+// so two writers cannot deadlock upgrading from a read lock.
+func openDB(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate", path, busyTimeout.Milliseconds())
+	return sql.Open("sqlite3", dsn)
+}
 
+// archivesIndexDDL creates the archives indexes that predate lineage
+const archivesIndexDDL = `
 	CREATE INDEX IF NOT EXISTS idx_archives_created ON archives(created);
 	CREATE INDEX IF NOT EXISTS idx_archives_uploaded ON archives(uploaded);
 	CREATE INDEX IF NOT EXISTS idx_archives_destination ON archives(destination);
@@ -79,89 +72,81 @@ func (r *Registry) initSchema() error {
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_archives_uid ON archives(uid);
 	`
 
-	if _, err := r.db.Exec(query); err != nil {
-		return err
-	}
-
-	// Apply proper migration system
-	if err := r.RecordCurrentSchemaAsApplied(); err != nil {
-		return fmt.Errorf("failed to record schema state: %w", err)
-	}
-
+// initSchema brings the schema up to date by applying pending migrations;
+// a new registry is built by running all of them
+func (r *Registry) initSchema() error {
 	if err := r.ApplyPendingMigrations(); err != nil {
 		return fmt.Errorf("failed to apply migrations: %w", err)
 	}
 
+	if err := r.ensureFullText(); err != nil {
+		return fmt.Errorf("failed to initialize full-text index: %w", err)
+	}
+
 	return nil
 }
 
 // Add inserts a new archive into the registry
 func (r *Registry) Add(archive *Archive) error {
-	query := `
-	INSERT INTO archives (uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	result, err := r.db.Exec(query,
-		archive.UID,
-		archive.Name,
-		archive.Path,
-		archive.Size,
-		archive.Created,
-		archive.Checksum,
-		archive.Profile,
-		archive.Managed,
-		archive.Status,
-		archive.LastSeen,
-		archive.DeletedAt,
-		archive.OriginalPath,
-		archive.Uploaded,
-		archive.Destination,
-		archive.UploadedAt,
-		archive.Metadata,
-	)
-
+	tags, err := NormalizeTags(archive.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to add archive: %w", err)
+		return err
 	}
+	query := `
+	INSERT INTO archives (uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata, source_path, source_fingerprint, source_size)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
-	}
+	return r.mutate(0, ActionAdd, func(tx *sql.Tx) (int64, error) {
+		result, err := tx.Exec(query,
+			archive.UID,
+			archive.Name,
+			archive.Path,
+			archive.Size,
+			archive.Created,
+			archive.Checksum,
+			archive.Profile,
+			archive.Managed,
+			archive.Status,
+			archive.LastSeen,
+			archive.DeletedAt,
+			archive.OriginalPath,
+			archive.Uploaded,
+			archive.Destination,
+			archive.UploadedAt,
+			archive.Metadata,
+			archive.SourcePath,
+			archive.SourceFingerprint,
+			archive.SourceSize,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add archive: %w", err)
+		}
 
-	archive.ID = id
-	return nil
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		archive.ID = id
+		if err := addTags(tx, id, tags); err != nil {
+			return 0, err
+		}
+		return id, setFields(tx, id, archive.FieldList())
+	})
 }
 
-// Get retrieves an archive by name
+// Get retrieves an archive by name. Names are not unique across versions;
+// the most recently created match is returned.
 func (r *Registry) Get(name string) (*Archive, error) {
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE name = ?
+	ORDER BY created DESC
+	LIMIT 1
 	`
 
-	archive := &Archive{}
-	err := r.db.QueryRow(query, name).Scan(
-		&archive.ID,
-		&archive.UID,
-		&archive.Name,
-		&archive.Path,
-		&archive.Size,
-		&archive.Created,
-		&archive.Checksum,
-		&archive.Profile,
-		&archive.Managed,
-		&archive.Status,
-		&archive.LastSeen,
-		&archive.DeletedAt,
-		&archive.OriginalPath,
-		&archive.Uploaded,
-		&archive.Destination,
-		&archive.UploadedAt,
-		&archive.Metadata,
-	)
+	archive, err := scanArchive(r.db.QueryRow(query, name))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("archive not found: %s", name)
@@ -170,7 +155,7 @@ func (r *Registry) Get(name string) (*Archive, error) {
 		return nil, fmt.Errorf("failed to get archive: %w", err)
 	}
 
-	return archive, nil
+	return r.hydrateOne(archive, nil)
 }
 
 // Exists reports whether an archive with the given name exists.
@@ -187,7 +172,7 @@ func (r *Registry) Exists(name string) (bool, error) {
 // List returns all archives
 func (r *Registry) List() ([]*Archive, error) {
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	ORDER BY created DESC
 	`
@@ -198,41 +183,13 @@ func (r *Registry) List() ([]*Archive, error) {
 	}
 	defer rows.Close()
 
-	var archives []*Archive
-	for rows.Next() {
-		archive := &Archive{}
-		err := rows.Scan(
-			&archive.ID,
-			&archive.UID,
-			&archive.Name,
-			&archive.Path,
-			&archive.Size,
-			&archive.Created,
-			&archive.Checksum,
-			&archive.Profile,
-			&archive.Managed,
-			&archive.Status,
-			&archive.LastSeen,
-			&archive.DeletedAt,
-			&archive.OriginalPath,
-			&archive.Uploaded,
-			&archive.Destination,
-			&archive.UploadedAt,
-			&archive.Metadata,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan archive: %w", err)
-		}
-		archives = append(archives, archive)
-	}
-
-	return archives, rows.Err()
+	return r.hydrate(scanArchives(rows))
 }
 
 // ListNotUploaded returns archives that haven't been uploaded
 func (r *Registry) ListNotUploaded() ([]*Archive, error) {
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE uploaded = FALSE
 	ORDER BY created DESC
@@ -244,42 +201,14 @@ func (r *Registry) ListNotUploaded() ([]*Archive, error) {
 	}
 	defer rows.Close()
 
-	var archives []*Archive
-	for rows.Next() {
-		archive := &Archive{}
-		err := rows.Scan(
-			&archive.ID,
-			&archive.UID,
-			&archive.Name,
-			&archive.Path,
-			&archive.Size,
-			&archive.Created,
-			&archive.Checksum,
-			&archive.Profile,
-			&archive.Managed,
-			&archive.Status,
-			&archive.LastSeen,
-			&archive.DeletedAt,
-			&archive.OriginalPath,
-			&archive.Uploaded,
-			&archive.Destination,
-			&archive.UploadedAt,
-			&archive.Metadata,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan archive: %w", err)
-		}
-		archives = append(archives, archive)
-	}
-
-	return archives, rows.Err()
+	return r.hydrate(scanArchives(rows))
 }
 
 // ListOlderThan returns archives older than the specified duration
 func (r *Registry) ListOlderThan(duration time.Duration) ([]*Archive, error) {
 	cutoff := time.Now().Add(-duration)
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE created < ?
 	ORDER BY created DESC
@@ -291,46 +220,25 @@ func (r *Registry) ListOlderThan(duration time.Duration) ([]*Archive, error) {
 	}
 	defer rows.Close()
 
-	var archives []*Archive
-	for rows.Next() {
-		archive := &Archive{}
-		err := rows.Scan(
-			&archive.ID,
-			&archive.UID,
-			&archive.Name,
-			&archive.Path,
-			&archive.Size,
-			&archive.Created,
-			&archive.Checksum,
-			&archive.Profile,
-			&archive.Managed,
-			&archive.Status,
-			&archive.LastSeen,
-			&archive.DeletedAt,
-			&archive.OriginalPath,
-			&archive.Uploaded,
-			&archive.Destination,
-			&archive.UploadedAt,
-			&archive.Metadata,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan archive: %w", err)
-		}
-		archives = append(archives, archive)
-	}
-
-	return archives, rows.Err()
+	return r.hydrate(scanArchives(rows))
 }
 
 // Update updates an existing archive
 func (r *Registry) Update(archive *Archive) error {
+	return r.mutate(archive.ID, "", func(tx *sql.Tx) (int64, error) {
+		return archive.ID, updateArchive(tx, archive)
+	})
+}
+
+// updateArchive writes every column but name and created
+func updateArchive(q querier, archive *Archive) error {
 	query := `
 	UPDATE archives
-	SET uid = ?, path = ?, size = ?, checksum = ?, profile = ?, managed = ?, status = ?, last_seen = ?, deleted_at = ?, original_path = ?, uploaded = ?, destination = ?, uploaded_at = ?, metadata = ?
+	SET uid = ?, path = ?, size = ?, checksum = ?, profile = ?, managed = ?, status = ?, last_seen = ?, deleted_at = ?, original_path = ?, uploaded = ?, destination = ?, uploaded_at = ?, metadata = ?, source_path = ?, source_fingerprint = ?, source_size = ?
 	WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query,
+	_, err := q.Exec(query,
 		archive.UID,
 		archive.Path,
 		archive.Size,
@@ -345,6 +253,9 @@ func (r *Registry) Update(archive *Archive) error {
 		archive.Destination,
 		archive.UploadedAt,
 		archive.Metadata,
+		archive.SourcePath,
+		archive.SourceFingerprint,
+		archive.SourceSize,
 		archive.ID,
 	)
 
@@ -381,71 +292,49 @@ func (r *Registry) BackfillUIDs(gen func() string) error {
 // GetByID retrieves an archive by numeric id
 func (r *Registry) GetByID(id int64) (*Archive, error) {
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE id = ?`
-	archive := &Archive{}
-	err := r.db.QueryRow(query, id).Scan(
-		&archive.ID,
-		&archive.UID,
-		&archive.Name,
-		&archive.Path,
-		&archive.Size,
-		&archive.Created,
-		&archive.Checksum,
-		&archive.Profile,
-		&archive.Managed,
-		&archive.Status,
-		&archive.LastSeen,
-		&archive.DeletedAt,
-		&archive.OriginalPath,
-		&archive.Uploaded,
-		&archive.Destination,
-		&archive.UploadedAt,
-		&archive.Metadata,
-	)
+	archive, err := scanArchive(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("archive not found: %d", id)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get archive by id: %w", err)
 	}
-	return archive, nil
+	return r.hydrateOne(archive, nil)
 }
 
 // GetByUID retrieves an archive by exact UID
 func (r *Registry) GetByUID(uid string) (*Archive, error) {
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE uid = ?`
-	archive := &Archive{}
-	err := r.db.QueryRow(query, uid).Scan(
-		&archive.ID,
-		&archive.UID,
-		&archive.Name,
-		&archive.Path,
-		&archive.Size,
-		&archive.Created,
-		&archive.Checksum,
-		&archive.Profile,
-		&archive.Managed,
-		&archive.Status,
-		&archive.LastSeen,
-		&archive.DeletedAt,
-		&archive.OriginalPath,
-		&archive.Uploaded,
-		&archive.Destination,
-		&archive.UploadedAt,
-		&archive.Metadata,
-	)
+	archive, err := scanArchive(r.db.QueryRow(query, uid))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("archive not found: %s", uid)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get archive by uid: %w", err)
 	}
-	return archive, nil
+	return r.hydrateOne(archive, nil)
+}
+
+// GetByPath retrieves an archive by its current file path
+func (r *Registry) GetByPath(path string) (*Archive, error) {
+	query := `
+	SELECT ` + archiveColumns + `
+	FROM archives
+	WHERE path = ?`
+	archive, err := scanArchive(r.db.QueryRow(query, path))
+	if err == sql.ErrNoRows {
+		return nil, &ArchiveNotFoundError{ID: path}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive by path: %w", err)
+	}
+	return r.hydrateOne(archive, nil)
 }
 
 // FindByUIDPrefix returns archives whose UID starts with prefix
@@ -454,7 +343,7 @@ func (r *Registry) FindByUIDPrefix(prefix string, limit int) ([]*Archive, error)
 		limit = 50
 	}
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE uid LIKE ?
 	ORDER BY created DESC
@@ -464,33 +353,7 @@ func (r *Registry) FindByUIDPrefix(prefix string, limit int) ([]*Archive, error)
 		return nil, fmt.Errorf("failed to query by uid prefix: %w", err)
 	}
 	defer rows.Close()
-	var out []*Archive
-	for rows.Next() {
-		archive := &Archive{}
-		if err := rows.Scan(
-			&archive.ID,
-			&archive.UID,
-			&archive.Name,
-			&archive.Path,
-			&archive.Size,
-			&archive.Created,
-			&archive.Checksum,
-			&archive.Profile,
-			&archive.Managed,
-			&archive.Status,
-			&archive.LastSeen,
-			&archive.DeletedAt,
-			&archive.OriginalPath,
-			&archive.Uploaded,
-			&archive.Destination,
-			&archive.UploadedAt,
-			&archive.Metadata,
-		); err != nil {
-			return nil, err
-		}
-		out = append(out, archive)
-	}
-	return out, rows.Err()
+	return r.hydrate(scanArchives(rows))
 }
 
 // FindByChecksumPrefix returns archives whose checksum starts with prefix
@@ -499,7 +362,7 @@ func (r *Registry) FindByChecksumPrefix(prefix string, limit int) ([]*Archive, e
 		limit = 50
 	}
 	query := `
-	SELECT id, uid, name, path, size, created, checksum, profile, managed, status, last_seen, deleted_at, original_path, uploaded, destination, uploaded_at, metadata
+	SELECT ` + archiveColumns + `
 	FROM archives
 	WHERE checksum LIKE ?
 	ORDER BY created DESC
@@ -509,45 +372,159 @@ func (r *Registry) FindByChecksumPrefix(prefix string, limit int) ([]*Archive, e
 		return nil, fmt.Errorf("failed to query by checksum prefix: %w", err)
 	}
 	defer rows.Close()
-	var out []*Archive
-	for rows.Next() {
-		archive := &Archive{}
-		if err := rows.Scan(
-			&archive.ID,
-			&archive.UID,
-			&archive.Name,
-			&archive.Path,
-			&archive.Size,
-			&archive.Created,
-			&archive.Checksum,
-			&archive.Profile,
-			&archive.Managed,
-			&archive.Status,
-			&archive.LastSeen,
-			&archive.DeletedAt,
-			&archive.OriginalPath,
-			&archive.Uploaded,
-			&archive.Destination,
-			&archive.UploadedAt,
-			&archive.Metadata,
-		); err != nil {
-			return nil, err
+	return r.hydrate(scanArchives(rows))
+}
+
+// ListBySource returns every version created from a source path, oldest first
+func (r *Registry) ListBySource(sourcePath string) ([]*Archive, error) {
+	query := `
+	SELECT ` + archiveColumns + `
+	FROM archives
+	WHERE source_path = ?
+	ORDER BY created ASC, id ASC`
+	rows, err := r.db.Query(query, sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query by source: %w", err)
+	}
+	defer rows.Close()
+	return r.hydrate(scanArchives(rows))
+}
+
+// DeleteByID removes a single archive row from the registry
+func (r *Registry) DeleteByID(id int64) error {
+	return r.mutate(id, ActionRemove, func(tx *sql.Tx) (int64, error) {
+		return id, deleteArchive(tx, id)
+	})
+}
+
+// deleteArchive removes an archive row with its tags and fields
+func deleteArchive(tx *sql.Tx, id int64) error {
+	for _, table := range []string{"archive_tags", "archive_meta"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE archive_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
 		}
-		out = append(out, archive)
 	}
-	return out, rows.Err()
+	if _, err := tx.Exec(`DELETE FROM archives WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete archive: %w", err)
+	}
+	return nil
 }
 
-// Delete removes an archive from the registry
+// Delete removes an archive from the registry. All versions sharing the name
+// are removed; use DeleteByID to remove a single row.
 func (r *Registry) Delete(name string) error {
-	query := `DELETE FROM archives WHERE name = ?`
-	_, err := r.db.Exec(query, name)
+	rows, err := r.db.Query(`SELECT id FROM archives WHERE name = ?`, name)
 	if err != nil {
 		return fmt.Errorf("failed to delete archive: %w", err)
 	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to delete archive: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		if err := r.DeleteByID(id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// archiveColumns is the SELECT list matching scanArchive
+// Nullable text columns are coalesced so rows written by older versions scan cleanly.
+const archiveColumns = `id, uid, name, path, size, created, COALESCE(checksum, ''), COALESCE(profile, ''), managed, status, last_seen, deleted_at, COALESCE(original_path, ''), uploaded, COALESCE(destination, ''), uploaded_at, COALESCE(metadata, ''), COALESCE(source_path, ''), COALESCE(source_fingerprint, ''), COALESCE(source_size, 0)`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanArchive reads one archive selected with archiveColumns
+func scanArchive(row rowScanner) (*Archive, error) {
+	archive := &Archive{}
+	err := row.Scan(
+		&archive.ID,
+		&archive.UID,
+		&archive.Name,
+		&archive.Path,
+		&archive.Size,
+		&archive.Created,
+		&archive.Checksum,
+		&archive.Profile,
+		&archive.Managed,
+		&archive.Status,
+		&archive.LastSeen,
+		&archive.DeletedAt,
+		&archive.OriginalPath,
+		&archive.Uploaded,
+		&archive.Destination,
+		&archive.UploadedAt,
+		&archive.Metadata,
+		&archive.SourcePath,
+		&archive.SourceFingerprint,
+		&archive.SourceSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// hydrate loads tags and custom fields for archives from a list query
+func (r *Registry) hydrate(archives []*Archive, err error) ([]*Archive, error) {
+	return r.withFields(r.withTags(archives, err))
+}
+
+// hydrateOne loads tags and custom fields for a single archive
+func (r *Registry) hydrateOne(archive *Archive, err error) (*Archive, error) {
+	archive, err = r.withTag(archive, err)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := r.Fields(archive.ID)
+	if err != nil {
+		return nil, err
+	}
+	archive.Fields = fields
+	return archive, nil
+}
+
+// hydrateByIDLimit is the largest list whose side rows are loaded by id;
+// bigger lists read the whole side table, which is cheaper than the lookup
+const hydrateByIDLimit = 1000
+
+// archiveIDsClause returns a WHERE clause restricting archive_id to the given
+// archives, or nothing when the list is large enough to load every row
+func archiveIDsClause(archives []*Archive) (string, []interface{}) {
+	if len(archives) > hydrateByIDLimit {
+		return "", nil
+	}
+	ids := make([]int64, len(archives))
+	for i, a := range archives {
+		ids[i] = a.ID
+	}
+	encoded, _ := json.Marshal(ids)
+	return ` WHERE archive_id IN (SELECT value FROM json_each(?))`, []interface{}{string(encoded)}
+}
+
+// scanArchives drains rows selected with archiveColumns
+func scanArchives(rows *sql.Rows) ([]*Archive, error) {
+	var archives []*Archive
+	for rows.Next() {
+		archive, err := scanArchive(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan archive: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	return archives, rows.Err()
+}
+
 // Path returns the underlying database file path (for backups)
 func (r *Registry) Path() string { return r.dbPath }
 
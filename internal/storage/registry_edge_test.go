@@ -253,12 +253,19 @@ func TestRegistryConsistency(t *testing.T) {
 			t.Fatalf("Failed to add first archive: %v", err)
 		}
 
+		// Names are no longer unique: repeated backups of a source are versions
 		err = registry.Add(archive2)
-		if err == nil {
-			t.Error("Expected error adding duplicate name, got nil")
+		if err != nil {
+			t.Fatalf("Expected duplicate name to be allowed, got %v", err)
 		}
 
-		t.Logf("Duplicate name prevented: %v", err)
+		latest, err := registry.Get("duplicate.7z")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if latest.ID != archive2.ID {
+			t.Errorf("Expected Get to return the most recent version, got id %d", latest.ID)
+		}
 	})
 
 	t.Run("duplicate_uids", func(t *testing.T) {
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ScheduleRun records one time a configured schedule fell due: the job it
+// queued, or why it was skipped
+type ScheduleRun struct {
+	ID       int64     `json:"id"`
+	Schedule string    `json:"schedule"`
+	Due      time.Time `json:"due"`    // time the cron expression matched
+	Queued   time.Time `json:"queued"` // time the daemon acted on it
+	JobID    int64     `json:"job_id,omitempty"`
+	Skipped  string    `json:"skipped,omitempty"`
+	Job      *Job      `json:"job,omitempty"` // the queued job, with its outcome
+}
+
+// scheduleRunsDDL creates the schedule run history
+const scheduleRunsDDL = `
+	CREATE TABLE IF NOT EXISTS schedule_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		schedule TEXT NOT NULL,
+		due TIMESTAMP NOT NULL,
+		queued TIMESTAMP NOT NULL,
+		job_id INTEGER,
+		skipped TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule, due);
+	`
+
+// RecordScheduleRun adds a run to a schedule's history
+func (r *Registry) RecordScheduleRun(run *ScheduleRun) error {
+	var jobID interface{}
+	if run.JobID != 0 {
+		jobID = run.JobID
+	}
+	res, err := r.db.Exec(`INSERT INTO schedule_runs (schedule, due, queued, job_id, skipped) VALUES (?, ?, ?, ?, ?)`,
+		run.Schedule, run.Due, run.Queued, jobID, run.Skipped)
+	if err != nil {
+		return fmt.Errorf("failed to record run of schedule %s: %w", run.Schedule, err)
+	}
+	run.ID, err = res.LastInsertId()
+	return err
+}
+
+// LastScheduleRun returns the latest run of a schedule, nil if it never ran
+func (r *Registry) LastScheduleRun(schedule string) (*ScheduleRun, error) {
+	runs, err := r.ScheduleRuns(schedule, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// ScheduleRuns returns a schedule's runs newest first, or every schedule's
+// when schedule is empty, with the jobs they queued
+func (r *Registry) ScheduleRuns(schedule string, limit int) ([]*ScheduleRun, error) {
+	query := `SELECT id, schedule, due, queued, job_id, skipped FROM schedule_runs`
+	var args []interface{}
+	if schedule != "" {
+		query += ` WHERE schedule = ?`
+		args = append(args, schedule)
+	}
+	query += ` ORDER BY due DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule runs: %w", err)
+	}
+	var runs []*ScheduleRun
+	for rows.Next() {
+		run := &ScheduleRun{}
+		var jobID sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.Schedule, &run.Due, &run.Queued, &jobID, &run.Skipped); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		run.JobID = jobID.Int64
+		runs = append(runs, run)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if run.JobID == 0 {
+			continue
+		}
+		j, err := scanJob(r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, run.JobID))
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		run.Job = j
+	}
+	return runs, nil
+}
+
+// ActiveScheduleJob returns a job queued by a schedule that is still queued
+// or running, nil if there is none
+func (r *Registry) ActiveScheduleJob(schedule string) (*Job, error) {
+	j, err := scanJob(r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE status IN (?, ?)
+		AND id IN (SELECT job_id FROM schedule_runs WHERE schedule = ?) ORDER BY id DESC LIMIT 1`,
+		JobQueued, JobRunning, schedule))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
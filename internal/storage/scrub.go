@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"os"
+	"time"
+)
+
+// ScrubResult is the outcome of checking one archive's file
+type ScrubResult struct {
+	Archive  *Archive
+	Computed string // checksum of the file; empty when missing or none is recorded
+	Verified bool   // the computed checksum matches the recorded one
+}
+
+// Scrub checks that an archive's file is present, recording its status and
+// when it was last seen, and recomputes its checksum when one is recorded
+func (m *Manager) Scrub(a *Archive) (*ScrubResult, error) {
+	res := &ScrubResult{Archive: a}
+	now := time.Now()
+	a.LastSeen = &now
+	if _, err := os.Stat(a.Path); err == nil {
+		a.Status = "present"
+	} else {
+		a.Status = "missing"
+	}
+	if err := m.registry.Update(a); err != nil {
+		return nil, err
+	}
+	if a.Status == "present" && a.Checksum != "" {
+		computed, err := FileChecksum(a.Path)
+		if err != nil {
+			return nil, err
+		}
+		res.Computed = computed
+		res.Verified = computed == a.Checksum
+	}
+	return res, nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SyncAction describes what a sync pass did for a single path
+type SyncAction string
+
+const (
+	SyncIgnored    SyncAction = "ignored"    // not an archive we track (temp file, directory, ...)
+	SyncUnchanged  SyncAction = "unchanged"  // file gone and not registered
+	SyncSeen       SyncAction = "seen"       // registered archive confirmed present
+	SyncRegistered SyncAction = "registered" // new archive found on disk and added
+	SyncMissing    SyncAction = "missing"    // registered archive no longer on disk
+)
+
+// ReconcileReport summarises a full managed storage scan
+type ReconcileReport struct {
+	Seen       int
+	Registered int
+	Missing    int
+	Errors     []error
+}
+
+// IsArchiveFile reports whether a path looks like a finished 7z archive.
+// Hidden files and common partial-download/temp suffixes are skipped so that
+// files still being written by other tools are never registered.
+func IsArchiveFile(path string) bool {
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	return strings.EqualFold(filepath.Ext(base), ".7z")
+}
+
+// SyncFile brings the registry row for a single managed path in line with the
+// file system. Present files are marked present (and registered when unknown),
+// vanished files are marked missing. Deleted (trashed) rows are left alone.
+func (m *Manager) SyncFile(path string) (SyncAction, error) {
+	if !IsArchiveFile(path) {
+		return SyncIgnored, nil
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return SyncIgnored, fmt.Errorf("failed to stat %s: %w", path, statErr)
+	}
+	if statErr == nil && info.IsDir() {
+		return SyncIgnored, nil
+	}
+
+	// Only a path the registry does not know may be registered; any other
+	// lookup failure would add a duplicate or hide a vanished archive
+	existing, err := m.registry.GetByPath(path)
+	var notFound *ArchiveNotFoundError
+	if errors.As(err, &notFound) {
+		existing = nil
+	} else if err != nil {
+		return SyncIgnored, err
+	}
+
+	now := time.Now()
+
+	// File is gone
+	if os.IsNotExist(statErr) {
+		if existing == nil || existing.Status == "deleted" || existing.Status == "missing" {
+			return SyncUnchanged, nil
+		}
+		existing.Status = "missing"
+		if err := m.registry.Update(existing); err != nil {
+			return SyncIgnored, err
+		}
+		return SyncMissing, nil
+	}
+
+	// File is present and already known
+	if existing != nil {
+		if existing.Status == "deleted" {
+			return SyncIgnored, nil
+		}
+		if existing.Size != info.Size() {
+			existing.Size = info.Size()
+			if sum, err := FileChecksum(path); err == nil {
+				existing.Checksum = sum
+			}
+		}
+		existing.Status = "present"
+		existing.LastSeen = &now
+		if err := m.registry.Update(existing); err != nil {
+			return SyncIgnored, err
+		}
+		return SyncSeen, nil
+	}
+
+	// New file dropped into managed storage by hand
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		return SyncIgnored, fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	archive := &Archive{
+		UID:      generateUID(),
+		Name:     filepath.Base(path),
+		Path:     path,
+		Size:     info.Size(),
+		Created:  info.ModTime(),
+		Checksum: checksum,
+		Managed:  m.IsManagedPath(path),
+		Status:   "present",
+		LastSeen: &now,
+	}
+	if err := m.registry.Add(archive); err != nil {
+		return SyncIgnored, err
+	}
+	return SyncRegistered, nil
+}
+
+// Reconcile scans the managed archives directory and syncs every file found,
+// then marks managed rows whose files have disappeared as missing.
+func (m *Manager) Reconcile() (*ReconcileReport, error) {
+	return m.reconcile(m.GetArchivesPath(), false)
+}
+
+// ReconcileTree reconciles only the archives under root, such as a directory
+// moved out of managed storage as a whole. A root that no longer exists is
+// not an error; the archives that were under it are marked missing.
+func (m *Manager) ReconcileTree(root string) (*ReconcileReport, error) {
+	return m.reconcile(root, true)
+}
+
+func (m *Manager) reconcile(root string, allowGone bool) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+
+	record := func(action SyncAction, err error) {
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			return
+		}
+		switch action {
+		case SyncSeen:
+			report.Seen++
+		case SyncRegistered:
+			report.Registered++
+		case SyncMissing:
+			report.Missing++
+		}
+	}
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if allowGone && path == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		record(m.SyncFile(path))
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to scan managed storage: %w", err)
+	}
+
+	archives, err := m.registry.List()
+	if err != nil {
+		return report, err
+	}
+	for _, a := range archives {
+		if !a.Managed || a.Status != "present" || !m.IsManagedPath(a.Path) || !isWithin(root, a.Path) {
+			continue
+		}
+		if _, statErr := os.Stat(a.Path); os.IsNotExist(statErr) {
+			record(m.SyncFile(a.Path))
+		}
+	}
+
+	return report, nil
+}
+
+// IsManagedPath reports whether path lives under the managed base path
+func (m *Manager) IsManagedPath(path string) bool {
+	return isWithin(m.basePath, path)
+}
+
+// isWithin reports whether path is inside dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	up := ".." + string(os.PathSeparator)
+	return rel != ".." && !strings.HasPrefix(rel, up)
+}
+
+// FileChecksum returns the hex-encoded SHA256 of a file
+func FileChecksum(path string) (string, error) {
+	// #nosec G304: path is an archive from the registry or the command line
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
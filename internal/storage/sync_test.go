@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncFileLifecycle(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	path := filepath.Join(mgr.GetArchivesPath(), "manual.7z")
+	if err := os.WriteFile(path, []byte("payload"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	action, err := mgr.SyncFile(path)
+	if err != nil || action != SyncRegistered {
+		t.Fatalf("first sync: action=%s err=%v", action, err)
+	}
+	a, err := mgr.Registry().GetByPath(path)
+	if err != nil {
+		t.Fatalf("GetByPath: %v", err)
+	}
+	if !a.Managed || a.Status != "present" || a.LastSeen == nil || a.Size != 7 {
+		t.Fatalf("unexpected registered row: %+v", a)
+	}
+
+	if action, _ := mgr.SyncFile(path); action != SyncSeen {
+		t.Fatalf("second sync: expected seen, got %s", action)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := mgr.SyncFile(path); action != SyncMissing {
+		t.Fatalf("after removal: expected missing, got %s", action)
+	}
+	if action, _ := mgr.SyncFile(path); action != SyncUnchanged {
+		t.Fatalf("repeat removal: expected unchanged, got %s", action)
+	}
+}
+
+func TestSyncFileReturnsLookupErrors(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	path := filepath.Join(mgr.GetArchivesPath(), "gone.7z")
+	if err := mgr.Add("gone.7z", path, 1, "balanced", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+	// A failing lookup is not the same as an unknown path
+	if err := mgr.Registry().DB().Close(); err != nil {
+		t.Fatal(err)
+	}
+	if action, err := mgr.SyncFile(path); err == nil {
+		t.Fatalf("expected the lookup error, got action %s", action)
+	}
+}
+
+func TestSyncFileIgnoresTempAndTrashed(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	for _, name := range []string{".partial.7z", "download.7z.part", "notes.txt"} {
+		p := filepath.Join(mgr.GetArchivesPath(), name)
+		_ = os.WriteFile(p, []byte("x"), 0600)
+		if action, _ := mgr.SyncFile(p); action != SyncIgnored {
+			t.Errorf("%s: expected ignored, got %s", name, action)
+		}
+	}
+
+	gone := filepath.Join(mgr.GetArchivesPath(), "trashed.7z")
+	if err := mgr.Add("trashed.7z", gone, 1, "balanced", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := mgr.Get("trashed.7z")
+	a.Status = "deleted"
+	_ = mgr.Registry().Update(a)
+	if action, _ := mgr.SyncFile(gone); action != SyncUnchanged {
+		t.Fatalf("deleted row should be left alone, got %s", action)
+	}
+}
+
+func TestReconcileMarksMissing(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	vanished := filepath.Join(mgr.GetArchivesPath(), "vanished.7z")
+	if err := mgr.Add("vanished.7z", vanished, 1, "balanced", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mgr.GetArchivesPath(), "new.7z"), []byte("n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := mgr.Reconcile()
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if report.Registered != 1 || report.Missing != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
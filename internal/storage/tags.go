@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxTagLength bounds a single tag
+const MaxTagLength = 64
+
+// TagCount is a tag and the number of archives carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag lower-cases and trims a tag and rejects empty tags, tags with
+// whitespace or commas, and overly long tags. Free-form labels use a
+// key:value form such as client:acme or project:website.
+func NormalizeTag(tag string) (string, error) {
+	t := strings.ToLower(strings.TrimSpace(tag))
+	if t == "" {
+		return "", fmt.Errorf("tag cannot be empty")
+	}
+	if len(t) > MaxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+	}
+	for _, r := range t {
+		if unicode.IsSpace(r) || r == ',' {
+			return "", fmt.Errorf("tag %q must not contain spaces or commas", tag)
+		}
+	}
+	return t, nil
+}
+
+// NormalizeTags normalizes and de-duplicates a list of tags, keeping order
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// HasTags reports whether the archive carries every given tag
+func (a *Archive) HasTags(tags ...string) bool {
+	for _, want := range tags {
+		found := false
+		for _, have := range a.Tags {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTags attaches tags to an archive; existing tags are left as they are
+func (r *Registry) AddTags(archiveID int64, tags ...string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	return r.mutate(archiveID, ActionTag, func(tx *sql.Tx) (int64, error) {
+		return archiveID, addTags(tx, archiveID, tags)
+	})
+}
+
+func addTags(q querier, archiveID int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := q.Exec(`INSERT OR IGNORE INTO archive_tags (archive_id, tag) VALUES (?, ?)`, archiveID, tag); err != nil {
+			return fmt.Errorf("failed to add tag %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// RemoveTags detaches tags from an archive and returns how many were removed
+func (r *Registry) RemoveTags(archiveID int64, tags ...string) (int, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	err = r.mutate(archiveID, ActionUntag, func(tx *sql.Tx) (int64, error) {
+		var err error
+		removed, err = removeTags(tx, archiveID, tags)
+		return archiveID, err
+	})
+	return removed, err
+}
+
+func removeTags(q querier, archiveID int64, tags []string) (int, error) {
+	removed := 0
+	for _, tag := range tags {
+		res, err := q.Exec(`DELETE FROM archive_tags WHERE archive_id = ? AND tag = ?`, archiveID, tag)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove tag %s: %w", tag, err)
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// Tags returns the tags of one archive, sorted
+func (r *Registry) Tags(archiveID int64) ([]string, error) {
+	rows, err := r.db.Query(`SELECT tag FROM archive_tags WHERE archive_id = ? ORDER BY tag`, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns every tag in use with its archive count, most used first
+func (r *Registry) ListTags() ([]TagCount, error) {
+	rows, err := r.db.Query(`SELECT tag, COUNT(*) FROM archive_tags GROUP BY tag ORDER BY COUNT(*) DESC, tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+	var out []TagCount
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		out = append(out, tc)
+	}
+	return out, rows.Err()
+}
+
+// withTags fills Tags on archives loaded by a list query
+func (r *Registry) withTags(archives []*Archive, err error) ([]*Archive, error) {
+	if err != nil || len(archives) == 0 {
+		return archives, err
+	}
+	where, args := archiveIDsClause(archives)
+	rows, err := r.db.Query(`SELECT archive_id, tag FROM archive_tags`+where+` ORDER BY tag`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+	byID := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		byID[id] = append(byID[id], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, a := range archives {
+		a.Tags = byID[a.ID]
+	}
+	return archives, nil
+}
+
+// withTag fills Tags on a single archive
+func (r *Registry) withTag(archive *Archive, err error) (*Archive, error) {
+	if err != nil {
+		return nil, err
+	}
+	tags, err := r.Tags(archive.ID)
+	if err != nil {
+		return nil, err
+	}
+	archive.Tags = tags
+	return archive, nil
+}
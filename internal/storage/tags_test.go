@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	cases := map[string]string{
+		"Client:Acme": "client:acme",
+		"  urgent ":   "urgent",
+	}
+	for in, want := range cases {
+		got, err := NormalizeTag(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "   ", "two words", "a,b"} {
+		if _, err := NormalizeTag(bad); err == nil {
+			t.Errorf("NormalizeTag(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRegistryTags(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+	reg := mgr.Registry()
+
+	add := func(name string) *Archive {
+		a := &Archive{UID: generateUID(), Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name), Status: "present"}
+		if err := reg.Add(a); err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	one, two := add("one.7z"), add("two.7z")
+
+	if err := reg.AddTags(one.ID, "Client:Acme", "project:site", "client:acme"); err != nil {
+		t.Fatalf("AddTags: %v", err)
+	}
+	if err := reg.AddTags(two.ID, "client:acme"); err != nil {
+		t.Fatalf("AddTags: %v", err)
+	}
+
+	got, err := reg.GetByID(one.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tags) != 2 || !got.HasTags("client:acme", "project:site") {
+		t.Fatalf("unexpected tags on get: %v", got.Tags)
+	}
+
+	all, err := reg.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range all {
+		if !a.HasTags("client:acme") {
+			t.Errorf("%s: list did not load tags: %v", a.Name, a.Tags)
+		}
+	}
+
+	counts, err := reg.ListTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts[0] != (TagCount{Tag: "client:acme", Count: 2}) {
+		t.Fatalf("unexpected tag counts: %+v", counts)
+	}
+
+	removed, err := reg.RemoveTags(one.ID, "project:site", "missing")
+	if err != nil || removed != 1 {
+		t.Fatalf("RemoveTags: removed=%d err=%v", removed, err)
+	}
+
+	if err := reg.DeleteByID(two.ID); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ = reg.ListTags()
+	if len(counts) != 1 || counts[0].Count != 1 {
+		t.Fatalf("tags of deleted archive should be gone: %+v", counts)
+	}
+}
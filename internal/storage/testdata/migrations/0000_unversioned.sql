@@ -0,0 +1,20 @@
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		checksum TEXT,
+		profile TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	);
+INSERT INTO archives VALUES(1,'photos-2024.7z','/data/archives/photos-2024.7z',1024,'2025-01-01 12:00:00','sum0','media',0,'',NULL,'{"note":"fixture"}');
+INSERT INTO archives VALUES(2,'projects.7z','/data/archives/projects.7z',2048,'2025-02-01 12:00:00','sum1','media',1,'s3',NULL,'{"note":"fixture"}');
+INSERT INTO archives VALUES(3,'old-mail.7z','/data/archives/old-mail.7z',3072,'2025-03-01 12:00:00','sum2','media',0,'',NULL,'{"note":"fixture"}');
+INSERT INTO sqlite_sequence VALUES('archives',3);
+COMMIT;
@@ -0,0 +1,28 @@
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	, uid TEXT, managed BOOLEAN DEFAULT FALSE, status TEXT NOT NULL DEFAULT 'present', last_seen TIMESTAMP);
+INSERT INTO archives VALUES(1,'photos-2024.7z','/data/archives/photos-2024.7z',1024,'2025-01-01 12:00:00','sum0','media',0,'',NULL,'{"note":"fixture"}','uid0001',1,'present','2025-09-01 08:00:00');
+INSERT INTO archives VALUES(2,'projects.7z','/data/archives/projects.7z',2048,'2025-02-01 12:00:00','sum1','media',1,'s3',NULL,'{"note":"fixture"}','uid0002',1,'present','2025-09-01 08:00:00');
+INSERT INTO archives VALUES(3,'old-mail.7z','/data/archives/old-mail.7z',3072,'2025-03-01 12:00:00','sum2','media',0,'',NULL,'{"note":"fixture"}','uid0003',1,'deleted','2025-09-01 08:00:00');
+CREATE TABLE IF NOT EXISTS "schema_migrations" (id TEXT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL);
+INSERT INTO schema_migrations VALUES('0001_baseline','Baseline schema','2025-08-01 10:00:00');
+INSERT INTO schema_migrations VALUES('0002_identity_and_status','Add uid/managed/status/last_seen and indexes','2025-08-01 10:00:00');
+INSERT INTO sqlite_sequence VALUES('archives',3);
+CREATE INDEX idx_archives_created ON archives(created);
+CREATE INDEX idx_archives_uploaded ON archives(uploaded);
+CREATE INDEX idx_archives_destination ON archives(destination);
+CREATE INDEX idx_archives_checksum ON archives(checksum);
+CREATE UNIQUE INDEX idx_archives_uid ON archives(uid);
+COMMIT;
@@ -0,0 +1,56 @@
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE archives (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE,
+		name TEXT UNIQUE NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		managed BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'present',
+		last_seen TIMESTAMP,
+		deleted_at TIMESTAMP,
+		original_path TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT
+	);
+INSERT INTO archives VALUES(1,'uid0001','photos-2024.7z','/data/archives/photos-2024.7z',1024,'2025-01-01 12:00:00','sum0','media',1,'present','2025-09-01 08:00:00',NULL,NULL,0,'',NULL,'{"note":"fixture"}');
+INSERT INTO archives VALUES(2,'uid0002','projects.7z','/data/archives/projects.7z',2048,'2025-02-01 12:00:00','sum1','media',1,'present','2025-09-01 08:00:00',NULL,NULL,1,'s3',NULL,'{"note":"fixture"}');
+INSERT INTO archives VALUES(3,'uid0003','old-mail.7z','/data/trash/old-mail.7z',3072,'2025-03-01 12:00:00','sum2','media',1,'deleted','2025-09-01 08:00:00','2025-09-02 08:00:00','/data/archives/old-mail.7z',0,'',NULL,'{"note":"fixture"}');
+CREATE TABLE schema_migrations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);
+INSERT INTO schema_migrations VALUES('0001_baseline','Baseline schema','2025-08-01 10:00:00');
+INSERT INTO schema_migrations VALUES('0002_identity_and_status','Add uid/managed/status/last_seen and indexes','2025-08-01 10:00:00');
+INSERT INTO schema_migrations VALUES('0003_trash_fields','Add deleted_at and original_path for trash support','2025-08-01 10:00:00');
+INSERT INTO schema_migrations VALUES('0004_query_system','Add queries table for saved query support','2025-08-01 10:00:00');
+INSERT INTO schema_migrations VALUES('0005_search_index','Add search_index table for full-text search support','2025-08-01 10:00:00');
+CREATE TABLE queries (
+					name TEXT PRIMARY KEY,
+					filters TEXT NOT NULL,
+					created INTEGER NOT NULL,
+					last_used INTEGER,
+					use_count INTEGER DEFAULT 0
+				);
+INSERT INTO queries VALUES('media','{"profile":"media"}',1754000000,NULL,2);
+CREATE TABLE search_index (
+					term TEXT,
+					archive_uid TEXT,
+					field TEXT,
+					PRIMARY KEY (term, archive_uid, field)
+				);
+INSERT INTO sqlite_sequence VALUES('archives',3);
+CREATE INDEX idx_archives_created ON archives(created);
+CREATE INDEX idx_archives_uploaded ON archives(uploaded);
+CREATE INDEX idx_archives_destination ON archives(destination);
+CREATE INDEX idx_archives_checksum ON archives(checksum);
+CREATE UNIQUE INDEX idx_archives_uid ON archives(uid);
+CREATE INDEX idx_search_term ON search_index(term);
+COMMIT;
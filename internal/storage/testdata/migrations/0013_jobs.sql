@@ -0,0 +1,154 @@
+/* WARNING: Script requires that SQLITE_DBCONFIG_DEFENSIVE be disabled */
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE queries (
+		name TEXT PRIMARY KEY,
+		filters TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		last_used INTEGER,
+		use_count INTEGER DEFAULT 0
+	);
+INSERT INTO queries VALUES('media','{"profile":"media"}',1754000000,NULL,2);
+CREATE TABLE IF NOT EXISTS "archives" (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		uid TEXT UNIQUE,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		checksum TEXT,
+		profile TEXT,
+		managed BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'present',
+		last_seen TIMESTAMP,
+		deleted_at TIMESTAMP,
+		original_path TEXT,
+		uploaded BOOLEAN DEFAULT FALSE,
+		destination TEXT,
+		uploaded_at TIMESTAMP,
+		metadata TEXT,
+		source_path TEXT,
+		source_fingerprint TEXT
+	, source_size INTEGER);
+INSERT INTO archives VALUES(1,'uid0001','photos-2024.7z','/data/archives/photos-2024.7z',1024,'2025-01-01 12:00:00','sum0','media',1,'present','2025-09-01 08:00:00',NULL,NULL,0,'',NULL,'{"note":"fixture"}','/home/me/photos-2024','fp0',3072);
+INSERT INTO archives VALUES(2,'uid0002','projects.7z','/data/archives/projects.7z',2048,'2025-02-01 12:00:00','sum1','media',1,'present','2025-09-01 08:00:00',NULL,NULL,1,'s3',NULL,'{"note":"fixture"}','/home/me/projects','fp1',6144);
+INSERT INTO archives VALUES(3,'uid0003','old-mail.7z','/data/archives/old-mail.7z',3072,'2025-03-01 12:00:00','sum2','media',1,'deleted','2025-09-01 08:00:00','2025-09-02 08:00:00','/data/archives/old-mail.7z',0,'',NULL,'{"note":"fixture"}','/home/me/old-mail','fp2',NULL);
+CREATE TABLE archive_tags (
+		archive_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (archive_id, tag)
+	);
+INSERT INTO archive_tags VALUES(1,'family');
+INSERT INTO archive_tags VALUES(1,'photos');
+INSERT INTO archive_tags VALUES(2,'work');
+CREATE TABLE archive_meta (
+		archive_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		type TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (archive_id, key)
+	);
+INSERT INTO archive_meta VALUES(1,'year','int','2024');
+INSERT INTO archive_meta VALUES(2,'client','string','acme');
+CREATE TABLE events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created TIMESTAMP NOT NULL,
+		actor TEXT NOT NULL,
+		command TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		archive_id INTEGER NOT NULL,
+		archive_uid TEXT NOT NULL DEFAULT '',
+		archive_name TEXT NOT NULL DEFAULT '',
+		before TEXT,
+		after TEXT,
+		undoes INTEGER
+	);
+INSERT INTO events VALUES(1,'2025-09-02 08:00:00','me','delete','delete',3,'uid0003','old-mail.7z','{}','{}',NULL);
+CREATE TABLE file_ops (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started TIMESTAMP NOT NULL,
+		host TEXT NOT NULL,
+		pid INTEGER NOT NULL,
+		archive_id INTEGER NOT NULL,
+		src TEXT NOT NULL,
+		dst TEXT NOT NULL DEFAULT '',
+		after TEXT NOT NULL
+	);
+PRAGMA writable_schema=ON;
+INSERT INTO sqlite_schema(type,name,tbl_name,rootpage,sql)VALUES('table','archives_fts4','archives_fts4',0,'CREATE VIRTUAL TABLE archives_fts4 USING fts4(name, path, profile, metadata, tags, prefix="2,3", tokenize=unicode61)');
+CREATE TABLE IF NOT EXISTS 'archives_fts4_content'(docid INTEGER PRIMARY KEY, 'c0name', 'c1path', 'c2profile', 'c3metadata', 'c4tags');
+INSERT INTO archives_fts4_content VALUES(1,'photos-2024.7z','/data/archives/photos-2024.7z','media','{"note":"fixture"}','family photos');
+INSERT INTO archives_fts4_content VALUES(2,'projects.7z','/data/archives/projects.7z','media','{"note":"fixture"}','work');
+INSERT INTO archives_fts4_content VALUES(3,'old-mail.7z','/data/archives/old-mail.7z','media','{"note":"fixture"}','');
+CREATE TABLE IF NOT EXISTS 'archives_fts4_segments'(blockid INTEGER PRIMARY KEY, block BLOB);
+CREATE TABLE IF NOT EXISTS 'archives_fts4_segdir'(level INTEGER,idx INTEGER,start_block INTEGER,leaves_end_block INTEGER,end_block INTEGER,root BLOB,PRIMARY KEY(level, idx));
+INSERT INTO archives_fts4_segdir VALUES(0,0,0,0,'0 237',X'000432303234060103010105000002377a12010401010600010301010500010401010600000861726368697665730f0101010300010101030001010103000004646174610f010101020001010102000101010200000666616d696c7905010104020001066978747572650f01010303000101030300010103030000046d61696c060303010105000104656469610f01010202000101020200010102020000046e6f74650f01010302000101030200010103020000036f6c6406030201010400000670686f746f73090102010104010403000107726f6a65637473060202010104000004776f726b050201040200');
+INSERT INTO archives_fts4_segdir VALUES(1024,0,0,0,'0 198',X'00023230060103010105000002377a12010401010600010301010500010401010600000261720f010101030001010103000101010300000264610f010101020001010102000101010200000266610501010402000101690f01010303000101030300010103030000026d61060303010105000101650f01010202000101020200010102020000026e6f0f01010302000101030200010103020000026f6c060302010104000002706809010201010401040300010172060202010104000002776f050201040200');
+INSERT INTO archives_fts4_segdir VALUES(2048,0,0,0,'0 187',X'00033230320601030101050000036172630f01010103000101010300010101030000036461740f010101020001010102000101010200000366616d050101040200010269780f01010303000101030300010103030000036d616906030301010500010265640f01010202000101020200010102020000036e6f740f01010302000101030200010103020000036f6c6406030201010400000370686f090102010104010403000102726f060202010104000003776f72050201040200');
+CREATE TABLE IF NOT EXISTS 'archives_fts4_docsize'(docid INTEGER PRIMARY KEY, size BLOB);
+INSERT INTO archives_fts4_docsize VALUES(1,X'0305010202');
+INSERT INTO archives_fts4_docsize VALUES(2,X'0204010201');
+INSERT INTO archives_fts4_docsize VALUES(3,X'0305010200');
+CREATE TABLE IF NOT EXISTS 'archives_fts4_stat'(id INTEGER PRIMARY KEY, value BLOB);
+INSERT INTO archives_fts4_stat VALUES(0,X'03080e030603cb01');
+INSERT INTO sqlite_schema(type,name,tbl_name,rootpage,sql)VALUES('table','archives_fts4_terms','archives_fts4_terms',0,'CREATE VIRTUAL TABLE archives_fts4_terms USING fts4aux(archives_fts4)');
+CREATE TABLE IF NOT EXISTS "schema_migrations" (id TEXT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL, checksum TEXT NOT NULL DEFAULT '');
+INSERT INTO schema_migrations VALUES('0001_baseline','Baseline schema','2025-08-01 10:00:00','2742d0e253b299a20fafc951e73fc360ae67695d1d9f8d5357acf3c784d8c58d');
+INSERT INTO schema_migrations VALUES('0002_identity_and_status','Add uid/managed/status/last_seen and indexes','2025-08-01 10:00:00','392c85aa4f1f2dd713370f1ae8ebe5d797f1e8765714a9445167f06b8a714681');
+INSERT INTO schema_migrations VALUES('0003_trash_fields','Add deleted_at and original_path for trash support','2025-08-01 10:00:00','cb2a8ef9f4a7e5a6e084380bf945d077a25855f2bb8a4be02a796906f6f5f694');
+INSERT INTO schema_migrations VALUES('0004_query_system','Add queries table for saved query support','2025-08-01 10:00:00','ada3f7c53fbe2909b80f1c8d9a300a2fea5f0a78e2f796a0770597b588693cf5');
+INSERT INTO schema_migrations VALUES('0005_search_index','Add search_index table for full-text search support','2025-08-01 10:00:00','194f4c770a473c048ee53518ca4d9e4ad7052311a2902b4af06f258454ee1df6');
+INSERT INTO schema_migrations VALUES('0006_lineage','Add source lineage columns and allow repeated archive names','2025-08-01 10:00:00','7e54984c4ac5f3c77d0c92dc39511f213347b2030cb2729be9a4b24a60718a55');
+INSERT INTO schema_migrations VALUES('0007_tags','Add archive_tags table for archive labels','2025-08-01 10:00:00','4533a7c77bf0339db9e407339462b574d081e4fe360bcc1bddc058bd33053375');
+INSERT INTO schema_migrations VALUES('0008_archive_meta','Add archive_meta table for typed custom fields','2025-08-01 10:00:00','5b1d0ae6a68710b0bbb473c8fec100146b5b0356a359f95c021dacb3e98f45c2');
+INSERT INTO schema_migrations VALUES('0009_fulltext','Replace search_index with a trigger-maintained full-text index','2025-08-01 10:00:00','7b39784b123b8fc34ace13f2cbb8386976aa10241a9d6be67b7b9c5564bef6ba');
+INSERT INTO schema_migrations VALUES('0010_events','Add events journal of registry changes','2025-08-01 10:00:00','5c00c765fe267f04d2eb8b5c3dac30670ad5ff11875bb9d4ca73d0ac8b1ab578');
+INSERT INTO schema_migrations VALUES('0011_file_ops','Add file_ops journal of in-flight file moves','2025-08-01 10:00:00','ee3db948d5bdd27bc1ed50bff934b7f7026b66e5acb45a40ae7e6cc99ad09e0b');
+INSERT INTO schema_migrations VALUES('0012_source_size','Add source_size for compression history','2025-08-01 10:00:00','e04a381c00ebf0df8773c5df84e2dfe51b293bdd0d16d51394205a4cd48bbef0');
+INSERT INTO schema_migrations VALUES('0013_jobs','Add jobs queue for background commands','2025-08-01 10:00:00','28d531d10cba83fe5f4cb9d77b946ef43b5d0cd28e7aae9050a3d3cb428080a3');
+CREATE TABLE jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		args TEXT NOT NULL,
+		dir TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created TIMESTAMP NOT NULL,
+		started TIMESTAMP,
+		finished TIMESTAMP,
+		host TEXT NOT NULL DEFAULT '',
+		pid INTEGER NOT NULL DEFAULT 0,
+		cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+		error TEXT NOT NULL DEFAULT ''
+	);
+INSERT INTO jobs VALUES(1,'create','["create","/home/me/photos-2024"]','/home/me','succeeded',1,'2025-09-03 08:00:00','2025-09-03 08:00:01','2025-09-03 08:02:00','box',4242,0,'');
+INSERT INTO sqlite_sequence VALUES('archives',3);
+INSERT INTO sqlite_sequence VALUES('events',1);
+INSERT INTO sqlite_sequence VALUES('archives',3);
+INSERT INTO sqlite_sequence VALUES('events',1);
+INSERT INTO sqlite_sequence VALUES('jobs',1);
+CREATE TRIGGER events_no_update BEFORE UPDATE ON events
+	BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;
+CREATE TRIGGER events_no_delete BEFORE DELETE ON events
+	BEGIN SELECT RAISE(ABORT, 'events are append-only'); END;
+CREATE TRIGGER archives_fts4_ai AFTER INSERT ON archives BEGIN INSERT INTO archives_fts4 (rowid, name, path, profile, metadata, tags) VALUES (new.id, new.name, new.path, COALESCE(new.profile, ''), COALESCE(new.metadata, ''),
+			(SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = new.id)); END;
+CREATE TRIGGER archives_fts4_ad AFTER DELETE ON archives BEGIN DELETE FROM archives_fts4 WHERE rowid = old.id; END;
+CREATE TRIGGER archives_fts4_au AFTER UPDATE OF id, name, path, profile, metadata ON archives BEGIN DELETE FROM archives_fts4 WHERE rowid = old.id; INSERT INTO archives_fts4 (rowid, name, path, profile, metadata, tags) VALUES (new.id, new.name, new.path, COALESCE(new.profile, ''), COALESCE(new.metadata, ''),
+			(SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = new.id)); END;
+CREATE TRIGGER archives_fts4_tai AFTER INSERT ON archive_tags BEGIN UPDATE archives_fts4 SET tags = (SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = new.archive_id) WHERE rowid = new.archive_id; END;
+CREATE TRIGGER archives_fts4_tad AFTER DELETE ON archive_tags BEGIN UPDATE archives_fts4 SET tags = (SELECT COALESCE(group_concat(tag, ' '), '') FROM archive_tags WHERE archive_id = old.archive_id) WHERE rowid = old.archive_id; END;
+CREATE INDEX idx_archives_created ON archives(created);
+CREATE INDEX idx_archives_uploaded ON archives(uploaded);
+CREATE INDEX idx_archives_destination ON archives(destination);
+CREATE INDEX idx_archives_checksum ON archives(checksum);
+CREATE UNIQUE INDEX idx_archives_uid ON archives(uid);
+CREATE INDEX idx_archives_name ON archives(name);
+CREATE INDEX idx_archives_source ON archives(source_path);
+CREATE INDEX idx_archive_tags_tag ON archive_tags(tag);
+CREATE INDEX idx_archive_meta_key ON archive_meta(key);
+CREATE INDEX idx_events_archive ON events(archive_id);
+CREATE INDEX idx_events_undoes ON events(undoes);
+CREATE INDEX idx_jobs_status ON jobs(status);
+PRAGMA writable_schema=OFF;
+COMMIT;
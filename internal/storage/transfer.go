@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export and import move a catalog between registries. Records use the
+// Archive JSON that list --output json prints; import matches them to local
+// archives by UID.
+
+// Export formats
+const (
+	FormatJSON   = "json"   // one JSON array
+	FormatNDJSON = "ndjson" // one archive per line
+)
+
+// Merge modes for an imported archive whose UID is already registered
+const (
+	MergeSkip      = "skip"      // keep the local archive
+	MergeOverwrite = "overwrite" // replace it with the imported one
+	MergeNewer     = "newer"     // keep whichever changed last
+)
+
+// ParseMergeMode validates a merge mode name
+func ParseMergeMode(s string) (string, error) {
+	switch s {
+	case MergeSkip, MergeOverwrite, MergeNewer:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid merge mode %q (use skip, overwrite or newer)", s)
+}
+
+// PathRewrite replaces a leading directory in imported paths
+type PathRewrite struct {
+	Old, New string
+}
+
+// ParsePathRewrite parses an old=new rule
+func ParsePathRewrite(s string) (PathRewrite, error) {
+	old, repl, ok := strings.Cut(s, "=")
+	old = strings.TrimRight(old, `/\`)
+	if !ok || old == "" {
+		return PathRewrite{}, fmt.Errorf("invalid path rewrite %q (use old=new)", s)
+	}
+	return PathRewrite{Old: old, New: strings.TrimRight(repl, `/\`)}, nil
+}
+
+// Apply rewrites path when it is Old or lies under it
+func (p PathRewrite) Apply(path string) (string, bool) {
+	if path == p.Old {
+		return p.New, true
+	}
+	if strings.HasPrefix(path, p.Old) && strings.ContainsAny(path[len(p.Old):len(p.Old)+1], `/\`) {
+		return p.New + path[len(p.Old):], true
+	}
+	return path, false
+}
+
+// ImportOptions controls how Import merges records
+type ImportOptions struct {
+	Mode     string        // MergeSkip, MergeOverwrite or MergeNewer; default skip
+	Rewrites []PathRewrite // the first matching rule applies to each path
+	DryRun   bool          // report without changing the registry
+}
+
+// ImportConflict is an imported record that clashed with the registry
+type ImportConflict struct {
+	UID        string   `json:"uid"`
+	Name       string   `json:"name"`
+	Reason     string   `json:"reason"`
+	Resolution string   `json:"resolution"`
+	Changes    []string `json:"changes,omitempty"` // local → imported
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	Added     int              `json:"added"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Skipped   int              `json:"skipped"`
+	Conflicts []ImportConflict `json:"conflicts,omitempty"`
+}
+
+// Export writes every archive, with its tags and fields, to w
+func (r *Registry) Export(w io.Writer, format string) (int, error) {
+	archives, err := r.List()
+	if err != nil {
+		return 0, err
+	}
+	switch format {
+	case FormatJSON:
+		if archives == nil {
+			archives = []*Archive{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(archives); err != nil {
+			return 0, fmt.Errorf("failed to write export: %w", err)
+		}
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, a := range archives {
+			if err := enc.Encode(a); err != nil {
+				return 0, fmt.Errorf("failed to write export: %w", err)
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unsupported export format: %s (supported: json, ndjson)", format)
+	}
+	return len(archives), nil
+}
+
+// ReadArchives reads archives written by Export in either format
+func ReadArchives(rd io.Reader) ([]*Archive, error) {
+	br := bufio.NewReader(rd)
+	dec := json.NewDecoder(br)
+	var archives []*Archive
+	if first, err := peekNonSpace(br); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	} else if first == '[' {
+		if err := dec.Decode(&archives); err != nil {
+			return nil, fmt.Errorf("invalid JSON export: %w", err)
+		}
+	} else {
+		for n := 1; ; n++ {
+			var a Archive
+			if err := dec.Decode(&a); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("invalid NDJSON export, record %d: %w", n, err)
+			}
+			archives = append(archives, &a)
+		}
+	}
+	for _, a := range archives {
+		for k, f := range a.Fields {
+			f.Key = k
+			a.Fields[k] = f
+		}
+	}
+	return archives, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// Import merges archives into the registry in one transaction. New UIDs are
+// added; a UID already registered is resolved by opts.Mode and reported as a
+// conflict when the two differ. A record whose path belongs to another live
+// archive is skipped.
+func (r *Registry) Import(archives []*Archive, opts ImportOptions) (*ImportResult, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = MergeSkip
+	}
+	if _, err := ParseMergeMode(mode); err != nil {
+		return nil, err
+	}
+	for i, a := range archives {
+		if err := prepareImport(a, opts.Rewrites); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res := &ImportResult{}
+	for _, in := range archives {
+		var id int64
+		err := tx.QueryRow(`SELECT id FROM archives WHERE uid = ?`, in.UID).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to look up %s: %w", in.UID, err)
+		}
+		local, err := snapshot(tx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if in.Status != "deleted" {
+			var other string
+			err := tx.QueryRow(`SELECT uid FROM archives WHERE path = ? AND uid != ? AND status != 'deleted' LIMIT 1`, in.Path, in.UID).Scan(&other)
+			if err == nil {
+				res.Skipped++
+				res.Conflicts = append(res.Conflicts, ImportConflict{UID: in.UID, Name: in.Name,
+					Reason: fmt.Sprintf("path %s is registered to %s", in.Path, other), Resolution: "skipped"})
+				continue
+			}
+			if err != sql.ErrNoRows {
+				return nil, fmt.Errorf("failed to check path %s: %w", in.Path, err)
+			}
+		}
+
+		if local == nil {
+			in.ID = 0
+			if err := importArchive(tx, in, nil); err != nil {
+				return nil, err
+			}
+			res.Added++
+			continue
+		}
+
+		in.ID = local.ID
+		before, after := normalizeTimes(local), normalizeTimes(in)
+		if sameState(before, after) {
+			res.Unchanged++
+			continue
+		}
+		conflict := ImportConflict{UID: in.UID, Name: in.Name, Reason: "differs from the registered archive",
+			Changes: (&Event{Before: before, After: after}).Changes()}
+		overwrite := mode == MergeOverwrite
+		if mode == MergeNewer {
+			overwrite = lastChanged(in).After(lastChanged(local))
+			if overwrite {
+				conflict.Reason += "; imported copy is newer"
+			} else {
+				conflict.Reason += "; registered copy is newer"
+			}
+		}
+		if overwrite {
+			if err := importArchive(tx, in, local); err != nil {
+				return nil, err
+			}
+			conflict.Resolution = "overwritten"
+			res.Updated++
+		} else {
+			conflict.Resolution = "kept registered"
+			res.Skipped++
+		}
+		res.Conflicts = append(res.Conflicts, conflict)
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return res, nil
+}
+
+// prepareImport checks a record and applies path rewrites and defaults
+func prepareImport(a *Archive, rewrites []PathRewrite) error {
+	if a.UID == "" {
+		return fmt.Errorf("%s has no uid", a.Name)
+	}
+	if a.Name == "" || a.Path == "" {
+		return fmt.Errorf("archive %s needs a name and path", a.UID)
+	}
+	a.Vault = ""
+	if a.Status == "" {
+		a.Status = "present"
+	}
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+	tags, err := NormalizeTags(a.Tags)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.UID, err)
+	}
+	sort.Strings(tags)
+	a.Tags = tags
+	for _, p := range []*string{&a.Path, &a.OriginalPath, &a.SourcePath} {
+		for _, rw := range rewrites {
+			if out, ok := rw.Apply(*p); ok {
+				*p = out
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// importArchive adds a, or replaces local with it, and journals the change
+func importArchive(tx *sql.Tx, a, local *Archive) error {
+	before := local
+	id := int64(0)
+	if local == nil {
+		res, err := tx.Exec(`INSERT INTO archives (uid, name, path, size, created, status) VALUES (?, ?, ?, ?, ?, ?)`,
+			a.UID, a.Name, a.Path, a.Size, a.Created, a.Status)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", a.Name, err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		a.ID = id
+	} else {
+		id = local.ID
+		if _, err := tx.Exec(`UPDATE archives SET name = ?, created = ? WHERE id = ?`, a.Name, a.Created, id); err != nil {
+			return fmt.Errorf("failed to update %s: %w", a.Name, err)
+		}
+		if _, err := removeTags(tx, id, local.Tags); err != nil {
+			return err
+		}
+		if _, err := unsetFields(tx, id, fieldKeys(local.Fields, nil)); err != nil {
+			return err
+		}
+	}
+	if err := updateArchive(tx, a); err != nil {
+		return err
+	}
+	if err := addTags(tx, id, a.Tags); err != nil {
+		return err
+	}
+	if err := setFields(tx, id, a.FieldList()); err != nil {
+		return err
+	}
+	return journal(tx, &Event{}, before, id)
+}
+
+// lastChanged is the latest time recorded on an archive
+func lastChanged(a *Archive) time.Time {
+	t := a.Created
+	for _, p := range []*time.Time{a.LastSeen, a.DeletedAt, a.UploadedAt} {
+		if p != nil && p.After(t) {
+			t = *p
+		}
+	}
+	return t
+}
+
+// normalizeTimes returns a copy of a with times in UTC so archives read from
+// different sources compare equal
+func normalizeTimes(a *Archive) *Archive {
+	c := *a
+	c.Created = c.Created.UTC()
+	for _, p := range []**time.Time{&c.LastSeen, &c.DeletedAt, &c.UploadedAt} {
+		if *p != nil {
+			t := (**p).UTC()
+			*p = &t
+		}
+	}
+	if len(c.Tags) == 0 {
+		c.Tags = nil
+	}
+	return &c
+}
+
+// ReadArchivesFile reads an export from path, or standard input for "-"
+func ReadArchivesFile(path string) ([]*Archive, error) {
+	if path == "-" {
+		return ReadArchives(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	return ReadArchives(f)
+}
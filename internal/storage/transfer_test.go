@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seedTransferRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg, err := NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reg.Close() })
+	a := &Archive{UID: generateUID(), Name: "photos.7z", Path: "/old/archives/photos.7z", Size: 10,
+		Created: time.Now().Add(-time.Hour), Status: "present", Managed: true, Tags: []string{"client:acme", "photos"},
+		SourcePath: "/old/src/photos"}
+	if err := reg.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	fields := []string{"year=2024", "billable=true", "shot=2024-05-01", "client=Acme"}
+	for _, s := range fields {
+		f, err := ParseFieldAssignment(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := reg.SetFields(a.ID, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := &Archive{UID: generateUID(), Name: "docs.7z", Path: "/elsewhere/docs.7z", Size: 20, Created: time.Now(), Status: "present"}
+	if err := reg.Add(b); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := seedTransferRegistry(t)
+	for _, format := range []string{FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if n, err := src.Export(&buf, format); err != nil || n != 2 {
+				t.Fatalf("export = %d, %v", n, err)
+			}
+			archives, err := ReadArchives(&buf)
+			if err != nil || len(archives) != 2 {
+				t.Fatalf("read = %d, %v", len(archives), err)
+			}
+
+			dst, err := NewRegistry(filepath.Join(t.TempDir(), "registry.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+			rw, _ := ParsePathRewrite("/old/=/new")
+			res, err := dst.Import(archives, ImportOptions{Rewrites: []PathRewrite{rw}})
+			if err != nil || res.Added != 2 {
+				t.Fatalf("import = %+v, %v", res, err)
+			}
+
+			want, _ := src.Get("photos.7z")
+			got, err := dst.GetByUID(want.UID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Path != "/new/archives/photos.7z" || got.SourcePath != "/new/src/photos" {
+				t.Errorf("paths not rewritten: %s, %s", got.Path, got.SourcePath)
+			}
+			if strings.Join(got.Tags, ",") != "client:acme,photos" {
+				t.Errorf("tags = %v", got.Tags)
+			}
+			for k, f := range want.Fields {
+				if got.Fields[k] != f {
+					t.Errorf("field %s = %+v, want %+v", k, got.Fields[k], f)
+				}
+			}
+			if other, _ := dst.Get("docs.7z"); other == nil || other.Path != "/elsewhere/docs.7z" {
+				t.Errorf("unmatched path rewritten: %+v", other)
+			}
+
+			// Importing the same export again changes nothing
+			archives, _ = ReadArchives(strings.NewReader(mustExport(t, src, format)))
+			res, err = dst.Import(archives, ImportOptions{Rewrites: []PathRewrite{rw}})
+			if err != nil || res.Unchanged != 2 || len(res.Conflicts) != 0 {
+				t.Fatalf("re-import = %+v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestImportReadsListJSON(t *testing.T) {
+	src := seedTransferRegistry(t)
+	archives, err := src.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// list --output json encodes the archive slice directly
+	data, err := json.Marshal(archives)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadArchives(bytes.NewReader(data))
+	if err != nil || len(read) != len(archives) {
+		t.Fatalf("read = %d, %v", len(read), err)
+	}
+	if f := read[len(read)-1].Fields["year"]; f.Key != "year" || f.Type != FieldNumber {
+		t.Errorf("year field = %+v", f)
+	}
+}
+
+func TestImportMergeModes(t *testing.T) {
+	for _, c := range []struct {
+		mode      string
+		older     bool // the imported copy predates the registered one
+		wantSize  int64
+		wantRes   string
+		wantCount func(*ImportResult) int
+	}{
+		{MergeSkip, false, 10, "kept registered", func(r *ImportResult) int { return r.Skipped }},
+		{MergeOverwrite, false, 99, "overwritten", func(r *ImportResult) int { return r.Updated }},
+		{MergeNewer, false, 99, "overwritten", func(r *ImportResult) int { return r.Updated }},
+		{MergeNewer, true, 10, "kept registered", func(r *ImportResult) int { return r.Skipped }},
+	} {
+		reg := seedTransferRegistry(t)
+		local, _ := reg.Get("photos.7z")
+		in := *local
+		in.Size = 99
+		in.Tags = []string{"photos"}
+		seen := time.Now().Add(time.Minute)
+		if c.older {
+			seen = time.Now().Add(-time.Minute)
+			later := time.Now()
+			local.LastSeen = &later
+			if err := reg.Update(local); err != nil {
+				t.Fatal(err)
+			}
+		}
+		in.LastSeen = &seen
+
+		res, err := reg.Import([]*Archive{&in}, ImportOptions{Mode: c.mode})
+		if err != nil {
+			t.Fatalf("%s: %v", c.mode, err)
+		}
+		if c.wantCount(res) != 1 || len(res.Conflicts) != 1 || res.Conflicts[0].Resolution != c.wantRes {
+			t.Errorf("%s (older=%v): result %+v", c.mode, c.older, res)
+		}
+		if got, _ := reg.GetByUID(local.UID); got.Size != c.wantSize {
+			t.Errorf("%s (older=%v): size = %d, want %d", c.mode, c.older, got.Size, c.wantSize)
+		}
+		if c.wantSize == 99 {
+			if got, _ := reg.GetByUID(local.UID); strings.Join(got.Tags, ",") != "photos" {
+				t.Errorf("%s: tags = %v, want imported tags", c.mode, got.Tags)
+			}
+		}
+	}
+}
+
+func TestImportConflictsAndDryRun(t *testing.T) {
+	reg := seedTransferRegistry(t)
+	clash := &Archive{UID: generateUID(), Name: "clash.7z", Path: "/elsewhere/docs.7z", Size: 1, Created: time.Now()}
+	fresh := &Archive{UID: generateUID(), Name: "fresh.7z", Path: "/elsewhere/fresh.7z", Size: 1, Created: time.Now()}
+
+	res, err := reg.Import([]*Archive{clash, fresh}, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 1 || res.Skipped != 1 || len(res.Conflicts) != 1 || !strings.Contains(res.Conflicts[0].Reason, "path /elsewhere/docs.7z") {
+		t.Fatalf("dry run = %+v", res)
+	}
+	if _, err := reg.GetByUID(fresh.UID); err == nil {
+		t.Error("dry run added an archive")
+	}
+
+	if _, err := reg.Import([]*Archive{{Name: "nouid.7z", Path: "/x"}}, ImportOptions{}); err == nil {
+		t.Error("expected an error for a record without a uid")
+	}
+	if _, err := ParseMergeMode("latest"); err == nil {
+		t.Error("expected an error for an unknown merge mode")
+	}
+	if _, err := ParsePathRewrite("/old"); err == nil {
+		t.Error("expected an error for a rewrite without '='")
+	}
+	if out, ok := (PathRewrite{Old: "/data", New: "/mnt"}).Apply("/database/x.7z"); ok {
+		t.Errorf("rewrite matched a partial directory name: %s", out)
+	}
+}
+
+func mustExport(t *testing.T, reg *Registry, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := reg.Export(&buf, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Trash soft-deletes an archive: a managed archive's file moves into the trash
+// directory, an external archive is only marked deleted in the registry
+func (m *Manager) Trash(a *Archive) error {
+	if !a.Managed {
+		MarkDeleted(a.Path)(a)
+		if err := m.registry.Update(a); err != nil {
+			return err
+		}
+		m.notify(LifecycleDelete, a, nil)
+		return nil
+	}
+
+	trashPath := filepath.Join(m.GetTrashPath(), filepath.Base(a.Path))
+	if _, err := os.Stat(trashPath); err == nil && len(a.UID) >= 8 {
+		// Duplicates often share a file name; never overwrite a trashed copy
+		ext := filepath.Ext(trashPath)
+		trashPath = strings.TrimSuffix(trashPath, ext) + "-" + a.UID[:8] + ext
+	}
+	if err := m.MoveArchive(a, trashPath, MarkDeleted(a.Path)); err != nil {
+		return fmt.Errorf("failed to move to trash: %w", err)
+	}
+	m.notify(LifecycleDelete, a, nil)
+	return nil
+}
+
+// Remove removes an archive's file without going through the trash and
+// records it as deleted
+func (m *Manager) Remove(a *Archive) error {
+	if err := m.RemoveArchiveFile(a, MarkDeleted(a.Path)); err != nil {
+		return err
+	}
+	m.notify(LifecycleDelete, a, nil)
+	return nil
+}
+
+// Purge permanently removes a deleted archive: its file, when it sits in
+// the managed trash, and its registry entry
+func (m *Manager) Purge(a *Archive) error {
+	var err error
+	if a.Managed && isWithin(m.GetTrashPath(), a.Path) {
+		err = m.purgeArchiveFile(a)
+	} else {
+		err = m.registry.DeleteByID(a.ID)
+	}
+	if err != nil {
+		return err
+	}
+	m.notify(LifecyclePurge, a, nil)
+	return nil
+}
+
+// MarkDeleted returns an update recording an archive as deleted from orig
+func MarkDeleted(orig string) func(*Archive) {
+	now := time.Now()
+	return func(a *Archive) {
+		a.Status = "deleted"
+		a.DeletedAt = &now
+		if a.OriginalPath == "" {
+			a.OriginalPath = orig
+		}
+	}
+}
+
+// RestoreTarget returns where a deleted archive is restored to: the path it
+// was deleted from, or its place under the current layout if that is unknown
+func (m *Manager) RestoreTarget(a *Archive) string {
+	if a.OriginalPath != "" {
+		return a.OriginalPath
+	}
+	name := a.Name
+	if name == "" {
+		name = filepath.Base(a.Path)
+	}
+	return m.ManagedPathFor(name, a.Profile, a.Created)
+}
+
+// Restore brings a deleted archive back to RestoreTarget. A managed archive's
+// file moves out of trash; force replaces a file already at the target.
+func (m *Manager) Restore(a *Archive, force bool) error {
+	if a.Status != "deleted" {
+		return fmt.Errorf("archive '%s' is not deleted (status=%s)", a.Name, a.Status)
+	}
+	target := m.RestoreTarget(a)
+
+	if _, err := os.Stat(target); err == nil && a.Managed && target != a.Path {
+		if !force {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", target)
+		}
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("failed to remove existing %s: %w", target, err)
+		}
+	}
+
+	now := time.Now()
+	restored := func(a *Archive) {
+		a.Status = "present"
+		a.DeletedAt = nil
+		a.LastSeen = &now
+	}
+	if a.Managed {
+		// Managed archive: file lives in trash and needs moving back
+		if err := m.MoveArchive(a, target, restored); err != nil {
+			return fmt.Errorf("failed to restore file: %w", err)
+		}
+		return nil
+	}
+	// External soft delete: file likely remained in place; just flip status
+	a.Path = target
+	restored(a)
+	if err := m.registry.Update(a); err != nil {
+		return fmt.Errorf("failed to update registry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UndoPlan is the reverse of a journaled event, computed by PlanUndo
+type UndoPlan struct {
+	Event    *Event
+	Archive  *Archive // state the archive is returned to
+	From, To string   // file move, empty when the file stays put
+	Changes  []string // what the undo changes, as in Event.Changes
+}
+
+// undoColumns are the Archive fields an undo may revert; the identity and
+// last_seen are never touched
+var undoColumns = []string{"Name", "Path", "Size", "Created", "Checksum", "Profile", "Managed", "Status",
+	"DeletedAt", "OriginalPath", "Uploaded", "Destination", "UploadedAt", "Metadata", "SourcePath", "SourceFingerprint", "SourceSize"}
+
+// PlanUndo works out how to reverse an event. It fails if the event cannot be
+// reversed or the archive has changed since in a way that conflicts.
+func (m *Manager) PlanUndo(eventID int64) (*UndoPlan, error) {
+	ev, err := m.registry.Event(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if ev.UndoneBy != 0 {
+		return nil, fmt.Errorf("event %d was already undone by event %d", ev.ID, ev.UndoneBy)
+	}
+	if ev.Before == nil || ev.After == nil {
+		return nil, fmt.Errorf("event %d (%s) cannot be undone", ev.ID, ev.Action)
+	}
+	current, err := m.registry.GetByID(ev.ArchiveID)
+	if err != nil {
+		return nil, fmt.Errorf("archive of event %d no longer exists", ev.ID)
+	}
+
+	conflict := func(what string) error {
+		return fmt.Errorf("%s of %s has changed since event %d; undo later events first", what, ev.ArchiveName, ev.ID)
+	}
+	reverted := *current
+	before, after := reflect.ValueOf(ev.Before).Elem(), reflect.ValueOf(ev.After).Elem()
+	cur, rev := reflect.ValueOf(current).Elem(), reflect.ValueOf(&reverted).Elem()
+	for _, name := range undoColumns {
+		b, a := before.FieldByName(name), after.FieldByName(name)
+		if formatValue(b) == formatValue(a) {
+			continue
+		}
+		if formatValue(cur.FieldByName(name)) != formatValue(a) {
+			return nil, conflict(strings.ToLower(name))
+		}
+		rev.FieldByName(name).Set(b)
+	}
+
+	added, removed := diffStrings(ev.Before.Tags, ev.After.Tags)
+	tags := make([]string, 0, len(current.Tags))
+	for _, t := range current.Tags {
+		if !contains(added, t) {
+			tags = append(tags, t)
+		}
+	}
+	reverted.Tags = append(tags, removed...)
+
+	reverted.Fields = make(map[string]Field, len(current.Fields))
+	for k, f := range current.Fields {
+		reverted.Fields[k] = f
+	}
+	for _, k := range fieldKeys(ev.Before.Fields, ev.After.Fields) {
+		b, hadB := ev.Before.Fields[k]
+		a, hadA := ev.After.Fields[k]
+		if hadB == hadA && b == a {
+			continue
+		}
+		if c, ok := current.Fields[k]; ok != hadA || c != a {
+			return nil, conflict("field " + k)
+		}
+		if hadB {
+			reverted.Fields[k] = b
+		} else {
+			delete(reverted.Fields, k)
+		}
+	}
+
+	plan := &UndoPlan{Event: ev, Archive: &reverted}
+	if reverted.Path != current.Path {
+		if _, err := os.Stat(current.Path); err == nil {
+			plan.From, plan.To = current.Path, reverted.Path
+		} else if reverted.Status == "present" {
+			return nil, fmt.Errorf("cannot undo event %d: file %s is missing", ev.ID, current.Path)
+		}
+	} else if reverted.Status == "present" && current.Status != "present" {
+		if _, err := os.Stat(reverted.Path); err != nil {
+			return nil, fmt.Errorf("cannot undo event %d: file %s no longer exists", ev.ID, reverted.Path)
+		}
+	}
+	plan.Changes = (&Event{Before: current, After: &reverted}).Changes()
+	return plan, nil
+}
+
+// Undo applies a plan: the file is moved back first, then the registry row is
+// reverted and an undo event journaled. The move is recorded in file_ops like
+// MoveArchive, so a crash in between is repaired on the next start. If the
+// registry update fails the file is returned to where it was.
+func (m *Manager) Undo(plan *UndoPlan) (*Event, error) {
+	a := plan.Archive
+	var opID int64
+	if plan.From != "" {
+		if _, err := os.Stat(plan.To); err == nil {
+			return nil, fmt.Errorf("cannot undo event %d: %s already exists", plan.Event.ID, plan.To)
+		}
+		// #nosec G301: restrict permissions on restored directory
+		if err := os.MkdirAll(filepath.Dir(plan.To), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(plan.To), err)
+		}
+		var err error
+		if opID, err = m.registry.beginFileOp(plan.From, plan.To, a); err != nil {
+			return nil, err
+		}
+		failpoint("intent")
+		if err := relocateFile(plan.From, plan.To); err != nil {
+			_ = m.registry.endFileOp(opID)
+			return nil, fmt.Errorf("failed to move %s: %w", plan.From, err)
+		}
+		failpoint("file")
+	}
+
+	ev := &Event{Action: ActionUndo, Undoes: plan.Event.ID}
+	err := m.registry.mutateEvent(a.ID, ev, func(tx *sql.Tx) (int64, error) {
+		current, err := snapshot(tx, a.ID)
+		if err != nil {
+			return 0, err
+		}
+		if current == nil {
+			return 0, fmt.Errorf("archive %s no longer exists", a.Name)
+		}
+		if err := updateArchive(tx, a); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE archives SET name = ?, created = ? WHERE id = ?`, a.Name, a.Created, a.ID); err != nil {
+			return 0, fmt.Errorf("failed to update archive: %w", err)
+		}
+
+		added, removed := diffStrings(current.Tags, a.Tags)
+		if err := addTags(tx, a.ID, added); err != nil {
+			return 0, err
+		}
+		if _, err := removeTags(tx, a.ID, removed); err != nil {
+			return 0, err
+		}
+
+		var set []Field
+		var unset []string
+		for _, k := range fieldKeys(current.Fields, a.Fields) {
+			f, ok := a.Fields[k]
+			switch {
+			case !ok:
+				unset = append(unset, k)
+			case f != current.Fields[k]:
+				set = append(set, f)
+			}
+		}
+		if err := setFields(tx, a.ID, set); err != nil {
+			return 0, err
+		}
+		if _, err := unsetFields(tx, a.ID, unset); err != nil {
+			return 0, err
+		}
+		if opID != 0 {
+			return a.ID, clearFileOp(tx, opID)
+		}
+		return a.ID, nil
+	})
+	if err != nil {
+		if plan.From != "" {
+			// If the file cannot go back the intent stays and the next start
+			// completes the undo's move
+			if mvErr := relocateFile(plan.To, plan.From); mvErr != nil {
+				return nil, fmt.Errorf("%w; could not move %s back: %v", err, plan.To, mvErr)
+			}
+			_ = m.registry.endFileOp(opID)
+		}
+		return nil, err
+	}
+	return ev, nil
+}
+
+// fieldKeys returns the keys of both field maps, sorted
+func fieldKeys(a, b map[string]Field) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
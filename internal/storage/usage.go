@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Quota limits what one vault's managed storage holds; zero fields are
+// unlimited. MinFree is disk space new archives must leave free.
+type Quota struct {
+	MaxBytes    int64
+	MaxArchives int
+	MinFree     int64
+}
+
+// SetQuota sets the limits CheckCapacity enforces
+func (m *Manager) SetQuota(q Quota) { m.quota = q }
+
+// Quota returns the limits set with SetQuota
+func (m *Manager) Quota() Quota { return m.quota }
+
+// Usage is what managed storage holds. Archives in the trash still take
+// space, so they count until purged.
+type Usage struct {
+	Archives     int
+	Bytes        int64
+	Trashed      int
+	TrashedBytes int64
+}
+
+// Usage counts the managed archives that are not missing
+func (r *Registry) Usage() (*Usage, error) {
+	u := &Usage{}
+	err := r.db.QueryRow(`
+	SELECT COUNT(*), COALESCE(SUM(size), 0),
+		COALESCE(SUM(status = 'deleted'), 0), COALESCE(SUM(CASE WHEN status = 'deleted' THEN size END), 0)
+	FROM archives WHERE managed AND status != 'missing'`).Scan(&u.Archives, &u.Bytes, &u.Trashed, &u.TrashedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure usage: %w", err)
+	}
+	return u, nil
+}
+
+// CheckCapacity reports whether managed storage can take one more archive of
+// size bytes. The quota must allow it, and the disk must hold it plus
+// MinFree unless it comes from src on the same filesystem, where moving it
+// in is a rename. src is "" for a file yet to be written.
+func (m *Manager) CheckCapacity(size int64, src string) error {
+	q := m.quota
+	if q.MaxBytes > 0 || q.MaxArchives > 0 {
+		u, err := m.registry.Usage()
+		if err != nil {
+			return err
+		}
+		if (q.MaxArchives > 0 && u.Archives >= q.MaxArchives) || (q.MaxBytes > 0 && u.Bytes+size > q.MaxBytes) {
+			return &QuotaExceededError{Path: m.basePath, Archives: u.Archives, Bytes: u.Bytes, Adding: size, Quota: q}
+		}
+	}
+	if src != "" && sameFilesystem(src, m.basePath) {
+		return nil
+	}
+	return CheckFreeSpace(m.GetArchivesPath(), size, q.MinFree)
+}
+
+// CheckFreeSpace fails when the filesystem holding dir, or its nearest
+// existing parent, has less than size plus reserve bytes free. Free space
+// that cannot be read does not block the caller.
+func CheckFreeSpace(dir string, size, reserve int64) error {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+	free, _, err := DiskSpace(dir)
+	if err != nil {
+		return nil
+	}
+	if free < uint64(size+reserve) {
+		return &InsufficientSpaceError{Path: dir, Need: size, Reserve: reserve, Free: free}
+	}
+	return nil
+}
+
+// CompressionRatio returns the archived-to-source size ratio over archives
+// made with a profile that recorded their source size, and how many there
+// were; the ratio is 0 without history
+func (r *Registry) CompressionRatio(profile string) (float64, int, error) {
+	var n int
+	var size, source int64
+	err := r.db.QueryRow(`
+	SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(source_size), 0)
+	FROM archives WHERE profile = ? COLLATE NOCASE AND source_size > 0`, profile).Scan(&n, &size, &source)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read compression history: %w", err)
+	}
+	if source == 0 {
+		return 0, 0, nil
+	}
+	return float64(size) / float64(source), n, nil
+}
+
+// GrowthMonth is what one calendar month added to managed storage
+type GrowthMonth struct {
+	Month    time.Time
+	Archives int
+	Bytes    int64
+}
+
+// UsageReport describes a vault's managed storage: what it holds against
+// its quota and disk, and how fast it has been growing
+type UsageReport struct {
+	Path string
+	Usage
+	Quota       Quota
+	Free, Total uint64 // filesystem; zero when unknown
+	// Months holds archives added per month, oldest first. Growth counts
+	// additions by creation date; removals are not subtracted.
+	Months      []GrowthMonth
+	Window      time.Duration
+	DailyGrowth float64 // bytes added per day over Window
+}
+
+// UsageReport measures managed storage as of now, with growth for the last
+// months calendar months and a daily rate over window
+func (m *Manager) UsageReport(months int, window time.Duration, now time.Time) (*UsageReport, error) {
+	u, err := m.registry.Usage()
+	if err != nil {
+		return nil, err
+	}
+	rep := &UsageReport{Path: m.basePath, Usage: *u, Quota: m.quota, Window: window}
+	rep.Free, rep.Total, _ = DiskSpace(m.basePath)
+
+	if months < 1 {
+		months = 1
+	}
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 1-months, 0)
+	for i := 0; i < months; i++ {
+		rep.Months = append(rep.Months, GrowthMonth{Month: start.AddDate(0, i, 0)})
+	}
+	since := now.Add(-window)
+
+	rows, err := m.registry.db.Query(`SELECT created, size FROM archives WHERE managed`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive history: %w", err)
+	}
+	defer rows.Close()
+	var recent int64
+	for rows.Next() {
+		var created time.Time
+		var size int64
+		if err := rows.Scan(&created, &size); err != nil {
+			return nil, fmt.Errorf("failed to read archive history: %w", err)
+		}
+		created = created.In(now.Location())
+		if !created.Before(since) && !created.After(now) {
+			recent += size
+		}
+		if created.Before(start) || created.After(now) {
+			continue
+		}
+		i := (created.Year()-start.Year())*12 + int(created.Month()-start.Month())
+		rep.Months[i].Archives++
+		rep.Months[i].Bytes += size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive history: %w", err)
+	}
+	if days := window.Hours() / 24; days > 0 {
+		rep.DailyGrowth = float64(recent) / days
+	}
+	return rep, nil
+}
+
+// DaysUntil estimates the days until growth adds the given bytes, or -1
+// when storage is not growing
+func (u *UsageReport) DaysUntil(bytes int64) int {
+	if u.DailyGrowth <= 0 {
+		return -1
+	}
+	if bytes <= 0 {
+		return 0
+	}
+	return int(float64(bytes) / u.DailyGrowth)
+}
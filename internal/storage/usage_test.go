@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckCapacity(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	for name, size := range map[string]int64{"a.7z": 400, "b.7z": 300} {
+		a := &Archive{UID: generateUID(), Name: name, Path: filepath.Join(mgr.GetArchivesPath(), name),
+			Size: size, Created: time.Now(), Status: "present", Managed: true}
+		if err := mgr.Registry().Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	external := &Archive{UID: generateUID(), Name: "ext.7z", Path: "/elsewhere/ext.7z", Size: 5000, Created: time.Now(), Status: "present"}
+	if err := mgr.Registry().Add(external); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.CheckCapacity(1<<20, ""); err != nil {
+		t.Errorf("no quota: %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	mgr.SetQuota(Quota{MaxBytes: 1000})
+	if err := mgr.CheckCapacity(300, ""); err != nil {
+		t.Errorf("within size quota: %v", err)
+	}
+	if err := mgr.CheckCapacity(301, ""); !errors.As(err, &quotaErr) {
+		t.Errorf("over size quota: %v", err)
+	}
+	mgr.SetQuota(Quota{MaxArchives: 2})
+	if err := mgr.CheckCapacity(1, ""); !errors.As(err, &quotaErr) {
+		t.Errorf("over archive quota: %v", err)
+	}
+
+	var spaceErr *InsufficientSpaceError
+	mgr.SetQuota(Quota{MinFree: math.MaxInt64 / 2})
+	if err := mgr.CheckCapacity(1, ""); !errors.As(err, &spaceErr) {
+		t.Errorf("expected insufficient space, got %v", err)
+	}
+	// Moving a file in from the same filesystem needs no space
+	if err := mgr.CheckCapacity(1, mgr.GetBasePath()); err != nil {
+		t.Errorf("same filesystem move: %v", err)
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	reg := seedTransferRegistry(t)
+	if ratio, n, err := reg.CompressionRatio("Media"); err != nil || ratio != 0 || n != 0 {
+		t.Fatalf("without history = %v, %d, %v", ratio, n, err)
+	}
+	for _, sizes := range [][2]int64{{100, 1000}, {300, 1000}} {
+		a := &Archive{UID: generateUID(), Name: "m.7z", Path: "/m/" + generateUID(), Size: sizes[0], SourceSize: sizes[1],
+			Profile: "Media", Created: time.Now(), Status: "present"}
+		if err := reg.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ratio, n, err := reg.CompressionRatio("media"); err != nil || ratio != 0.2 || n != 2 {
+		t.Errorf("ratio = %v over %d archives, %v", ratio, n, err)
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	mgr, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		created time.Time
+		size    int64
+		status  string
+	}{
+		{now.AddDate(0, 0, -1), 100, "present"},
+		{now.AddDate(0, 0, -10), 200, "deleted"},
+		{now.AddDate(0, -1, 0), 400, "present"},
+		{now.AddDate(-1, 0, 0), 800, "present"}, // before the months shown
+	} {
+		a := &Archive{UID: generateUID(), Name: "a.7z", Path: "/v/" + generateUID(), Size: c.size, Created: c.created,
+			Status: c.status, Managed: true}
+		if err := mgr.Registry().Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rep, err := mgr.UsageReport(3, 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Archives != 4 || rep.Bytes != 1500 || rep.Trashed != 1 || rep.TrashedBytes != 200 {
+		t.Errorf("usage = %+v", rep.Usage)
+	}
+	if len(rep.Months) != 3 || rep.Months[0].Month.Format("2006-01") != "2026-03" {
+		t.Fatalf("months = %+v", rep.Months)
+	}
+	if m := rep.Months[2]; m.Archives != 2 || m.Bytes != 300 {
+		t.Errorf("May = %+v", m)
+	}
+	if m := rep.Months[1]; m.Archives != 1 || m.Bytes != 400 {
+		t.Errorf("April = %+v", m)
+	}
+	// 100 + 200 + 400 bytes in the last 30 days
+	if rep.DailyGrowth != 700.0/30 {
+		t.Errorf("daily growth = %v", rep.DailyGrowth)
+	}
+	if d := rep.DaysUntil(7000); d != 300 {
+		t.Errorf("days until 7000 bytes = %d", d)
+	}
+}
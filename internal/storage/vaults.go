@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// A vault is a managed storage root with its own registry. Archives from
+// several vaults are combined in memory; a UID identifies an archive across
+// vaults, row ids only within one.
+
+// FindAll runs Find against each named registry and merges the results in
+// the criteria's order, setting each archive's Vault. Cursors belong to one
+// registry, so c.After is not supported; Offset and Limit apply to the
+// merged list.
+func FindAll(ctx context.Context, regs map[string]*Registry, c Criteria) ([]*Archive, error) {
+	if c.After != "" {
+		return nil, fmt.Errorf("cursors cannot be used across vaults; use --offset")
+	}
+	sortKey, err := ParseSortKey(string(c.Sort))
+	if err != nil {
+		return nil, err
+	}
+
+	each := c
+	each.Offset = 0
+	if c.Limit > 0 {
+		each.Limit = c.Offset + c.Limit
+	}
+	var all []*Archive
+	for name, reg := range regs {
+		page, err := reg.Find(ctx, each)
+		if err != nil {
+			return nil, fmt.Errorf("vault %s: %w", name, err)
+		}
+		for _, a := range page.Archives {
+			a.Vault = name
+		}
+		all = append(all, page.Archives...)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if cmp := compareArchives(sortKey, all[i], all[j]); cmp != 0 {
+			return (cmp < 0) == c.Ascending
+		}
+		if all[i].Vault != all[j].Vault {
+			return all[i].Vault < all[j].Vault
+		}
+		return (all[i].ID < all[j].ID) == c.Ascending
+	})
+	if c.Offset >= len(all) {
+		return nil, nil
+	}
+	all = all[c.Offset:]
+	if c.Limit > 0 && len(all) > c.Limit {
+		all = all[:c.Limit]
+	}
+	return all, nil
+}
+
+// compareArchives orders two archives by key as Find's ORDER BY does
+func compareArchives(key SortKey, a, b *Archive) int {
+	switch key {
+	case SortName:
+		switch {
+		case a.Name < b.Name:
+			return -1
+		case a.Name > b.Name:
+			return 1
+		}
+	case SortSize:
+		switch {
+		case a.Size < b.Size:
+			return -1
+		case a.Size > b.Size:
+			return 1
+		}
+	case SortCreated:
+		return a.Created.Compare(b.Created)
+	}
+	return 0
+}
+
+// TransferArchive moves an archive into dst's managed storage, within dst's
+// quota, and hands its row, with tags and fields, to dst's registry under
+// the same UID. The file moves first, through the crash-safe MoveArchive,
+// and its .log and .sha256 sidecars follow it; a transfer interrupted after
+// that leaves this registry pointing at the moved file and can simply be run
+// again.
+func (m *Manager) TransferArchive(a *Archive, dst *Manager) error {
+	if err := dst.CheckCapacity(a.Size, a.Path); err != nil {
+		return err
+	}
+	name := a.Name
+	if name == "" {
+		name = filepath.Base(a.Path)
+	}
+	target := dst.ManagedPathFor(name, a.Profile, a.Created)
+	if from := a.Path; from != target {
+		if err := m.MoveArchive(a, target, func(x *Archive) { x.Managed = false }); err != nil {
+			return err
+		}
+		moveSidecars(from, target)
+		removeEmptiedDirs(m.GetArchivesPath(), []string{filepath.Dir(from)})
+	}
+
+	moved, err := m.registry.GetByID(a.ID)
+	if err != nil {
+		return err
+	}
+	moved.Managed = true
+	res, err := dst.registry.Import([]*Archive{moved}, ImportOptions{Mode: MergeOverwrite})
+	if err != nil {
+		return fmt.Errorf("failed to register %s in the destination vault: %w", name, err)
+	}
+	if res.Added+res.Updated+res.Unchanged == 0 {
+		return fmt.Errorf("failed to register %s in the destination vault: %s", name, res.Conflicts[0].Reason)
+	}
+	return m.registry.DeleteByID(a.ID)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindAll(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	regs := map[string]*Registry{}
+	for v, names := range map[string][]string{"ssd": {"a.7z", "c.7z"}, "hdd": {"b.7z", "d.7z"}} {
+		mgr, err := NewManager(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { mgr.Close() })
+		for _, n := range names {
+			created := base.Add(time.Duration(n[0]-'a') * time.Minute)
+			a := &Archive{UID: generateUID(), Name: n, Path: "/" + v + "/" + n, Size: int64(n[0]), Created: created, Status: "present"}
+			if err := mgr.Registry().Add(a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		regs[v] = mgr.Registry()
+	}
+
+	names := func(archives []*Archive) string {
+		var out []string
+		for _, a := range archives {
+			out = append(out, a.Vault+":"+a.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	all, err := FindAll(context.Background(), regs, Criteria{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(all); got != "hdd:d.7z,ssd:c.7z,hdd:b.7z,ssd:a.7z" {
+		t.Errorf("newest first = %s", got)
+	}
+	page, err := FindAll(context.Background(), regs, Criteria{Sort: SortName, Ascending: true, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(page); got != "hdd:b.7z,ssd:c.7z" {
+		t.Errorf("by name, offset 1 limit 2 = %s", got)
+	}
+	if _, err := FindAll(context.Background(), regs, Criteria{After: "cursor"}); err == nil {
+		t.Error("expected an error for a cursor across vaults")
+	}
+}
+
+func TestTransferArchive(t *testing.T) {
+	src, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	dst.SetLayout(LayoutByDate)
+
+	path := filepath.Join(src.GetArchivesPath(), "photos.7z")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range sidecarExts {
+		if err := os.WriteFile(path+ext, []byte("sidecar"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	arc := &Archive{UID: generateUID(), Name: "photos.7z", Path: path, Size: 4, Created: time.Now(), Status: "present", Managed: true, Tags: []string{"photos"}}
+	if err := src.Registry().Add(arc); err != nil {
+		t.Fatal(err)
+	}
+	field, _ := ParseFieldAssignment("year=2024")
+	if err := src.Registry().SetFields(arc.ID, field); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.TransferArchive(arc, dst); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	want := dst.ManagedPathFor("photos.7z", "", arc.Created)
+	moved, err := dst.Registry().GetByUID(arc.UID)
+	if err != nil {
+		t.Fatalf("archive not in destination registry: %v", err)
+	}
+	if moved.Path != want || !moved.Managed || !moved.HasTags("photos") || moved.Fields["year"].Value != "2024" {
+		t.Errorf("moved archive = %+v", moved)
+	}
+	if !fileExists(want) || fileExists(path) {
+		t.Errorf("file not moved to %s", want)
+	}
+	for _, ext := range sidecarExts {
+		if !fileExists(want+ext) || fileExists(path+ext) {
+			t.Errorf("%s sidecar not moved to %s", ext, want+ext)
+		}
+	}
+	if _, err := src.Registry().GetByUID(arc.UID); err == nil {
+		t.Error("archive still registered in the source vault")
+	}
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -65,14 +66,26 @@ func NewSimpleApp(themeName string) *SimpleApp {
 	}
 }
 
+// loadPageSize is how many archives each load step reads, so the first
+// screen renders before large registries finish loading
+const loadPageSize = 500
+
 // Init loads archives
 func (a *SimpleApp) Init() tea.Cmd {
+	return a.loadArchives("")
+}
+
+// loadArchives reads the page of archives after cursor
+func (a *SimpleApp) loadArchives(cursor string) tea.Cmd {
 	return func() tea.Msg {
 		if a.manager == nil {
 			return archivesLoadedMsg{err: fmt.Errorf("no storage manager")}
 		}
-		archives, err := a.manager.List()
-		return archivesLoadedMsg{archives: archives, err: err}
+		page, err := a.manager.Registry().Find(context.Background(), storage.Criteria{Limit: loadPageSize, After: cursor})
+		if err != nil {
+			return archivesLoadedMsg{err: err}
+		}
+		return archivesLoadedMsg{archives: page.Archives, next: page.Next, more: cursor != ""}
 	}
 }
 
@@ -110,7 +123,14 @@ func (a *SimpleApp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		
 	case archivesLoadedMsg:
 		if m.err == nil {
-			a.archives = m.archives
+			if m.more {
+				a.archives = append(a.archives, m.archives...)
+			} else {
+				a.archives = m.archives
+			}
+			if m.next != "" {
+				return a, a.loadArchives(m.next)
+			}
 		}
 	}
 	
@@ -350,5 +370,7 @@ func (a *SimpleApp) handleDetailKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 // Messages
 type archivesLoadedMsg struct {
 	archives []*storage.Archive
+	next     string // cursor for the next page, if any
+	more     bool   // archives continue a previous page
 	err      error
 }
@@ -0,0 +1,178 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+// Syncer is the part of storage.Manager the daemon relies on
+type Syncer interface {
+	SyncFile(path string) (storage.SyncAction, error)
+	Reconcile() (*storage.ReconcileReport, error)
+	ReconcileTree(root string) (*storage.ReconcileReport, error)
+	GetArchivesPath() string
+}
+
+// Options configures the watch daemon
+type Options struct {
+	// Debounce is how long a path must be quiet before it is synced
+	Debounce time.Duration
+	// Settle is the gap between two size checks used to detect files that
+	// are still being written; a changing file is re-queued
+	Settle time.Duration
+	// Poll forces the polling backend instead of inotify
+	Poll         bool
+	PollInterval time.Duration
+	// Logf receives one line per registry change; nil discards output
+	Logf func(format string, args ...interface{})
+}
+
+// Daemon watches managed storage and syncs changes into the registry
+type Daemon struct {
+	syncer   Syncer
+	opts     Options
+	debounce *Debouncer
+	mu       sync.Mutex // serialises registry writes
+}
+
+// NewDaemon creates a watch daemon for the given syncer
+func NewDaemon(syncer Syncer, opts Options) *Daemon {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 2 * time.Second
+	}
+	if opts.Settle <= 0 {
+		opts.Settle = 500 * time.Millisecond
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	d := &Daemon{syncer: syncer, opts: opts}
+	d.debounce = NewDebouncer(opts.Debounce, d.handle)
+	return d
+}
+
+// Run performs an initial reconcile and then processes events until ctx is done
+func (d *Daemon) Run(ctx context.Context) error {
+	if err := d.reconcile(); err != nil {
+		return err
+	}
+
+	var w Watcher
+	if d.opts.Poll {
+		w = NewPollWatcher(d.opts.PollInterval)
+	} else {
+		var err error
+		if w, err = NewWatcher(); err != nil {
+			return err
+		}
+	}
+	defer w.Close()
+	defer d.debounce.Stop()
+
+	root := d.syncer.GetArchivesPath()
+	if err := w.Add(root); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			if ev.Tree {
+				d.reconcileTree(ev.Name)
+				continue
+			}
+			d.debounce.Trigger(ev.Name)
+		case err, ok := <-w.Errors():
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, ErrOverflow) {
+				d.opts.Logf("event queue overflowed, rescanning managed storage")
+				if err := d.reconcile(); err != nil {
+					d.opts.Logf("rescan failed: %v", err)
+				}
+				continue
+			}
+			d.opts.Logf("watch error: %v", err)
+		}
+	}
+}
+
+func (d *Daemon) reconcile() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	report, err := d.syncer.Reconcile()
+	if err != nil {
+		return fmt.Errorf("initial scan failed: %w", err)
+	}
+	for _, e := range report.Errors {
+		d.opts.Logf("scan error: %v", e)
+	}
+	if report.Registered > 0 || report.Missing > 0 {
+		d.opts.Logf("scan: %d registered, %d missing, %d present", report.Registered, report.Missing, report.Seen)
+	}
+	return nil
+}
+
+// reconcileTree syncs the archives under a directory that moved away
+func (d *Daemon) reconcileTree(root string) {
+	d.mu.Lock()
+	report, err := d.syncer.ReconcileTree(root)
+	d.mu.Unlock()
+	if err != nil {
+		d.opts.Logf("rescan %s failed: %v", root, err)
+		return
+	}
+	for _, e := range report.Errors {
+		d.opts.Logf("scan error: %v", e)
+	}
+	if report.Registered > 0 || report.Missing > 0 {
+		d.opts.Logf("scan %s: %d registered, %d missing, %d present", root, report.Registered, report.Missing, report.Seen)
+	}
+}
+
+// handle runs after a path has been quiet for the debounce period
+func (d *Daemon) handle(path string) {
+	if !d.stable(path) {
+		d.debounce.Trigger(path)
+		return
+	}
+
+	d.mu.Lock()
+	action, err := d.syncer.SyncFile(path)
+	d.mu.Unlock()
+	if err != nil {
+		d.opts.Logf("sync %s: %v", path, err)
+		return
+	}
+	switch action {
+	case storage.SyncRegistered, storage.SyncMissing:
+		d.opts.Logf("%s: %s", action, path)
+	}
+}
+
+// stable reports whether a file has stopped growing. Missing files are
+// considered stable so deletions are recorded straight away.
+func (d *Daemon) stable(path string) bool {
+	before, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	time.Sleep(d.opts.Settle)
+	after, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return before.Size() == after.Size() && before.ModTime().Equal(after.ModTime())
+}
@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer coalesces bursts of events for the same path and fires once the
+// path has been quiet for the configured delay. Copying a multi-gigabyte
+// archive produces thousands of write events; only the last one matters.
+type Debouncer struct {
+	delay   time.Duration
+	fire    func(path string)
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	stopped bool
+}
+
+// NewDebouncer creates a debouncer that calls fire after delay of quiet
+func NewDebouncer(delay time.Duration, fire func(path string)) *Debouncer {
+	return &Debouncer{
+		delay:  delay,
+		fire:   fire,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// Trigger schedules (or reschedules) the callback for path
+func (d *Debouncer) Trigger(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	if t, ok := d.timers[path]; ok {
+		t.Stop()
+	}
+	d.timers[path] = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		delete(d.timers, path)
+		stopped := d.stopped
+		d.mu.Unlock()
+		if !stopped {
+			d.fire(path)
+		}
+	})
+}
+
+// Pending returns the number of paths waiting to fire
+func (d *Debouncer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.timers)
+}
+
+// Stop cancels all pending callbacks
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	for path, t := range d.timers {
+		t.Stop()
+		delete(d.timers, path)
+	}
+}
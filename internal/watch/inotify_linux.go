@@ -0,0 +1,242 @@
+//go:build linux
+
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher is a pure-Go inotify backend built on the syscall package.
+// The inotify descriptor is non-blocking and wrapped in an *os.File so reads
+// go through the runtime poller and Close unblocks the reader goroutine.
+type inotifyWatcher struct {
+	fd      int
+	file    *os.File
+	mu      sync.Mutex
+	watches map[int32]string
+	paths   map[string]int32
+	events  chan Event
+	errors  chan error
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newNativeWatcher() (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init failed: %w", err)
+	}
+	w := &inotifyWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string),
+		paths:   make(map[string]int32),
+		events:  make(chan Event, 256),
+		errors:  make(chan error, 16),
+		done:    make(chan struct{}),
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan Event { return w.events }
+func (w *inotifyWatcher) Errors() <-chan error { return w.errors }
+
+// Add watches root and all of its subdirectories
+func (w *inotifyWatcher) Add(root string) error {
+	return walkDirs(root, w.addWatch)
+}
+
+func (w *inotifyWatcher) addWatch(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.paths[dir]; ok {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w.watches[int32(wd)] = dir
+	w.paths[dir] = int32(wd)
+	return nil
+}
+
+func (w *inotifyWatcher) removeWatch(wd int32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dir, ok := w.watches[wd]; ok {
+		delete(w.paths, dir)
+		delete(w.watches, wd)
+	}
+}
+
+// removeTree stops watching root and every directory beneath it
+func (w *inotifyWatcher) removeTree(root string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for dir, wd := range w.paths {
+		if dir != root && !strings.HasPrefix(dir, root+string(os.PathSeparator)) {
+			continue
+		}
+		// The kernel follows up with IN_IGNORED, which finds nothing to remove
+		_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.watches, wd)
+		delete(w.paths, dir)
+	}
+}
+
+// Close stops the watcher and releases the inotify descriptor
+func (w *inotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
+
+func (w *inotifyWatcher) readEvents() {
+	defer close(w.events)
+	defer close(w.errors)
+
+	buf := make([]byte, syscall.SizeofInotifyEvent*4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			if !w.sendError(err) {
+				return
+			}
+			continue
+		}
+
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= n {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			start := offset + syscall.SizeofInotifyEvent
+			end := start + nameLen
+			if end > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[start:end]), "\x00")
+			offset = end
+
+			if !w.handle(wd, mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// handle translates a raw inotify record; returns false once closed
+func (w *inotifyWatcher) handle(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return w.sendError(ErrOverflow)
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		w.removeWatch(wd)
+		return true
+	}
+
+	w.mu.Lock()
+	dir, ok := w.watches[wd]
+	w.mu.Unlock()
+	if !ok {
+		return true
+	}
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+
+	// Follow new subdirectories so organised layouts keep working; files
+	// moved in together with a directory never produce their own events.
+	if mask&syscall.IN_ISDIR != 0 {
+		if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			if err := w.Add(path); err != nil {
+				return w.sendError(err)
+			}
+			return w.emitTree(path)
+		}
+		if mask&syscall.IN_MOVED_FROM != 0 {
+			w.removeTree(path)
+			return w.sendEvent(Event{Name: path, Op: Rename, Tree: true})
+		}
+		if mask&syscall.IN_DELETE == 0 {
+			return true
+		}
+	}
+
+	var op Op
+	if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		op |= Create
+	}
+	if mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MODIFY) != 0 {
+		op |= Write
+	}
+	if mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0 {
+		op |= Remove
+	}
+	if mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0 {
+		op |= Rename
+	}
+	if op == 0 {
+		return true
+	}
+	return w.sendEvent(Event{Name: path, Op: op})
+}
+
+// emitTree reports every file under a newly appeared directory as created
+func (w *inotifyWatcher) emitTree(root string) bool {
+	ok := true
+	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !w.sendEvent(Event{Name: path, Op: Create}) {
+			ok = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return ok
+}
+
+func (w *inotifyWatcher) sendEvent(ev Event) bool {
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *inotifyWatcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watch
+
+// newNativeWatcher falls back to polling on platforms without an inotify backend
+func newNativeWatcher() (Watcher, error) {
+	return NewPollWatcher(DefaultPollInterval), nil
+}
@@ -0,0 +1,162 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is used by the polling backend when none is given
+const DefaultPollInterval = 2 * time.Second
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// pollWatcher detects changes by periodically walking the watched trees and
+// diffing size/mtime snapshots. It works on any platform and file system,
+// including network mounts where inotify is unreliable.
+type pollWatcher struct {
+	interval time.Duration
+	mu       sync.Mutex
+	roots    []string
+	snapshot map[string]fileState
+	events   chan Event
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewPollWatcher creates a polling watcher that rescans every interval
+func NewPollWatcher(interval time.Duration) Watcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	w := &pollWatcher{
+		interval: interval,
+		snapshot: make(map[string]fileState),
+		events:   make(chan Event, 256),
+		errors:   make(chan error, 16),
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *pollWatcher) Events() <-chan Event { return w.events }
+func (w *pollWatcher) Errors() <-chan error { return w.errors }
+
+// Add records the current state of root so only later changes are reported
+func (w *pollWatcher) Add(root string) error {
+	current, err := scanTree(root)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.roots = append(w.roots, root)
+	for path, st := range current {
+		w.snapshot[path] = st
+	}
+	return nil
+}
+
+// Close stops the polling loop
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) loop() {
+	defer close(w.events)
+	defer close(w.errors)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if !w.poll() {
+				return
+			}
+		}
+	}
+}
+
+// poll rescans all roots and emits the differences; returns false once closed
+func (w *pollWatcher) poll() bool {
+	w.mu.Lock()
+	roots := append([]string(nil), w.roots...)
+	w.mu.Unlock()
+
+	current := make(map[string]fileState)
+	for _, root := range roots {
+		found, err := scanTree(root)
+		if err != nil {
+			select {
+			case w.errors <- err:
+			case <-w.done:
+				return false
+			}
+			continue
+		}
+		for path, st := range found {
+			current[path] = st
+		}
+	}
+
+	w.mu.Lock()
+	previous := w.snapshot
+	w.snapshot = current
+	w.mu.Unlock()
+
+	var changes []Event
+	for path, st := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			changes = append(changes, Event{Name: path, Op: Create})
+		case old.size != st.size || !old.modTime.Equal(st.modTime):
+			changes = append(changes, Event{Name: path, Op: Write})
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changes = append(changes, Event{Name: path, Op: Remove})
+		}
+	}
+
+	for _, ev := range changes {
+		select {
+		case w.events <- ev:
+		case <-w.done:
+			return false
+		}
+	}
+	return true
+}
+
+// scanTree returns size/mtime for every regular file beneath root
+func scanTree(root string) (map[string]fileState, error) {
+	out := make(map[string]fileState)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// File vanished between readdir and stat
+			return nil
+		}
+		out[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return out, err
+}
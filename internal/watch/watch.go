@@ -0,0 +1,85 @@
+// Package watch keeps the registry in sync with files that appear in or
+// disappear from managed storage outside of 7zarch-go.
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Op describes what happened to a watched path
+type Op uint32
+
+const (
+	Create Op = 1 << iota
+	Write
+	Remove
+	Rename
+)
+
+// String returns a readable name for the operation set
+func (op Op) String() string {
+	var names []string
+	if op&Create != 0 {
+		names = append(names, "CREATE")
+	}
+	if op&Write != 0 {
+		names = append(names, "WRITE")
+	}
+	if op&Remove != 0 {
+		names = append(names, "REMOVE")
+	}
+	if op&Rename != 0 {
+		names = append(names, "RENAME")
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	out := names[0]
+	for _, n := range names[1:] {
+		out += "|" + n
+	}
+	return out
+}
+
+// Event is a single file system notification
+type Event struct {
+	Name string
+	Op   Op
+	// Tree is set when Name is a directory that left the watched tree as a
+	// whole; the files inside it produce no events of their own
+	Tree bool
+}
+
+// ErrOverflow is reported when the kernel queue overflowed and events were
+// dropped; callers should fall back to a full rescan.
+var ErrOverflow = errors.New("watch: event queue overflow")
+
+// Watcher is a small fsnotify-style interface implemented by the inotify
+// backend on Linux and by a polling backend everywhere else.
+// Add watches a directory tree recursively.
+type Watcher interface {
+	Add(root string) error
+	Events() <-chan Event
+	Errors() <-chan error
+	Close() error
+}
+
+// NewWatcher returns the best native watcher for the current platform
+func NewWatcher() (Watcher, error) {
+	return newNativeWatcher()
+}
+
+// walkDirs calls fn for root and every directory beneath it
+func walkDirs(root string, fn func(dir string) error) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return fn(path)
+	})
+}
@@ -0,0 +1,236 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adamstac/7zarch-go/internal/storage"
+)
+
+func TestDebouncerCoalescesBursts(t *testing.T) {
+	var fired int32
+	d := NewDebouncer(50*time.Millisecond, func(string) { atomic.AddInt32(&fired, 1) })
+	defer d.Stop()
+
+	for i := 0; i < 10; i++ {
+		d.Trigger("/tmp/a.7z")
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&fired); got != 1 {
+		t.Fatalf("expected 1 callback, got %d", got)
+	}
+	if d.Pending() != 0 {
+		t.Fatalf("expected no pending timers, got %d", d.Pending())
+	}
+}
+
+func TestDebouncerStopCancelsPending(t *testing.T) {
+	var fired int32
+	d := NewDebouncer(30*time.Millisecond, func(string) { atomic.AddInt32(&fired, 1) })
+	d.Trigger("/tmp/a.7z")
+	d.Stop()
+	time.Sleep(80 * time.Millisecond)
+	if atomic.LoadInt32(&fired) != 0 {
+		t.Fatal("callback fired after Stop")
+	}
+}
+
+func TestPollWatcherReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.7z")
+	if err := os.WriteFile(existing, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewPollWatcher(20 * time.Millisecond)
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	created := filepath.Join(dir, "new.7z")
+	if err := os.WriteFile(created, []byte("y"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]Op{}
+	deadline := time.After(2 * time.Second)
+	for len(seen) < 2 {
+		select {
+		case ev := <-w.Events():
+			seen[ev.Name] |= ev.Op
+		case <-deadline:
+			t.Fatalf("timed out waiting for events, got %v", seen)
+		}
+	}
+	if seen[created]&Create == 0 {
+		t.Errorf("expected CREATE for %s, got %v", created, seen[created])
+	}
+	if seen[existing]&Remove == 0 {
+		t.Errorf("expected REMOVE for %s, got %v", existing, seen[existing])
+	}
+}
+
+func TestNativeWatcherReportsCreate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher()
+	if err != nil {
+		t.Skipf("native watcher unavailable: %v", err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	sub := filepath.Join(dir, "2026", "10")
+	if err := os.MkdirAll(sub, 0750); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(sub, "nested.7z")
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(target, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events():
+			if ev.Name == target {
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for nested file event")
+		}
+	}
+}
+
+func TestDaemonSyncsRegistry(t *testing.T) {
+	base := t.TempDir()
+	mgr, err := storage.NewManager(base)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	// Pre-existing file is picked up by the initial reconcile
+	preexisting := filepath.Join(mgr.GetArchivesPath(), "before.7z")
+	if err := os.WriteFile(preexisting, []byte("before"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	daemon := NewDaemon(mgr, Options{
+		Debounce:     20 * time.Millisecond,
+		Settle:       10 * time.Millisecond,
+		Poll:         true,
+		PollInterval: 20 * time.Millisecond,
+	})
+	done := make(chan error, 1)
+	go func() { done <- daemon.Run(ctx) }()
+
+	waitFor(t, func() bool {
+		a, err := mgr.Registry().GetByPath(preexisting)
+		return err == nil && a.Status == "present"
+	})
+
+	dropped := filepath.Join(mgr.GetArchivesPath(), "dropped.7z")
+	if err := os.WriteFile(dropped, []byte("dropped by hand"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		a, err := mgr.Registry().GetByPath(dropped)
+		return err == nil && a.Managed && a.Checksum != ""
+	})
+
+	if err := os.Remove(preexisting); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		a, err := mgr.Registry().GetByPath(preexisting)
+		return err == nil && a.Status == "missing"
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestDaemonMarksMovedAwayDirectoryMissing(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("directory moves are reported by the inotify backend")
+	}
+	base := t.TempDir()
+	mgr, err := storage.NewManager(base)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer mgr.Close()
+
+	sub := filepath.Join(mgr.GetArchivesPath(), "2026")
+	if err := os.MkdirAll(sub, 0750); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(sub, "nested.7z")
+	if err := os.WriteFile(nested, []byte("nested"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(mgr.GetArchivesPath(), "kept.7z")
+	if err := os.WriteFile(kept, []byte("kept"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	daemon := NewDaemon(mgr, Options{Debounce: 20 * time.Millisecond, Settle: 10 * time.Millisecond})
+	done := make(chan error, 1)
+	go func() { done <- daemon.Run(ctx) }()
+
+	waitFor(t, func() bool {
+		a, err := mgr.Registry().GetByPath(nested)
+		return err == nil && a.Status == "present"
+	})
+	// Give the watcher time to add its watches after the initial scan
+	time.Sleep(100 * time.Millisecond)
+
+	// Files inside a directory moved out of the tree produce no events
+	if err := os.Rename(sub, filepath.Join(t.TempDir(), "2026")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		a, err := mgr.Registry().GetByPath(nested)
+		return err == nil && a.Status == "missing"
+	})
+	if a, err := mgr.Registry().GetByPath(kept); err != nil || a.Status != "present" {
+		t.Fatalf("archive outside the moved directory changed: %+v, %v", a, err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
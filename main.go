@@ -58,10 +58,36 @@ Features:
 	rootCmd.AddCommand(cmd.SearchCmd())
 	// Batch Operations (7EP-0007)
 	rootCmd.AddCommand(cmd.BatchCmd())
+	// Managed storage sync
+	rootCmd.AddCommand(cmd.WatchCmd())
+	rootCmd.AddCommand(cmd.DupesCmd())
+	rootCmd.AddCommand(cmd.PruneCmd())
+	rootCmd.AddCommand(cmd.HistoryCmd())
+	rootCmd.AddCommand(cmd.TagCmd())
+	rootCmd.AddCommand(cmd.MetaCmd())
+	rootCmd.AddCommand(cmd.LogCmd())
+	rootCmd.AddCommand(cmd.UndoCmd())
+	// Named vaults
+	rootCmd.AddCommand(cmd.VaultCmd())
+	cmd.AddVaultFlag(rootCmd)
+	cmd.AddFormatFlag(rootCmd)
+	// Capacity planning
+	rootCmd.AddCommand(cmd.UsageCmd())
+	// HTTP API
+	rootCmd.AddCommand(cmd.ServeCmd())
+
+	// Background jobs
+	rootCmd.AddCommand(cmd.WorkerCmd())
+	rootCmd.AddCommand(cmd.JobsCmd())
+
+	// Scheduled tasks
+	rootCmd.AddCommand(cmd.DaemonCmd())
+	rootCmd.AddCommand(cmd.SchedulesCmd())
+	rootCmd.AddCommand(cmd.ScrubCmd())
 
 	// Execute
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	executed, err := rootCmd.ExecuteC()
+	if err := cmd.Finish(executed, err); err != nil {
 		os.Exit(1)
 	}
 }